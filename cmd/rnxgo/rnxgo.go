@@ -4,6 +4,7 @@ package main
 
 /*
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -91,8 +92,13 @@ func main() {
 						log.Fatal(err)
 					}
 
-					//obs1.Opts.SatSys = []rune("GR")
-					return obs1.Diff(obs2)
+					diff, err := obs1.Diff(obs2, rinex.DiffOptions{CheckHeader: true})
+					if err != nil {
+						return err
+					}
+					enc := json.NewEncoder(c.App.Writer)
+					enc.SetIndent("", "  ")
+					return enc.Encode(diff)
 				},
 				OnUsageError: func(c *cli.Context, err error, isSubcommand bool) error {
 					fmt.Fprintf(c.App.Writer, "for shame\n")
@@ -4,7 +4,10 @@ go 1.24.0
 
 require (
 	github.com/go-playground/validator/v10 v10.25.0
+	github.com/google/flatbuffers v25.2.10+incompatible
+	github.com/klauspost/compress v1.18.0
 	github.com/stretchr/testify v1.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -18,5 +21,4 @@ require (
 	golang.org/x/net v0.37.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -119,6 +119,24 @@ func (prn PRN) String() string {
 	return fmt.Sprintf("%s%02d", prn.Sys.Abbr(), prn.Num)
 }
 
+// MarshalText implements the encoding.TextMarshaler interface, e.g. for JSON encoding as "G12".
+func (prn PRN) MarshalText() ([]byte, error) {
+	return []byte(prn.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (prn *PRN) UnmarshalText(text []byte) error {
+	if len(text) != 3 {
+		return fmt.Errorf("invalid PRN: %q", text)
+	}
+	p, err := NewPRN(string(text))
+	if err != nil {
+		return err
+	}
+	*prn = p
+	return nil
+}
+
 // ByPRN implements sort.Interface based on the PRN.
 type ByPRN []PRN
 
@@ -46,3 +46,19 @@ func TestParseSatSystems(t *testing.T) {
 		})
 	}
 }
+
+func TestPRN_MarshalText(t *testing.T) {
+	assert := assert.New(t)
+	prns := map[PRN]int{{Sys: SysGPS, Num: 5}: 1, {Sys: SysGAL, Num: 12}: 2}
+	b, err := json.Marshal(prns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(`{"E12":2,"G05":1}`, string(b))
+
+	var got []PRN
+	assert.NoError(json.Unmarshal([]byte(`["G05","R24"]`), &got))
+	assert.Equal([]PRN{{Sys: SysGPS, Num: 5}, {Sys: SysGLO, Num: 24}}, got)
+	assert.Error(json.Unmarshal([]byte(`["G5"]`), &got))
+	assert.Error(json.Unmarshal([]byte(`["X05"]`), &got))
+}
@@ -0,0 +1,133 @@
+package gnss
+
+// SpeedOfLight is the speed of light in vacuum in m/s.
+const SpeedOfLight = 299792458.0
+
+// The GLONASS FDMA channel spacing in Hz for the bands 1 and 2.
+const (
+	gloL1Step = 0.5625e6
+	gloL2Step = 0.4375e6
+)
+
+// carrierFreqs are the carrier frequencies in Hz per system and RINEX frequency band.
+// GLONASS FDMA bands 1 and 2 are given for channel number 0.
+var carrierFreqs = map[System]map[byte]float64{
+	SysGPS:   {'1': 1575.42e6, '2': 1227.60e6, '5': 1176.45e6},
+	SysGLO:   {'1': 1602.0e6, '2': 1246.0e6, '3': 1202.025e6, '4': 1600.995e6, '6': 1248.06e6},
+	SysGAL:   {'1': 1575.42e6, '5': 1176.45e6, '6': 1278.75e6, '7': 1207.14e6, '8': 1191.795e6},
+	SysQZSS:  {'1': 1575.42e6, '2': 1227.60e6, '5': 1176.45e6, '6': 1278.75e6},
+	SysBDS:   {'1': 1575.42e6, '2': 1561.098e6, '5': 1176.45e6, '6': 1268.52e6, '7': 1207.14e6, '8': 1191.795e6},
+	SysNavIC: {'1': 1575.42e6, '5': 1176.45e6, '9': 2492.028e6},
+	SysSBAS:  {'1': 1575.42e6, '5': 1176.45e6},
+}
+
+// trackingModes are the signals and tracking modes per system, with band and attribute as key, see RINEX 4 section 5.
+var trackingModes = map[System]map[string]string{
+	SysGPS: {
+		"1C": "C/A", "1S": "L1C (D)", "1L": "L1C (P)", "1X": "L1C (D+P)", "1P": "P (AS off)", "1W": "Z-tracking",
+		"1Y": "Y", "1M": "M", "1N": "codeless",
+		"2C": "C/A", "2D": "L1(C/A)+(P2-P1) semi-codeless", "2S": "L2C (M)", "2L": "L2C (L)", "2X": "L2C (M+L)",
+		"2P": "P (AS off)", "2W": "Z-tracking", "2Y": "Y", "2M": "M", "2N": "codeless",
+		"5I": "L5 I", "5Q": "L5 Q", "5X": "L5 I+Q",
+	},
+	SysGLO: {
+		"1C": "C/A", "1P": "P",
+		"4A": "L1OCd", "4B": "L1OCp", "4X": "L1OCd+L1OCp",
+		"2C": "C/A", "2P": "P",
+		"6A": "L2CSI", "6B": "L2OCp", "6X": "L2CSI+L2OCp",
+		"3I": "L3 I", "3Q": "L3 Q", "3X": "L3 I+Q",
+	},
+	SysGAL: {
+		"1A": "E1 PRS", "1B": "E1 I/NAV OS/CS/SoL", "1C": "E1 no data", "1X": "E1 B+C", "1Z": "E1 A+B+C",
+		"5I": "E5a I", "5Q": "E5a Q", "5X": "E5a I+Q",
+		"7I": "E5b I", "7Q": "E5b Q", "7X": "E5b I+Q",
+		"8I": "E5 AltBOC I", "8Q": "E5 AltBOC Q", "8X": "E5 AltBOC I+Q",
+		"6A": "E6 PRS", "6B": "E6 data", "6C": "E6 no data", "6X": "E6 B+C", "6Z": "E6 A+B+C",
+	},
+	SysQZSS: {
+		"1C": "C/A", "1E": "L1C/B", "1S": "L1C (D)", "1L": "L1C (P)", "1X": "L1C (D+P)", "1Z": "L1-SAIF", "1B": "L1Sub",
+		"2S": "L2C (M)", "2L": "L2C (L)", "2X": "L2C (M+L)",
+		"5I": "L5 I", "5Q": "L5 Q", "5X": "L5 I+Q", "5D": "L5S I", "5P": "L5S Q", "5Z": "L5S I+Q",
+		"6S": "L6D", "6L": "L6P", "6X": "L6 D+P", "6E": "L6E", "6Z": "L6 D+E",
+	},
+	SysBDS: {
+		"2I": "B1I", "2Q": "B1Q", "2X": "B1I+Q",
+		"1D": "B1C data", "1P": "B1C pilot", "1X": "B1C data+pilot", "1A": "B1A data", "1N": "B1A pilot",
+		"5D": "B2a data", "5P": "B2a pilot", "5X": "B2a data+pilot",
+		"7I": "B2I", "7Q": "B2Q", "7X": "B2I+Q", "7D": "B2b data", "7P": "B2b pilot", "7Z": "B2b data+pilot",
+		"8D": "B2a+b data", "8P": "B2a+b pilot", "8X": "B2a+b data+pilot",
+		"6I": "B3I", "6Q": "B3Q", "6X": "B3I+Q", "6A": "B3A",
+	},
+	SysNavIC: {
+		"5A": "L5 SPS", "5B": "L5 RS (D)", "5C": "L5 RS (P)", "5X": "L5 RS (D+P)",
+		"9A": "S SPS", "9B": "S RS (D)", "9C": "S RS (P)", "9X": "S RS (D+P)",
+		"1D": "L1 SPS data", "1P": "L1 SPS pilot", "1X": "L1 SPS data+pilot",
+	},
+	SysSBAS: {
+		"1C": "C/A",
+		"5I": "L5 I", "5Q": "L5 Q", "5X": "L5 I+Q",
+	},
+}
+
+// Signal is a GNSS signal given by the satellite system, the RINEX frequency band and the RINEX attribute.
+type Signal struct {
+	Sys       System
+	Band      byte // The RINEX frequency band, '1' to '9'.
+	Attribute byte // The RINEX attribute that specifies the tracking mode, e.g. 'C' or 'W'. 0 if unknown, e.g. in RINEX-2.
+}
+
+// IsFDMA reports whether the signal is a GLONASS FDMA signal, with a frequency depending on the channel number.
+func (sig Signal) IsFDMA() bool {
+	return sig.Sys == SysGLO && (sig.Band == '1' || sig.Band == '2')
+}
+
+// Frequency returns the carrier frequency in Hz, or 0 if unknown. The GLONASS channel number,
+// -7 to +6, is only used for FDMA signals, see IsFDMA.
+func (sig Signal) Frequency(gloChannel int) float64 {
+	f := carrierFreqs[sig.Sys][sig.Band]
+	if f == 0 || !sig.IsFDMA() {
+		return f
+	}
+	if sig.Band == '1' {
+		return f + float64(gloChannel)*gloL1Step
+	}
+	return f + float64(gloChannel)*gloL2Step
+}
+
+// Wavelength returns the carrier wavelength in m, or 0 if the frequency is unknown.
+func (sig Signal) Wavelength(gloChannel int) float64 {
+	f := sig.Frequency(gloChannel)
+	if f == 0 {
+		return 0
+	}
+	return SpeedOfLight / f
+}
+
+// FrequencyOf returns the carrier frequency in Hz of the signal of the satellite, or 0 if unknown.
+// For GLONASS FDMA signals the channel number is taken from gloSlots, e.g. the
+// GLONASS SLOT / FRQ # of a RINEX header. 0 is returned if the satellite has no slot.
+func (sig Signal) FrequencyOf(prn PRN, gloSlots map[PRN]int) float64 {
+	if !sig.IsFDMA() {
+		return sig.Frequency(0)
+	}
+	k, ok := gloSlots[prn]
+	if !ok {
+		return 0
+	}
+	return sig.Frequency(k)
+}
+
+// WavelengthOf returns the carrier wavelength in m of the signal of the satellite, or 0 if unknown, see FrequencyOf.
+func (sig Signal) WavelengthOf(prn PRN, gloSlots map[PRN]int) float64 {
+	f := sig.FrequencyOf(prn, gloSlots)
+	if f == 0 {
+		return 0
+	}
+	return SpeedOfLight / f
+}
+
+// TrackingMode returns the signal and tracking mode of the attribute, e.g. "C/A" or "Z-tracking" for GPS,
+// or "E5a I+Q" for Galileo. It returns an empty string if the attribute is unknown.
+func (sig Signal) TrackingMode() string {
+	return trackingModes[sig.Sys][string([]byte{sig.Band, sig.Attribute})]
+}
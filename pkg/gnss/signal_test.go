@@ -0,0 +1,63 @@
+package gnss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignal_Frequency(t *testing.T) {
+	tests := []struct {
+		name       string
+		sig        Signal
+		gloChannel int
+		wantFreq   float64
+	}{
+		{name: "gps-L1", sig: Signal{Sys: SysGPS, Band: '1', Attribute: 'C'}, wantFreq: 1575.42e6},
+		{name: "gps-L5", sig: Signal{Sys: SysGPS, Band: '5'}, wantFreq: 1176.45e6},
+		{name: "gal-E5b", sig: Signal{Sys: SysGAL, Band: '7', Attribute: 'Q'}, wantFreq: 1207.14e6},
+		{name: "bds-B1I", sig: Signal{Sys: SysBDS, Band: '2', Attribute: 'I'}, wantFreq: 1561.098e6},
+		{name: "navic-S", sig: Signal{Sys: SysNavIC, Band: '9', Attribute: 'A'}, wantFreq: 2492.028e6},
+		{name: "glo-G1-k1", sig: Signal{Sys: SysGLO, Band: '1', Attribute: 'C'}, gloChannel: 1, wantFreq: 1602.5625e6},
+		{name: "glo-G2-k-7", sig: Signal{Sys: SysGLO, Band: '2', Attribute: 'P'}, gloChannel: -7, wantFreq: 1242.9375e6},
+		{name: "glo-G3-cdma", sig: Signal{Sys: SysGLO, Band: '3', Attribute: 'Q'}, gloChannel: 5, wantFreq: 1202.025e6},
+		{name: "unknown-band", sig: Signal{Sys: SysGPS, Band: '7'}, wantFreq: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			assert.Equal(tt.wantFreq, tt.sig.Frequency(tt.gloChannel))
+			if tt.wantFreq == 0 {
+				assert.Zero(tt.sig.Wavelength(tt.gloChannel))
+				return
+			}
+			assert.InDelta(SpeedOfLight/tt.wantFreq, tt.sig.Wavelength(tt.gloChannel), 1e-12)
+		})
+	}
+}
+
+func TestSignal_FrequencyOf(t *testing.T) {
+	assert := assert.New(t)
+	gloSlots := map[PRN]int{{Sys: SysGLO, Num: 1}: 1, {Sys: SysGLO, Num: 2}: -4}
+	g1 := Signal{Sys: SysGLO, Band: '1'}
+	assert.True(g1.IsFDMA())
+	assert.Equal(1602.5625e6, g1.FrequencyOf(PRN{Sys: SysGLO, Num: 1}, gloSlots))
+	assert.Equal(1599.75e6, g1.FrequencyOf(PRN{Sys: SysGLO, Num: 2}, gloSlots))
+	assert.Zero(g1.FrequencyOf(PRN{Sys: SysGLO, Num: 3}, gloSlots), "no channel number")
+	assert.Zero(g1.WavelengthOf(PRN{Sys: SysGLO, Num: 3}, gloSlots))
+	assert.InDelta(0.190293672798, Signal{Sys: SysGPS, Band: '1'}.WavelengthOf(PRN{Sys: SysGPS, Num: 5}, nil), 1e-12)
+
+	g3 := Signal{Sys: SysGLO, Band: '3'}
+	assert.False(g3.IsFDMA())
+	assert.Equal(1202.025e6, g3.FrequencyOf(PRN{Sys: SysGLO, Num: 3}, nil))
+}
+
+func TestSignal_TrackingMode(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("C/A", Signal{Sys: SysGPS, Band: '1', Attribute: 'C'}.TrackingMode())
+	assert.Equal("Z-tracking", Signal{Sys: SysGPS, Band: '2', Attribute: 'W'}.TrackingMode())
+	assert.Equal("E5a I+Q", Signal{Sys: SysGAL, Band: '5', Attribute: 'X'}.TrackingMode())
+	assert.Equal("B2a pilot", Signal{Sys: SysBDS, Band: '5', Attribute: 'P'}.TrackingMode())
+	assert.Empty(Signal{Sys: SysGPS, Band: '1'}.TrackingMode(), "RINEX-2 without attribute")
+	assert.Empty(Signal{Sys: SysGAL, Band: '1', Attribute: 'W'}.TrackingMode())
+}
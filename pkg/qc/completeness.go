@@ -0,0 +1,205 @@
+package qc
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/de-bkg/gognss/pkg/rinex"
+)
+
+// ErrNoPosition is returned if the observation header has no approximate position.
+var ErrNoPosition = errors.New("qc: no approximate position in observation header")
+
+// CompletenessReport compares the observations with the observations expected from the broadcast orbits.
+type CompletenessReport struct {
+	ElevationMask float64            `json:"elevationMask"` // The elevation cutoff angle in degrees.
+	Interval      float64            `json:"interval"`      // The observation interval in seconds, from the header or derived from the data.
+	NumEpochs     int                `json:"numEpochs"`     // The number of observation epochs.
+	NumNominal    int                `json:"numNominal"`    // The number of nominal epochs the expected observations are counted for.
+	Systems       []*SysCompleteness `json:"systems"`
+}
+
+// SysCompleteness is the completeness of a satellite system.
+type SysCompleteness struct {
+	Sys          gnss.System           `json:"sys"`
+	NumExpected  int                   `json:"numExpected"`  // The number of satellite epochs above the elevation mask.
+	NumObserved  int                   `json:"numObserved"`  // The number of expected satellite epochs with observations.
+	Completeness float64               `json:"completeness"` // The percentage of observed to expected satellite epochs.
+	Signals      []*SignalCompleteness `json:"signals"`
+	Satellites   []*SatCompleteness    `json:"satellites"`
+	NotTracked   []gnss.PRN            `json:"notTracked"` // Satellites above the elevation mask that were never observed.
+}
+
+// SignalCompleteness is the completeness of an observation type.
+type SignalCompleteness struct {
+	Code         rinex.ObsCode `json:"code"`
+	NumObs       int           `json:"numObs"` // The number of observations above the elevation mask.
+	NumExpected  int           `json:"numExpected"`
+	Completeness float64       `json:"completeness"`
+}
+
+// SatCompleteness is the completeness of a satellite.
+type SatCompleteness struct {
+	PRN          gnss.PRN `json:"prn"`
+	NumExpected  int      `json:"numExpected"` // The number of epochs above the elevation mask.
+	NumObserved  int      `json:"numObserved"` // The number of epochs above the elevation mask with observations.
+	Completeness float64  `json:"completeness"`
+}
+
+// CheckCompleteness compares the observations with the observations expected from the broadcast ephemerides.
+// An observation is expected for each nominal epoch and satellite with an elevation above elevMask, in degrees,
+// seen from the approximate position of the observation header. The nominal epochs span the period from
+// TIME OF FIRST OBS to TIME OF LAST OBS, or from the first to the last epoch of the file if missing, at the
+// INTERVAL of the header or at the interval derived from the data. Epochs missing in the file thus reduce
+// the completeness. The completeness is reported per satellite system, signal
+// and satellite, together with the satellites that were visible but never tracked.
+//
+// Only satellites with orbit parameters in the navigation data are considered, the ephemerides are selected
+// with rinex.EphStore. Unhealthy satellites are not expected.
+func CheckCompleteness(obsDec *rinex.ObsDecoder, navDec *rinex.NavDecoder, elevMask float64) (*CompletenessReport, error) {
+	hdr := obsDec.Header
+	if hdr.Position == (rinex.Coord{}) {
+		return nil, ErrNoPosition
+	}
+	lat, lon := geodetic(hdr.Position)
+
+	ephs, err := readEphemerides(navDec)
+	if err != nil {
+		return nil, err
+	}
+	prns := []gnss.PRN{}
+	for _, prn := range ephs.PRNs() {
+		if hdr.RINEXVersion < 3 && (hdr.SatSystem == gnss.SysMIXED || hdr.SatSystem == prn.Sys) || len(hdr.ObsTypes[prn.Sys]) > 0 {
+			prns = append(prns, prn)
+		}
+	}
+
+	rep := &CompletenessReport{ElevationMask: elevMask, Systems: []*SysCompleteness{}}
+	systems := map[gnss.System]*SysCompleteness{}
+	signals := map[gnss.System]map[rinex.ObsCode]*SignalCompleteness{}
+	sats := map[gnss.PRN]*SatCompleteness{}
+	for _, prn := range prns {
+		sysRep, ok := systems[prn.Sys]
+		if !ok {
+			sysRep = &SysCompleteness{Sys: prn.Sys, Signals: []*SignalCompleteness{}, Satellites: []*SatCompleteness{}, NotTracked: []gnss.PRN{}}
+			systems[prn.Sys] = sysRep
+			signals[prn.Sys] = map[rinex.ObsCode]*SignalCompleteness{}
+			for _, code := range obsTypes(&hdr, prn.Sys) {
+				sig := &SignalCompleteness{Code: code}
+				sysRep.Signals = append(sysRep.Signals, sig)
+				signals[prn.Sys][code] = sig
+			}
+			rep.Systems = append(rep.Systems, sysRep)
+		}
+		sats[prn] = &SatCompleteness{PRN: prn}
+	}
+
+	// The observations by nominal epoch.
+	epochs := []*rinex.Epoch{}
+	for obsDec.NextEpoch() {
+		if epo := obsDec.Epoch(); epo.Flag <= rinex.EpochFlagPowerFailure {
+			epochs = append(epochs, epo)
+		}
+	}
+	if err := obsDec.Err(); err != nil {
+		return nil, err
+	}
+	rep.NumEpochs = len(epochs)
+	start, end, interval := nominalEpochs(&hdr, epochs)
+	rep.Interval = interval.Seconds()
+	observed := map[int64]map[gnss.PRN]map[rinex.ObsCode]rinex.Obs{}
+	for _, epo := range epochs {
+		obs := make(map[gnss.PRN]map[rinex.ObsCode]rinex.Obs, len(epo.ObsList))
+		for _, satObs := range epo.ObsList {
+			obs[satObs.Prn] = satObs.Obss
+		}
+		observed[nominalIndex(epo.Time, start, interval)] = obs
+	}
+
+	for i := int64(0); len(epochs) > 0; i++ {
+		t := start.Add(time.Duration(i) * interval)
+		if t.After(end) {
+			break
+		}
+		rep.NumNominal++
+		for _, prn := range prns {
+			eph, err := ephs.Best(prn, t)
+			if err != nil || elevation(hdr.Position, lat, lon, satPos(eph, t)) < elevMask {
+				continue
+			}
+			sysRep, sat := systems[prn.Sys], sats[prn]
+			sysRep.NumExpected++
+			sat.NumExpected++
+			hasObs := false
+			for code, sig := range signals[prn.Sys] {
+				sig.NumExpected++
+				if obs, ok := observed[i][prn][code]; ok && obs.Val != 0 {
+					sig.NumObs++
+					hasObs = true
+				}
+			}
+			if hasObs {
+				sysRep.NumObserved++
+				sat.NumObserved++
+			}
+		}
+		if interval == 0 {
+			break
+		}
+	}
+
+	for _, prn := range prns {
+		sat := sats[prn]
+		if sat.NumExpected == 0 {
+			continue
+		}
+		sat.Completeness = percent(sat.NumObserved, sat.NumExpected)
+		sysRep := systems[prn.Sys]
+		sysRep.Satellites = append(sysRep.Satellites, sat)
+		if sat.NumObserved == 0 {
+			sysRep.NotTracked = append(sysRep.NotTracked, prn)
+		}
+	}
+	for _, sysRep := range rep.Systems {
+		sysRep.Completeness = percent(sysRep.NumObserved, sysRep.NumExpected)
+		for _, sig := range sysRep.Signals {
+			sig.Completeness = percent(sig.NumObs, sig.NumExpected)
+		}
+	}
+	return rep, nil
+}
+
+// nominalEpochs returns the period and the interval of the nominal epochs. The period is taken from the header,
+// or from the epochs if missing. The interval is taken from the header, or the shortest interval between
+// the epochs if missing.
+func nominalEpochs(hdr *rinex.ObsHeader, epochs []*rinex.Epoch) (start, end time.Time, interval time.Duration) {
+	if len(epochs) == 0 {
+		return start, end, 0
+	}
+	start, end = hdr.TimeOfFirstObs, hdr.TimeOfLastObs
+	if start.IsZero() || epochs[0].Time.Before(start) {
+		start = epochs[0].Time
+	}
+	if last := epochs[len(epochs)-1].Time; end.IsZero() || last.After(end) {
+		end = last
+	}
+	interval = time.Duration(hdr.Interval * float64(time.Second))
+	if interval <= 0 {
+		for i := 1; i < len(epochs); i++ {
+			if d := epochs[i].Time.Sub(epochs[i-1].Time); d > 0 && (interval == 0 || d < interval) {
+				interval = d
+			}
+		}
+	}
+	return start, end, interval
+}
+
+// nominalIndex returns the index of the nominal epoch closest to t.
+func nominalIndex(t, start time.Time, interval time.Duration) int64 {
+	if interval <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(t.Sub(start)) / float64(interval)))
+}
@@ -0,0 +1,149 @@
+package qc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/de-bkg/gognss/pkg/rinex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCompleteness(t *testing.T) {
+	assert := assert.New(t)
+	const navFile = "../rinex/testdata/white/AREG00PER_R_20201690000_01D_MN.rnx"
+	ephs := readNavFile(t, navFile)
+	lat, lon := geodetic(areg)
+
+	// Simulate one hour of GPS observations at AREG. All satellites above 10 degrees are tracked except the
+	// first one, which is missing, and the second one, which has no phase in the first half hour.
+	// A satellite is tracked below the mask too, these observations are not counted. The last five epochs
+	// are missing in the file, they are nominal epochs according to TIME OF LAST OBS.
+	hdr := rinex.ObsHeader{
+		RINEXVersion: 3.04, RINEXType: "O", SatSystem: gnss.SysGPS, MarkerName: "AREG", Position: areg,
+		ObsTypes: map[gnss.System][]rinex.ObsCode{gnss.SysGPS: {"C1C", "L1C"}}, Interval: 60,
+	}
+	start := time.Date(2020, 6, 17, 0, 0, 0, 0, time.UTC)
+	hdr.TimeOfFirstObs, hdr.TimeOfLastObs = start, start.Add(59*time.Minute)
+	gapStart := start.Add(55 * time.Minute)
+
+	var missing, noPhase, low gnss.PRN
+	inGap := map[gnss.PRN]int{} // the epochs above the mask in the gap
+	epochs := []*rinex.Epoch{}
+	for ti := start; ti.Before(start.Add(time.Hour)); ti = ti.Add(time.Minute) {
+		epo := &rinex.Epoch{Time: ti}
+		for num := int8(1); num <= 32; num++ {
+			prn := gnss.PRN{Sys: gnss.SysGPS, Num: num}
+			eph, err := ephs.Best(prn, ti)
+			if err != nil {
+				continue
+			}
+			elev := elevation(areg, lat, lon, satPos(eph, ti))
+			if elev < 10 {
+				if low == (gnss.PRN{}) && elev > 0 {
+					low = prn
+				}
+				if prn != low {
+					continue
+				}
+			} else if missing == (gnss.PRN{}) {
+				missing = prn
+			} else if noPhase == (gnss.PRN{}) && prn != missing {
+				noPhase = prn
+			}
+			if prn == missing {
+				continue
+			}
+			if !ti.Before(gapStart) {
+				if elev >= 10 {
+					inGap[prn]++
+				}
+				continue
+			}
+			obss := map[rinex.ObsCode]rinex.Obs{"C1C": {Val: 2e7}, "L1C": {Val: 1e8}}
+			if prn == noPhase && ti.Before(start.Add(30*time.Minute)) {
+				obss["L1C"] = rinex.Obs{}
+			}
+			epo.ObsList = append(epo.ObsList, rinex.SatObs{Prn: prn, Obss: obss})
+		}
+		epo.NumSat = uint8(len(epo.ObsList))
+		if ti.Before(gapStart) {
+			epochs = append(epochs, epo)
+		}
+	}
+	numGap := 0
+	for _, n := range inGap {
+		numGap += n
+	}
+
+	rep, err := CheckCompleteness(encodeEpochs(t, hdr, epochs), newNavDecoder(t, navFile), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(55, rep.NumEpochs)
+	assert.Equal(60, rep.NumNominal)
+	assert.Equal(60.0, rep.Interval)
+	if !assert.Len(rep.Systems, 1) {
+		return
+	}
+	gps := rep.Systems[0]
+	assert.Equal(gnss.SysGPS, gps.Sys)
+	assert.Equal([]gnss.PRN{missing}, gps.NotTracked)
+	assert.Greater(gps.NumExpected, 60*5)
+
+	var missingSat, noPhaseSat *SatCompleteness
+	for _, sat := range gps.Satellites {
+		switch sat.PRN {
+		case missing:
+			missingSat = sat
+		case noPhase:
+			noPhaseSat = sat
+		case low:
+			assert.Less(sat.NumExpected, 60)
+			assert.Equal(sat.NumExpected-inGap[low], sat.NumObserved)
+		default:
+			assert.Equal(sat.NumExpected-inGap[sat.PRN], sat.NumObserved, sat.PRN)
+		}
+	}
+	if assert.NotNil(missingSat) {
+		assert.Zero(missingSat.NumObserved)
+		assert.Zero(missingSat.Completeness)
+	}
+	if assert.NotNil(noPhaseSat) {
+		assert.Equal(noPhaseSat.NumExpected-inGap[noPhase], noPhaseSat.NumObserved)
+	}
+
+	assert.Positive(numGap)
+	assert.Equal(gps.NumExpected-missingSat.NumExpected-numGap, gps.NumObserved)
+	if assert.Len(gps.Signals, 2) {
+		c1c, l1c := gps.Signals[0], gps.Signals[1]
+		assert.Equal(rinex.ObsCode("C1C"), c1c.Code)
+		assert.Equal(gps.NumExpected, c1c.NumExpected)
+		assert.Equal(gps.NumObserved, c1c.NumObs)
+		assert.Equal(gps.NumObserved-30, l1c.NumObs)
+		assert.Equal(percent(l1c.NumObs, l1c.NumExpected), l1c.Completeness)
+	}
+
+	b, err := json.Marshal(rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(string(b), `"notTracked":["`+missing.String()+`"]`)
+
+	// the interval derived from the data
+	hdr.Interval = 0
+	rep2, err := CheckCompleteness(encodeEpochs(t, hdr, epochs), newNavDecoder(t, navFile), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(rep, rep2)
+}
+
+func TestCheckCompleteness_noPosition(t *testing.T) {
+	navDec := newNavDecoder(t, "../rinex/testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	hdr := rinex.ObsHeader{RINEXVersion: 3.04, RINEXType: "O", SatSystem: gnss.SysGPS,
+		ObsTypes: map[gnss.System][]rinex.ObsCode{gnss.SysGPS: {"C1C"}}}
+	_, err := CheckCompleteness(encodeEpochs(t, hdr, nil), navDec, 10)
+	assert.ErrorIs(t, err, ErrNoPosition)
+}
@@ -0,0 +1,47 @@
+package qc
+
+import (
+	"math"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/rinex"
+)
+
+// Constants of WGS84.
+const (
+	wgs84A = 6378137.0 // semi-major axis in m
+	wgs84F = 1 / 298.257223563
+)
+
+// readEphemerides reads the ephemerides from the decoder into a store.
+func readEphemerides(dec *rinex.NavDecoder) (*rinex.EphStore, error) {
+	ephs := rinex.NewEphStore()
+	return ephs, ephs.Load(dec)
+}
+
+// satPos returns the earth-fixed satellite position at the time t.
+func satPos(eph rinex.Eph, t time.Time) rinex.Coord {
+	pos, _, _ := eph.Position(t)
+	return pos
+}
+
+// geodetic returns the WGS84 latitude and longitude in radians of the earth-fixed position.
+func geodetic(pos rinex.Coord) (lat, lon float64) {
+	e2 := wgs84F * (2 - wgs84F)
+	p := math.Hypot(pos.X, pos.Y)
+	lat = math.Atan2(pos.Z, p*(1-e2))
+	for range 5 {
+		sinLat := math.Sin(lat)
+		n := wgs84A / math.Sqrt(1-e2*sinLat*sinLat)
+		lat = math.Atan2(pos.Z+e2*n*sinLat, p)
+	}
+	return lat, math.Atan2(pos.Y, pos.X)
+}
+
+// elevation returns the elevation angle in degrees of the satellite seen from the receiver at the geodetic
+// latitude and longitude.
+func elevation(rcv rinex.Coord, lat, lon float64, sat rinex.Coord) float64 {
+	dx, dy, dz := sat.X-rcv.X, sat.Y-rcv.Y, sat.Z-rcv.Z
+	up := math.Cos(lat)*math.Cos(lon)*dx + math.Cos(lat)*math.Sin(lon)*dy + math.Sin(lat)*dz
+	return math.Asin(up/math.Sqrt(dx*dx+dy*dy+dz*dz)) * 180 / math.Pi
+}
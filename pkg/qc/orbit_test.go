@@ -0,0 +1,95 @@
+package qc
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/de-bkg/gognss/pkg/rinex"
+	"github.com/stretchr/testify/assert"
+)
+
+// areg is the approximate position of the station AREG00PER.
+var areg = rinex.Coord{X: 1942826.2, Y: -5804070.3, Z: -1796894.1}
+
+// newNavDecoder opens the RINEX nav file and returns a decoder for it.
+func newNavDecoder(t *testing.T, path string) *rinex.NavDecoder {
+	t.Helper()
+	r, err := rinex.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	dec, err := rinex.NewNavDecoder(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dec
+}
+
+func readNavFile(t *testing.T, path string) *rinex.EphStore {
+	t.Helper()
+	ephs, err := readEphemerides(newNavDecoder(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ephs
+}
+
+func TestSatPos(t *testing.T) {
+	assert := assert.New(t)
+	ephs := readNavFile(t, "../rinex/testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	nGPS := 0
+	for _, prn := range ephs.PRNs() {
+		if prn.Sys == gnss.SysGPS {
+			nGPS++
+		}
+	}
+	assert.Equal(31, nGPS, "GPS satellites")
+
+	// Consecutive ephemerides must give nearly the same position in between.
+	for _, prn := range ephs.PRNs() {
+		if prn.Sys != gnss.SysGPS {
+			continue
+		}
+		list := ephs.Ephemerides(prn)
+		for i := 1; i < len(list); i++ {
+			t1, t2 := list[i-1].GetTime(), list[i].GetTime()
+			if t2.Sub(t1) != 2*time.Hour {
+				continue
+			}
+			mid := t1.Add(time.Hour)
+			p1, p2 := satPos(list[i-1], mid), satPos(list[i], mid)
+			dist := math.Sqrt(math.Pow(p1.X-p2.X, 2) + math.Pow(p1.Y-p2.Y, 2) + math.Pow(p1.Z-p2.Z, 2))
+			assert.Less(dist, 10.0, "%s at %s", prn, mid)
+
+			r := math.Sqrt(p1.X*p1.X + p1.Y*p1.Y + p1.Z*p1.Z)
+			assert.InDelta(26560e3, r, 700e3, "%s orbit radius", prn)
+		}
+	}
+
+	g02 := gnss.PRN{Sys: gnss.SysGPS, Num: 2}
+	ti := time.Date(2020, 6, 17, 0, 0, 0, 0, time.UTC)
+	eph, err := ephs.Best(g02, ti)
+	if assert.NoError(err) {
+		assert.Equal(ti, eph.GetTime())
+	}
+	_, err = ephs.Best(g02, ti.Add(-5*time.Hour))
+	assert.ErrorIs(err, rinex.ErrEphemerisExpired)
+	_, err = ephs.Best(gnss.PRN{Sys: gnss.SysGPS, Num: 33}, ti)
+	assert.ErrorIs(err, rinex.ErrNoEphemeris)
+}
+
+func TestElevation(t *testing.T) {
+	assert := assert.New(t)
+	lat, lon := geodetic(areg)
+	assert.InDelta(-16.4655, lat*180/math.Pi, 0.001)
+	assert.InDelta(-71.4928, lon*180/math.Pi, 0.001)
+
+	// a satellite at the zenith and one at the horizon
+	up := rinex.Coord{X: areg.X * 1.5, Y: areg.Y * 1.5, Z: areg.Z * 1.5}
+	north := rinex.Coord{X: areg.X - math.Sin(lat)*math.Cos(lon)*1e6, Y: areg.Y - math.Sin(lat)*math.Sin(lon)*1e6, Z: areg.Z + math.Cos(lat)*1e6}
+	assert.InDelta(90, elevation(areg, lat, lon, up), 0.2)
+	assert.InDelta(0, elevation(areg, lat, lon, north), 1e-6)
+}
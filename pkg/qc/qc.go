@@ -0,0 +1,539 @@
+// Package qc provides the quality control of GNSS observation data, similar to tools like teqc or Anubis.
+//
+// The report contains per satellite system and signal the number of observations and the completeness,
+// data gaps, loss of lock indicators, SNR statistics, the code multipath and the detected cycle slips.
+// CheckCompleteness compares the observations with the satellites visible according to the broadcast orbits.
+package qc
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/de-bkg/gognss/pkg/rinex"
+)
+
+// Default options.
+const (
+	DefaultGFThreshold  = 0.15 // m
+	DefaultMWThreshold  = 4.0  // widelane cycles
+	DefaultMinArcLength = 10   // epochs
+)
+
+// Options for the quality control. Zero values are replaced by the defaults.
+type Options struct {
+	MinGap       time.Duration // A time difference between observations larger than MinGap is a data gap. Defaults to 1.5 times the interval.
+	GFThreshold  float64       // The maximum change of the geometry-free combination between two epochs in m.
+	MWThreshold  float64       // The maximum deviation of the Melbourne-Wübbena combination from the arc mean in widelane cycles.
+	MinArcLength int           // The minimum number of epochs of an arc for the multipath estimation.
+}
+
+// Report is the quality control report of an observation file.
+type Report struct {
+	MarkerName     string       `json:"markerName"`
+	RINEXVersion   float32      `json:"rinexVersion"`
+	TimeOfFirstObs time.Time    `json:"timeOfFirstObs"`
+	TimeOfLastObs  time.Time    `json:"timeOfLastObs"`
+	Interval       float64      `json:"interval"`       // The observation interval in seconds, from the header or derived from the data.
+	NumEpochs      int          `json:"numEpochs"`      // The number of observation epochs, without special events.
+	ExpectedEpochs int          `json:"expectedEpochs"` // The number of epochs expected between the first and the last observation.
+	NumSats        int          `json:"numSats"`        // The number of observed satellites.
+	Gaps           []Gap        `json:"gaps"`           // Gaps between epochs.
+	Systems        []*SysReport `json:"systems"`
+}
+
+// Gap is a period without observations. Start is the last epoch with and End the first epoch after the gap.
+type Gap struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Length float64   `json:"length"` // The length in seconds.
+}
+
+// SysReport is the quality control report of a satellite system.
+type SysReport struct {
+	Sys          gnss.System     `json:"sys"`
+	NumSats      int             `json:"numSats"`      // The number of observed satellites.
+	NumSatEpochs int             `json:"numSatEpochs"` // The number of epochs summed over all satellites.
+	Gaps         []Gap           `json:"gaps"`         // Periods without any satellite of the system.
+	CycleSlips   *SlipReport     `json:"cycleSlips,omitempty"`
+	Signals      []*SignalReport `json:"signals"`
+}
+
+// SlipReport holds the cycle slips detected with the geometry-free and the Melbourne-Wübbena combination.
+type SlipReport struct {
+	Phases     [2]rinex.ObsCode `json:"phases"`          // The phases used for the combinations.
+	Codes      [2]rinex.ObsCode `json:"codes,omitempty"` // The codes used for the Melbourne-Wübbena combination.
+	NumObs     int              `json:"numObs"`          // The number of dual-frequency observations.
+	GF         int              `json:"gf"`              // The slips detected with the geometry-free combination.
+	MW         int              `json:"mw"`              // The slips detected with the Melbourne-Wübbena combination.
+	Slips      int              `json:"slips"`           // The number of epochs with slips.
+	ObsPerSlip int              `json:"obsPerSlip"`      // The number of observations per slip, 0 without slips.
+}
+
+// SignalReport is the quality control report of an observation type.
+type SignalReport struct {
+	Code         rinex.ObsCode `json:"code"`
+	NumObs       int           `json:"numObs"`              // The number of observations.
+	NumExpected  int           `json:"numExpected"`         // The number of expected observations, i.e. the satellite epochs of the system.
+	Completeness float64       `json:"completeness"`        // The percentage of observed to expected observations.
+	LLI          int           `json:"lli"`                 // The number of observations with loss of lock.
+	Gaps         []Gap         `json:"gaps"`                // Periods without the signal for any satellite of the system.
+	Multipath    float64       `json:"multipath,omitempty"` // The RMS of the code multipath in m, for codes only.
+	SNR          *SNRStats     `json:"snr,omitempty"`       // For signal strength observations only.
+}
+
+// SNRStats are statistics on the signal strength in dBHz.
+type SNRStats struct {
+	Mean   float64 `json:"mean"`
+	Min    float64 `json:"min"`
+	P10    float64 `json:"p10"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+	Max    float64 `json:"max"`
+}
+
+// CheckFile runs the quality control on the RINEX observation file, which may be compressed.
+func CheckFile(path string, opts Options) (*Report, error) {
+	r, err := rinex.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	dec, err := rinex.NewObsDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	return Check(dec, opts)
+}
+
+// Check reads all epochs from the decoder and returns the quality control report.
+func Check(dec *rinex.ObsDecoder, opts Options) (*Report, error) {
+	c := newChecker(dec.Header, opts)
+	for dec.NextEpoch() {
+		c.add(dec.Epoch())
+	}
+	if err := dec.Err(); err != nil {
+		return nil, err
+	}
+	return c.report(), nil
+}
+
+// numIntervalEpochs is the number of epochs used to derive the interval from the data.
+const numIntervalEpochs = 11
+
+// checker accumulates the statistics epoch by epoch.
+type checker struct {
+	hdr      rinex.ObsHeader
+	opts     Options
+	interval time.Duration
+	pending  []*rinex.Epoch // epochs read before the interval is known
+
+	rep     *Report
+	gaps    gapTracker
+	systems map[gnss.System]*sysState
+	sats    map[gnss.PRN]*satState
+}
+
+type sysState struct {
+	rep     *SysReport
+	gaps    gapTracker
+	signals map[rinex.ObsCode]*signalState
+	dual    dualFreq
+	hasDual bool
+	sats    map[gnss.PRN]struct{}
+}
+
+type signalState struct {
+	rep   *SignalReport
+	gaps  gapTracker
+	snr   *snrHist
+	mpSS  float64 // sum of the squared multipath residuals
+	mpNum int
+}
+
+// satState is the current arc of a satellite.
+type satState struct {
+	last   time.Time // the last epoch of the arc
+	numObs int
+	gf     float64 // the last geometry-free combination
+	mw     arcStat
+	mp     map[rinex.ObsCode]*arcStat
+}
+
+type arcStat struct {
+	sum, sumSq float64
+	n          int
+}
+
+func (a *arcStat) add(v float64) {
+	a.sum += v
+	a.sumSq += v * v
+	a.n++
+}
+
+func (a *arcStat) mean() float64 {
+	return a.sum / float64(a.n)
+}
+
+type gapTracker struct {
+	last time.Time
+	gaps []Gap
+}
+
+// add adds the time of an observation and records a gap if the time since the previous one exceeds minGap.
+func (g *gapTracker) add(t time.Time, minGap time.Duration) {
+	if minGap > 0 && !g.last.IsZero() && t.Sub(g.last) > minGap {
+		g.gaps = append(g.gaps, Gap{Start: g.last, End: t, Length: t.Sub(g.last).Seconds()})
+	}
+	g.last = t
+}
+
+func newChecker(hdr rinex.ObsHeader, opts Options) *checker {
+	if opts.GFThreshold <= 0 {
+		opts.GFThreshold = DefaultGFThreshold
+	}
+	if opts.MWThreshold <= 0 {
+		opts.MWThreshold = DefaultMWThreshold
+	}
+	if opts.MinArcLength <= 0 {
+		opts.MinArcLength = DefaultMinArcLength
+	}
+	c := &checker{
+		hdr:      hdr,
+		opts:     opts,
+		interval: time.Duration(hdr.Interval * float64(time.Second)),
+		rep:      &Report{MarkerName: hdr.MarkerName, RINEXVersion: hdr.RINEXVersion, Gaps: []Gap{}, Systems: []*SysReport{}},
+		systems:  map[gnss.System]*sysState{},
+		sats:     map[gnss.PRN]*satState{},
+	}
+	if c.hdr.GloSlots == nil {
+		c.hdr.GloSlots = map[gnss.PRN]int{}
+	}
+	c.setMinGap()
+	return c
+}
+
+func (c *checker) setMinGap() {
+	if c.opts.MinGap <= 0 {
+		c.opts.MinGap = c.interval * 3 / 2
+	}
+}
+
+// add processes the epoch. Special events are skipped. The epochs are buffered until the interval is known.
+func (c *checker) add(epo *rinex.Epoch) {
+	if epo.Flag > rinex.EpochFlagPowerFailure {
+		return
+	}
+	if c.interval == 0 {
+		c.pending = append(c.pending, epo)
+		if len(c.pending) < numIntervalEpochs {
+			return
+		}
+		c.flushPending()
+		return
+	}
+	c.process(epo)
+}
+
+// flushPending derives the interval from the buffered epochs and processes them.
+func (c *checker) flushPending() {
+	for i := 1; i < len(c.pending); i++ {
+		if d := c.pending[i].Time.Sub(c.pending[i-1].Time); d > 0 && (c.interval == 0 || d < c.interval) {
+			c.interval = d
+		}
+	}
+	c.setMinGap()
+	for _, epo := range c.pending {
+		c.process(epo)
+	}
+	c.pending = nil
+}
+
+// obsTypes returns the observation types of the system. In RINEX-2 the types are valid for all systems.
+func obsTypes(hdr *rinex.ObsHeader, sys gnss.System) []rinex.ObsCode {
+	if hdr.RINEXVersion < 3 {
+		return hdr.ObsTypes[hdr.SatSystem]
+	}
+	return hdr.ObsTypes[sys]
+}
+
+// percent returns the percentage of n to total, rounded to two decimals.
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(total)*10000) / 100
+}
+
+func (c *checker) system(sys gnss.System) *sysState {
+	if st, ok := c.systems[sys]; ok {
+		return st
+	}
+	st := &sysState{
+		rep:     &SysReport{Sys: sys, Signals: []*SignalReport{}},
+		signals: map[rinex.ObsCode]*signalState{},
+		sats:    map[gnss.PRN]struct{}{},
+	}
+	st.dual, st.hasDual = selectDualFreq(sys, obsTypes(&c.hdr, sys))
+	if st.hasDual {
+		st.rep.CycleSlips = &SlipReport{Phases: st.dual.phases, Codes: st.dual.codes}
+	}
+	c.systems[sys] = st
+	return st
+}
+
+func (st *sysState) signal(code rinex.ObsCode) *signalState {
+	if sig, ok := st.signals[code]; ok {
+		return sig
+	}
+	sig := &signalState{rep: &SignalReport{Code: code}}
+	if code[0] == 'S' {
+		sig.snr = &snrHist{}
+	}
+	st.signals[code] = sig
+	return sig
+}
+
+func (c *checker) process(epo *rinex.Epoch) {
+	rep := c.rep
+	if rep.NumEpochs == 0 {
+		rep.TimeOfFirstObs = epo.Time
+	}
+	rep.TimeOfLastObs = epo.Time
+	rep.NumEpochs++
+	c.gaps.add(epo.Time, c.opts.MinGap)
+
+	for _, satObs := range epo.ObsList {
+		prn := satObs.Prn
+		st := c.system(prn.Sys)
+		observed := false
+		for code, obs := range satObs.Obss {
+			if obs.Val == 0 {
+				continue
+			}
+			observed = true
+			sig := st.signal(code)
+			sig.rep.NumObs++
+			sig.gaps.add(epo.Time, c.opts.MinGap)
+			if obs.LLI&1 != 0 {
+				sig.rep.LLI++
+			}
+			if sig.snr != nil {
+				sig.snr.add(obs.Val)
+			}
+		}
+		if !observed {
+			continue
+		}
+		st.rep.NumSatEpochs++
+		st.gaps.add(epo.Time, c.opts.MinGap)
+		st.sats[prn] = struct{}{}
+		if st.hasDual {
+			c.checkDualFreq(epo, satObs, st)
+		}
+	}
+}
+
+// checkDualFreq detects cycle slips and computes the code multipath of the satellite. The arc of the satellite
+// is closed on data gaps, loss of lock, power failures and cycle slips.
+func (c *checker) checkDualFreq(epo *rinex.Epoch, satObs rinex.SatObs, st *sysState) {
+	prn, df := satObs.Prn, st.dual
+	la, lb := satObs.Obss[df.phases[0]], satObs.Obss[df.phases[1]]
+	fa := df.phases[0].Frequency(prn, c.hdr.GloSlots)
+	fb := df.phases[1].Frequency(prn, c.hdr.GloSlots)
+	if la.Val == 0 || lb.Val == 0 || fa == 0 || fb == 0 {
+		return
+	}
+
+	sat, ok := c.sats[prn]
+	if !ok {
+		sat = &satState{mp: map[rinex.ObsCode]*arcStat{}}
+		c.sats[prn] = sat
+	}
+
+	// phases in m
+	phiA, phiB := la.Val*gnss.SpeedOfLight/fa, lb.Val*gnss.SpeedOfLight/fb
+	gf := phiA - phiB
+	mw, hasMW := 0.0, false
+	if pa, pb := satObs.Obss[df.codes[0]], satObs.Obss[df.codes[1]]; df.codes[0] != "" && pa.Val != 0 && pb.Val != 0 {
+		wlWavelength := gnss.SpeedOfLight / (fa - fb)
+		mw = ((fa*phiA-fb*phiB)/(fa-fb) - (fa*pa.Val+fb*pb.Val)/(fa+fb)) / wlWavelength
+		hasMW = true
+	}
+
+	slips := st.rep.CycleSlips
+	slips.NumObs++
+	newArc := sat.numObs == 0 || epo.Time.Sub(sat.last) > c.opts.MinGap || epo.Flag == rinex.EpochFlagPowerFailure ||
+		la.LLI&1 != 0 || lb.LLI&1 != 0
+	if !newArc {
+		slip := false
+		if math.Abs(gf-sat.gf) > c.opts.GFThreshold {
+			slips.GF++
+			slip = true
+		}
+		if hasMW && sat.mw.n > 0 && math.Abs(mw-sat.mw.mean()) > c.opts.MWThreshold {
+			slips.MW++
+			slip = true
+		}
+		if slip {
+			slips.Slips++
+			newArc = true
+		}
+	}
+	if newArc {
+		c.closeArc(st, sat)
+	}
+	sat.last = epo.Time
+	sat.numObs++
+	sat.gf = gf
+	if hasMW {
+		sat.mw.add(mw)
+	}
+
+	// Multipath of each code: the code minus the linear combination of the phases that removes
+	// the geometry and the first-order ionosphere.
+	gammaA, gammaB := 1.0, (fa/fb)*(fa/fb)
+	for code, obs := range satObs.Obss {
+		if code.Type() != 'C' || obs.Val == 0 {
+			continue
+		}
+		fc := code.Frequency(prn, c.hdr.GloSlots)
+		if fc == 0 {
+			continue
+		}
+		gammaC := (fa / fc) * (fa / fc)
+		x := (gammaC + gammaB) / (gammaB - gammaA)
+		mp := obs.Val - x*phiA - (1-x)*phiB
+		arc, ok := sat.mp[code]
+		if !ok {
+			arc = &arcStat{}
+			sat.mp[code] = arc
+		}
+		arc.add(mp)
+	}
+}
+
+// closeArc adds the multipath of the satellite's arc to the signals and resets the arc.
+func (c *checker) closeArc(st *sysState, sat *satState) {
+	for code, arc := range sat.mp {
+		if arc.n >= c.opts.MinArcLength {
+			sig := st.signal(code)
+			sig.mpSS += arc.sumSq - arc.sum*arc.sum/float64(arc.n)
+			sig.mpNum += arc.n
+		}
+		delete(sat.mp, code)
+	}
+	sat.numObs = 0
+	sat.mw = arcStat{}
+}
+
+// report finishes the statistics and returns the report.
+func (c *checker) report() *Report {
+	if len(c.pending) > 0 {
+		c.flushPending()
+	}
+	for prn, sat := range c.sats {
+		c.closeArc(c.systems[prn.Sys], sat)
+	}
+
+	rep := c.rep
+	rep.Interval = c.interval.Seconds()
+	if c.interval > 0 && rep.NumEpochs > 0 {
+		rep.ExpectedEpochs = int(math.Round(float64(rep.TimeOfLastObs.Sub(rep.TimeOfFirstObs))/float64(c.interval))) + 1
+	}
+	rep.Gaps = append(rep.Gaps, c.gaps.gaps...)
+
+	syss := make([]gnss.System, 0, len(c.systems))
+	for sys := range c.systems {
+		syss = append(syss, sys)
+	}
+	for sys := range c.hdr.ObsTypes {
+		if c.hdr.RINEXVersion >= 3 && !slices.Contains(syss, sys) {
+			syss = append(syss, sys)
+		}
+	}
+	slices.Sort(syss)
+
+	for _, sys := range syss {
+		st := c.system(sys)
+		st.rep.NumSats = len(st.sats)
+		rep.NumSats += st.rep.NumSats
+		st.rep.Gaps = append([]Gap{}, st.gaps.gaps...)
+		if slips := st.rep.CycleSlips; slips != nil && slips.Slips > 0 {
+			slips.ObsPerSlip = slips.NumObs / slips.Slips
+		}
+
+		// all types of the header in RINEX-3, only the observed types in RINEX-2
+		for _, code := range obsTypes(&c.hdr, sys) {
+			if _, ok := st.signals[code]; !ok && c.hdr.RINEXVersion < 3 {
+				continue
+			}
+			sig := st.signal(code)
+			st.rep.Signals = append(st.rep.Signals, sig.report(st.rep.NumSatEpochs))
+		}
+		rep.Systems = append(rep.Systems, st.rep)
+	}
+	return rep
+}
+
+func (sig *signalState) report(numExpected int) *SignalReport {
+	rep := sig.rep
+	rep.NumExpected = numExpected
+	rep.Completeness = percent(rep.NumObs, numExpected)
+	rep.Gaps = append([]Gap{}, sig.gaps.gaps...)
+	if sig.mpNum > 0 {
+		rep.Multipath = math.Sqrt(sig.mpSS / float64(sig.mpNum))
+	}
+	if sig.snr != nil && sig.snr.n > 0 {
+		rep.SNR = sig.snr.stats()
+	}
+	return rep
+}
+
+// snrResolution is the bin width of the SNR histogram in dBHz.
+const snrResolution = 0.1
+
+// snrHist is a histogram of the SNR values, used to compute percentiles without keeping all values.
+type snrHist struct {
+	bins          [1001]int // 0 to 100 dBHz
+	n             int
+	sum, min, max float64
+}
+
+func (h *snrHist) add(v float64) {
+	if h.n == 0 || v < h.min {
+		h.min = v
+	}
+	if h.n == 0 || v > h.max {
+		h.max = v
+	}
+	h.n++
+	h.sum += v
+	i := min(max(int(math.Round(v/snrResolution)), 0), len(h.bins)-1)
+	h.bins[i]++
+}
+
+// percentile returns the value at the percentile p in the range 0-100.
+func (h *snrHist) percentile(p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(h.n)))
+	cum := 0
+	for i, cnt := range h.bins {
+		cum += cnt
+		if cum >= rank && cnt > 0 {
+			return math.Round(float64(i)*snrResolution*10) / 10
+		}
+	}
+	return h.max
+}
+
+func (h *snrHist) stats() *SNRStats {
+	return &SNRStats{
+		Mean:   h.sum / float64(h.n),
+		Min:    h.min,
+		P10:    h.percentile(10),
+		Median: h.percentile(50),
+		P90:    h.percentile(90),
+		Max:    h.max,
+	}
+}
@@ -0,0 +1,278 @@
+package qc
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/de-bkg/gognss/pkg/rinex"
+	"github.com/stretchr/testify/assert"
+)
+
+// newObsDecoder opens the RINEX obs file and returns a decoder for it.
+func newObsDecoder(t *testing.T, path string) *rinex.ObsDecoder {
+	t.Helper()
+	r, err := rinex.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	dec, err := rinex.NewObsDecoder(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dec
+}
+
+// readEpochs returns the header and all epochs of the RINEX obs file.
+func readEpochs(t *testing.T, path string) (rinex.ObsHeader, []*rinex.Epoch) {
+	t.Helper()
+	dec := newObsDecoder(t, path)
+	epochs := []*rinex.Epoch{}
+	for dec.NextEpoch() {
+		epochs = append(epochs, dec.Epoch())
+	}
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return dec.Header, epochs
+}
+
+// encodeEpochs encodes the epochs and returns a decoder for them.
+func encodeEpochs(t *testing.T, hdr rinex.ObsHeader, epochs []*rinex.Epoch) *rinex.ObsDecoder {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := rinex.NewObsEncoder(&buf, hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, epo := range epochs {
+		if err := enc.Encode(epo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := rinex.NewObsDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dec
+}
+
+// checkEpochs encodes the epochs and returns the report.
+func checkEpochs(t *testing.T, hdr rinex.ObsHeader, epochs []*rinex.Epoch) *Report {
+	t.Helper()
+	rep, err := Check(encodeEpochs(t, hdr, epochs), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rep
+}
+
+func sysReport(rep *Report, sys gnss.System) *SysReport {
+	for _, sysRep := range rep.Systems {
+		if sysRep.Sys == sys {
+			return sysRep
+		}
+	}
+	return nil
+}
+
+func signalReport(sysRep *SysReport, code rinex.ObsCode) *SignalReport {
+	for _, sig := range sysRep.Signals {
+		if sig.Code == code {
+			return sig
+		}
+	}
+	return nil
+}
+
+func TestCheckFile(t *testing.T) {
+	assert := assert.New(t)
+	rep, err := CheckFile("../rinex/testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("BRUX", rep.MarkerName)
+	assert.Equal(30.0, rep.Interval)
+	assert.Equal(120, rep.NumEpochs)
+	assert.Equal(120, rep.ExpectedEpochs)
+	assert.Equal(time.Date(2018, 11, 6, 19, 0, 0, 0, time.UTC), rep.TimeOfFirstObs)
+	assert.Equal(time.Date(2018, 11, 6, 19, 59, 30, 0, time.UTC), rep.TimeOfLastObs)
+	assert.Empty(rep.Gaps)
+
+	syss := []gnss.System{}
+	numSats := 0
+	for _, sysRep := range rep.Systems {
+		syss = append(syss, sysRep.Sys)
+		numSats += sysRep.NumSats
+	}
+	assert.Equal([]gnss.System{gnss.SysGPS, gnss.SysGLO, gnss.SysGAL, gnss.SysBDS}, syss)
+	assert.Equal(numSats, rep.NumSats)
+
+	gps := sysReport(rep, gnss.SysGPS)
+	assert.Equal(13, gps.NumSats)
+	assert.Len(gps.Signals, 14, "all types of the header")
+	if assert.NotNil(gps.CycleSlips) {
+		assert.Equal([2]rinex.ObsCode{"L1C", "L2W"}, gps.CycleSlips.Phases)
+		assert.Equal([2]rinex.ObsCode{"C1C", "C2W"}, gps.CycleSlips.Codes)
+		assert.Greater(gps.CycleSlips.NumObs, 1000)
+	}
+	for _, sig := range gps.Signals {
+		assert.Equal(gps.NumSatEpochs, sig.NumExpected, sig.Code)
+		assert.LessOrEqual(sig.NumObs, sig.NumExpected, sig.Code)
+		assert.InDelta(100*float64(sig.NumObs)/float64(sig.NumExpected), sig.Completeness, 0.01, sig.Code)
+		switch sig.Code[0] {
+		case 'C':
+			assert.Greater(sig.Multipath, 0.01, sig.Code)
+			assert.Less(sig.Multipath, 1.0, sig.Code)
+		case 'S':
+			if assert.NotNil(sig.SNR, sig.Code) {
+				assert.LessOrEqual(sig.SNR.Min, sig.SNR.P10)
+				assert.LessOrEqual(sig.SNR.P10, sig.SNR.Median)
+				assert.LessOrEqual(sig.SNR.Median, sig.SNR.P90)
+				assert.LessOrEqual(sig.SNR.P90, sig.SNR.Max)
+				assert.Greater(sig.SNR.Mean, 20.0)
+			}
+		default:
+			assert.Zero(sig.Multipath, sig.Code)
+			assert.Nil(sig.SNR, sig.Code)
+		}
+	}
+	l1c := signalReport(gps, "L1C")
+	assert.Equal(5, l1c.LLI)
+
+	// JSON
+	b, err := json.Marshal(rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(string(b), `"sys":"G"`)
+	var got map[string]any
+	assert.NoError(json.Unmarshal(b, &got))
+	assert.Equal(120.0, got["numEpochs"])
+	assert.Len(got["systems"], 4)
+}
+
+func TestCheck_v2(t *testing.T) {
+	assert := assert.New(t)
+	rep, err := CheckFile("testdata/brst155h.20o.gz", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(120, rep.NumEpochs)
+
+	gps := sysReport(rep, gnss.SysGPS)
+	if assert.NotNil(gps) && assert.NotNil(gps.CycleSlips) {
+		assert.Equal([2]rinex.ObsCode{"L1", "L2"}, gps.CycleSlips.Phases)
+		assert.Equal([2]rinex.ObsCode{"P1", "P2"}, gps.CycleSlips.Codes)
+	}
+	assert.Nil(signalReport(gps, "P1"), "not observed for GPS")
+	assert.NotNil(signalReport(gps, "C1"))
+
+	// No GLONASS frequency numbers in the header.
+	glo := sysReport(rep, gnss.SysGLO)
+	if assert.NotNil(glo) && assert.NotNil(glo.CycleSlips) {
+		assert.Zero(glo.CycleSlips.NumObs)
+	}
+}
+
+func TestCheck_gaps(t *testing.T) {
+	assert := assert.New(t)
+	hdr, epochs := readEpochs(t, "../rinex/testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+	hdr.Interval = 0 // derive from the data
+
+	// Remove the epochs between 19:15 and 19:30, and Galileo after 19:45.
+	gapStart := time.Date(2018, 11, 6, 19, 15, 0, 0, time.UTC)
+	galEnd := time.Date(2018, 11, 6, 19, 45, 0, 0, time.UTC)
+	kept := []*rinex.Epoch{}
+	for _, epo := range epochs {
+		if !epo.Time.Before(gapStart) && epo.Time.Before(gapStart.Add(15*time.Minute)) {
+			continue
+		}
+		if epo.Time.Equal(galEnd) {
+			obsList := []rinex.SatObs{}
+			for _, satObs := range epo.ObsList {
+				if satObs.Prn.Sys != gnss.SysGAL {
+					obsList = append(obsList, satObs)
+				}
+			}
+			epo.ObsList = obsList
+			epo.NumSat = uint8(len(obsList))
+		}
+		kept = append(kept, epo)
+	}
+
+	rep := checkEpochs(t, hdr, kept)
+	assert.Equal(30.0, rep.Interval)
+	assert.Equal(90, rep.NumEpochs)
+	assert.Equal(120, rep.ExpectedEpochs)
+	wantGap := Gap{Start: gapStart.Add(-30 * time.Second), End: gapStart.Add(15 * time.Minute), Length: 930}
+	assert.Equal([]Gap{wantGap}, rep.Gaps)
+
+	gps := sysReport(rep, gnss.SysGPS)
+	assert.Equal([]Gap{wantGap}, gps.Gaps)
+	assert.Equal([]Gap{wantGap}, signalReport(gps, "C1C").Gaps)
+
+	gal := sysReport(rep, gnss.SysGAL)
+	assert.Equal([]Gap{wantGap, {Start: galEnd.Add(-30 * time.Second), End: galEnd.Add(30 * time.Second), Length: 60}}, gal.Gaps)
+}
+
+func TestCheck_cycleSlips(t *testing.T) {
+	assert := assert.New(t)
+	hdr, epochs := readEpochs(t, "../rinex/testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+	rep := checkEpochs(t, hdr, epochs)
+	gps := sysReport(rep, gnss.SysGPS)
+	slips := *gps.CycleSlips
+
+	// Add a slip of 5 cycles on L1 for G13 at epoch 60, and a slip of 77 and 60 cycles at epoch 90,
+	// which is almost invisible in the geometry-free combination.
+	prn := gnss.PRN{Sys: gnss.SysGPS, Num: 13}
+	for i, epo := range epochs[60:] {
+		for _, satObs := range epo.ObsList {
+			if satObs.Prn != prn {
+				continue
+			}
+			for _, code := range []rinex.ObsCode{"L1C", "L2W"} {
+				obs := satObs.Obss[code]
+				if obs.Val == 0 {
+					t.Fatalf("no %s for %s at %s", code, prn, epo.Time)
+				}
+				if code == "L1C" {
+					obs.Val += 5
+				}
+				if i >= 30 {
+					obs.Val += map[rinex.ObsCode]float64{"L1C": 77, "L2W": 60}[code]
+				}
+				satObs.Obss[code] = obs
+			}
+		}
+	}
+	rep = checkEpochs(t, hdr, epochs)
+	got := sysReport(rep, gnss.SysGPS).CycleSlips
+	assert.Equal(slips.NumObs, got.NumObs)
+	assert.Equal(slips.Slips+2, got.Slips)
+	assert.Equal(slips.GF+1, got.GF, "the 77/60 slip is not detected by GF")
+	assert.Equal(slips.MW+2, got.MW)
+	assert.Equal(got.NumObs/got.Slips, got.ObsPerSlip)
+}
+
+func TestSNRHist(t *testing.T) {
+	assert := assert.New(t)
+	h := &snrHist{}
+	for i := 1; i <= 100; i++ {
+		h.add(float64(i) / 2)
+	}
+	h.add(120)
+	got := h.stats()
+	assert.Equal(0.5, got.Min)
+	assert.Equal(120.0, got.Max)
+	assert.InDelta((5050.0/2+120)/101, got.Mean, 1e-9)
+	assert.Equal(5.5, got.P10)
+	assert.Equal(25.5, got.Median)
+	assert.Equal(45.5, got.P90)
+}
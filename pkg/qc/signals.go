@@ -0,0 +1,69 @@
+package qc
+
+import (
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/de-bkg/gognss/pkg/rinex"
+)
+
+// bandPairs are the preferred frequency bands for dual-frequency combinations per system.
+var bandPairs = map[gnss.System][][2]byte{
+	gnss.SysGPS:   {{'1', '2'}, {'1', '5'}},
+	gnss.SysGLO:   {{'1', '2'}},
+	gnss.SysGAL:   {{'1', '5'}, {'1', '7'}},
+	gnss.SysQZSS:  {{'1', '2'}, {'1', '5'}},
+	gnss.SysBDS:   {{'2', '7'}, {'2', '6'}, {'1', '5'}},
+	gnss.SysNavIC: {{'5', '9'}},
+	gnss.SysSBAS:  {{'1', '5'}},
+}
+
+// dualFreq are the observations used for the dual-frequency combinations of a system.
+type dualFreq struct {
+	phases [2]rinex.ObsCode
+	codes  [2]rinex.ObsCode // optional, for the Melbourne-Wübbena combination
+}
+
+// selectDualFreq selects the phase and code observations for the dual-frequency combinations from the
+// observation types of a system. It returns false if there are no two phases on the preferred bands.
+func selectDualFreq(sys gnss.System, typs []rinex.ObsCode) (dualFreq, bool) {
+	for _, pair := range bandPairs[sys] {
+		var df dualFreq
+		ok := true
+		for i, b := range pair {
+			df.phases[i] = firstType(typs, 'L', b, 0)
+			if df.phases[i] == "" {
+				ok = false
+				break
+			}
+			// a code with the same tracking mode, P-codes first for RINEX-2
+			attr := df.phases[i].Attribute()
+			df.codes[i] = firstType(typs, 'P', b, 0)
+			if df.codes[i] == "" {
+				df.codes[i] = firstType(typs, 'C', b, attr)
+			}
+			if df.codes[i] == "" {
+				df.codes[i] = firstType(typs, 'C', b, 0)
+			}
+		}
+		if ok {
+			if df.codes[0] == "" || df.codes[1] == "" {
+				df.codes = [2]rinex.ObsCode{}
+			}
+			return df, true
+		}
+	}
+	return dualFreq{}, false
+}
+
+// firstType returns the first observation code with the type and band, and the attribute if not 0.
+func firstType(typs []rinex.ObsCode, typ, b, attr byte) rinex.ObsCode {
+	for _, code := range typs {
+		if len(code) < 2 || code[0] != typ || code.Band() != b {
+			continue
+		}
+		if attr != 0 && (len(code) < 3 || code[2] != attr) {
+			continue
+		}
+		return code
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package qc
+
+import (
+	"testing"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/de-bkg/gognss/pkg/rinex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectDualFreq(t *testing.T) {
+	tests := []struct {
+		name   string
+		sys    gnss.System
+		typs   []rinex.ObsCode
+		want   dualFreq
+		wantOK bool
+	}{
+		{name: "gps-v3", sys: gnss.SysGPS, typs: []rinex.ObsCode{"C1C", "L1C", "C1W", "C2W", "L2W", "C2L", "L2L"},
+			want: dualFreq{phases: [2]rinex.ObsCode{"L1C", "L2W"}, codes: [2]rinex.ObsCode{"C1C", "C2W"}}, wantOK: true},
+		{name: "gps-v2", sys: gnss.SysGPS, typs: []rinex.ObsCode{"L1", "L2", "C1", "C2", "P1", "P2"},
+			want: dualFreq{phases: [2]rinex.ObsCode{"L1", "L2"}, codes: [2]rinex.ObsCode{"P1", "P2"}}, wantOK: true},
+		{name: "gps-L5", sys: gnss.SysGPS, typs: []rinex.ObsCode{"C1C", "L1C", "C5Q", "L5Q"},
+			want: dualFreq{phases: [2]rinex.ObsCode{"L1C", "L5Q"}, codes: [2]rinex.ObsCode{"C1C", "C5Q"}}, wantOK: true},
+		{name: "gal-no-codes", sys: gnss.SysGAL, typs: []rinex.ObsCode{"L1C", "L7Q", "C1C"},
+			want: dualFreq{phases: [2]rinex.ObsCode{"L1C", "L7Q"}}, wantOK: true},
+		{name: "bds", sys: gnss.SysBDS, typs: []rinex.ObsCode{"C2I", "L2I", "S2I", "C7I", "L7I", "S7I"},
+			want: dualFreq{phases: [2]rinex.ObsCode{"L2I", "L7I"}, codes: [2]rinex.ObsCode{"C2I", "C7I"}}, wantOK: true},
+		{name: "single-freq", sys: gnss.SysGPS, typs: []rinex.ObsCode{"C1C", "L1C"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			got, ok := selectDualFreq(tt.sys, tt.typs)
+			assert.Equal(tt.wantOK, ok)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestFrequency(t *testing.T) {
+	assert := assert.New(t)
+	gloSlots := map[gnss.PRN]int{{Sys: gnss.SysGLO, Num: 1}: 1, {Sys: gnss.SysGLO, Num: 2}: -4}
+	assert.Equal(1575.42e6, rinex.ObsCode("L1").Frequency(gnss.PRN{Sys: gnss.SysGPS, Num: 1}, gloSlots))
+	assert.Equal(1602.5625e6, rinex.ObsCode("L1").Frequency(gnss.PRN{Sys: gnss.SysGLO, Num: 1}, gloSlots))
+	assert.Equal(1244.25e6, rinex.ObsCode("L2").Frequency(gnss.PRN{Sys: gnss.SysGLO, Num: 2}, gloSlots))
+	assert.Equal(1202.025e6, rinex.ObsCode("L3").Frequency(gnss.PRN{Sys: gnss.SysGLO, Num: 3}, gloSlots))
+	assert.Zero(rinex.ObsCode("L1").Frequency(gnss.PRN{Sys: gnss.SysGLO, Num: 3}, gloSlots), "unknown frequency number")
+	assert.Zero(rinex.ObsCode("L7").Frequency(gnss.PRN{Sys: gnss.SysGPS, Num: 1}, gloSlots))
+}
@@ -0,0 +1,174 @@
+package qc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/de-bkg/gognss/pkg/rinex"
+	"github.com/de-bkg/gognss/pkg/site"
+)
+
+// DefaultPositionTolerance is the default maximum distance in meters between the approximate positions
+// of the RINEX header and the sitelog.
+const DefaultPositionTolerance = 10.0
+
+// eccTolerance is the tolerance for antenna eccentricities in meters, the sitelog gives them with 4 decimals.
+const eccTolerance = 0.00005
+
+// ErrNoEquipment is returned if the sitelog has no receiver or antenna installed at the time of the first observation.
+var ErrNoEquipment = errors.New("qc: no receiver or antenna in sitelog at time of first observation")
+
+// SitelogCheck is the result of the cross-check of a RINEX observation header against the sitelog.
+type SitelogCheck struct {
+	Time       time.Time      `json:"time"`       // The time of the first observation the equipment was looked up for.
+	Receiver   *gnss.Receiver `json:"receiver"`   // The receiver installed at Time.
+	Antenna    *gnss.Antenna  `json:"antenna"`    // The antenna installed at Time.
+	Mismatches []*Mismatch    `json:"mismatches"` // The header fields that do not match the sitelog.
+	Warnings   []string       `json:"warnings"`   // Problems of the sitelog that prevent a comparison, not covered by Edits.
+}
+
+// Mismatch is a header field that differs from the sitelog.
+type Mismatch struct {
+	Field   string `json:"field"`   // The name of the ObsHeader field, e.g. "ReceiverType".
+	Header  any    `json:"header"`  // The value in the RINEX header.
+	Sitelog any    `json:"sitelog"` // The value according to the sitelog.
+}
+
+// String returns the mismatch in a readable form.
+func (m *Mismatch) String() string {
+	return fmt.Sprintf("%s: header %v, sitelog %v", m.Field, m.Header, m.Sitelog)
+}
+
+// OK reports whether the header matches the sitelog.
+func (c *SitelogCheck) OK() bool {
+	return len(c.Mismatches) == 0
+}
+
+// Edits returns the header edits that correct the mismatches, to be used with rinex.EditHeader.
+func (c *SitelogCheck) Edits() rinex.HeaderEdits {
+	edits := rinex.HeaderEdits{}
+	for _, m := range c.Mismatches {
+		edits[m.Field] = m.Sitelog
+	}
+	return edits
+}
+
+// CorrectHeader returns a copy of the header with the mismatching fields set to the values of the sitelog.
+func (c *SitelogCheck) CorrectHeader(hdr *rinex.ObsHeader) rinex.ObsHeader {
+	corr := *hdr
+	v := reflect.ValueOf(&corr).Elem()
+	for _, m := range c.Mismatches {
+		v.FieldByName(m.Field).Set(reflect.ValueOf(m.Sitelog))
+	}
+	return corr
+}
+
+// CheckSitelog cross-checks the RINEX observation header against the sitelog. The receiver and antenna installed
+// at the time of the first observation are compared with the header records REC # / TYPE / VERS, ANT # / TYPE and
+// ANTENNA: DELTA H/E/N. The marker number is compared with the DOMES number, and the approximate position
+// with the one of the sitelog, allowing a distance of posTol meters.
+func CheckSitelog(hdr *rinex.ObsHeader, s *site.Site, posTol float64) (*SitelogCheck, error) {
+	t := hdr.TimeOfFirstObs
+	recv, ant := receiverAt(s.Receivers, t), antennaAt(s.Antennas, t)
+	if recv == nil || ant == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoEquipment, t.Format(time.RFC3339))
+	}
+
+	model, radome := antennaType(ant)
+	xyz := s.Location.ApproximatePosition.CartesianPosition.Coordinates
+	c := &SitelogCheck{Time: t, Receiver: recv, Antenna: ant, Mismatches: []*Mismatch{}, Warnings: []string{}}
+	sl := &rinex.ObsHeader{
+		ReceiverType:    recv.Type,
+		ReceiverNumber:  recv.SerialNum,
+		ReceiverVersion: recv.Firmware,
+		AntennaType:     fmt.Sprintf("%-16s%4s", model, radome),
+		AntennaNumber:   ant.SerialNum,
+		AntennaDelta:    rinex.CoordNEU{N: ant.EccNorth, E: ant.EccEast, Up: ant.EccUp},
+		MarkerNumber:    s.Ident.DOMESNumber,
+		Position:        rinex.Coord{X: xyz[0], Y: xyz[1], Z: xyz[2]},
+	}
+
+	c.compareString("ReceiverType", hdr.ReceiverType, sl.ReceiverType)
+	c.compareString("ReceiverNumber", hdr.ReceiverNumber, sl.ReceiverNumber)
+	c.compareString("ReceiverVersion", hdr.ReceiverVersion, sl.ReceiverVersion)
+	if model == "" {
+		c.Warnings = append(c.Warnings, "antenna type missing in sitelog, header "+strings.TrimSpace(hdr.AntennaType))
+	} else if strings.Join(strings.Fields(hdr.AntennaType), " ") != model+" "+radome {
+		c.addMismatch("AntennaType", hdr.AntennaType, sl.AntennaType)
+	}
+	c.compareString("AntennaNumber", hdr.AntennaNumber, sl.AntennaNumber)
+	d := hdr.AntennaDelta
+	if math.Abs(d.N-ant.EccNorth) > eccTolerance || math.Abs(d.E-ant.EccEast) > eccTolerance || math.Abs(d.Up-ant.EccUp) > eccTolerance {
+		c.addMismatch("AntennaDelta", d, sl.AntennaDelta)
+	}
+	if sl.MarkerNumber != "" {
+		c.compareString("MarkerNumber", hdr.MarkerNumber, sl.MarkerNumber)
+	}
+	if sl.Position != (rinex.Coord{}) {
+		p := hdr.Position
+		if math.Sqrt(math.Pow(p.X-xyz[0], 2)+math.Pow(p.Y-xyz[1], 2)+math.Pow(p.Z-xyz[2], 2)) > posTol {
+			c.addMismatch("Position", p, sl.Position)
+		}
+	}
+	return c, nil
+}
+
+// antennaType returns the antenna model and the radome of the sitelog antenna. The radome is taken from the
+// antenna type, or from the radome field, and defaults to NONE. The model is empty if the type is missing.
+func antennaType(ant *gnss.Antenna) (model, radome string) {
+	fields := strings.Fields(ant.Type)
+	if len(fields) > 0 {
+		model = fields[0]
+	}
+	if len(fields) > 1 {
+		radome = fields[1]
+	} else {
+		radome = ant.Radome
+	}
+	if radome == "" {
+		radome = "NONE"
+	}
+	return model, radome
+}
+
+func (c *SitelogCheck) compareString(field, hdrVal, sitelogVal string) {
+	if !strings.EqualFold(strings.TrimSpace(hdrVal), strings.TrimSpace(sitelogVal)) {
+		c.addMismatch(field, hdrVal, sitelogVal)
+	}
+}
+
+func (c *SitelogCheck) addMismatch(field string, hdrVal, sitelogVal any) {
+	c.Mismatches = append(c.Mismatches, &Mismatch{Field: field, Header: hdrVal, Sitelog: sitelogVal})
+}
+
+// installed reports whether the equipment is installed at the time t.
+func installed(t, from, to time.Time) bool {
+	return !t.Before(from) && (to.IsZero() || t.Before(to))
+}
+
+// receiverAt returns the receiver installed at the time t. The latest installation wins, as sitelogs
+// are not always consistent with the removal dates.
+func receiverAt(receivers []*gnss.Receiver, t time.Time) *gnss.Receiver {
+	for _, recv := range slices.Backward(receivers) {
+		if installed(t, recv.DateInstalled, recv.DateRemoved) {
+			return recv
+		}
+	}
+	return nil
+}
+
+// antennaAt returns the antenna installed at the time t. The latest installation wins.
+func antennaAt(antennas []*gnss.Antenna, t time.Time) *gnss.Antenna {
+	for _, ant := range slices.Backward(antennas) {
+		if installed(t, ant.DateInstalled, ant.DateRemoved) {
+			return ant
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package qc
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/rinex"
+	"github.com/de-bkg/gognss/pkg/site"
+	"github.com/stretchr/testify/assert"
+)
+
+func readSitelog(t *testing.T, path string) *site.Site {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s, err := site.DecodeSitelog(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCheckSitelog(t *testing.T) {
+	assert := assert.New(t)
+	s := readSitelog(t, "../site/testdata/brux_20200225.log")
+
+	tests := []struct {
+		name string
+		file string
+		recv string
+	}{
+		{name: "2018", file: "../rinex/testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", recv: "SEPT POLARX4TR"},
+		{name: "2020", file: "../rinex/testdata/white/BRUX00BEL_R_20202302000_01H_30S_MO.crx", recv: "SEPT POLARX5TR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hdr := newObsDecoder(t, tt.file).Header
+			c, err := CheckSitelog(&hdr, s, DefaultPositionTolerance)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(tt.recv, c.Receiver.Type)
+			assert.Equal("00464", c.Antenna.SerialNum)
+			assert.True(c.OK(), "mismatches: %v", c.Mismatches)
+		})
+	}
+
+	hdr := newObsDecoder(t, "../rinex/testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx").Header
+	hdr.ReceiverVersion = "2.9.5"
+	hdr.AntennaType = "JAVRINGANT_DM   SCIS"
+	hdr.AntennaDelta.Up = 0.4698
+	hdr.MarkerNumber = "13101M004"
+	hdr.Position.X += 100
+	c, err := CheckSitelog(&hdr, s, DefaultPositionTolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(c.OK())
+	fields := []string{}
+	for _, m := range c.Mismatches {
+		fields = append(fields, m.Field)
+	}
+	assert.Equal([]string{"ReceiverVersion", "AntennaType", "AntennaDelta", "MarkerNumber", "Position"}, fields)
+	assert.Equal("ReceiverVersion: header 2.9.5, sitelog 2.9.6", c.Mismatches[0].String())
+	assert.Equal("JAVRINGANT_DM   NONE", c.Mismatches[1].Sitelog)
+
+	corr := c.CorrectHeader(&hdr)
+	assert.Equal("2.9.6", corr.ReceiverVersion)
+	assert.Equal(0.4689, corr.AntennaDelta.Up)
+	assert.Equal("13101M010", corr.MarkerNumber)
+	assert.Equal(4027881.628, corr.Position.X)
+	assert.Equal("2.9.5", hdr.ReceiverVersion, "header unchanged")
+	c2, err := CheckSitelog(&corr, s, DefaultPositionTolerance)
+	assert.NoError(err)
+	assert.True(c2.OK())
+
+	// Correct the file header with the edits.
+	data, err := os.ReadFile("../rinex/testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	changes, err := rinex.EditHeader(&buf, bytes.NewReader(data), c.Edits())
+	assert.NoError(err)
+	assert.Len(changes, 1, "only the position differs from the file")
+	dec, err := rinex.NewObsDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(corr.Position, dec.Header.Position)
+
+	// no equipment
+	hdr.TimeOfFirstObs = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = CheckSitelog(&hdr, s, DefaultPositionTolerance)
+	assert.ErrorIs(err, ErrNoEquipment)
+}
+
+func TestCheckSitelog_antennaType(t *testing.T) {
+	hdr := newObsDecoder(t, "../rinex/testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx").Header
+	tests := []struct {
+		name        string
+		antType     string
+		radome      string
+		wantSitelog any  // the sitelog value of the AntennaType mismatch, nil if there is none
+		wantWarning bool // the type is missing in the sitelog
+	}{
+		{name: "type and radome", antType: "JAVRINGANT_DM   NONE", wantSitelog: nil},
+		{name: "radome field", antType: "JAVRINGANT_DM", radome: "SCIS", wantSitelog: "JAVRINGANT_DM   SCIS"},
+		{name: "no radome", antType: "JAVRINGANT_DM", wantSitelog: nil},
+		{name: "empty type", antType: "", radome: "NONE", wantSitelog: nil, wantWarning: true},
+		{name: "empty antenna", antType: "", wantSitelog: nil, wantWarning: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			s := readSitelog(t, "../site/testdata/brux_20200225.log")
+			ant := antennaAt(s.Antennas, hdr.TimeOfFirstObs)
+			ant.Type, ant.Radome = tt.antType, tt.radome
+
+			c, err := CheckSitelog(&hdr, s, DefaultPositionTolerance)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got any
+			for _, m := range c.Mismatches {
+				if m.Field == "AntennaType" {
+					got = m.Sitelog
+				}
+			}
+			assert.Equal(tt.wantSitelog, got)
+			if tt.wantWarning {
+				assert.Equal([]string{"antenna type missing in sitelog, header JAVRINGANT_DM   NONE"}, c.Warnings)
+				_, ok := c.Edits()["AntennaType"]
+				assert.False(ok, "no edit")
+				assert.Equal(hdr.AntennaType, c.CorrectHeader(&hdr).AntennaType)
+			} else {
+				assert.Empty(c.Warnings)
+			}
+		})
+	}
+}
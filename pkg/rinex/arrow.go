@@ -0,0 +1,264 @@
+package rinex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"slices"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// The Apache Arrow IPC file format, see https://arrow.apache.org/docs/format/Columnar.html#ipc-file-format.
+// The flatbuffers metadata follows Schema.fbs and Message.fbs of the Arrow format, version V5.
+
+// arrowMagic starts and ends an Arrow IPC file.
+const arrowMagic = "ARROW1"
+
+const arrowMetadataV5 = 4 // MetadataVersion V5
+
+// The Arrow message header types (MessageHeader union).
+const (
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+)
+
+// The Arrow data types (Type union).
+const (
+	arrowTypeInt           = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeUtf8          = 5
+	arrowTypeTimestamp     = 10
+)
+
+const (
+	arrowPrecisionDouble = 2 // Precision DOUBLE
+	arrowUnitNanosecond  = 3 // TimeUnit NANOSECOND
+)
+
+// arrowBlock is the position of a record batch in the file, for the footer.
+type arrowBlock struct {
+	offset     int64
+	metaLength int32
+	bodyLength int64
+}
+
+// arrowWriter writes an Arrow IPC file with a record batch per batchRows rows.
+type arrowWriter struct {
+	w         *countWriter
+	batchRows int
+	batch     *columnBatch
+	blocks    []arrowBlock
+}
+
+func (aw *arrowWriter) writeHeader(cols []column) error {
+	aw.batch = newColumnBatch(cols)
+	aw.w.Write([]byte(arrowMagic + "\x00\x00"))
+	_, err := aw.writeMessage(arrowHeaderSchema, aw.buildSchema, nil)
+	return err
+}
+
+func (aw *arrowWriter) writeRow(row []any) error {
+	if err := aw.batch.append(row); err != nil {
+		return err
+	}
+	if aw.batch.n == aw.batchRows {
+		return aw.writeBatch()
+	}
+	return nil
+}
+
+func (aw *arrowWriter) flush() error {
+	if aw.batch.n > 0 {
+		if err := aw.writeBatch(); err != nil {
+			return err
+		}
+	}
+	// end-of-stream marker
+	aw.w.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+
+	b := flatbuffers.NewBuilder(1024)
+	schema := aw.buildSchema(b)
+	b.StartVector(24, len(aw.blocks), 8)
+	for _, blk := range slices.Backward(aw.blocks) {
+		b.Prep(8, 24)
+		b.PrependInt64(blk.bodyLength)
+		b.Pad(4)
+		b.PrependInt32(blk.metaLength)
+		b.PrependInt64(blk.offset)
+	}
+	batches := b.EndVector(len(aw.blocks))
+	dicts := emptyVector(b)
+	b.StartObject(5) // Footer
+	b.PrependInt16Slot(0, arrowMetadataV5, 0)
+	b.PrependUOffsetTSlot(1, schema, 0)
+	b.PrependUOffsetTSlot(2, dicts, 0)
+	b.PrependUOffsetTSlot(3, batches, 0)
+	b.Finish(b.EndObject())
+	footer := b.FinishedBytes()
+	aw.w.Write(footer)
+	aw.w.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	aw.w.Write([]byte(arrowMagic))
+	return aw.w.w.Flush()
+}
+
+// writeBatch writes the buffered rows as record batch.
+func (aw *arrowWriter) writeBatch() error {
+	var body bytes.Buffer
+	type buffer struct{ offset, length int64 }
+	buffers := make([]buffer, 0, 3*len(aw.batch.cols))
+	addBuffer := func(data []byte) {
+		buffers = append(buffers, buffer{offset: int64(body.Len()), length: int64(len(data))})
+		body.Write(data)
+		body.Write(make([]byte, pad8(len(data))))
+	}
+
+	n := aw.batch.n
+	for i, col := range aw.batch.cols {
+		cd := &aw.batch.data[i]
+		if cd.nulls > 0 {
+			bitmap := make([]byte, (n+7)/8)
+			for j, valid := range cd.valid {
+				if valid {
+					bitmap[j/8] |= 1 << (j % 8)
+				}
+			}
+			addBuffer(bitmap)
+		} else {
+			addBuffer(nil)
+		}
+		var data []byte
+		switch col.typ {
+		case colTime:
+			data = make([]byte, 0, 8*n)
+			for _, v := range cd.ints {
+				data = binary.LittleEndian.AppendUint64(data, uint64(v))
+			}
+		case colInt8:
+			data = make([]byte, 0, n)
+			for _, v := range cd.ints {
+				data = append(data, byte(int8(v)))
+			}
+		case colFloat64:
+			data = make([]byte, 0, 8*n)
+			for _, v := range cd.floats {
+				data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+			}
+		case colString:
+			offsets := make([]byte, 0, 4*(n+1))
+			offsets = binary.LittleEndian.AppendUint32(offsets, 0)
+			for _, s := range cd.strs {
+				data = append(data, s...)
+				offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+			}
+			addBuffer(offsets)
+		}
+		addBuffer(data)
+	}
+
+	buildBatch := func(b *flatbuffers.Builder) flatbuffers.UOffsetT {
+		b.StartVector(16, len(aw.batch.cols), 8)
+		for i := len(aw.batch.cols) - 1; i >= 0; i-- {
+			b.Prep(8, 16) // FieldNode
+			b.PrependInt64(int64(aw.batch.data[i].nulls))
+			b.PrependInt64(int64(n))
+		}
+		nodes := b.EndVector(len(aw.batch.cols))
+		b.StartVector(16, len(buffers), 8)
+		for _, buf := range slices.Backward(buffers) {
+			b.Prep(8, 16) // Buffer
+			b.PrependInt64(buf.length)
+			b.PrependInt64(buf.offset)
+		}
+		bufs := b.EndVector(len(buffers))
+		b.StartObject(5) // RecordBatch
+		b.PrependInt64Slot(0, int64(n), 0)
+		b.PrependUOffsetTSlot(1, nodes, 0)
+		b.PrependUOffsetTSlot(2, bufs, 0)
+		return b.EndObject()
+	}
+	offset := aw.w.n
+	metaLength, err := aw.writeMessage(arrowHeaderRecordBatch, buildBatch, body.Bytes())
+	aw.blocks = append(aw.blocks, arrowBlock{offset: offset, metaLength: metaLength, bodyLength: int64(body.Len())})
+	aw.batch.reset()
+	return err
+}
+
+// writeMessage writes an encapsulated message with the header built by buildHeader and the body.
+// It returns the length of the metadata including its prefix and padding.
+func (aw *arrowWriter) writeMessage(headerType byte, buildHeader func(b *flatbuffers.Builder) flatbuffers.UOffsetT, body []byte) (int32, error) {
+	b := flatbuffers.NewBuilder(1024)
+	header := buildHeader(b)
+	b.StartObject(5) // Message
+	b.PrependInt16Slot(0, arrowMetadataV5, 0)
+	b.PrependByteSlot(1, headerType, 0)
+	b.PrependUOffsetTSlot(2, header, 0)
+	b.PrependInt64Slot(3, int64(len(body)), 0)
+	b.Finish(b.EndObject())
+	meta := b.FinishedBytes()
+
+	// The metadata is padded, so that the body starts at a multiple of 8 bytes.
+	padding := pad8(8 + len(meta))
+	aw.w.Write(binary.LittleEndian.AppendUint32([]byte{0xff, 0xff, 0xff, 0xff}, uint32(len(meta)+padding)))
+	aw.w.Write(meta)
+	aw.w.Write(make([]byte, padding))
+	_, err := aw.w.Write(body) // the errors of the buffered writer are sticky
+	return int32(8 + len(meta) + padding), err
+}
+
+// buildSchema builds the Schema table with the fields of the columns.
+func (aw *arrowWriter) buildSchema(b *flatbuffers.Builder) flatbuffers.UOffsetT {
+	fields := make([]flatbuffers.UOffsetT, len(aw.batch.cols))
+	for i, col := range aw.batch.cols {
+		name := b.CreateString(col.name)
+		var typeType byte
+		var typ flatbuffers.UOffsetT
+		switch col.typ {
+		case colTime:
+			tz := b.CreateString("UTC")
+			b.StartObject(2) // Timestamp
+			b.PrependInt16Slot(0, arrowUnitNanosecond, 0)
+			b.PrependUOffsetTSlot(1, tz, 0)
+			typeType, typ = arrowTypeTimestamp, b.EndObject()
+		case colString:
+			b.StartObject(0) // Utf8
+			typeType, typ = arrowTypeUtf8, b.EndObject()
+		case colFloat64:
+			b.StartObject(1) // FloatingPoint
+			b.PrependInt16Slot(0, arrowPrecisionDouble, 0)
+			typeType, typ = arrowTypeFloatingPoint, b.EndObject()
+		case colInt8:
+			b.StartObject(2) // Int
+			b.PrependInt32Slot(0, 8, 0)
+			b.PrependBoolSlot(1, true, false)
+			typeType, typ = arrowTypeInt, b.EndObject()
+		}
+		children := emptyVector(b)
+		b.StartObject(7) // Field
+		b.PrependUOffsetTSlot(0, name, 0)
+		b.PrependBoolSlot(1, col.nullable, false)
+		b.PrependByteSlot(2, typeType, 0)
+		b.PrependUOffsetTSlot(3, typ, 0)
+		b.PrependUOffsetTSlot(5, children, 0)
+		fields[i] = b.EndObject()
+	}
+	b.StartVector(4, len(fields), 4)
+	for _, field := range slices.Backward(fields) {
+		b.PrependUOffsetT(field)
+	}
+	fieldsVec := b.EndVector(len(fields))
+	b.StartObject(4) // Schema, little endian by default
+	b.PrependUOffsetTSlot(1, fieldsVec, 0)
+	return b.EndObject()
+}
+
+// emptyVector creates an empty vector for the required vectors of the Arrow metadata.
+func emptyVector(b *flatbuffers.Builder) flatbuffers.UOffsetT {
+	b.StartVector(4, 0, 4)
+	return b.EndVector(0)
+}
+
+// pad8 returns the number of bytes to pad n to a multiple of 8.
+func pad8(n int) int {
+	return (8 - n%8) % 8
+}
@@ -0,0 +1,190 @@
+package rinex
+
+import (
+	"bufio"
+	"bytes"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// defaultChunkEpochs is the number of epochs per chunk in parallel decoding.
+const defaultChunkEpochs = 100
+
+// chunkDecoder splits the data of RINEX-3/4 observation files at the epoch lines into chunks,
+// which are decoded in parallel.
+type chunkDecoder struct {
+	workers     int      // the number of chunks decoded in parallel
+	chunkEpochs int      // the number of epochs per chunk
+	queue       []*Epoch // the decoded epochs not yet returned
+	pending     []byte   // the epoch line starting the next chunk
+	pendingLine int      // the line number of the pending line
+	chunkSize   int      // the size of the last chunk in bytes, to preallocate the next chunk
+	done        bool
+}
+
+// chunk is a part of the data starting with an epoch line, and the result of its decoding.
+type chunk struct {
+	data      []byte
+	startLine int
+	epochs    []*Epoch
+	warnings  []*RinexError
+	errs      []error
+	changed   *ObsDecoder // the decoder at the end of the chunk, if an event changed the obs types or scale factors
+}
+
+// SetWorkers sets the number of workers for parallel decoding. With n > 1 the data is split at the epoch lines into
+// chunks, that are decoded by n workers in parallel. The epochs are still returned in order.
+// Parallel decoding is supported for RINEX version 3 and higher, older versions are decoded sequentially.
+// If a special event changes the observation types or scale factors, the chunks following the event are decoded
+// again with the changed settings.
+// SetWorkers must be called before the first call to NextEpoch.
+func (dec *ObsDecoder) SetWorkers(n int) {
+	if n < 2 {
+		dec.chunks = nil
+		return
+	}
+	dec.chunks = &chunkDecoder{workers: n, chunkEpochs: defaultChunkEpochs}
+}
+
+// nextChunked returns the next epoch of the decoded chunks. If all epochs are returned, the next chunks are decoded.
+func (dec *ObsDecoder) nextChunked() bool {
+	cd := dec.chunks
+	for len(cd.queue) == 0 {
+		if cd.done {
+			return false
+		}
+		dec.decodeChunks()
+	}
+	dec.epo = cd.queue[0]
+	cd.queue[0] = nil
+	cd.queue = cd.queue[1:]
+	dec.updateHeader(dec.epo)
+	return true
+}
+
+// decodeChunks reads up to one chunk per worker and decodes the chunks in parallel.
+func (dec *ObsDecoder) decodeChunks() {
+	cd := dec.chunks
+	chunks := make([]*chunk, 0, cd.workers)
+	for range cd.workers {
+		c := dec.readChunk()
+		if len(c.data) == 0 {
+			cd.done = true
+			break
+		}
+		chunks = append(chunks, c)
+	}
+
+	from := dec
+	for len(chunks) > 0 {
+		var wg sync.WaitGroup
+		for _, c := range chunks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				dec.decodeChunk(from, c)
+			}()
+		}
+		wg.Wait()
+
+		rest := chunks[len(chunks):]
+		for i, c := range chunks {
+			cd.queue = append(cd.queue, c.epochs...)
+			dec.warnings = append(dec.warnings, c.warnings...)
+			if len(c.errs) > 0 {
+				for _, err := range c.errs {
+					dec.setErr(err)
+				}
+				cd.done = true
+				return
+			}
+			c.data = nil
+			if c.changed != nil {
+				// The following chunks were decoded with outdated obs types or scale factors.
+				from, rest = c.changed, chunks[i+1:]
+				break
+			}
+		}
+		chunks = rest
+	}
+}
+
+// readChunk reads the lines of the next chunkEpochs epochs.
+func (dec *ObsDecoder) readChunk() *chunk {
+	cd := dec.chunks
+	c := &chunk{startLine: dec.lineNum + 1, data: make([]byte, 0, cd.chunkSize+cd.chunkSize/8)}
+	nEpochs := 0
+	if cd.pending != nil {
+		c.data = append(append(c.data, cd.pending...), '\n')
+		c.startLine = cd.pendingLine
+		cd.pending = nil
+		nEpochs++
+	}
+	for dec.readLine() {
+		line := dec.sc.Bytes()
+		if bytes.HasPrefix(line, []byte("> ")) {
+			if nEpochs == cd.chunkEpochs {
+				cd.pending = bytes.Clone(line)
+				cd.pendingLine = dec.lineNum
+				cd.chunkSize = len(c.data)
+				return c
+			}
+			nEpochs++
+		}
+		c.data = append(append(c.data, line...), '\n')
+	}
+	if err := dec.sc.Err(); err != nil {
+		dec.setErr(dec.readError(dec.lineNum, err))
+	}
+	return c
+}
+
+// decodeChunk decodes the epochs of the chunk with a decoder having the settings of dec and the obs types,
+// scale factors and running header of from.
+func (dec *ObsDecoder) decodeChunk(from *ObsDecoder, c *chunk) {
+	sub := &ObsDecoder{
+		Header:      dec.Header,
+		sc:          bufio.NewScanner(bytes.NewReader(c.data)),
+		obsTypes:    from.obsTypes,
+		scales:      from.scales,
+		lineNum:     c.startLine - 1,
+		indexed:     dec.indexed,
+		decoderDiag: decoderDiag{filename: dec.filename, lenient: dec.lenient},
+	}
+	if from.running != nil {
+		sub.running = from.running.clone()
+	}
+	c.epochs, c.warnings, c.errs, c.changed = nil, nil, nil, nil
+	for sub.nextEpoch() {
+		c.epochs = append(c.epochs, sub.epo)
+	}
+	c.warnings = sub.warnings
+	if err := sub.Err(); err != nil {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			c.errs = joined.Unwrap()
+		} else {
+			c.errs = []error{err}
+		}
+	}
+	if slices.ContainsFunc(c.epochs, changesObsTypes) {
+		c.changed = sub
+	}
+}
+
+// changesObsTypes reports whether the epoch is a special event changing the observation types or scale factors.
+func changesObsTypes(epo *Epoch) bool {
+	if epo.Flag != EpochFlagNewSite && epo.Flag != EpochFlagHeaderInfo {
+		return false
+	}
+	return slices.ContainsFunc(epo.Records, func(line string) bool {
+		if len(line) < 60 {
+			return false
+		}
+		switch strings.TrimSpace(line[60:]) {
+		case "SYS / # / OBS TYPES", "# / TYPES OF OBSERV", "SYS / SCALE FACTOR":
+			return true
+		}
+		return false
+	})
+}
@@ -0,0 +1,130 @@
+package rinex
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeEpochs decodes all epochs of the data, with setup configuring the decoder.
+func decodeEpochs(t testing.TB, data string, setup func(dec *ObsDecoder)) ([]*Epoch, *ObsDecoder) {
+	dec, err := NewObsDecoder(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup(dec)
+	epochs := []*Epoch{}
+	for dec.NextEpoch() {
+		epochs = append(epochs, dec.Epoch())
+	}
+	return epochs, dec
+}
+
+// setParallel sets parallel decoding with small chunks, to have many chunk boundaries.
+func setParallel(dec *ObsDecoder) {
+	dec.SetWorkers(3)
+	if dec.chunks != nil {
+		dec.chunks.chunkEpochs = 7
+	}
+}
+
+func TestObsDecoder_SetWorkers(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "v3", file: "testdata/white/REYK00ISL_R_20192701000_01H_30S_MO.rnx"},
+		{name: "crx", file: "testdata/white/BRUX00BEL_R_20202302000_01H_30S_MO.crx"},
+		{name: "v2", file: "testdata/white/brst155h.20o"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			data, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, dec := decodeEpochs(t, string(data), func(dec *ObsDecoder) {})
+			assert.NoError(dec.Err())
+			got, dec := decodeEpochs(t, string(data), setParallel)
+			assert.NoError(dec.Err())
+			assert.Equal(len(want), len(got))
+			assert.Equal(want, got)
+
+			// with filter
+			filt := ObsFilter{Start: want[10].Time, End: want[20].Time}
+			got, dec = decodeEpochs(t, string(data), func(dec *ObsDecoder) {
+				setParallel(dec)
+				dec.SetFilter(filt)
+			})
+			assert.NoError(dec.Err())
+			assert.Equal(want[10:21], got)
+		})
+	}
+}
+
+func TestObsDecoder_SetWorkers_errors(t *testing.T) {
+	assert := assert.New(t)
+	data, err := os.ReadFile("testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// corrupt the epoch flag of the 20th and 50th epoch
+	corrupt := strings.Replace(string(data), "> 2018 11 06 19 09 30.0000000  0", "> 2018 11 06 19 09 30.0000000  x", 1)
+	corrupt = strings.Replace(corrupt, "> 2018 11 06 19 24 30.0000000  0", "> 2018 11 06 19 24 30.0000000  x", 1)
+
+	want, wantDec := decodeEpochs(t, corrupt, func(dec *ObsDecoder) {})
+	got, gotDec := decodeEpochs(t, corrupt, setParallel)
+	assert.Len(got, 19)
+	assert.Equal(want, got)
+	assert.Error(gotDec.Err())
+	assert.Equal(wantDec.Err(), gotDec.Err())
+
+	lenient := func(dec *ObsDecoder) { dec.SetLenient(true) }
+	want, wantDec = decodeEpochs(t, corrupt, lenient)
+	got, gotDec = decodeEpochs(t, corrupt, func(dec *ObsDecoder) {
+		lenient(dec)
+		setParallel(dec)
+	})
+	assert.Len(got, 118)
+	assert.Equal(want, got)
+	assert.NoError(gotDec.Err())
+	assert.Len(gotDec.Warnings(), 2)
+	assert.Equal(wantDec.Warnings(), gotDec.Warnings())
+}
+
+func BenchmarkReadEpochs_parallel(b *testing.B) {
+	benchmarkReadEpochs(b, func(dec *ObsDecoder) { dec.SetWorkers(4) })
+}
+
+func BenchmarkReadEpochs_indexed(b *testing.B) {
+	benchmarkReadEpochs(b, func(dec *ObsDecoder) { dec.SetIndexed(true) })
+}
+
+func BenchmarkReadEpochs_parallelIndexed(b *testing.B) {
+	benchmarkReadEpochs(b, func(dec *ObsDecoder) {
+		dec.SetWorkers(4)
+		dec.SetIndexed(true)
+	})
+}
+
+func benchmarkReadEpochs(b *testing.B, setup func(dec *ObsDecoder)) {
+	b.ReportAllocs()
+	data, err := os.ReadFile("testdata/white/REYK00ISL_S_20192701000_01H_30S_MO.rnx")
+	if err != nil {
+		b.Fatalf("%v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, dec := decodeEpochs(b, string(data), setup)
+		if err := dec.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadEpochs_sequential(b *testing.B) {
+	benchmarkReadEpochs(b, func(dec *ObsDecoder) {})
+}
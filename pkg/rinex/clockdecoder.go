@@ -35,14 +35,29 @@ type ClockHeader struct {
 	Labels   []string // all Header Labels found
 }
 
+// ClockRecord is a RINEX clock data record.
+type ClockRecord struct {
+	Type   string    // The data type: AR, AS, CR, DR or MS.
+	Name   string    // The receiver or satellite name, e.g. "ZIMM00CHE" or "G01".
+	Time   time.Time // The epoch time.
+	Values []float64 // The clock bias, its sigma, the rate, its sigma, the acceleration and its sigma, as far as given.
+}
+
+// GetTime returns the epoch time of the record.
+func (rec *ClockRecord) GetTime() time.Time {
+	return rec.Time
+}
+
 // ClockDecoder reads and decodes from a RINEX Clock input stream.
 type ClockDecoder struct {
 	// The Header is valid after NewClockDecoder or Reader.Reset. The header must exist,
 	// otherwise ErrNoHeader will be returned.
 	Header  *ClockHeader
 	sc      *bufio.Scanner
+	rec     *ClockRecord // the current record
 	lineNum int
 	err     error
+	decoderDiag
 }
 
 // NewClockDecoder returns a new RINEX clock decoder that reads from r.
@@ -51,6 +66,7 @@ type ClockDecoder struct {
 // It is the caller's responsibility to call Close on the underlying reader when done!
 func NewClockDecoder(r io.Reader) (*ClockDecoder, error) {
 	dec := &ClockDecoder{sc: bufio.NewScanner(r)}
+	dec.init(r)
 	dec.Header, dec.err = dec.readHeader()
 	return dec, dec.err
 }
@@ -79,14 +95,14 @@ func (dec *ClockDecoder) readHeaderVersion() (*ClockHeader, error) {
 	dec.readLine()
 	line := dec.line()
 	if !strings.Contains(line, "RINEX VERSION") {
-		return nil, fmt.Errorf("parse RINEX VERSION failed: %q", line)
+		return nil, dec.newError(dec.lineNum, "RINEX VERSION / TYPE", 61, 80, fmt.Errorf("invalid first header line: %q", line))
 	}
 
 	// RINEX Version
 	if f64, err := strconv.ParseFloat(strings.TrimSpace(line[:9]), 32); err == nil {
 		hdr.RINEXVersion = float32(f64)
 	} else {
-		return nil, fmt.Errorf("parse RINEX VERSION: %v", err)
+		return nil, dec.newError(dec.lineNum, "RINEX VERSION / TYPE", 1, 9, err)
 	}
 
 	// RINEX Filetype
@@ -97,7 +113,7 @@ func (dec *ClockDecoder) readHeaderVersion() (*ClockHeader, error) {
 	}
 
 	if hdr.RINEXType != "C" {
-		return nil, fmt.Errorf("invalid RINEX TYPE: %q", hdr.RINEXType)
+		return nil, dec.newError(dec.lineNum, "RINEX VERSION / TYPE", 21, 22, fmt.Errorf("invalid RINEX TYPE: %q", hdr.RINEXType))
 	}
 
 	// Satellite system
@@ -112,7 +128,7 @@ func (dec *ClockDecoder) readHeaderVersion() (*ClockHeader, error) {
 		if s, ok := gnss.ByAbbr[sys]; ok {
 			hdr.SatSystem = s
 		} else {
-			return nil, fmt.Errorf("read header: invalid satellite system: %q", sys)
+			return nil, dec.newError(dec.lineNum, "RINEX VERSION / TYPE", 41, 43, fmt.Errorf("invalid satellite system: %q", sys))
 		}
 	}
 
@@ -159,7 +175,7 @@ readln:
 		case "# OF SOLN SATS":
 			nSats, err := strconv.Atoi(strings.TrimSpace(val[:6]))
 			if err != nil {
-				return hdr, fmt.Errorf("parse %q: %v", key, err)
+				return hdr, dec.newError(dec.lineNum, key, 1, 6, err)
 			}
 			hdr.NumSolnSats = nSats
 		case "SOLN STA NAME / NUM":
@@ -219,7 +235,7 @@ readln:
 		case "# OF SOLN SATS":
 			nSats, err := strconv.Atoi(strings.TrimSpace(val[:6]))
 			if err != nil {
-				return hdr, fmt.Errorf("parse %q: %v", key, err)
+				return hdr, dec.newError(dec.lineNum, key, 1, 6, err)
 			}
 			hdr.NumSolnSats = nSats
 		case "SOLN STA NAME / NUM":
@@ -266,6 +282,97 @@ func (dec *ClockDecoder) setErr(err error) {
 	dec.err = errors.Join(dec.err, err)
 }
 
+// SetLenient sets the lenient mode. In lenient mode a corrupt record is skipped and added to the warnings.
+// By default the decoder stops at the first corrupt record.
+func (dec *ClockDecoder) SetLenient(lenient bool) {
+	dec.lenient = lenient
+}
+
+// Warnings returns the warnings that occurred while decoding the data, e.g. the records skipped in lenient mode.
+func (dec *ClockDecoder) Warnings() []*RinexError {
+	return dec.warnings
+}
+
+// NextRecord reads the next clock data record.
+// It returns false when the scan stops, either by reaching the end of the input or an error.
+func (dec *ClockDecoder) NextRecord() bool {
+	for dec.readLine() {
+		line := dec.line()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		rec, err := dec.parseRecord(line)
+		if err != nil {
+			e := dec.newError(dec.lineNum, recordClock, 0, 0, err)
+			if !dec.lenient {
+				dec.setErr(e)
+				return false
+			}
+			dec.warn(e)
+			continue
+		}
+		dec.rec = rec
+		return true
+	}
+
+	if err := dec.sc.Err(); err != nil {
+		dec.setErr(dec.readError(dec.lineNum, err))
+	}
+	return false // EOF
+}
+
+// Record returns the most recent clock data record generated by a call to NextRecord.
+func (dec *ClockDecoder) Record() *ClockRecord {
+	return dec.rec
+}
+
+// parseRecord parses a clock data record starting in line. The values exceeding the first line
+// are read from the continuation line.
+func (dec *ClockDecoder) parseRecord(line string) (*ClockRecord, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 10 {
+		return nil, fmt.Errorf("invalid clock data record: %q", line)
+	}
+
+	rec := &ClockRecord{Type: fields[0], Name: fields[1]}
+	var date [5]int
+	for i := range date {
+		n, err := strconv.Atoi(fields[2+i])
+		if err != nil {
+			return nil, fmt.Errorf("parse epoch: %v", err)
+		}
+		date[i] = n
+	}
+	sec, err := strconv.ParseFloat(fields[7], 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse epoch: %v", err)
+	}
+	rec.Time = time.Date(date[0], time.Month(date[1]), date[2], date[3], date[4], 0, 0, time.UTC).Add(time.Duration(sec * float64(time.Second)))
+
+	nVals, err := strconv.Atoi(fields[8])
+	if err != nil {
+		return nil, fmt.Errorf("parse number of values: %v", err)
+	}
+	vals := fields[9:]
+	if nVals > 2 && len(vals) < nVals {
+		if !dec.readLine() {
+			return nil, fmt.Errorf("missing continuation line")
+		}
+		vals = append(vals, strings.Fields(dec.line())...)
+	}
+	if len(vals) < nVals {
+		return nil, fmt.Errorf("expected %d values, got %d", nVals, len(vals))
+	}
+	rec.Values = make([]float64, nVals)
+	for i := range nVals {
+		if rec.Values[i], err = parseFloat(vals[i]); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
 // readLine reads the next line into buffer. It returns false if an error
 // occurs or EOF was reached.
 func (dec *ClockDecoder) readLine() bool {
@@ -138,3 +138,70 @@ R21 R22 R23 R24 R26                                              PRN LIST
 		})
 	}
 } */
+
+func TestClockDecoder_headerError(t *testing.T) {
+	const header = `     3.00           C                                       RINEX VERSION / TYPE
+CCLOCK              IGSACC @ GA MIT                         PGM / RUN BY / DATE
+    3x                                                      # OF SOLN SATS
+                                                            END OF HEADER
+`
+	assert := assert.New(t)
+	_, err := NewClockDecoder(strings.NewReader(header))
+	assert.ErrorIs(err, ErrParser)
+	var rerr *RinexError
+	if assert.ErrorAs(err, &rerr) {
+		assert.Equal(3, rerr.Line)
+		assert.Equal(1, rerr.StartCol)
+		assert.Equal(6, rerr.EndCol)
+		assert.Equal("# OF SOLN SATS", rerr.Record)
+	}
+}
+
+func TestClockDecoder_ClockRecords(t *testing.T) {
+	const data = `3.04                 C                    M                      RINEX VERSION / TYPE
+CCRNXC V5.3          AIUB                 21-AUG-20 05:54        PGM / RUN BY / DATE
+   GPS                                                           TIME SYSTEM ID
+     2    AR    AS                                               # / TYPES OF DATA
+                                                                 END OF HEADER
+AR TIDB00AUS 2020 08 16 00 00  0.000000  2    0.000000000000E+00  0.000000000000E+00
+AR ZIMM00CHE 2020 08 16 00 00  0.000000  2   -1.391562883451E-08  1.264970000000E-11
+AS G01       2020 08 16 00 00  5.000000  4   -1.818397614937E-04  1.153200000000E-11
+    -1.209000000000E-12  1.100000000000E-14
+AS R01       2020 08 16 00 05  0.000000  1    3.087456281540E-05
+`
+	assert := assert.New(t)
+	dec, err := NewClockDecoder(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs := []*ClockRecord{}
+	for rec, err := range dec.ClockRecords() {
+		assert.NoError(err)
+		recs = append(recs, rec)
+	}
+	if assert.Len(recs, 4) {
+		assert.Equal(&ClockRecord{Type: "AR", Name: "ZIMM00CHE", Time: time.Date(2020, 8, 16, 0, 0, 0, 0, time.UTC),
+			Values: []float64{-1.391562883451e-08, 1.26497e-11}}, recs[1])
+		assert.Equal("G01", recs[2].Name)
+		assert.Equal(time.Date(2020, 8, 16, 0, 0, 5, 0, time.UTC), recs[2].Time)
+		assert.Equal([]float64{-1.818397614937e-04, 1.1532e-11, -1.209e-12, 1.1e-14}, recs[2].Values)
+		assert.Equal([]float64{3.08745628154e-05}, recs[3].Values)
+	}
+
+	// corrupt record
+	corrupt := strings.Replace(data, "AS G01       2020 08 16", "AS G01       2020 x8 16", 1)
+	dec, err = NewClockDecoder(strings.NewReader(corrupt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for dec.NextRecord() {
+		n++
+	}
+	assert.Equal(2, n)
+	var rerr *RinexError
+	if assert.ErrorAs(dec.Err(), &rerr) {
+		assert.Equal(8, rerr.Line)
+		assert.Equal("CLOCK DATA", rerr.Record)
+	}
+}
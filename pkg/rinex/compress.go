@@ -0,0 +1,446 @@
+package rinex
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Magic bytes of the supported compression formats.
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicZ     = []byte{0x1f, 0x9d}
+	magicBzip2 = []byte("BZh")
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ErrUnknownCompression is returned for compressed data with an unsupported format.
+var ErrUnknownCompression = errors.New("rinex: unknown compression format")
+
+// OpenFile opens the named RINEX file for reading. Compressed files (gzip, Unix compress .Z, bzip2 and zstd)
+// are decompressed and Compact RINEX (Hatanaka) data is converted to RINEX on the fly.
+// The compression format is detected by the magic bytes, or by the filename extension if there are none.
+//
+// It is the caller's responsibility to call Close on the returned reader when done.
+func OpenFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rc := &multiReadCloser{name: path, closers: []io.Closer{f}}
+
+	br := bufio.NewReader(f)
+	comp, err := detectCompression(br, path)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r, err := decompress(br, comp)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("rinex: open %s: %w", path, err)
+	}
+	if c, ok := r.(io.Closer); ok {
+		rc.closers = append(rc.closers, c)
+	}
+
+	// Compact RINEX
+	br = bufio.NewReader(r)
+	if isCrx(br) {
+		cr, err := NewCrxReader(br)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		rc.Reader = cr
+		return rc, nil
+	}
+	rc.Reader = br
+	return rc, nil
+}
+
+// detectCompression returns the compression format of the data in br, like "gz", "Z", "bz2" or "zst".
+// The filename extension is used if the magic bytes do not specify a format. It returns an empty string
+// for uncompressed data.
+func detectCompression(br *bufio.Reader, path string) (string, error) {
+	b, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	switch {
+	case bytes.HasPrefix(b, magicGzip):
+		return "gz", nil
+	case bytes.HasPrefix(b, magicZ):
+		return "Z", nil
+	case bytes.HasPrefix(b, magicBzip2):
+		return "bz2", nil
+	case bytes.HasPrefix(b, magicZstd):
+		return "zst", nil
+	}
+
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	switch strings.ToLower(ext) {
+	case "gz", "gzip", "bz2", "zst":
+		return strings.ToLower(ext), nil
+	case "z":
+		return "Z", nil
+	}
+	return "", nil
+}
+
+// decompress returns a reader that decompresses r with the compression format comp.
+func decompress(r io.Reader, comp string) (io.Reader, error) {
+	switch comp {
+	case "":
+		return r, nil
+	case "gz", "gzip":
+		return gzip.NewReader(r)
+	case "Z":
+		return NewZReader(r)
+	case "bz2":
+		return bzip2.NewReader(r), nil
+	case "zst":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownCompression, comp)
+}
+
+// multiReadCloser reads from its reader and closes all closers in reverse order.
+type multiReadCloser struct {
+	io.Reader
+	name    string
+	closers []io.Closer
+}
+
+// Name returns the name of the file as presented to OpenFile.
+func (rc *multiReadCloser) Name() string {
+	return rc.name
+}
+
+// Close closes all underlying readers.
+func (rc *multiReadCloser) Close() error {
+	var errs []error
+	for i := len(rc.closers) - 1; i >= 0; i-- {
+		errs = append(errs, rc.closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Parameters of the Unix compress (.Z) format.
+const (
+	zInitBits  = 9   // initial number of bits per code
+	zMaxBits   = 16  // maximum number of bits per code
+	zClear     = 256 // code to clear the string table in block mode
+	zBlockMode = 0x80
+	zBitsMask  = 0x1f
+)
+
+// ZReader decompresses data in the Unix compress (.Z) format, the LZW variant used by the
+// compress(1) utility. Go's compress/lzw package does not support this format.
+type ZReader struct {
+	r         *bufio.Reader
+	maxBits   uint
+	blockMode bool
+
+	// bit input
+	bits      uint32
+	nBits     uint
+	codeBits  uint // the current code width
+	nCodes    int  // the number of codes read with the current code width
+	freeEntry int  // the next free entry of the string table
+	maxCode   int  // the maximum code for the current code width
+	prefix    []uint16
+	suffix    []byte
+	oldCode   int
+	finChar   byte
+	stack     []byte // the decoded string, reversed
+	buf       []byte
+	out       []byte // decoded data not yet read
+	err       error
+}
+
+// NewZReader creates a new reader that decompresses the Unix compress (.Z) data from r.
+func NewZReader(r io.Reader) (*ZReader, error) {
+	br := bufio.NewReader(r)
+	hdr := make([]byte, 3)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, fmt.Errorf("rinex: read .Z header: %w", err)
+	}
+	if !bytes.Equal(hdr[:2], magicZ) {
+		return nil, fmt.Errorf("rinex: invalid .Z header")
+	}
+	maxBits := uint(hdr[2] & zBitsMask)
+	if maxBits < zInitBits || maxBits > zMaxBits {
+		return nil, fmt.Errorf("rinex: .Z: invalid number of bits: %d", maxBits)
+	}
+
+	zr := &ZReader{
+		r:         br,
+		maxBits:   maxBits,
+		blockMode: hdr[2]&zBlockMode != 0,
+		codeBits:  zInitBits,
+		maxCode:   1<<zInitBits - 1,
+		prefix:    make([]uint16, 1<<maxBits),
+		suffix:    make([]byte, 1<<maxBits),
+		oldCode:   -1,
+		stack:     make([]byte, 0, 1<<maxBits),
+	}
+	for i := range 256 {
+		zr.suffix[i] = byte(i)
+	}
+	zr.freeEntry = 256
+	if zr.blockMode {
+		zr.freeEntry = zClear + 1
+	}
+	return zr, nil
+}
+
+// Read implements the io.Reader interface.
+func (zr *ZReader) Read(p []byte) (int, error) {
+	zr.out = append(zr.buf[:0], zr.out...)
+	for len(zr.out) < len(p) && zr.err == nil {
+		zr.decode()
+	}
+	zr.buf = zr.out
+	n := copy(p, zr.out)
+	zr.out = zr.out[n:]
+	if n == 0 {
+		return 0, zr.err
+	}
+	return n, nil
+}
+
+// readCode reads the next code. Codes are written in groups of 8 codes with the same width.
+func (zr *ZReader) readCode() (int, error) {
+	for zr.nBits < zr.codeBits {
+		b, err := zr.r.ReadByte()
+		if err != nil {
+			return 0, err // remaining bits are padding at the end of the data
+		}
+		zr.bits |= uint32(b) << zr.nBits
+		zr.nBits += 8
+	}
+	code := int(zr.bits & (1<<zr.codeBits - 1))
+	zr.bits >>= zr.codeBits
+	zr.nBits -= zr.codeBits
+	zr.nCodes++
+	return code, nil
+}
+
+// skipGroup discards the rest of the current group of codes, when the code width changes.
+func (zr *ZReader) skipGroup() error {
+	for zr.nCodes%8 != 0 {
+		if _, err := zr.readCode(); err != nil {
+			return err
+		}
+	}
+	zr.nCodes = 0
+	zr.bits, zr.nBits = 0, 0
+	return nil
+}
+
+// decode decodes the next code into the output buffer.
+func (zr *ZReader) decode() {
+	if zr.freeEntry > zr.maxCode && zr.codeBits < zr.maxBits {
+		if zr.err = zr.skipGroup(); zr.err != nil {
+			return
+		}
+		zr.codeBits++
+		zr.maxCode = 1<<zr.codeBits - 1
+		if zr.codeBits == zr.maxBits {
+			zr.maxCode = 1 << zr.maxBits
+		}
+	}
+
+	code, err := zr.readCode()
+	if err != nil {
+		zr.err = err
+		return
+	}
+
+	if zr.oldCode == -1 {
+		if code >= 256 {
+			zr.err = fmt.Errorf("rinex: .Z: corrupt input: invalid first code %d", code)
+			return
+		}
+		zr.oldCode, zr.finChar = code, byte(code)
+		zr.out = append(zr.out, byte(code))
+		return
+	}
+
+	if code == zClear && zr.blockMode {
+		zr.freeEntry = zClear
+		if zr.err = zr.skipGroup(); zr.err != nil {
+			return
+		}
+		zr.codeBits = zInitBits
+		zr.maxCode = 1<<zInitBits - 1
+		return
+	}
+
+	inCode := code
+	zr.stack = zr.stack[:0]
+	if code >= zr.freeEntry { // the KwKwK case
+		if code > zr.freeEntry {
+			zr.err = fmt.Errorf("rinex: .Z: corrupt input: code %d out of range", code)
+			return
+		}
+		zr.stack = append(zr.stack, zr.finChar)
+		code = zr.oldCode
+	}
+	for code >= 256 {
+		zr.stack = append(zr.stack, zr.suffix[code])
+		code = int(zr.prefix[code])
+	}
+	zr.finChar = zr.suffix[code]
+	zr.stack = append(zr.stack, zr.finChar)
+
+	for i := len(zr.stack) - 1; i >= 0; i-- {
+		zr.out = append(zr.out, zr.stack[i])
+	}
+
+	// Add the new entry to the string table.
+	if zr.freeEntry < 1<<zr.maxBits {
+		zr.prefix[zr.freeEntry] = uint16(zr.oldCode)
+		zr.suffix[zr.freeEntry] = zr.finChar
+		zr.freeEntry++
+	}
+	zr.oldCode = inCode
+}
+
+// ZWriter compresses data in the Unix compress (.Z) format with block mode, compatible with compress(1).
+// The string table is cleared when it is full.
+type ZWriter struct {
+	w       *bufio.Writer
+	maxBits uint
+
+	table     map[int]int // the string table with prefix<<8|char as key
+	ent       int         // the code of the current string, -1 at the start
+	codeBits  uint
+	maxCode   int
+	freeEntry int
+
+	// bit output
+	bits   uint64
+	nBits  uint
+	nCodes int
+	err    error
+}
+
+// NewZWriter returns a new writer that compresses the data written to it with maxBits bits per code at maximum.
+// Use 16 like compress(1) if unsure. It is the caller's responsibility to call Close on the ZWriter when done.
+func NewZWriter(w io.Writer, maxBits uint) (*ZWriter, error) {
+	if maxBits < zInitBits || maxBits > zMaxBits {
+		return nil, fmt.Errorf("rinex: .Z: invalid number of bits: %d", maxBits)
+	}
+	zw := &ZWriter{w: bufio.NewWriter(w), maxBits: maxBits, ent: -1}
+	zw.reset()
+	_, zw.err = zw.w.Write(append(magicZ, byte(maxBits)|zBlockMode))
+	return zw, zw.err
+}
+
+// reset clears the string table.
+func (zw *ZWriter) reset() {
+	zw.table = make(map[int]int, 1<<zw.maxBits)
+	zw.codeBits = zInitBits
+	zw.maxCode = 1<<zInitBits - 1
+	zw.freeEntry = zClear + 1
+}
+
+// Write implements the io.Writer interface.
+func (zw *ZWriter) Write(p []byte) (int, error) {
+	if zw.err != nil {
+		return 0, zw.err
+	}
+	for _, c := range p {
+		if zw.ent == -1 {
+			zw.ent = int(c)
+			continue
+		}
+		key := zw.ent<<8 | int(c)
+		if code, ok := zw.table[key]; ok {
+			zw.ent = code
+			continue
+		}
+		zw.output(zw.ent)
+		zw.ent = int(c)
+		if zw.freeEntry < 1<<zw.maxBits {
+			zw.table[key] = zw.freeEntry
+			zw.freeEntry++
+			continue
+		}
+		// The table is full.
+		zw.output(zClear)
+		zw.padGroup()
+		zw.reset()
+	}
+	return len(p), zw.err
+}
+
+// output writes the code. The code width is increased after the code if the next entry does not fit,
+// following compress(1).
+func (zw *ZWriter) output(code int) {
+	zw.bits |= uint64(code) << zw.nBits
+	zw.nBits += zw.codeBits
+	zw.nCodes++
+	zw.writeBytes()
+	if code != zClear && zw.freeEntry > zw.maxCode && zw.codeBits < zw.maxBits {
+		zw.padGroup()
+		zw.codeBits++
+		zw.maxCode = 1<<zw.codeBits - 1
+		if zw.codeBits == zw.maxBits {
+			zw.maxCode = 1 << zw.maxBits
+		}
+	}
+}
+
+// padGroup completes the current group of 8 codes, as the decoder reads the codes in groups of the same width.
+func (zw *ZWriter) padGroup() {
+	for zw.nCodes%8 != 0 {
+		zw.nBits += zw.codeBits
+		zw.nCodes++
+		zw.writeBytes()
+	}
+	zw.nCodes = 0
+}
+
+// writeBytes writes the complete bytes of the bit buffer.
+func (zw *ZWriter) writeBytes() {
+	for zw.nBits >= 8 && zw.err == nil {
+		zw.err = zw.w.WriteByte(byte(zw.bits))
+		zw.bits >>= 8
+		zw.nBits -= 8
+	}
+}
+
+// Close writes the remaining data and flushes it to the underlying writer. It does not close the underlying writer.
+func (zw *ZWriter) Close() error {
+	if zw.err != nil {
+		return zw.err
+	}
+	if zw.ent != -1 {
+		zw.output(zw.ent)
+		zw.ent = -1
+	}
+	if zw.nBits > 0 {
+		zw.nBits = 8
+		zw.writeBytes()
+	}
+	if zw.err != nil {
+		return zw.err
+	}
+	return zw.w.Flush()
+}
@@ -0,0 +1,278 @@
+package rinex
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gzipToTempDir gzips the file src into the directory dir and returns the path of the gzip file.
+func gzipToTempDir(t *testing.T, src, dir string) string {
+	t.Helper()
+	dst := filepath.Join(dir, filepath.Base(src)+".gz")
+	if err := gzipFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	return dst
+}
+
+func TestOpenFile(t *testing.T) {
+	want, err := os.ReadFile("testdata/white/kais329w.18o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crx, err := os.Open("testdata/white/brst155h.20d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer crx.Close()
+	cr, err := NewCrxReader(crx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCrx, err := io.ReadAll(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		file string
+		want []byte
+	}{
+		{name: "plain", file: "testdata/white/kais329w.18o", want: want},
+		{name: "gzip", file: "testdata/white/kais329w.18o.gz", want: want},
+		{name: "compress", file: "testdata/white/kais329w.18o.Z", want: want},
+		{name: "bzip2", file: "testdata/white/kais329w.18o.bz2", want: want},
+		{name: "zstd", file: "testdata/white/kais329w.18o.zst", want: want},
+		{name: "crx", file: "testdata/white/brst155h.20d", want: wantCrx},
+		{name: "crx-compress", file: "testdata/white/brst155h.20d.Z", want: wantCrx},
+		{name: "crx-gzip", file: gzipToTempDir(t, "testdata/white/brst155h.20d", t.TempDir()), want: wantCrx},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := OpenFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tt.want, got) {
+				t.Errorf("%s: decompressed data differs", tt.file)
+			}
+		})
+	}
+
+	_, err = OpenFile("testdata/white/nonexisting.18o.gz")
+	assert.Error(t, err)
+}
+
+func TestOpenFile_truncated(t *testing.T) {
+	for _, ext := range []string{"gz", "bz2", "zst"} {
+		t.Run(ext, func(t *testing.T) {
+			assert := assert.New(t)
+			data, err := os.ReadFile("testdata/white/kais329w.18o." + ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "kais329w.18o."+ext)
+			if err := os.WriteFile(path, data[:len(data)/2], 0o644); err != nil {
+				t.Fatal(err)
+			}
+			r, err := OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			dec, err := NewObsDecoder(r)
+			if err == nil { // the header could be read
+				for dec.NextEpoch() {
+				}
+				err = dec.Err()
+			}
+			assert.Error(err)
+			assert.NotErrorIs(err, ErrParser, "read errors are no parse errors")
+		})
+	}
+}
+
+func TestDetectCompression(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		path string
+		want string
+	}{
+		{name: "gzip-magic", data: []byte{0x1f, 0x8b, 0x08, 0x00}, path: "abcd0010.24o", want: "gz"},
+		{name: "compress-magic", data: []byte{0x1f, 0x9d, 0x90}, path: "abcd0010.24o", want: "Z"},
+		{name: "bzip2-magic", data: []byte("BZh9"), path: "abcd0010.24o", want: "bz2"},
+		{name: "zstd-magic", data: []byte{0x28, 0xb5, 0x2f, 0xfd}, path: "abcd0010.24o", want: "zst"},
+		{name: "plain", data: []byte("     3.04           OBSERVATION DATA"), path: "abcd0010.24o", want: ""},
+		{name: "extension", data: []byte("xxxx"), path: "abcd0010.24o.gz", want: "gz"},
+		{name: "extension-Z", data: []byte("xxxx"), path: "abcd0010.24d.Z", want: "Z"},
+		{name: "empty", data: nil, path: "ABCD00XXX_R_20240010000_01D_30S_MO.crx.zst", want: "zst"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectCompression(bufio.NewReader(bytes.NewReader(tt.data)), tt.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestZReader_errors(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewZReader(bytes.NewReader([]byte{0x1f, 0x8b, 0x10}))
+	assert.Error(err, "invalid magic")
+
+	_, err = NewZReader(bytes.NewReader([]byte{0x1f, 0x9d, 0x91}))
+	assert.Error(err, "invalid maxbits")
+
+	_, err = NewZReader(bytes.NewReader([]byte{0x1f}))
+	assert.Error(err, "short header")
+
+	// truncated data is returned up to the truncation
+	data, err := os.ReadFile("testdata/white/kais329w.18o.Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := NewZReader(bytes.NewReader(data[:1000]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	assert.NoError(err)
+	assert.Greater(len(got), 1000)
+}
+
+func TestObsFile_compressed(t *testing.T) {
+	assert := assert.New(t)
+	plain, err := NewObsFile("testdata/white/kais329w.18o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHdr, err := plain.ReadHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStats, err := plain.ComputeObsStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ext := range []string{"gz", "Z", "bz2", "zst"} {
+		obsFil, err := NewObsFile("testdata/white/kais329w.18o." + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(ext, obsFil.Compression)
+
+		hdr, err := obsFil.ReadHeader()
+		assert.NoError(err, ext)
+		assert.Equal(wantHdr, hdr, ext)
+
+		stats, err := obsFil.ComputeObsStats()
+		assert.NoError(err, ext)
+		assert.Equal(wantStats, stats, ext)
+	}
+
+	// Compact RINEX
+	obsFil, err := NewObsFile("testdata/white/brst155h.20d.Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := obsFil.ComputeObsStats()
+	assert.NoError(err)
+	assert.Equal(120, stats.NumEpochs)
+	assert.Equal("BRST", obsFil.Header.MarkerName)
+}
+
+func TestNavFile_compressed(t *testing.T) {
+	assert := assert.New(t)
+	path := gzipToTempDir(t, "testdata/white/AREG00PER_R_20201690000_01D_MN.rnx", t.TempDir())
+	fil, err := NewNavFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("gz", fil.Compression)
+
+	hdr, err := fil.ReadHeader()
+	assert.NoError(err)
+	assert.Equal(float32(3.04), hdr.RINEXVersion)
+
+	stats, err := fil.GetStats()
+	assert.NoError(err)
+	assert.Equal(3612, stats.NumEphemeris)
+}
+
+func TestMeteoFile_compressed(t *testing.T) {
+	assert := assert.New(t)
+	path := gzipToTempDir(t, "testdata/white/BAUT00DEU_R_20223131300_01H_10S_MM.rnx", t.TempDir())
+	fil, err := NewMeteoFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("gz", fil.Compression)
+
+	_, err = fil.ReadHeader()
+	assert.NoError(err)
+	assert.Equal("BAUT", fil.Header.MarkerName)
+
+	stats, err := fil.ComputeObsStats()
+	assert.NoError(err)
+	assert.Equal(360, stats.NumEpochs)
+}
+
+func TestZWriter(t *testing.T) {
+	assert := assert.New(t)
+	data, err := os.ReadFile("testdata/white/kais329w.18o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		maxBits uint
+	}{
+		{name: "empty", data: []byte{}, maxBits: 16},
+		{name: "one byte", data: []byte("x"), maxBits: 16},
+		{name: "rinex 16 bits", data: data, maxBits: 16},
+		{name: "rinex 12 bits", data: data, maxBits: 12},
+		{name: "rinex 9 bits", data: data, maxBits: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw, err := NewZWriter(&buf, tt.maxBits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = zw.Write(tt.data)
+			assert.NoError(err)
+			assert.NoError(zw.Close())
+			if len(tt.data) > 1000 && tt.maxBits >= 12 {
+				assert.Less(buf.Len(), len(tt.data)/2)
+			}
+
+			zr, err := NewZReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(zr)
+			assert.NoError(err)
+			assert.Equal(tt.data, got)
+		})
+	}
+
+	_, err = NewZWriter(io.Discard, 17)
+	assert.Error(err)
+}
@@ -0,0 +1,340 @@
+package rinex
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// ObsCodeMap maps the RINEX-2 observation types per satellite system to RINEX-3 observation codes.
+// The RINEX-3 codes are given in order of priority: converting from version 2 the first code is used,
+// converting to version 2 the first code available in the data is used.
+// The code lists of a system must not overlap.
+type ObsCodeMap map[gnss.System]map[ObsCode][]ObsCode
+
+// DefaultObsCodeMap is the default signal mapping between RINEX-2 and RINEX-3 observation types.
+// The RINEX-2 types are those of RINEX version 2.11.
+var DefaultObsCodeMap = ObsCodeMap{
+	gnss.SysGPS: {
+		"C1": {"C1C"}, "P1": {"C1W", "C1P", "C1Y"}, "L1": attrCodes("L1", "CWPYSLX"), "D1": attrCodes("D1", "CWPYSLX"), "S1": attrCodes("S1", "CWPYSLX"),
+		"C2": attrCodes("C2", "XLSCD"), "P2": {"C2W", "C2P", "C2Y"}, "L2": attrCodes("L2", "WPYXLSCD"), "D2": attrCodes("D2", "WPYXLSCD"), "S2": attrCodes("S2", "WPYXLSCD"),
+		"C5": attrCodes("C5", "XQI"), "L5": attrCodes("L5", "XQI"), "D5": attrCodes("D5", "XQI"), "S5": attrCodes("S5", "XQI"),
+	},
+	gnss.SysGLO: {
+		"C1": {"C1C"}, "P1": {"C1P"}, "L1": attrCodes("L1", "CP"), "D1": attrCodes("D1", "CP"), "S1": attrCodes("S1", "CP"),
+		"C2": {"C2C"}, "P2": {"C2P"}, "L2": attrCodes("L2", "PC"), "D2": attrCodes("D2", "PC"), "S2": attrCodes("S2", "PC"),
+	},
+	gnss.SysGAL: {
+		"C1": attrCodes("C1", "XCBA"), "L1": attrCodes("L1", "XCBA"), "D1": attrCodes("D1", "XCBA"), "S1": attrCodes("S1", "XCBA"),
+		"C5": attrCodes("C5", "XQI"), "L5": attrCodes("L5", "XQI"), "D5": attrCodes("D5", "XQI"), "S5": attrCodes("S5", "XQI"),
+		"C6": attrCodes("C6", "XCBA"), "L6": attrCodes("L6", "XCBA"), "D6": attrCodes("D6", "XCBA"), "S6": attrCodes("S6", "XCBA"),
+		"C7": attrCodes("C7", "XQI"), "L7": attrCodes("L7", "XQI"), "D7": attrCodes("D7", "XQI"), "S7": attrCodes("S7", "XQI"),
+		"C8": attrCodes("C8", "XQI"), "L8": attrCodes("L8", "XQI"), "D8": attrCodes("D8", "XQI"), "S8": attrCodes("S8", "XQI"),
+	},
+	gnss.SysSBAS: {
+		"C1": {"C1C"}, "L1": {"L1C"}, "D1": {"D1C"}, "S1": {"S1C"},
+		"C5": attrCodes("C5", "XIQ"), "L5": attrCodes("L5", "XIQ"), "D5": attrCodes("D5", "XIQ"), "S5": attrCodes("S5", "XIQ"),
+	},
+}
+
+// attrCodes returns the observation codes for the type and band typBand, e.g. "L1", with each of the given attributes.
+func attrCodes(typBand string, attrs string) []ObsCode {
+	codes := make([]ObsCode, 0, len(attrs))
+	for _, attr := range attrs {
+		codes = append(codes, ObsCode(typBand+string(attr)))
+	}
+	return codes
+}
+
+// ConvertOptions sets options for the conversion of RINEX observation data.
+type ConvertOptions struct {
+	RINEXVersion float32    // The target RINEX version, e.g. 2.11 or 3.05.
+	Mapping      ObsCodeMap // The signal mapping. DefaultObsCodeMap is used if nil.
+
+	// The satellite systems contained in a RINEX-2 mixed file. All systems of the mapping are used if empty.
+	SatSystems []gnss.System
+
+	// The GLONASS slot and frequency numbers for the RINEX-3 header. The source header slots are kept if nil.
+	// They are required for GLONASS observations converted from RINEX-2, whose header has no slots.
+	GloSlots map[gnss.PRN]int
+}
+
+// ObsConverter converts RINEX observation data between RINEX version 2 and version 3/4.
+type ObsConverter struct {
+	// The converted header. It is valid after NewObsConverter.
+	Header ObsHeader
+	srcHdr ObsHeader
+	codes  map[gnss.System]map[ObsCode]ObsCode // source obs code to target obs code, nil if the codes are kept.
+}
+
+// NewObsConverter returns a converter for data with the header hdr to the RINEX version given in opts.
+// The RINEX-2 observation types are mapped to RINEX-3 observation codes and vice versa, the
+// observation types and phase shifts of the converted header are regenerated, the GLONASS slots are taken from opts.
+// The number of satellites and of observations per satellite are cleared, as they refer to the source types.
+func NewObsConverter(hdr ObsHeader, opts ConvertOptions) (*ObsConverter, error) {
+	if opts.RINEXVersion < 2 || opts.RINEXVersion >= 5 {
+		return nil, fmt.Errorf("rinex: convert: invalid RINEX version: %.2f", opts.RINEXVersion)
+	}
+	mapping := opts.Mapping
+	if mapping == nil {
+		mapping = DefaultObsCodeMap
+	}
+
+	conv := &ObsConverter{Header: hdr, srcHdr: hdr}
+	conv.Header.RINEXVersion = opts.RINEXVersion
+	conv.Header.Labels = nil
+	conv.Header.NSatellites = 0
+	conv.Header.ObsPerSat = nil
+
+	isSrcV2, isDstV2 := hdr.RINEXVersion < 3, opts.RINEXVersion < 3
+	switch {
+	case isSrcV2 && !isDstV2:
+		syss := []gnss.System{hdr.SatSystem}
+		if hdr.SatSystem == gnss.SysMIXED {
+			syss = opts.SatSystems
+			if len(syss) == 0 {
+				syss = sortedSystems(mapping)
+			}
+		}
+		conv.toRnx3(syss, mapping)
+	case !isSrcV2 && isDstV2:
+		conv.toRnx2(mapping)
+	}
+
+	if !isDstV2 {
+		if _, ok := conv.Header.ObsTypes[gnss.SysGLO]; ok {
+			if opts.GloSlots != nil {
+				conv.Header.GloSlots = opts.GloSlots
+			}
+			if len(conv.Header.GloSlots) == 0 {
+				return nil, fmt.Errorf("rinex: convert: GLONASS slots missing for RINEX version %.2f", opts.RINEXVersion)
+			}
+		}
+	}
+
+	if len(conv.Header.ObsTypes) == 0 {
+		return nil, fmt.Errorf("rinex: convert: no observation types left")
+	}
+	return conv, nil
+}
+
+// Set the header and obs code mapping for the conversion from RINEX-2 to RINEX-3.
+func (conv *ObsConverter) toRnx3(syss []gnss.System, mapping ObsCodeMap) {
+	conv.Header.ObsTypes = make(map[gnss.System][]ObsCode, len(syss))
+	conv.Header.PhaseShifts = nil
+	conv.codes = make(map[gnss.System]map[ObsCode]ObsCode, len(syss))
+	for _, sys := range syss {
+		sysMapping, ok := mapping[sys]
+		if !ok {
+			continue
+		}
+		codes := map[ObsCode]ObsCode{}
+		for _, typ := range conv.srcHdr.ObsTypes[conv.srcHdr.SatSystem] {
+			if rnx3Codes := sysMapping[typ]; len(rnx3Codes) > 0 {
+				codes[typ] = rnx3Codes[0]
+				conv.Header.ObsTypes[sys] = append(conv.Header.ObsTypes[sys], rnx3Codes[0])
+				if typ[0] == 'L' {
+					conv.Header.PhaseShifts = append(conv.Header.PhaseShifts, PhaseShift{Sys: sys, Code: rnx3Codes[0]})
+				}
+			}
+		}
+		if len(codes) > 0 {
+			conv.codes[sys] = codes
+		}
+	}
+	if len(conv.Header.ObsTypes) == 1 {
+		for sys := range conv.Header.ObsTypes {
+			conv.Header.SatSystem = sys
+		}
+	}
+}
+
+// Set the header and obs code mapping for the conversion from RINEX-3 to RINEX-2.
+func (conv *ObsConverter) toRnx2(mapping ObsCodeMap) {
+	conv.codes = make(map[gnss.System]map[ObsCode]ObsCode, len(conv.srcHdr.ObsTypes))
+	rnx2Types := []ObsCode{}
+	for sys, srcCodes := range conv.srcHdr.ObsTypes {
+		codes := map[ObsCode]ObsCode{}
+		for typ, rnx3Codes := range mapping[sys] {
+			for _, code := range rnx3Codes {
+				if slices.Contains(srcCodes, code) {
+					codes[code] = typ
+					if !slices.Contains(rnx2Types, typ) {
+						rnx2Types = append(rnx2Types, typ)
+					}
+					break
+				}
+			}
+		}
+		if len(codes) > 0 {
+			conv.codes[sys] = codes
+		}
+	}
+	slices.SortFunc(rnx2Types, compareRnx2Types)
+
+	conv.Header.SatSystem = gnss.SysMIXED
+	if len(conv.codes) == 1 {
+		for sys := range conv.codes {
+			conv.Header.SatSystem = sys
+		}
+	}
+	conv.Header.ObsTypes = map[gnss.System][]ObsCode{}
+	if len(rnx2Types) > 0 {
+		conv.Header.ObsTypes[conv.Header.SatSystem] = rnx2Types
+	}
+	conv.Header.PhaseShifts = nil
+	conv.Header.GloSlots = nil
+}
+
+// Convert returns the converted epoch. Observations that can not be mapped and satellites without observations are dropped.
+// Special event epochs are returned unchanged.
+func (conv *ObsConverter) Convert(epo *Epoch) *Epoch {
+	if conv.codes == nil || (epo.Flag > EpochFlagPowerFailure && epo.Flag != EpochFlagCycleSlip) {
+		return epo
+	}
+
+	newEpo := *epo
+	newEpo.ObsList = make([]SatObs, 0, len(epo.ObsList))
+	for _, satObs := range epo.ObsList {
+		codes, ok := conv.codes[satObs.Prn.Sys]
+		if !ok {
+			continue
+		}
+		obss := make(map[ObsCode]Obs, len(codes))
+		for code, obs := range satObs.Obss {
+			if newCode, ok := codes[code]; ok {
+				obss[newCode] = obs
+			}
+		}
+		if len(obss) == 0 {
+			continue
+		}
+		newEpo.ObsList = append(newEpo.ObsList, SatObs{Prn: satObs.Prn, Obss: obss})
+	}
+	newEpo.NumSat = uint8(len(newEpo.ObsList))
+	return &newEpo
+}
+
+// Convert converts the obs file to the given RINEX version. The converted file is written to the directory dir
+// and named following the RINEX convention of the target version, see Rnx3Filename and Rnx2Filename.
+// For a conversion to RINEX-3 the file's CountryCode must be set.
+func (f *ObsFile) Convert(dir string, opts ConvertOptions) (*ObsFile, error) {
+	r, err := OpenFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	dec, err := NewObsDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// The satellite systems of RINEX-2 mixed files are derived from the data.
+	if dec.Header.RINEXVersion < 3 && dec.Header.SatSystem == gnss.SysMIXED && len(opts.SatSystems) == 0 {
+		opts.SatSystems, err = f.satSystems()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conv, err := NewObsConverter(dec.Header, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rnx := *f.RnxFil
+	rnx.Format = "rnx"
+	rnx.Compression = ""
+	var fn string
+	if opts.RINEXVersion < 3 {
+		fn, err = rnx.Rnx2Filename()
+	} else {
+		newFil := &ObsFile{RnxFil: &rnx}
+		newFil.DataType = fmt.Sprintf("%s%s", conv.Header.SatSystem.Abbr(), "O")
+		fn, err = newFil.Rnx3Filename()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rinex: convert: build filename: %v", err)
+	}
+	rnx.Path = filepath.Join(dir, fn)
+
+	w, err := os.Create(rnx.Path)
+	if err != nil {
+		return nil, err
+	}
+	err = conv.writeAll(dec, w)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(rnx.Path)
+		return nil, err
+	}
+
+	return &ObsFile{RnxFil: &rnx, Header: &conv.Header, Opts: &Options{}}, nil
+}
+
+// writeAll converts all epochs of dec and writes them with the converted header to w.
+func (conv *ObsConverter) writeAll(dec *ObsDecoder, w io.Writer) error {
+	enc, err := NewObsEncoder(w, conv.Header)
+	if err != nil {
+		return err
+	}
+	for dec.NextEpoch() {
+		if err := enc.Encode(conv.Convert(dec.Epoch())); err != nil {
+			return err
+		}
+	}
+	if err := dec.Err(); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// satSystems returns the satellite systems contained in the data.
+func (f *ObsFile) satSystems() ([]gnss.System, error) {
+	r, err := OpenFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	dec, err := NewObsDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+
+	syss := []gnss.System{}
+	for dec.NextEpoch() {
+		for _, satObs := range dec.Epoch().ObsList {
+			if !slices.Contains(syss, satObs.Prn.Sys) {
+				syss = append(syss, satObs.Prn.Sys)
+			}
+		}
+	}
+	slices.Sort(syss)
+	return syss, dec.Err()
+}
+
+// sortedSystems returns the satellite systems of the mapping in ascending order.
+func sortedSystems(mapping ObsCodeMap) []gnss.System {
+	syss := make([]gnss.System, 0, len(mapping))
+	for sys := range mapping {
+		syss = append(syss, sys)
+	}
+	slices.Sort(syss)
+	return syss
+}
+
+// compareRnx2Types orders RINEX-2 observation types by frequency band and type, e.g. C1 P1 L1 D1 S1 C2 P2...
+func compareRnx2Types(a, b ObsCode) int {
+	if a[1] != b[1] {
+		return int(a[1]) - int(b[1])
+	}
+	const typeOrder = "CPLDS"
+	return strings.IndexByte(typeOrder, a[0]) - strings.IndexByte(typeOrder, b[0])
+}
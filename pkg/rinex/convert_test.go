@@ -0,0 +1,176 @@
+package rinex
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/stretchr/testify/assert"
+)
+
+// gloSlots are the GLONASS slot and frequency numbers of 2019/2020.
+var gloSlots = map[gnss.PRN]int{
+	{Sys: gnss.SysGLO, Num: 1}: 1, {Sys: gnss.SysGLO, Num: 2}: -4, {Sys: gnss.SysGLO, Num: 3}: 5, {Sys: gnss.SysGLO, Num: 4}: 6,
+	{Sys: gnss.SysGLO, Num: 5}: 1, {Sys: gnss.SysGLO, Num: 6}: -4, {Sys: gnss.SysGLO, Num: 7}: 5, {Sys: gnss.SysGLO, Num: 8}: 6,
+	{Sys: gnss.SysGLO, Num: 9}: -2, {Sys: gnss.SysGLO, Num: 10}: -7, {Sys: gnss.SysGLO, Num: 11}: 0, {Sys: gnss.SysGLO, Num: 12}: -1,
+	{Sys: gnss.SysGLO, Num: 13}: -2, {Sys: gnss.SysGLO, Num: 14}: -7, {Sys: gnss.SysGLO, Num: 15}: 0, {Sys: gnss.SysGLO, Num: 16}: -1,
+	{Sys: gnss.SysGLO, Num: 17}: 4, {Sys: gnss.SysGLO, Num: 18}: -3, {Sys: gnss.SysGLO, Num: 19}: 3, {Sys: gnss.SysGLO, Num: 20}: 2,
+	{Sys: gnss.SysGLO, Num: 21}: 4, {Sys: gnss.SysGLO, Num: 22}: -3, {Sys: gnss.SysGLO, Num: 23}: 3, {Sys: gnss.SysGLO, Num: 24}: 2,
+}
+
+func TestObsConverter(t *testing.T) {
+	assert := assert.New(t)
+	dec := newObsDecoder(t, "testdata/white/brst155h.20o")
+	epochs := readAllEpochs(t, dec)
+
+	// RINEX-2 to RINEX-3
+	opts := ConvertOptions{RINEXVersion: 3.05, SatSystems: []gnss.System{gnss.SysGPS, gnss.SysGLO, gnss.SysGAL, gnss.SysSBAS}}
+	_, err := NewObsConverter(dec.Header, opts)
+	assert.Error(err, "GLONASS slots missing")
+	opts.GloSlots = gloSlots
+	conv, err := NewObsConverter(dec.Header, opts)
+	assert.NoError(err)
+	hdr3 := conv.Header
+	assert.Equal(gloSlots, hdr3.GloSlots)
+	assert.Equal(float32(3.05), hdr3.RINEXVersion)
+	assert.Equal(gnss.SysMIXED, hdr3.SatSystem)
+	assert.Equal([]ObsCode{"L1C", "L2W", "C1C", "C2X", "C1W", "C2W", "D1C", "D2W", "S1C", "S2W", "L5X", "C5X", "D5X", "S5X"}, hdr3.ObsTypes[gnss.SysGPS])
+	assert.Equal([]ObsCode{"L1C", "L2P", "C1C", "C2C", "C1P", "C2P", "D1C", "D2P", "S1C", "S2P"}, hdr3.ObsTypes[gnss.SysGLO])
+	assert.Equal([]ObsCode{"L1X", "C1X", "D1X", "S1X", "L5X", "C5X", "D5X", "S5X", "L7X", "C7X", "D7X", "S7X", "L8X", "C8X", "D8X", "S8X"}, hdr3.ObsTypes[gnss.SysGAL])
+	assert.Contains(hdr3.PhaseShifts, PhaseShift{Sys: gnss.SysGLO, Code: "L2P"})
+	assert.Equal([]ObsCode{"L1C", "C1C", "D1C", "S1C", "L5X", "C5X", "D5X", "S5X"}, hdr3.ObsTypes[gnss.SysSBAS])
+	assert.Len(hdr3.PhaseShifts, 3+2+4+2)
+
+	epo3 := conv.Convert(epochs[0])
+	for _, satObs := range epo3.ObsList {
+		assert.Len(satObs.Obss, len(hdr3.ObsTypes[satObs.Prn.Sys]), "%s", satObs.Prn)
+	}
+	assert.Equal(epochs[0].ObsList[1].Obss["P2"], epo3.ObsList[1].Obss["C2W"])
+
+	// Encode and decode the RINEX-3 data.
+	var buf bytes.Buffer
+	enc, err := NewObsEncoder(&buf, hdr3)
+	assert.NoError(err)
+	for _, epo := range epochs {
+		assert.NoError(enc.Encode(conv.Convert(epo)))
+	}
+	assert.NoError(enc.Flush())
+	dec3, err := NewObsDecoder(&buf)
+	assert.NoError(err)
+	assert.Equal(hdr3.ObsTypes, dec3.Header.ObsTypes)
+	assert.Equal(hdr3.PhaseShifts, dec3.Header.PhaseShifts)
+	assert.Equal(gloSlots, dec3.Header.GloSlots)
+	epochs3 := readAllEpochs(t, dec3)
+
+	// and back to RINEX-2
+	conv2, err := NewObsConverter(dec3.Header, ConvertOptions{RINEXVersion: 2.11})
+	assert.NoError(err)
+	assert.Equal(gnss.SysMIXED, conv2.Header.SatSystem)
+	assert.Equal([]ObsCode{"C1", "P1", "L1", "D1", "S1", "C2", "P2", "L2", "D2", "S2", "C5", "L5", "D5", "S5", "C7", "L7", "D7", "S7", "C8", "L8", "D8", "S8"},
+		conv2.Header.ObsTypes[gnss.SysMIXED])
+	assert.Equal(len(epochs), len(epochs3))
+	for i, epo := range epochs3 {
+		if !assert.Equal(withoutBlankObs(epochs[i]), withoutBlankObs(conv2.Convert(epo)), "epoch %d", i+1) {
+			break
+		}
+	}
+}
+
+func TestObsConverter_mapping(t *testing.T) {
+	assert := assert.New(t)
+	hdr := ObsHeader{RINEXVersion: 3.04, SatSystem: gnss.SysMIXED, ObsTypes: map[gnss.System][]ObsCode{
+		gnss.SysGPS: {"C1C", "L1C", "C2L", "L2L", "C2W", "L2W"},
+		gnss.SysBDS: {"C2I", "L2I"},
+	}}
+
+	// L2W has priority over L2L.
+	conv, err := NewObsConverter(hdr, ConvertOptions{RINEXVersion: 2.11})
+	assert.NoError(err)
+	assert.Equal(gnss.SysGPS, conv.Header.SatSystem)
+	assert.Equal([]ObsCode{"C1", "L1", "C2", "P2", "L2"}, conv.Header.ObsTypes[gnss.SysGPS])
+
+	epo := &Epoch{NumSat: 2, ObsList: []SatObs{
+		{Prn: gnss.PRN{Sys: gnss.SysGPS, Num: 1}, Obss: map[ObsCode]Obs{"C1C": {Val: 1}, "L2L": {Val: 2}, "L2W": {Val: 3}}},
+		{Prn: gnss.PRN{Sys: gnss.SysBDS, Num: 1}, Obss: map[ObsCode]Obs{"C2I": {Val: 4}}},
+	}}
+	assert.Equal(&Epoch{NumSat: 1, ObsList: []SatObs{
+		{Prn: gnss.PRN{Sys: gnss.SysGPS, Num: 1}, Obss: map[ObsCode]Obs{"C1": {Val: 1}, "L2": {Val: 3}}},
+	}}, conv.Convert(epo))
+
+	// user defined mapping
+	mapping := ObsCodeMap{gnss.SysGPS: {"L2": {"L2L"}}}
+	conv, err = NewObsConverter(hdr, ConvertOptions{RINEXVersion: 2.11, Mapping: mapping})
+	assert.NoError(err)
+	assert.Equal([]ObsCode{"L2"}, conv.Header.ObsTypes[gnss.SysGPS])
+	assert.Equal(Obs{Val: 2}, conv.Convert(epo).ObsList[0].Obss["L2"])
+
+	_, err = NewObsConverter(hdr, ConvertOptions{RINEXVersion: 2.11, Mapping: ObsCodeMap{gnss.SysGAL: {"L1": {"L1X"}}}})
+	assert.Error(err, "no obs types left")
+	_, err = NewObsConverter(hdr, ConvertOptions{RINEXVersion: 1})
+	assert.Error(err, "invalid version")
+}
+
+func TestObsFile_Convert(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		opts    ConvertOptions
+		country string
+		want    string
+	}{
+		{name: "v2-v3", file: "testdata/white/brst155h.20o", opts: ConvertOptions{RINEXVersion: 3.05, GloSlots: gloSlots}, country: "FRA", want: "BRST00FRA_R_20201550700_01H_30S_MO.rnx"},
+		{name: "crx-v3", file: "testdata/white/brst155h.20d", opts: ConvertOptions{RINEXVersion: 4.00, GloSlots: gloSlots}, country: "FRA", want: "BRST00FRA_R_20201550700_01H_30S_MO.rnx"},
+		{name: "v3-v2", file: "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", opts: ConvertOptions{RINEXVersion: 2.11}, want: "brux310t.18o"},
+		{name: "v3-v2 with obs per sat", file: "testdata/white/REYK00ISL_R_20192701000_01H_30S_MO.rnx", opts: ConvertOptions{RINEXVersion: 2.11}, want: "reyk270k.19o"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			obsFil, err := NewObsFile(tt.file)
+			assert.NoError(err)
+			obsFil.CountryCode = tt.country
+			obsFil.DataSource = "R"
+
+			dir := t.TempDir()
+			newFil, err := obsFil.Convert(dir, tt.opts)
+			assert.NoError(err)
+			assert.Equal(filepath.Join(dir, tt.want), newFil.Path)
+			assert.Equal(tt.opts.RINEXVersion, newFil.Header.RINEXVersion)
+
+			hdr, err := newFil.ReadHeader()
+			assert.NoError(err)
+			assert.Equal(tt.opts.RINEXVersion, hdr.RINEXVersion)
+			assert.Equal(newFil.Header.ObsTypes, hdr.ObsTypes)
+			assert.Zero(hdr.NSatellites)
+			assert.Empty(hdr.ObsPerSat)
+			if tt.opts.RINEXVersion >= 3 {
+				assert.Equal(gloSlots, hdr.GloSlots)
+				// The systems are derived from the data.
+				assert.ElementsMatch([]gnss.System{gnss.SysGPS, gnss.SysGLO, gnss.SysGAL, gnss.SysSBAS}, hdr.SatSystems())
+			}
+		})
+	}
+
+	// missing country code
+	obsFil, err := NewObsFile("testdata/white/brst155h.20o")
+	assert.NoError(t, err)
+	_, err = obsFil.Convert(t.TempDir(), ConvertOptions{RINEXVersion: 3.05})
+	assert.Error(t, err)
+}
+
+// withoutBlankObs returns the epoch without missing observations.
+func withoutBlankObs(epo *Epoch) *Epoch {
+	newEpo := *epo
+	newEpo.ObsList = make([]SatObs, 0, len(epo.ObsList))
+	for _, satObs := range epo.ObsList {
+		obss := map[ObsCode]Obs{}
+		for code, obs := range satObs.Obss {
+			if obs != (Obs{}) {
+				obss[code] = obs
+			}
+		}
+		newEpo.ObsList = append(newEpo.ObsList, SatObs{Prn: satObs.Prn, Obss: obss})
+	}
+	return &newEpo
+}
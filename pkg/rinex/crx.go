@@ -0,0 +1,965 @@
+package rinex
+
+// Compact RINEX (Hatanaka compression) format, see
+// Hatanaka, Y. (2008): A Compression Format and Tools for GNSS Observation Data,
+// Bulletin of the Geographical Survey Institute, 55, 21-30 and http://terras.gsi.go.jp/ja/crx2rnx.html.
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The Compact RINEX versions.
+const (
+	crxVersion1 = 1 // Compact RINEX 1.0 for RINEX version 2 files.
+	crxVersion3 = 3 // Compact RINEX 3.0 for RINEX version 3 and 4 files.
+)
+
+// maxDiffOrder is the maximum order of the differences used in Compact RINEX.
+const maxDiffOrder = 9
+
+// ErrNoCrxHeader is returned when reading Compact RINEX data that does not begin with a CRINEX header.
+var ErrNoCrxHeader = errors.New("rinex: no compact RINEX header")
+
+// crxArc holds the state of a data arc, that is the differences of a series of observations.
+type crxArc struct {
+	order int                     // The order of the differences, -1 if the arc is not initialized.
+	n     int                     // The number of differences already stored.
+	diffs [maxDiffOrder + 1]int64 // The last value and its differences up to order.
+}
+
+// reset resets the arc, the next value must be an arc initialization.
+func (arc *crxArc) reset() {
+	arc.order = -1
+	arc.n = 0
+}
+
+// initialize starts a new arc with the given order and value.
+func (arc *crxArc) initialize(order int, val int64) {
+	arc.order = order
+	arc.n = 0
+	arc.diffs[0] = val
+}
+
+// decode recovers the next value from its difference of order n.
+func (arc *crxArc) decode(diff int64) (int64, error) {
+	if arc.order < 0 {
+		return 0, fmt.Errorf("uninitialized data arc")
+	}
+	if arc.n < arc.order {
+		arc.n++
+	}
+	arc.diffs[arc.n] = diff
+	for k := arc.n; k > 0; k-- {
+		arc.diffs[k-1] += arc.diffs[k]
+	}
+	return arc.diffs[0], nil
+}
+
+// crxSat holds the decompression state of a satellite.
+type crxSat struct {
+	arcs  []crxArc
+	flags []byte // The LLI and SNR flags, two bytes per observation type.
+}
+
+func newCrxSat(ntypes int) *crxSat {
+	sat := &crxSat{arcs: make([]crxArc, ntypes)}
+	for i := range sat.arcs {
+		sat.arcs[i].reset()
+	}
+	return sat
+}
+
+// CrxReader decompresses Compact RINEX (Hatanaka-compressed) observation data.
+// It implements io.Reader and returns the data as plain RINEX text, so that it can be used
+// as input e.g. for the ObsDecoder. CRINEX versions 1.0 and 3.0 are supported.
+type CrxReader struct {
+	CrxVersion   int     // The Compact RINEX format version, 1 or 3.
+	RINEXVersion float32 // The version of the compressed RINEX data.
+
+	sc      *bufio.Scanner
+	out     bytes.Buffer // decompressed data not yet read
+	lineNum int
+	err     error
+
+	ntypes  map[string]int     // number of obs types per satellite system, key "" is used for RINEX-2.
+	epoLine []byte             // the previous epoch line
+	clock   crxArc             // receiver clock offset
+	sats    map[string]*crxSat // satellites of the previous epoch
+}
+
+// NewCrxReader returns a new Compact RINEX reader that reads from r.
+// The CRINEX and RINEX header will be read implicitly. The header must exist,
+// otherwise ErrNoCrxHeader will be returned.
+//
+// It is the caller's responsibility to call Close on the underlying reader when done!
+func NewCrxReader(r io.Reader) (*CrxReader, error) {
+	cr := &CrxReader{sc: bufio.NewScanner(r), ntypes: map[string]int{}, sats: map[string]*crxSat{}}
+	cr.sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	cr.clock.reset()
+	err := cr.readHeader()
+	return cr, err
+}
+
+// isCrx reports whether the data in br begins with a Compact RINEX header.
+func isCrx(br *bufio.Reader) bool {
+	b, _ := br.Peek(80)
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		b = b[:i]
+	}
+	return bytes.Contains(b, []byte("CRINEX VERS"))
+}
+
+// Read reads up to len(p) bytes of decompressed RINEX data into p.
+func (cr *CrxReader) Read(p []byte) (int, error) {
+	for cr.out.Len() == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		cr.err = cr.decodeEpoch()
+	}
+	return cr.out.Read(p)
+}
+
+// readHeader reads the CRINEX header lines and passes the RINEX header through.
+func (cr *CrxReader) readHeader() error {
+	if !cr.readLine() {
+		return ErrNoCrxHeader
+	}
+	line := cr.line()
+	if !strings.Contains(line, "CRINEX VERS") || len(line) < 20 {
+		return ErrNoCrxHeader
+	}
+	switch vers := strings.TrimSpace(line[:20]); vers {
+	case "1.0":
+		cr.CrxVersion = crxVersion1
+	case "3.0":
+		cr.CrxVersion = crxVersion3
+	default:
+		return fmt.Errorf("crx: unsupported CRINEX version %q", vers)
+	}
+
+	if !cr.readLine() || !strings.Contains(cr.line(), "CRINEX PROG / DATE") {
+		return fmt.Errorf("crx: line %d: missing %q", cr.lineNum, "CRINEX PROG / DATE")
+	}
+
+	for cr.readLine() {
+		line := cr.line()
+		cr.out.WriteString(line)
+		cr.out.WriteByte('\n')
+		eoh, err := parseCrxHeaderLine(line, &cr.RINEXVersion, cr.ntypes)
+		if err != nil {
+			return fmt.Errorf("crx: line %d: %v", cr.lineNum, err)
+		}
+		if eoh {
+			return nil
+		}
+	}
+	if err := cr.sc.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("crx: unexpected EOF in header")
+}
+
+// parseCrxHeaderLine parses the RINEX header records needed for the (de)compression, that is the
+// RINEX version and the number of observation types per satellite system. It returns true at the end of header.
+func parseCrxHeaderLine(line string, version *float32, ntypes map[string]int) (bool, error) {
+	if len(line) < 60 {
+		return false, nil
+	}
+
+	val, key := line[:60], strings.TrimSpace(line[60:])
+	switch key {
+	case "RINEX VERSION / TYPE":
+		f64, err := strconv.ParseFloat(strings.TrimSpace(val[:20]), 32)
+		if err != nil {
+			return false, fmt.Errorf("parse RINEX VERSION: %v", err)
+		}
+		*version = float32(f64)
+	case "SYS / # / OBS TYPES":
+		if val[:1] == " " { // continuation line
+			return false, nil
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(val[3:6]))
+		if err != nil {
+			return false, fmt.Errorf("parse %q: %v", key, err)
+		}
+		ntypes[val[:1]] = n
+	case "# / TYPES OF OBSERV":
+		if strings.TrimSpace(val[:6]) == "" { // continuation line
+			return false, nil
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(val[:6]))
+		if err != nil {
+			return false, fmt.Errorf("parse %q: %v", key, err)
+		}
+		ntypes[""] = n
+	case "END OF HEADER":
+		if *version == 0 {
+			return false, fmt.Errorf("missing %q", "RINEX VERSION / TYPE")
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// decodeEpoch decompresses the next epoch and writes the RINEX lines to the output buffer.
+func (cr *CrxReader) decodeEpoch() error {
+	var line string
+	for {
+		if !cr.readLine() {
+			if err := cr.sc.Err(); err != nil {
+				return err
+			}
+			return io.EOF
+		}
+		line = cr.line()
+		if line != "" {
+			break
+		}
+	}
+
+	// The epoch line, either an initialization or the text difference to the previous epoch line.
+	isInit := (cr.CrxVersion == crxVersion3 && line[0] == '>') || (cr.CrxVersion == crxVersion1 && line[0] == '&')
+	if isInit {
+		cr.epoLine = []byte(line)
+		if cr.CrxVersion == crxVersion1 {
+			cr.epoLine[0] = ' '
+		}
+		clear(cr.sats)
+		cr.clock.reset()
+	} else {
+		if cr.epoLine == nil {
+			return fmt.Errorf("crx: line %d: first epoch is not initialized", cr.lineNum)
+		}
+		cr.epoLine = applyTextDiff(cr.epoLine, line)
+	}
+	epoLine := strings.TrimRight(string(cr.epoLine), " ")
+
+	flagPos, nsatPos, satsPos := 28, 29, 32 // RINEX-2
+	if cr.CrxVersion == crxVersion3 {
+		flagPos, nsatPos, satsPos = 31, 32, 41
+	}
+	if len(epoLine) < nsatPos+3 {
+		return fmt.Errorf("crx: line %d: invalid epoch line: %q", cr.lineNum, epoLine)
+	}
+
+	flag, err := parseEpochFlag(epoLine[flagPos : flagPos+1])
+	if err != nil {
+		return fmt.Errorf("crx: line %d: parse epoch flag: %q: %v", cr.lineNum, epoLine, err)
+	}
+	nsat, err := strconv.Atoi(strings.TrimSpace(epoLine[nsatPos : nsatPos+3]))
+	if err != nil {
+		return fmt.Errorf("crx: line %d: parse number of satellites: %q: %v", cr.lineNum, epoLine, err)
+	}
+
+	// Special events: the epoch line and the special records are stored as they are.
+	if flag > EpochFlagPowerFailure {
+		cr.out.WriteString(epoLine)
+		cr.out.WriteByte('\n')
+		for i := 0; i < numEventLines(cr.CrxVersion, flag, nsat, cr.ntypes[""]); i++ {
+			if !cr.readLine() {
+				return fmt.Errorf("crx: line %d: unexpected EOF in special event records", cr.lineNum)
+			}
+			cr.out.WriteString(cr.line())
+			cr.out.WriteByte('\n')
+		}
+		return nil
+	}
+
+	sats := ""
+	if len(epoLine) > satsPos {
+		sats = epoLine[satsPos:]
+	}
+	if len(sats) != 3*nsat {
+		return fmt.Errorf("crx: line %d: number of satellites %d does not match the satellite list: %q", cr.lineNum, nsat, epoLine)
+	}
+
+	// Receiver clock offset
+	if !cr.readLine() {
+		return fmt.Errorf("crx: line %d: unexpected EOF, missing clock offset line", cr.lineNum)
+	}
+	clock, hasClock, err := cr.decodeClock(cr.line())
+	if err != nil {
+		return fmt.Errorf("crx: line %d: clock offset: %v", cr.lineNum, err)
+	}
+
+	cr.writeEpochLine(epoLine[:satsPos], sats, clock, hasClock)
+
+	// Observation data
+	satsNew := make(map[string]*crxSat, nsat)
+	for i := 0; i < nsat; i++ {
+		id := sats[3*i : 3*i+3]
+		ntypes, ok := cr.ntypes[""]
+		if cr.CrxVersion == crxVersion3 {
+			ntypes, ok = cr.ntypes[id[:1]]
+		}
+		if !ok {
+			return fmt.Errorf("crx: line %d: no observation types for satellite %q", cr.lineNum, id)
+		}
+
+		if !cr.readLine() {
+			return fmt.Errorf("crx: line %d: unexpected EOF, missing data for satellite %q", cr.lineNum, id)
+		}
+
+		sat, exists := cr.sats[id]
+		if !exists {
+			sat = newCrxSat(ntypes)
+		}
+		vals, present, err := sat.decode(cr.line(), cr.CrxVersion)
+		if err != nil {
+			return fmt.Errorf("crx: line %d: satellite %s: %v", cr.lineNum, id, err)
+		}
+		satsNew[id] = sat
+		cr.writeObs(id, vals, present, sat.flags)
+	}
+	cr.sats = satsNew
+
+	return nil
+}
+
+// numEventLines returns the number of lines following the epoch line of a special event. For RINEX-2 cycle slip
+// records these are the continuation lines of the epoch line and the observation lines of the satellites.
+func numEventLines(crxVersion int, flag EpochFlag, nsat, ntypes int) int {
+	if flag == EpochFlagCycleSlip && crxVersion == crxVersion1 {
+		return max(nsat-1, 0)/12 + nsat*((ntypes+4)/5)
+	}
+	return nsat
+}
+
+// decodeClock decodes the receiver clock offset line.
+func (cr *CrxReader) decodeClock(line string) (int64, bool, error) {
+	if strings.TrimSpace(line) == "" {
+		cr.clock.reset()
+		return 0, false, nil
+	}
+	val, err := decodeCrxField(&cr.clock, strings.TrimSpace(line))
+	return val, true, err
+}
+
+// decode decodes a compressed data line of a satellite. It returns the observations as integers,
+// i.e. the RINEX values multiplied by 1000, and if the observations are present.
+func (sat *crxSat) decode(line string, crxVersion int) ([]int64, []bool, error) {
+	ntypes := len(sat.arcs)
+	vals := make([]int64, ntypes)
+	present := make([]bool, ntypes)
+
+	pos := 0
+	for i := 0; i < ntypes; i++ {
+		if pos >= len(line) || line[pos] == ' ' { // blank: data missing
+			sat.arcs[i].reset()
+			pos++
+			continue
+		}
+		end := strings.IndexByte(line[pos:], ' ')
+		if end < 0 {
+			end = len(line)
+		} else {
+			end += pos
+		}
+		val, err := decodeCrxField(&sat.arcs[i], line[pos:end])
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %d: %v", i+1, err)
+		}
+		vals[i], present[i] = val, true
+		pos = end + 1
+	}
+
+	diff := ""
+	if pos < len(line) {
+		diff = line[pos:]
+	}
+	sat.flags = applyTextDiff(sat.flags, diff)
+	if crxVersion == crxVersion1 { // the flags of missing observations are cleared implicitly
+		for i := range present {
+			if !present[i] && 2*i+1 < len(sat.flags) {
+				sat.flags[2*i], sat.flags[2*i+1] = ' ', ' '
+			}
+		}
+	}
+	return vals, present, nil
+}
+
+// decodeCrxField decodes a compressed field, either an arc initialization "n&value"
+// or a difference of the arc's order.
+func decodeCrxField(arc *crxArc, field string) (int64, error) {
+	if len(field) > 1 && field[1] == '&' {
+		order := int(field[0] - '0')
+		if order < 0 || order > maxDiffOrder {
+			return 0, fmt.Errorf("invalid order of arc initialization: %q", field)
+		}
+		val, err := strconv.ParseInt(field[2:], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse %q: %v", field, err)
+		}
+		arc.initialize(order, val)
+		return val, nil
+	}
+
+	diff, err := strconv.ParseInt(field, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %q: %v", field, err)
+	}
+	return arc.decode(diff)
+}
+
+// writeEpochLine writes the RINEX epoch line(s).
+func (cr *CrxReader) writeEpochLine(epoLine, sats string, clock int64, hasClock bool) {
+	if cr.CrxVersion == crxVersion3 {
+		line := strings.TrimRight(epoLine, " ")
+		if hasClock {
+			line = fmt.Sprintf("%-41s%s", line, formatScaledInt(clock, 12, 15))
+		}
+		cr.out.WriteString(line)
+		cr.out.WriteByte('\n')
+		return
+	}
+
+	// RINEX-2: 12 satellites per line, the clock offset in the first line.
+	for i := 0; i == 0 || i < len(sats); i += 36 {
+		end := min(i+36, len(sats))
+		var line string
+		if i == 0 {
+			line = epoLine + sats[:end]
+			if hasClock {
+				line = fmt.Sprintf("%-68s%s", line, formatScaledInt(clock, 9, 12))
+			}
+		} else {
+			line = strings.Repeat(" ", 32) + sats[i:end]
+		}
+		cr.out.WriteString(strings.TrimRight(line, " "))
+		cr.out.WriteByte('\n')
+	}
+}
+
+// writeObs writes the RINEX observation line(s) for a satellite.
+func (cr *CrxReader) writeObs(id string, vals []int64, present []bool, flags []byte) {
+	var line strings.Builder
+	if cr.CrxVersion == crxVersion3 {
+		line.WriteString(id)
+	}
+	for i := range vals {
+		if cr.CrxVersion == crxVersion1 && i > 0 && i%5 == 0 {
+			cr.out.WriteString(strings.TrimRight(line.String(), " "))
+			cr.out.WriteByte('\n')
+			line.Reset()
+		}
+		if !present[i] { // the flags of missing observations are left blank
+			line.WriteString("                ")
+			continue
+		}
+		line.WriteString(formatScaledInt(vals[i], 3, 14))
+		for k := 2 * i; k < 2*i+2; k++ {
+			if k < len(flags) {
+				line.WriteByte(flags[k])
+			} else {
+				line.WriteByte(' ')
+			}
+		}
+	}
+	cr.out.WriteString(strings.TrimRight(line.String(), " "))
+	cr.out.WriteByte('\n')
+}
+
+// readLine reads the next line into buffer. It returns false if an error
+// occurs or EOF was reached.
+func (cr *CrxReader) readLine() bool {
+	if ok := cr.sc.Scan(); !ok {
+		return ok
+	}
+	cr.lineNum++
+	return true
+}
+
+// line returns the current line.
+func (cr *CrxReader) line() string {
+	return cr.sc.Text()
+}
+
+// applyTextDiff applies the Compact RINEX text difference diff to old and returns the new text.
+// A blank keeps the old character, '&' sets a blank and any other character replaces the old one.
+func applyTextDiff(old []byte, diff string) []byte {
+	n := max(len(old), len(diff))
+	res := make([]byte, n)
+	copy(res, old)
+	for i := len(old); i < n; i++ {
+		res[i] = ' '
+	}
+	for i := 0; i < len(diff); i++ {
+		switch c := diff[i]; c {
+		case ' ':
+		case '&':
+			res[i] = ' '
+		default:
+			res[i] = c
+		}
+	}
+	return res
+}
+
+// formatScaledInt formats the integer val, that is a decimal number scaled by 10^decimals,
+// right-aligned into a field of the given width.
+func formatScaledInt(val int64, decimals, width int) string {
+	sign := ""
+	if val < 0 {
+		sign = "-"
+		val = -val
+	}
+	scale := int64(1)
+	for i := 0; i < decimals; i++ {
+		scale *= 10
+	}
+	s := fmt.Sprintf("%s%d.%0*d", sign, val/scale, decimals, val%scale)
+	return fmt.Sprintf("%*s", width, s)
+}
+
+// crxArcOrder is the order of the differences used for compression, as used by RNX2CRX.
+const crxArcOrder = 3
+
+// encode returns the difference of the next value val with the arc's order.
+func (arc *crxArc) encode(val int64) int64 {
+	prev := arc.diffs
+	if arc.n < arc.order {
+		arc.n++
+	}
+	arc.diffs[0] = val
+	for k := 1; k <= arc.n; k++ {
+		arc.diffs[k] = arc.diffs[k-1] - prev[k-1]
+	}
+	return arc.diffs[arc.n]
+}
+
+// encodeCrxField encodes the value val of an arc, either as arc initialization or as difference.
+func encodeCrxField(arc *crxArc, val int64) string {
+	if arc.order < 0 {
+		arc.initialize(crxArcOrder, val)
+		return fmt.Sprintf("%d&%d", crxArcOrder, val)
+	}
+	return strconv.FormatInt(arc.encode(val), 10)
+}
+
+// The states of the CrxWriter.
+const (
+	crxStateHeader = iota
+	crxStateEpoch
+	crxStateEpochCont
+	crxStateData
+	crxStateEvent
+)
+
+// CrxWriter compresses RINEX observation data into the Compact RINEX (Hatanaka) format.
+// It implements io.WriteCloser, the plain RINEX text written to it is compressed and written to
+// the underlying writer. RINEX-2 data is compressed to CRINEX version 1.0, RINEX-3 and -4 data to version 3.0.
+// The output is the same as from the RNX2CRX program.
+type CrxWriter struct {
+	Pgm string // The program name written to the CRINEX PROG / DATE record.
+
+	w          *bufio.Writer
+	buf        []byte // incomplete line not yet processed
+	lineNum    int
+	err        error
+	state      int
+	crxVersion int
+	rnxVersion float32
+	ntypes     map[string]int
+
+	// The epoch currently read.
+	epoLine   string
+	flag      EpochFlag
+	nsat      int
+	satList   string
+	lines     []string
+	remaining int // the number of lines still to be read for the epoch
+
+	epoLinePrev []byte // the previous epoch line, nil if the next epoch must be initialized
+	clock       crxArc
+	sats        map[string]*crxSat
+}
+
+// NewCrxWriter returns a new Compact RINEX writer that writes to w.
+// Close must be called to flush the data.
+func NewCrxWriter(w io.Writer) *CrxWriter {
+	cw := &CrxWriter{Pgm: "gognss", w: bufio.NewWriter(w), ntypes: map[string]int{}, sats: map[string]*crxSat{}}
+	cw.clock.reset()
+	return cw
+}
+
+// Write compresses the RINEX data p.
+func (cw *CrxWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	cw.buf = append(cw.buf, p...)
+	for {
+		i := bytes.IndexByte(cw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(bytes.TrimRight(cw.buf[:i], "\r"))
+		cw.buf = cw.buf[i+1:]
+		if err := cw.processLine(line); err != nil {
+			cw.err = err
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close processes any remaining data and flushes the output. It does not close the underlying writer.
+func (cw *CrxWriter) Close() error {
+	if cw.err != nil {
+		return cw.err
+	}
+	if len(cw.buf) > 0 {
+		line := string(bytes.TrimRight(cw.buf, "\r"))
+		cw.buf = nil
+		if err := cw.processLine(line); err != nil {
+			cw.err = err
+			return err
+		}
+	}
+	switch cw.state {
+	case crxStateHeader:
+		cw.err = fmt.Errorf("crx: unexpected EOF in header")
+	case crxStateEpoch:
+	default:
+		cw.err = fmt.Errorf("crx: line %d: unexpected EOF in epoch %q", cw.lineNum, cw.epoLine)
+	}
+	if err := cw.w.Flush(); err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return cw.err
+}
+
+// processLine processes the next line of RINEX data.
+func (cw *CrxWriter) processLine(line string) error {
+	cw.lineNum++
+	switch cw.state {
+	case crxStateHeader:
+		return cw.processHeaderLine(line)
+	case crxStateEpoch:
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		return cw.processEpochLine(line)
+	case crxStateEpochCont:
+		if len(line) > 32 {
+			cw.satList += strings.TrimRight(line[32:min(len(line), 68)], " ")
+		}
+	case crxStateEvent, crxStateData:
+		cw.lines = append(cw.lines, line)
+	}
+
+	cw.remaining--
+	if cw.remaining > 0 {
+		return nil
+	}
+	switch cw.state {
+	case crxStateEpochCont:
+		return cw.startData()
+	case crxStateEvent:
+		cw.writeEvent()
+	case crxStateData:
+		if err := cw.encodeEpoch(); err != nil {
+			return err
+		}
+	}
+	cw.state = crxStateEpoch
+	return nil
+}
+
+// processHeaderLine writes the CRINEX header and passes the RINEX header through.
+func (cw *CrxWriter) processHeaderLine(line string) error {
+	if cw.lineNum == 1 {
+		if !strings.Contains(line, "RINEX VERSION / TYPE") {
+			return ErrNoHeader
+		}
+		if _, err := parseCrxHeaderLine(line, &cw.rnxVersion, cw.ntypes); err != nil {
+			return fmt.Errorf("crx: line %d: %v", cw.lineNum, err)
+		}
+		vers := "1.0"
+		cw.crxVersion = crxVersion1
+		if cw.rnxVersion >= 3 {
+			vers = "3.0"
+			cw.crxVersion = crxVersion3
+		}
+		fmt.Fprintf(cw.w, "%-20s%-40s%s\n", vers, "COMPACT RINEX FORMAT", "CRINEX VERS   / TYPE")
+		fmt.Fprintf(cw.w, "%-40s%-20s%s\n", cw.Pgm, time.Now().UTC().Format("02-Jan-06 15:04"), "CRINEX PROG / DATE")
+	}
+
+	cw.w.WriteString(line)
+	cw.w.WriteByte('\n')
+	eoh, err := parseCrxHeaderLine(line, &cw.rnxVersion, cw.ntypes)
+	if err != nil {
+		return fmt.Errorf("crx: line %d: %v", cw.lineNum, err)
+	}
+	if eoh {
+		cw.state = crxStateEpoch
+	}
+	return nil
+}
+
+// processEpochLine processes the (first) epoch line.
+func (cw *CrxWriter) processEpochLine(line string) error {
+	flagPos, nsatPos := 28, 29 // RINEX-2
+	if cw.crxVersion == crxVersion3 {
+		if line[0] != '>' {
+			return fmt.Errorf("crx: line %d: invalid epoch line: %q", cw.lineNum, line)
+		}
+		flagPos, nsatPos = 31, 32
+	}
+	if len(line) < nsatPos+3 {
+		return fmt.Errorf("crx: line %d: invalid epoch line: %q", cw.lineNum, line)
+	}
+
+	flag, err := parseEpochFlag(line[flagPos : flagPos+1])
+	if err != nil {
+		return fmt.Errorf("crx: line %d: parse epoch flag: %q: %v", cw.lineNum, line, err)
+	}
+	nsat, err := strconv.Atoi(strings.TrimSpace(line[nsatPos : nsatPos+3]))
+	if err != nil {
+		return fmt.Errorf("crx: line %d: parse number of satellites: %q: %v", cw.lineNum, line, err)
+	}
+
+	cw.epoLine, cw.flag, cw.nsat, cw.lines = line, flag, nsat, cw.lines[:0]
+	if flag > EpochFlagPowerFailure {
+		cw.state, cw.remaining = crxStateEvent, numEventLines(cw.crxVersion, flag, nsat, cw.ntypes[""])
+		if cw.remaining == 0 {
+			cw.writeEvent()
+			cw.state = crxStateEpoch
+		}
+		return nil
+	}
+
+	cw.satList = ""
+	if cw.crxVersion == crxVersion1 {
+		if len(line) > 32 {
+			cw.satList = strings.TrimRight(line[32:min(len(line), 68)], " ")
+		}
+		if nsat > 12 {
+			cw.state, cw.remaining = crxStateEpochCont, (nsat-1)/12
+			return nil
+		}
+	}
+	return cw.startData()
+}
+
+// startData prepares reading the observation lines of the current epoch.
+func (cw *CrxWriter) startData() error {
+	cw.state, cw.remaining = crxStateData, cw.nsat
+	if cw.crxVersion == crxVersion1 {
+		cw.remaining = cw.nsat * ((cw.ntypes[""] + 4) / 5)
+	}
+	if cw.remaining == 0 {
+		cw.state = crxStateEpoch
+		return cw.encodeEpoch()
+	}
+	return nil
+}
+
+// writeEvent writes a special event epoch that is stored as it is. The next epoch will be initialized.
+func (cw *CrxWriter) writeEvent() {
+	line := []byte(strings.TrimRight(cw.epoLine, " "))
+	if cw.crxVersion == crxVersion1 {
+		line[0] = '&'
+	}
+	cw.w.Write(line)
+	cw.w.WriteByte('\n')
+	for _, l := range cw.lines {
+		cw.w.WriteString(l)
+		cw.w.WriteByte('\n')
+	}
+	cw.epoLinePrev = nil
+}
+
+// encodeEpoch compresses and writes the current epoch.
+func (cw *CrxWriter) encodeEpoch() error {
+	satsPos, clockPos, clockDecimals := 32, 68, 9 // RINEX-2
+	if cw.crxVersion == crxVersion3 {
+		satsPos, clockPos, clockDecimals = 41, 41, 12
+		var sb strings.Builder
+		for _, l := range cw.lines {
+			sb.WriteString(fmt.Sprintf("%-3.3s", l))
+		}
+		cw.satList = sb.String()
+	}
+	if len(cw.satList) != 3*cw.nsat {
+		return fmt.Errorf("crx: line %d: number of satellites %d does not match the satellite list: %q", cw.lineNum, cw.nsat, cw.epoLine)
+	}
+
+	// Epoch line
+	epoLine := []byte(fmt.Sprintf("%-*.*s%s", satsPos, satsPos, cw.epoLine, cw.satList))
+	if cw.epoLinePrev == nil {
+		line := bytes.TrimRight(bytes.Clone(epoLine), " ")
+		if cw.crxVersion == crxVersion1 {
+			line[0] = '&'
+		}
+		cw.w.Write(line)
+		clear(cw.sats)
+		cw.clock.reset()
+	} else {
+		cw.w.WriteString(textDiff(cw.epoLinePrev, epoLine))
+	}
+	cw.w.WriteByte('\n')
+	cw.epoLinePrev = epoLine
+
+	// Receiver clock offset
+	clockStr := ""
+	if len(cw.epoLine) > clockPos {
+		clockStr = strings.TrimSpace(cw.epoLine[clockPos:min(len(cw.epoLine), clockPos+15)])
+	}
+	if clockStr == "" {
+		cw.clock.reset()
+	} else {
+		clock, err := parseScaledInt(clockStr, clockDecimals)
+		if err != nil {
+			return fmt.Errorf("crx: line %d: parse receiver clock offset: %q: %v", cw.lineNum, cw.epoLine, err)
+		}
+		cw.w.WriteString(encodeCrxField(&cw.clock, clock))
+	}
+	cw.w.WriteByte('\n')
+
+	// Observation data
+	satsNew := make(map[string]*crxSat, cw.nsat)
+	linesPerSat := 1
+	ntypes, ok := cw.ntypes[""]
+	if cw.crxVersion == crxVersion1 {
+		linesPerSat = (ntypes + 4) / 5
+	}
+	for i := 0; i < cw.nsat; i++ {
+		id := cw.satList[3*i : 3*i+3]
+		var obs string
+		if cw.crxVersion == crxVersion3 {
+			ntypes, ok = cw.ntypes[id[:1]]
+			if len(cw.lines[i]) > 3 {
+				obs = cw.lines[i][3:]
+			}
+		} else {
+			var sb strings.Builder
+			for _, l := range cw.lines[i*linesPerSat : (i+1)*linesPerSat] {
+				sb.WriteString(fmt.Sprintf("%-80.80s", l))
+			}
+			obs = sb.String()
+		}
+		if !ok {
+			return fmt.Errorf("crx: line %d: no observation types for satellite %q", cw.lineNum, id)
+		}
+
+		sat, exists := cw.sats[id]
+		if !exists {
+			sat = newCrxSat(ntypes)
+			fill := byte(' ')
+			if cw.crxVersion == crxVersion3 {
+				fill = '&' // makes every flag explicit for new satellites, as RNX2CRX does
+			}
+			sat.flags = bytes.Repeat([]byte{fill}, 2*ntypes)
+		}
+		line, err := cw.encodeObs(sat, obs)
+		if err != nil {
+			return fmt.Errorf("crx: line %d: satellite %s: %v", cw.lineNum, id, err)
+		}
+		satsNew[id] = sat
+		cw.w.WriteString(line)
+		cw.w.WriteByte('\n')
+	}
+	cw.sats = satsNew
+	return nil
+}
+
+// encodeObs compresses the observations obs of a satellite and returns the compressed line.
+func (cw *CrxWriter) encodeObs(sat *crxSat, obs string) (string, error) {
+	var line strings.Builder
+	flags := make([]byte, len(sat.flags))
+	copy(flags, sat.flags)
+	var blanks []int // the missing observations
+	for i := range sat.arcs {
+		field := ""
+		if 16*i < len(obs) {
+			field = obs[16*i : min(len(obs), 16*i+16)]
+		}
+		field = fmt.Sprintf("%-16s", field)
+
+		valStr := strings.TrimSpace(field[:14])
+		if valStr == "" { // data missing
+			sat.arcs[i].reset()
+			line.WriteByte(' ')
+			if cw.crxVersion == crxVersion1 {
+				blanks = append(blanks, i)
+				flags[2*i], flags[2*i+1] = ' ', ' '
+				continue
+			}
+		} else {
+			val, err := parseScaledInt(valStr, 3)
+			if err != nil {
+				return "", fmt.Errorf("field %d: %q: %v", i+1, valStr, err)
+			}
+			line.WriteString(encodeCrxField(&sat.arcs[i], val))
+			line.WriteByte(' ')
+		}
+		flags[2*i], flags[2*i+1] = field[14], field[15]
+	}
+
+	// CRINEX 1.0: the flags of missing observations are cleared implicitly.
+	diff := []byte(textDiff(sat.flags, flags))
+	for _, i := range blanks {
+		for k := 2 * i; k < min(2*i+2, len(diff)); k++ {
+			diff[k] = ' '
+		}
+	}
+	line.Write(diff)
+	sat.flags = flags
+	return strings.TrimRight(line.String(), " "), nil
+}
+
+// textDiff returns the Compact RINEX text difference of the new text to the old one, see applyTextDiff.
+// Trailing spaces are removed.
+func textDiff(old, new []byte) string {
+	n := max(len(old), len(new))
+	diff := make([]byte, n)
+	for i := range n {
+		o, c := byte(' '), byte(' ')
+		if i < len(old) {
+			o = old[i]
+		}
+		if i < len(new) {
+			c = new[i]
+		}
+		switch {
+		case o == c:
+			diff[i] = ' '
+		case c == ' ':
+			diff[i] = '&'
+		default:
+			diff[i] = c
+		}
+	}
+	return string(bytes.TrimRight(diff, " "))
+}
+
+// parseScaledInt parses the decimal number s and returns it as integer scaled by 10^decimals.
+func parseScaledInt(s string, decimals int) (int64, error) {
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if len(fracPart) > decimals {
+		return 0, fmt.Errorf("too many decimals")
+	}
+	neg := strings.HasPrefix(intPart, "-")
+	intPart = strings.TrimPrefix(intPart, "-")
+	if intPart == "" {
+		intPart = "0"
+	}
+	digits := intPart + fracPart + strings.Repeat("0", decimals-len(fracPart))
+	val, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		val = -val
+	}
+	return val, nil
+}
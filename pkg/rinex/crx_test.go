@@ -0,0 +1,176 @@
+package rinex
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrxReader(t *testing.T) {
+	assert := assert.New(t)
+	r, err := os.Open("testdata/white/brst155h.20d")
+	assert.NoError(err)
+	defer r.Close()
+
+	cr, err := NewCrxReader(r)
+	assert.NoError(err)
+	assert.Equal(1, cr.CrxVersion, "CRINEX version")
+	assert.Equal(float32(2.11), cr.RINEXVersion, "RINEX version")
+
+	rnx, err := os.Open("testdata/white/brst155h.20o")
+	assert.NoError(err)
+	defer rnx.Close()
+
+	// The decompressed data must match the original file, apart from the leading zeros
+	// of values < 1 which are missing in the original file.
+	sc1, sc2 := bufio.NewScanner(cr), bufio.NewScanner(rnx)
+	lineNum := 0
+	for sc1.Scan() {
+		lineNum++
+		if !sc2.Scan() {
+			t.Fatalf("line %d: decompressed data longer than original", lineNum)
+		}
+		want := strings.ReplaceAll(sc2.Text(), " .", "0.")
+		if !assert.Equal(want, sc1.Text(), "line %d", lineNum) {
+			break
+		}
+	}
+	assert.NoError(sc1.Err())
+	assert.False(sc2.Scan(), "decompressed data shorter than original")
+}
+
+func TestCrxReader_v3(t *testing.T) {
+	assert := assert.New(t)
+	r, err := os.Open("testdata/white/BRUX00BEL_R_20202302000_01H_30S_MO.crx")
+	assert.NoError(err)
+	defer r.Close()
+
+	dec, err := NewObsDecoder(r)
+	assert.NoError(err)
+	assert.Equal(float32(3.04), dec.Header.RINEXVersion, "RINEX version")
+
+	numOfEpochs := 0
+	for dec.NextEpoch() {
+		numOfEpochs++
+	}
+	assert.NoError(dec.Err())
+	assert.Equal(120, numOfEpochs, "#epochs")
+}
+
+func TestCrxReader_errors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewCrxReader(strings.NewReader("     3.04           OBSERVATION DATA    M                   RINEX VERSION / TYPE\n"))
+	assert.ErrorIs(err, ErrNoCrxHeader)
+
+	r, err := os.Open("testdata/black/hubu00DEU_R_20230931500_01H_30S_MO.crx")
+	assert.NoError(err)
+	defer r.Close()
+
+	cr, err := NewCrxReader(r)
+	assert.NoError(err)
+	_, err = io.Copy(io.Discard, cr)
+	assert.Error(err)
+	t.Logf("%v", err)
+}
+
+func TestApplyTextDiff(t *testing.T) {
+	tests := []struct {
+		old, diff, want string
+	}{
+		{old: "", diff: "abc", want: "abc"},
+		{old: "abc", diff: "", want: "abc"},
+		{old: "abc", diff: " x", want: "axc"},
+		{old: "abc", diff: "& &", want: " b "},
+		{old: "abc", diff: "   de", want: "abcde"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, string(applyTextDiff([]byte(tt.old), tt.diff)), "%q + %q", tt.old, tt.diff)
+	}
+}
+
+func TestCrxWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		rnxFile string
+		crxFile string
+	}{
+		{name: "crx1", rnxFile: "testdata/white/brst155h.20o", crxFile: "testdata/white/brst155h.20d"},
+		{name: "crx3", rnxFile: "", crxFile: "testdata/white/BRUX00BEL_R_20202302000_01H_30S_MO.crx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			want, err := os.ReadFile(tt.crxFile)
+			assert.NoError(err)
+
+			// Without the RINEX file use the decompressed data.
+			var rnx io.Reader
+			if tt.rnxFile != "" {
+				r, err := os.Open(tt.rnxFile)
+				assert.NoError(err)
+				defer r.Close()
+				rnx = r
+			} else {
+				rnx, err = NewCrxReader(bytes.NewReader(want))
+				assert.NoError(err)
+			}
+
+			var buf bytes.Buffer
+			cw := NewCrxWriter(&buf)
+			_, err = io.Copy(cw, rnx)
+			assert.NoError(err)
+			assert.NoError(cw.Close())
+
+			// The output must be identical to RNX2CRX, except the CRINEX PROG / DATE line.
+			gotLines, wantLines := strings.Split(buf.String(), "\n"), strings.Split(string(want), "\n")
+			assert.Equal(len(wantLines), len(gotLines), "#lines")
+			assert.True(strings.HasPrefix(gotLines[1], "gognss"), "CRINEX PROG / DATE")
+			for i := range min(len(gotLines), len(wantLines)) {
+				if i == 1 {
+					continue
+				}
+				if !assert.Equal(wantLines[i], gotLines[i], "line %d", i+1) {
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCrxWriter_errors(t *testing.T) {
+	assert := assert.New(t)
+
+	cw := NewCrxWriter(io.Discard)
+	_, err := io.WriteString(cw, "1.0                 COMPACT RINEX FORMAT                    CRINEX VERS   / TYPE\n")
+	assert.ErrorIs(err, ErrNoHeader)
+
+	// incomplete epoch
+	rnx, err := os.ReadFile("testdata/white/brst155h.20o")
+	assert.NoError(err)
+	cw = NewCrxWriter(io.Discard)
+	_, err = cw.Write(rnx[:len(rnx)-200])
+	assert.NoError(err)
+	assert.Error(cw.Close())
+}
+
+func TestObsFile_Compress(t *testing.T) {
+	assert := assert.New(t)
+	rnxFilePath, err := copyToTempDir("testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", t.TempDir())
+	assert.NoError(err)
+
+	obsFil, err := NewObsFile(rnxFilePath)
+	assert.NoError(err)
+	assert.NoError(obsFil.Compress())
+	assert.Equal("BRUX00BEL_R_20183101900_01H_30S_MO.crx.gz", filepath.Base(obsFil.Path))
+	assert.Equal("crx", obsFil.Format)
+	assert.Equal("gz", obsFil.Compression)
+	assert.NoFileExists(rnxFilePath)
+	assert.FileExists(obsFil.Path)
+}
@@ -0,0 +1,282 @@
+package rinex
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// DiffOptions sets options for file comparison.
+type DiffOptions struct {
+	SatSys      string // satellite systems GRE..., empty for all systems
+	CheckHeader bool   // also compare the RINEX header
+
+	// The tolerances for the observation values. Differences greater than the tolerance are reported.
+	PhaseTolerance   float64 // carrier phase in cycles
+	CodeTolerance    float64 // pseudorange in meters
+	DopplerTolerance float64 // doppler in Hz
+	SNRTolerance     float64 // signal strength, usually in dBHz
+
+	PhaseFraction bool // compare only the fractional part of the carrier phases, e.g. if the ambiguities differ
+	MaxObsDiffs   int  // the maximum number of observation differences listed, 0 for no limit. The summary counts all differences.
+}
+
+// DiffKind is the kind of difference of an observation.
+type DiffKind string
+
+const (
+	DiffValue   DiffKind = "value"   // The values differ more than the tolerance.
+	DiffLLI     DiffKind = "lli"     // The loss of lock indicators differ.
+	DiffSNR     DiffKind = "snr"     // The signal strength indicators differ.
+	DiffMissing DiffKind = "missing" // The observation is missing in one of the files.
+)
+
+// ObsDiff is the result of the comparison of two RINEX observation files.
+type ObsDiff struct {
+	Header      []HeaderChange  `json:"header,omitempty"`      // The header fields that differ, Old is the value of file 1.
+	OnlyInFile1 []time.Time     `json:"onlyInFile1,omitempty"` // The epochs that exist in file 1 only.
+	OnlyInFile2 []time.Time     `json:"onlyInFile2,omitempty"` // The epochs that exist in file 2 only.
+	Obs         []*ObsValueDiff `json:"obs,omitempty"`         // The observation differences of the common epochs.
+	Summary     DiffSummary     `json:"summary"`
+}
+
+// ObsValueDiff is the difference of an observation between two files.
+type ObsValueDiff struct {
+	Time  time.Time  `json:"time"`
+	PRN   gnss.PRN   `json:"prn"`
+	Code  ObsCode    `json:"code"`
+	Obs1  *Obs       `json:"obs1"`  // The observation of file 1, nil if missing.
+	Obs2  *Obs       `json:"obs2"`  // The observation of file 2, nil if missing.
+	Delta float64    `json:"delta"` // The difference of the values, file 2 minus file 1.
+	Kinds []DiffKind `json:"kinds"`
+}
+
+// DiffSummary contains the counts of a file comparison.
+type DiffSummary struct {
+	Epochs1      int              `json:"epochs1"`      // The number of epochs of file 1.
+	Epochs2      int              `json:"epochs2"`      // The number of epochs of file 2.
+	CommonEpochs int              `json:"commonEpochs"` // The number of epochs in both files.
+	ComparedObs  int              `json:"comparedObs"`  // The number of observations compared in the common epochs.
+	HeaderDiffs  int              `json:"headerDiffs"`
+	ValueDiffs   int              `json:"valueDiffs"`
+	LLIDiffs     int              `json:"lliDiffs"`
+	SNRDiffs     int              `json:"snrDiffs"`
+	MissingObs   int              `json:"missingObs"`
+	PerPRN       map[gnss.PRN]int `json:"perPRN"`  // The number of differing observations per satellite.
+	PerCode      map[ObsCode]int  `json:"perCode"` // The number of differing observations per observation code.
+}
+
+// String returns the difference in a readable form, e.g.
+// "2019-09-27T10:00:00Z G01 L1C  84051594.915 0 7 | 84051594.925 0 7 [value]".
+func (d *ObsValueDiff) String() string {
+	format := func(obs *Obs) string {
+		if obs == nil {
+			return fmt.Sprintf("%14s %1s %1s", "-", "", "")
+		}
+		return fmt.Sprintf("%14.03f %d %d", obs.Val, obs.LLI, obs.SNR)
+	}
+	return fmt.Sprintf("%s %s %-3s %s | %s %v", d.Time.Format(time.RFC3339Nano), d.PRN, d.Code, format(d.Obs1), format(d.Obs2), d.Kinds)
+}
+
+// Equal reports whether no differences were found.
+func (d *ObsDiff) Equal() bool {
+	s := d.Summary
+	return s.HeaderDiffs == 0 && len(d.OnlyInFile1) == 0 && len(d.OnlyInFile2) == 0 &&
+		s.ValueDiffs == 0 && s.LLIDiffs == 0 && s.SNRDiffs == 0 && s.MissingObs == 0
+}
+
+// Diff compares two RINEX obs files.
+func (f *ObsFile) Diff(obsFil2 *ObsFile, opts DiffOptions) (*ObsDiff, error) {
+	// file 1
+	r, err := OpenFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open obs file: %v", err)
+	}
+	defer r.Close()
+	dec, err := NewObsDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// file 2
+	r2, err := OpenFile(obsFil2.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open obs file: %v", err)
+	}
+	defer r2.Close()
+	dec2, err := NewObsDecoder(r2)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffObs(dec, dec2, opts)
+}
+
+// DiffObs compares the header and the epochs of two RINEX observation streams. The special events are not compared.
+func DiffObs(dec1, dec2 *ObsDecoder, opts DiffOptions) (*ObsDiff, error) {
+	d := &ObsDiff{Summary: DiffSummary{PerPRN: map[gnss.PRN]int{}, PerCode: map[ObsCode]int{}}}
+	if opts.CheckHeader {
+		d.Header = diffObsHeader(&dec1.Header, &dec2.Header)
+		d.Summary.HeaderDiffs = len(d.Header)
+	}
+
+	isObsEpoch := func(epo *Epoch) bool { return epo.Flag <= EpochFlagPowerFailure }
+	next1, stop1 := iter.Pull2(filterSeq(dec1.Epochs(), isObsEpoch))
+	defer stop1()
+	next2, stop2 := iter.Pull2(filterSeq(dec2.Epochs(), isObsEpoch))
+	defer stop2()
+
+	epo1, err1, ok1 := next1()
+	epo2, err2, ok2 := next2()
+	for ok1 || ok2 {
+		if err := errors.Join(err1, err2); err != nil {
+			return d, err
+		}
+		switch {
+		case ok1 && ok2 && epo1.Time.Equal(epo2.Time):
+			d.Summary.Epochs1++
+			d.Summary.Epochs2++
+			d.Summary.CommonEpochs++
+			d.diffEpoch(epo1, epo2, &opts)
+			epo1, err1, ok1 = next1()
+			epo2, err2, ok2 = next2()
+		case !ok2 || (ok1 && epo1.Time.Before(epo2.Time)):
+			d.Summary.Epochs1++
+			d.OnlyInFile1 = append(d.OnlyInFile1, epo1.Time)
+			epo1, err1, ok1 = next1()
+		default:
+			d.Summary.Epochs2++
+			d.OnlyInFile2 = append(d.OnlyInFile2, epo2.Time)
+			epo2, err2, ok2 = next2()
+		}
+	}
+	return d, errors.Join(err1, err2)
+}
+
+// diffObsHeader returns the fields that differ between the headers. The list of header labels is not compared.
+func diffObsHeader(hdr1, hdr2 *ObsHeader) []HeaderChange {
+	changes := []HeaderChange{}
+	v1, v2 := reflect.ValueOf(hdr1).Elem(), reflect.ValueOf(hdr2).Elem()
+	for i := range v1.NumField() {
+		name := v1.Type().Field(i).Name
+		if name == "Labels" {
+			continue
+		}
+		if f1, f2 := v1.Field(i).Interface(), v2.Field(i).Interface(); !reflect.DeepEqual(f1, f2) {
+			changes = append(changes, HeaderChange{Field: name, Old: f1, New: f2})
+		}
+	}
+	return changes
+}
+
+// diffEpoch compares the observations of two epochs with the same time.
+func (d *ObsDiff) diffEpoch(epo1, epo2 *Epoch, opts *DiffOptions) {
+	keep := func(prn gnss.PRN) bool {
+		return opts.SatSys == "" || strings.Contains(opts.SatSys, prn.Sys.Abbr())
+	}
+	sats2 := make(map[gnss.PRN]SatObs, len(epo2.ObsList))
+	for _, satObs := range epo2.ObsList {
+		if keep(satObs.Prn) {
+			sats2[satObs.Prn] = satObs
+		}
+	}
+	for _, satObs1 := range epo1.ObsList {
+		if !keep(satObs1.Prn) {
+			continue
+		}
+		satObs2 := sats2[satObs1.Prn]
+		delete(sats2, satObs1.Prn)
+		d.diffSatObs(epo1.Time, satObs1.Prn, satObs1.Obss, satObs2.Obss, opts)
+	}
+	for _, satObs2 := range epo2.ObsList { // satellites of file 2 only
+		if _, ok := sats2[satObs2.Prn]; ok {
+			d.diffSatObs(epo1.Time, satObs2.Prn, nil, satObs2.Obss, opts)
+		}
+	}
+}
+
+// diffSatObs compares the observations of a satellite. Empty observations are treated as missing.
+func (d *ObsDiff) diffSatObs(t time.Time, prn gnss.PRN, obss1, obss2 map[ObsCode]Obs, opts *DiffOptions) {
+	codes := make([]ObsCode, 0, len(obss1))
+	for code := range obss1 {
+		codes = append(codes, code)
+	}
+	for code := range obss2 {
+		if _, ok := obss1[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+	slices.Sort(codes)
+
+	for _, code := range codes {
+		obs1, ok1 := obss1[code]
+		obs2, ok2 := obss2[code]
+		ok1, ok2 = ok1 && obs1 != (Obs{}), ok2 && obs2 != (Obs{})
+		if !ok1 && !ok2 {
+			continue
+		}
+		d.Summary.ComparedObs++
+
+		diff := &ObsValueDiff{Time: t, PRN: prn, Code: code}
+		if ok1 {
+			diff.Obs1 = &obs1
+		}
+		if ok2 {
+			diff.Obs2 = &obs2
+		}
+		if !ok1 || !ok2 {
+			diff.Kinds = append(diff.Kinds, DiffMissing)
+			d.Summary.MissingObs++
+		} else {
+			diff.Delta = obs2.Val - obs1.Val
+			if opts.PhaseFraction && code[0] == 'L' {
+				diff.Delta = math.Remainder(diff.Delta, 1)
+			}
+			if math.Abs(diff.Delta) > opts.tolerance(code) {
+				diff.Kinds = append(diff.Kinds, DiffValue)
+				d.Summary.ValueDiffs++
+			}
+			if obs1.LLI != obs2.LLI {
+				diff.Kinds = append(diff.Kinds, DiffLLI)
+				d.Summary.LLIDiffs++
+			}
+			if obs1.SNR != obs2.SNR {
+				diff.Kinds = append(diff.Kinds, DiffSNR)
+				d.Summary.SNRDiffs++
+			}
+		}
+		if len(diff.Kinds) == 0 {
+			continue
+		}
+		d.Summary.PerPRN[prn]++
+		d.Summary.PerCode[code]++
+		if opts.MaxObsDiffs == 0 || len(d.Obs) < opts.MaxObsDiffs {
+			d.Obs = append(d.Obs, diff)
+		}
+	}
+}
+
+// tolerance returns the tolerance for the observation type of the code.
+func (opts *DiffOptions) tolerance(code ObsCode) float64 {
+	if code == "" {
+		return 0
+	}
+	switch code[0] {
+	case 'L':
+		return opts.PhaseTolerance
+	case 'C', 'P':
+		return opts.CodeTolerance
+	case 'D':
+		return opts.DopplerTolerance
+	case 'S':
+		return opts.SNRTolerance
+	}
+	return 0
+}
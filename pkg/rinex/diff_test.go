@@ -0,0 +1,96 @@
+package rinex
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffObs(t *testing.T) {
+	assert := assert.New(t)
+	data, err := os.ReadFile("testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := func(data1, data2 string, opts DiffOptions) *ObsDiff {
+		dec1, err := NewObsDecoder(strings.NewReader(data1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec2, err := NewObsDecoder(strings.NewReader(data2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := DiffObs(dec1, dec2, opts)
+		assert.NoError(err)
+		return d
+	}
+
+	d := diff(string(data), string(data), DiffOptions{CheckHeader: true})
+	assert.True(d.Equal())
+	assert.Equal(120, d.Summary.CommonEpochs)
+	assert.Greater(d.Summary.ComparedObs, 0)
+
+	// Modify the second file: header, first epoch removed, C05 C2I +10 m and L2I LLI set in the 2nd epoch.
+	lines := strings.Split(string(data), "\n")
+	var epo1, c05 int
+	for i, line := range lines {
+		if strings.HasPrefix(line, "> 2018 11 06 19 00  0.0000000") {
+			epo1 = i
+		}
+		if strings.HasPrefix(line, "> 2018 11 06 19 00 30.0000000") {
+			c05 = i + 1
+			break
+		}
+	}
+	line := lines[c05]
+	assert.Equal("C05  40277819.057 5 209737186.71405", line[:35])
+	lines[c05] = line[:3] + "  40277829.057 5" + line[19:33] + "1" + line[34:]
+	epoLines := 1
+	for !strings.HasPrefix(lines[epo1+epoLines], "> ") {
+		epoLines++
+	}
+	lines = append(lines[:epo1], lines[epo1+epoLines:]...)
+	data2 := strings.Join(lines, "\n")
+	data2 = strings.Replace(data2, "BRUX                                                        MARKER NAME", "BRUS                                                        MARKER NAME", 1)
+
+	d = diff(string(data), data2, DiffOptions{CheckHeader: true, CodeTolerance: 0.01, PhaseTolerance: 0.01})
+	assert.False(d.Equal())
+	if assert.Len(d.Header, 1) {
+		assert.Equal(HeaderChange{Field: "MarkerName", Old: "BRUX", New: "BRUS"}, d.Header[0])
+	}
+	assert.Equal([]time.Time{time.Date(2018, 11, 6, 19, 0, 0, 0, time.UTC)}, d.OnlyInFile1)
+	assert.Empty(d.OnlyInFile2)
+	assert.Equal(119, d.Summary.CommonEpochs)
+	assert.Equal(1, d.Summary.ValueDiffs)
+	assert.Equal(1, d.Summary.LLIDiffs)
+	assert.Equal(0, d.Summary.SNRDiffs)
+	assert.Equal(2, d.Summary.PerPRN[gnss.PRN{Sys: gnss.SysBDS, Num: 5}])
+	if assert.Len(d.Obs, 2) {
+		assert.Equal(ObsCode("C2I"), d.Obs[0].Code)
+		assert.Equal([]DiffKind{DiffValue}, d.Obs[0].Kinds)
+		assert.InDelta(10.0, d.Obs[0].Delta, 1e-6)
+		assert.Equal(ObsCode("L2I"), d.Obs[1].Code)
+		assert.Equal([]DiffKind{DiffLLI}, d.Obs[1].Kinds)
+		assert.Equal("2018-11-06T19:00:30Z C05 C2I   40277819.057 0 5 |   40277829.057 0 5 [value]", d.Obs[0].String())
+	}
+
+	// system filter and MaxObsDiffs
+	d = diff(string(data), data2, DiffOptions{SatSys: "GR"})
+	assert.Empty(d.Obs)
+	d = diff(string(data), data2, DiffOptions{MaxObsDiffs: 1})
+	assert.Len(d.Obs, 1)
+	assert.Equal(2, d.Summary.PerCode["C2I"]+d.Summary.PerCode["L2I"])
+
+	b, err := json.Marshal(d)
+	assert.NoError(err)
+	var got ObsDiff
+	assert.NoError(json.Unmarshal(b, &got))
+	assert.Equal(d.Summary, got.Summary)
+	assert.Equal(d.Obs[0].PRN, got.Obs[0].PRN)
+}
@@ -0,0 +1,246 @@
+package rinex
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// The errors of EphStore.Best.
+var (
+	ErrNoEphemeris      = errors.New("rinex: no ephemeris")               // There is no ephemeris for the satellite at all.
+	ErrEphemerisExpired = errors.New("rinex: no ephemeris valid at time") // No ephemeris of the satellite is valid at the time.
+	ErrUnhealthy        = errors.New("rinex: satellite unhealthy")        // The ephemerides valid at the time are unhealthy.
+)
+
+// ephValidity is the maximum time difference to the reference time of the ephemeris per system, as used by RTKLIB.
+// For GPS the fit interval of the ephemeris is used, for QZSS the fit interval flag.
+var ephValidity = map[gnss.System]time.Duration{
+	gnss.SysGPS:   2 * time.Hour,
+	gnss.SysGLO:   30 * time.Minute,
+	gnss.SysGAL:   4 * time.Hour,
+	gnss.SysQZSS:  time.Hour,
+	gnss.SysBDS:   6 * time.Hour,
+	gnss.SysNavIC: 2 * time.Hour,
+	gnss.SysSBAS:  6 * time.Minute,
+}
+
+// ephMeta are the properties of an ephemeris used for the selection.
+type ephMeta struct {
+	ref      time.Time     // the reference time, i.e. TOE, or TOC for GLONASS and SBAS
+	validity time.Duration // the ephemeris is valid within ref +- validity
+	healthy  bool
+	iod      int       // the issue of data, IODE, IODnav, AODE, IODEC or IODN; -1 if there is none
+	tot      time.Time // the transmission time of the message; zero if unknown
+	hasOrbit bool
+}
+
+// newEphMeta returns the properties of the ephemeris.
+func newEphMeta(eph Eph) ephMeta {
+	m := ephMeta{ref: eph.GetTime(), validity: ephValidity[eph.GetPRN().Sys], healthy: true, iod: -1, hasOrbit: true}
+	tom := unknownTom
+	switch eph := eph.(type) {
+	case *EphGPS:
+		m.ref, m.healthy, m.iod, tom, m.hasOrbit = weekTime(gpsEpoch, eph.ToeWeek, eph.Toe), eph.Health == 0, int(eph.IODE), eph.Tom, eph.SqrtA != 0
+		if eph.FitInterval > 0 {
+			m.validity = time.Duration(eph.FitInterval*float64(time.Hour)) / 2
+		}
+	case *EphGAL:
+		m.ref, m.healthy, m.iod, tom, m.hasOrbit = weekTime(gpsEpoch, eph.ToeWeek, eph.Toe), eph.Health == 0, int(eph.IODnav), eph.Tom, eph.SqrtA != 0
+	case *EphQZSS:
+		m.ref, m.healthy, m.iod, tom, m.hasOrbit = weekTime(gpsEpoch, eph.ToeWeek, eph.Toe), eph.Health == 0, int(eph.IODE), eph.Tom, eph.SqrtA != 0
+		if eph.FitInterval != 0 {
+			m.validity = 2 * time.Hour
+		}
+	case *EphBDS:
+		m.ref, m.healthy, m.iod, tom, m.hasOrbit = weekTime(bdsEpoch, eph.ToeWeek, eph.Toe), eph.Health == 0, int(eph.AODE), eph.Tom, eph.SqrtA != 0
+		if !isLegacyMessage(eph.MessageType) {
+			m.iod = int(eph.IODE)
+		}
+	case *EphNavIC:
+		m.ref, m.healthy, m.iod, tom, m.hasOrbit = weekTime(gpsEpoch, eph.ToeWeek, eph.Toe), eph.Health == 0, int(eph.IODEC), eph.Tom, eph.SqrtA != 0
+	case *EphGLO:
+		m.healthy, tom, m.hasOrbit = eph.Health == 0, eph.FrameTime, eph.X != 0 || eph.Y != 0 || eph.Z != 0
+	case *EphSBAS:
+		// The SBAS health is a bit mask of the services and not evaluated.
+		m.iod, tom, m.hasOrbit = int(eph.IODN), eph.FrameTime, eph.X != 0 || eph.Y != 0 || eph.Z != 0
+	}
+	m.tot = transmissionTime(m.ref, tom)
+	return m
+}
+
+// unknownTom is the transmission time of the message in seconds of the week if it is not known.
+const unknownTom = 0.9999e9
+
+// transmissionTime returns the transmission time of a message given in seconds of the week tom, in the week
+// closest to the reference time ref, see nearestWeekTime. It returns the zero time if tom is unknown.
+func transmissionTime(ref time.Time, tom float64) time.Time {
+	if ref.IsZero() || math.Abs(tom) >= unknownTom {
+		return time.Time{}
+	}
+	return nearestWeekTime(ref, tom)
+}
+
+// storedEph is an ephemeris with its properties.
+type storedEph struct {
+	eph Eph
+	ephMeta
+}
+
+// EphStore holds broadcast ephemerides in memory, e.g. of a multi-day BRDC file, and selects the best valid
+// ephemeris for a satellite and time. The ephemerides of a satellite are ordered by their reference time TOE,
+// or TOC for GLONASS and SBAS.
+type EphStore struct {
+	Unhealthy  bool // Select unhealthy ephemerides too.
+	PreferFNAV bool // Prefer the Galileo F/NAV to the I/NAV ephemerides.
+
+	ephs       map[gnss.PRN][]*storedEph
+	duplicates int
+}
+
+// NewEphStore returns a new empty ephemeris store.
+func NewEphStore() *EphStore {
+	return &EphStore{ephs: map[gnss.PRN][]*storedEph{}}
+}
+
+// Load adds all ephemerides of the decoder, see Add.
+func (s *EphStore) Load(dec *NavDecoder) error {
+	for dec.NextEphemeris() {
+		s.Add(dec.Ephemeris())
+	}
+	return dec.Err()
+}
+
+// Add adds the ephemeris to the store and reports whether it was added. Duplicates, i.e. ephemerides of the
+// satellite with the same reference time, message type and issue of data, are not added. If the issue of data
+// differs, the ephemeris of the later transmission replaces the other one, or the ephemeris added last if the
+// transmission times are unknown. Ephemerides without orbit parameters, e.g. of unknown RINEX-4 message types,
+// are not added.
+func (s *EphStore) Add(eph Eph) bool {
+	m := newEphMeta(eph)
+	if !m.hasOrbit {
+		return false
+	}
+	prn := eph.GetPRN()
+	list := s.ephs[prn]
+	i, _ := slices.BinarySearchFunc(list, m.ref, func(se *storedEph, t time.Time) int { return se.ref.Compare(t) })
+	for j := i; j < len(list) && list[j].ref.Equal(m.ref); j++ {
+		if messageType(list[j].eph) != messageType(eph) {
+			continue
+		}
+		s.duplicates++
+		if list[j].iod != m.iod && (m.tot.IsZero() || list[j].tot.IsZero() || m.tot.After(list[j].tot)) {
+			list[j] = &storedEph{eph: eph, ephMeta: m}
+			return true
+		}
+		return false
+	}
+	s.ephs[prn] = slices.Insert(list, i, &storedEph{eph: eph, ephMeta: m})
+	return true
+}
+
+// Best returns the best ephemeris of the satellite at the time t, i.e. the healthy ephemeris with the reference
+// time closest to t within its validity. For Galileo the I/NAV ephemerides are preferred, see PreferFNAV.
+// The errors ErrNoEphemeris, ErrEphemerisExpired or ErrUnhealthy are returned wrapped if there is none.
+func (s *EphStore) Best(prn gnss.PRN, t time.Time) (Eph, error) {
+	list := s.ephs[prn]
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%w for %s", ErrNoEphemeris, prn)
+	}
+
+	var best *storedEph
+	bestAge, bestPreferred := time.Duration(0), false
+	nValid := 0
+	for _, se := range list {
+		age := t.Sub(se.ref).Abs()
+		if age > se.validity {
+			continue
+		}
+		nValid++
+		if !se.healthy && !s.Unhealthy {
+			continue
+		}
+		preferred := true
+		if gal, ok := se.eph.(*EphGAL); ok {
+			preferred = gal.IsFNAV() == s.PreferFNAV
+		}
+		if best == nil || preferred && !bestPreferred || preferred == bestPreferred && age <= bestAge {
+			best, bestAge, bestPreferred = se, age, preferred
+		}
+	}
+	if best != nil {
+		return best.eph, nil
+	}
+	if nValid > 0 {
+		return nil, fmt.Errorf("%w: %s at %s", ErrUnhealthy, prn, t.Format(time.RFC3339))
+	}
+	return nil, fmt.Errorf("%w: %s at %s", ErrEphemerisExpired, prn, t.Format(time.RFC3339))
+}
+
+// Ephemerides returns the ephemerides of the satellite ordered by their reference time.
+func (s *EphStore) Ephemerides(prn gnss.PRN) []Eph {
+	ephs := make([]Eph, 0, len(s.ephs[prn]))
+	for _, se := range s.ephs[prn] {
+		ephs = append(ephs, se.eph)
+	}
+	return ephs
+}
+
+// PRNs returns the satellites of the store in sorted order.
+func (s *EphStore) PRNs() []gnss.PRN {
+	prns := slices.Collect(maps.Keys(s.ephs))
+	slices.SortFunc(prns, comparePRN)
+	return prns
+}
+
+// comparePRN orders satellites by system and number.
+func comparePRN(a, b gnss.PRN) int {
+	if a.Sys != b.Sys {
+		return int(a.Sys) - int(b.Sys)
+	}
+	return int(a.Num) - int(b.Num)
+}
+
+// Len returns the number of ephemerides in the store.
+func (s *EphStore) Len() int {
+	n := 0
+	for _, list := range s.ephs {
+		n += len(list)
+	}
+	return n
+}
+
+// Duplicates returns the number of duplicates found by Add, including the replaced ephemerides.
+func (s *EphStore) Duplicates() int {
+	return s.duplicates
+}
+
+// messageType returns the RINEX-4 message type of the ephemeris. For Galileo the message is derived
+// from the data sources in RINEX-3.
+func messageType(eph Eph) string {
+	switch eph := eph.(type) {
+	case *EphGPS:
+		return eph.MessageType
+	case *EphGAL:
+		if eph.IsFNAV() {
+			return "FNAV"
+		}
+		return "INAV"
+	case *EphGLO:
+		return eph.MessageType
+	case *EphQZSS:
+		return eph.MessageType
+	case *EphBDS:
+		return eph.MessageType
+	case *EphNavIC:
+		return eph.MessageType
+	case *EphSBAS:
+		return eph.MessageType
+	}
+	return ""
+}
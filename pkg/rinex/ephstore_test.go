@@ -0,0 +1,187 @@
+package rinex
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEphStore_Load(t *testing.T) {
+	assert := assert.New(t)
+	f, err := os.Open("testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec, err := NewNavDecoder(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewEphStore()
+	assert.NoError(store.Load(dec))
+	assert.Greater(store.Len(), 0)
+
+	prns := store.PRNs()
+	assert.Equal(gnss.PRN{Sys: gnss.SysGPS, Num: 1}, prns[0])
+	for _, prn := range prns {
+		list := store.Ephemerides(prn)
+		for i := 1; i < len(list); i++ {
+			assert.False(newEphMeta(list[i]).ref.Before(newEphMeta(list[i-1]).ref), "%s ordered", prn)
+		}
+	}
+
+	g02 := gnss.PRN{Sys: gnss.SysGPS, Num: 2}
+	ti := time.Date(2020, 6, 17, 0, 0, 0, 0, time.UTC)
+	eph, err := store.Best(g02, ti.Add(50*time.Minute))
+	if assert.NoError(err) {
+		assert.Equal(ti, eph.GetTime())
+	}
+	eph, err = store.Best(g02, ti.Add(70*time.Minute))
+	if assert.NoError(err) {
+		assert.Equal(ti.Add(2*time.Hour), eph.GetTime())
+	}
+	_, err = store.Best(g02, ti.Add(-3*time.Hour))
+	assert.ErrorIs(err, ErrEphemerisExpired)
+	_, err = store.Best(gnss.PRN{Sys: gnss.SysGPS, Num: 33}, ti)
+	assert.ErrorIs(err, ErrNoEphemeris)
+
+	// The Galileo I/NAV ephemerides are preferred.
+	e01 := gnss.PRN{Sys: gnss.SysGAL, Num: 1}
+	eph, err = store.Best(e01, ti.Add(time.Hour))
+	if assert.NoError(err) {
+		assert.False(eph.(*EphGAL).IsFNAV())
+	}
+	store.PreferFNAV = true
+	eph, err = store.Best(e01, ti.Add(time.Hour))
+	if assert.NoError(err) {
+		assert.True(eph.(*EphGAL).IsFNAV())
+	}
+}
+
+// gpsEph returns a GPS ephemeris with the time of ephemeris t.
+func gpsEph(num int8, t time.Time, iode, tom float64) *EphGPS {
+	sow := t.Sub(gpsEpoch)
+	week := sow / (7 * 24 * time.Hour)
+	sow -= week * 7 * 24 * time.Hour
+	return &EphGPS{PRN: gnss.PRN{Sys: gnss.SysGPS, Num: num}, TOC: t, ToeWeek: float64(week), Toe: sow.Seconds(),
+		IODE: iode, Tom: tom, SqrtA: 5153.7}
+}
+
+func TestEphStore_Add(t *testing.T) {
+	assert := assert.New(t)
+	t0 := time.Date(2020, 6, 17, 2, 0, 0, 0, time.UTC)
+	g01 := gnss.PRN{Sys: gnss.SysGPS, Num: 1}
+
+	store := NewEphStore()
+	assert.True(store.Add(gpsEph(1, t0, 10, 100)))
+	assert.True(store.Add(gpsEph(1, t0.Add(-2*time.Hour), 9, 50)))
+	assert.True(store.Add(gpsEph(1, t0.Add(2*time.Hour), 11, 200)))
+	assert.Equal(3, store.Len())
+	assert.Equal(0, store.Duplicates())
+
+	// duplicate upload
+	assert.False(store.Add(gpsEph(1, t0, 10, 110)))
+	// an IODE change with the same TOE: the later upload wins
+	assert.False(store.Add(gpsEph(1, t0, 12, 90)))
+	newer := gpsEph(1, t0, 13, 120)
+	assert.True(store.Add(newer))
+	assert.Equal(3, store.Len())
+	assert.Equal(3, store.Duplicates())
+	eph, err := store.Best(g01, t0)
+	assert.NoError(err)
+	assert.Same(newer, eph)
+
+	list := store.Ephemerides(g01)
+	if assert.Len(list, 3) {
+		assert.Equal(t0.Add(-2*time.Hour), list[0].GetTime())
+		assert.Equal(t0.Add(2*time.Hour), list[2].GetTime())
+	}
+
+	// Ephemerides without orbit parameters are not added.
+	clockOnly := gpsEph(1, t0.Add(4*time.Hour), 0, 0)
+	clockOnly.SqrtA = 0
+	assert.False(store.Add(clockOnly))
+}
+
+func TestEphStore_addWeekRollover(t *testing.T) {
+	// The TOE is at the start of a GPS week, the messages were transmitted before or after the rollover.
+	toe := time.Date(2020, 6, 21, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		tom1, tom2    float64
+		secondReplace bool
+	}{
+		{name: "previous week", tom1: 597600, tom2: 600000, secondReplace: true},
+		{name: "previous and same week", tom1: 597600, tom2: 3600, secondReplace: true},
+		{name: "same and previous week", tom1: 3600, tom2: 597600, secondReplace: false},
+		{name: "adjusted to the week of TOE", tom1: -7200, tom2: 3600, secondReplace: true},
+		{name: "unknown", tom1: 3600, tom2: unknownTom, secondReplace: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			store := NewEphStore()
+			assert.True(store.Add(gpsEph(1, toe, 10, tt.tom1)))
+			assert.Equal(tt.secondReplace, store.Add(gpsEph(1, toe, 11, tt.tom2)))
+			list := store.Ephemerides(gnss.PRN{Sys: gnss.SysGPS, Num: 1})
+			if assert.Len(list, 1) {
+				wantIODE := 10.0
+				if tt.secondReplace {
+					wantIODE = 11
+				}
+				assert.Equal(wantIODE, list[0].(*EphGPS).IODE)
+			}
+		})
+	}
+}
+
+func TestEphStore_Best(t *testing.T) {
+	assert := assert.New(t)
+	t0 := time.Date(2020, 6, 17, 2, 0, 0, 0, time.UTC)
+	g01, g02 := gnss.PRN{Sys: gnss.SysGPS, Num: 1}, gnss.PRN{Sys: gnss.SysGPS, Num: 2}
+
+	store := NewEphStore()
+	store.Add(gpsEph(1, t0, 10, 100))
+	unhealthy := gpsEph(2, t0, 10, 100)
+	unhealthy.Health = 63
+	store.Add(unhealthy)
+	long := gpsEph(2, t0.Add(6*time.Hour), 11, 100)
+	long.FitInterval = 8
+	store.Add(long)
+
+	tests := []struct {
+		name    string
+		prn     gnss.PRN
+		t       time.Time
+		wantTOE time.Time
+		wantErr error
+	}{
+		{name: "at TOE", prn: g01, t: t0, wantTOE: t0},
+		{name: "within fit interval", prn: g01, t: t0.Add(-2 * time.Hour), wantTOE: t0},
+		{name: "expired", prn: g01, t: t0.Add(2*time.Hour + time.Second), wantErr: ErrEphemerisExpired},
+		{name: "unhealthy", prn: g02, t: t0, wantErr: ErrUnhealthy},
+		{name: "8h fit interval", prn: g02, t: t0.Add(3 * time.Hour), wantTOE: t0.Add(6 * time.Hour)},
+		{name: "no ephemeris", prn: gnss.PRN{Sys: gnss.SysGAL, Num: 1}, t: t0, wantErr: ErrNoEphemeris},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eph, err := store.Best(tt.prn, tt.t)
+			if tt.wantErr != nil {
+				assert.ErrorIs(err, tt.wantErr)
+				assert.Nil(eph)
+				return
+			}
+			if assert.NoError(err) {
+				assert.Equal(tt.wantTOE, eph.GetTime())
+			}
+		})
+	}
+
+	store.Unhealthy = true
+	eph, err := store.Best(g02, t0)
+	assert.NoError(err)
+	assert.Same(unhealthy, eph)
+}
@@ -0,0 +1,431 @@
+package rinex
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// ExportFormat is the file format of exported observations.
+type ExportFormat string
+
+// The supported export formats.
+const (
+	ExportCSV     ExportFormat = "csv"     // Comma-separated values with a header line.
+	ExportJSONL   ExportFormat = "jsonl"   // JSON Lines, one object per line.
+	ExportArrow   ExportFormat = "arrow"   // Apache Arrow IPC file format, also known as Feather V2.
+	ExportParquet ExportFormat = "parquet" // Apache Parquet, uncompressed.
+)
+
+// ExportOptions sets options for the export of observations.
+type ExportOptions struct {
+	SatSystems gnss.Systems // Export only these satellite systems, empty for all systems.
+
+	// Export only these observation codes, empty for all codes. A code may be a prefix,
+	// e.g. "L" for all carrier phases or "C1" for all C1 pseudoranges.
+	ObsCodes []ObsCode
+
+	// Wide format: one record per epoch and satellite with the columns value, LLI and SNR per observation code,
+	// e.g. "C1C", "C1C_lli", "C1C_snr". The columns are given by the observation types of the header.
+	// The default is the long format with one record per observation.
+	Wide bool
+}
+
+// ObsRecord is an observation in long format, i.e. one record per epoch, satellite and observation code.
+type ObsRecord struct {
+	Time  time.Time `json:"time"`
+	PRN   gnss.PRN  `json:"prn"`
+	Code  ObsCode   `json:"code"`
+	Value float64   `json:"value"`
+	LLI   int8      `json:"lli"`
+	SNR   int8      `json:"snr"`
+}
+
+// longColumns are the columns of the long format.
+var longColumns = []column{
+	{name: "time", typ: colTime},
+	{name: "prn", typ: colString},
+	{name: "code", typ: colString},
+	{name: "value", typ: colFloat64},
+	{name: "lli", typ: colInt8},
+	{name: "snr", typ: colInt8},
+}
+
+// Records returns the observations of the decoder in long format, ordered by epoch, satellite and the observation
+// types of the header. Special events and missing observations are skipped. An error is yielded last.
+func (dec *ObsDecoder) Records(opts ExportOptions) iter.Seq2[ObsRecord, error] {
+	return func(yield func(ObsRecord, error) bool) {
+		for epo, err := range dec.Epochs() {
+			if err != nil {
+				yield(ObsRecord{}, err)
+				return
+			}
+			if epo.Flag > EpochFlagPowerFailure {
+				continue
+			}
+			for so := range dec.satObs(epo, &opts) {
+				typs := dec.ObsTypesOf(so.Prn.Sys)
+				for i, obs := range so.Obs[:min(len(so.Obs), len(typs))] {
+					if obs == (Obs{}) || !opts.keepObsCode(typs[i]) {
+						continue
+					}
+					rec := ObsRecord{Time: epo.Time, PRN: so.Prn, Code: typs[i], Value: obs.Val, LLI: obs.LLI, SNR: obs.SNR}
+					if !yield(rec, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// ExportObs writes the observations of the decoder to w in the given format, e.g. for pandas or DuckDB.
+// The columns are "time", "prn", "code", "value", "lli" and "snr" in long format, see ExportOptions for the wide format.
+// Changes of the observation types in special events are not considered for the columns of the wide format.
+//
+// The time is given in RFC 3339 format in CSV and JSON Lines. Arrow and Parquet have a fixed schema:
+//
+//	time                        timestamp[ns, UTC], in Parquet INT64 TIMESTAMP(NANOS, true)
+//	prn, code                   string, in Parquet BYTE_ARRAY STRING
+//	value, <code>               float64, in Parquet DOUBLE
+//	lli, snr, <code>_lli|_snr   int8, in Parquet INT32 INT(8, true)
+//
+// All columns are non-nullable in long format, the observation columns of the wide format are nullable.
+// Arrow files have a record batch and Parquet files a row group per 65536 rows.
+func ExportObs(w io.Writer, dec *ObsDecoder, format ExportFormat, opts ExportOptions) error {
+	var tw tableWriter
+	switch format {
+	case ExportCSV:
+		tw = &csvWriter{w: csv.NewWriter(w)}
+	case ExportJSONL:
+		tw = &jsonlWriter{w: bufio.NewWriter(w)}
+	case ExportArrow:
+		tw = &arrowWriter{w: newCountWriter(w), batchRows: defaultBatchRows}
+	case ExportParquet:
+		tw = &parquetWriter{w: newCountWriter(w), batchRows: defaultBatchRows}
+	default:
+		return fmt.Errorf("rinex: export format %q not supported", format)
+	}
+	return exportObs(tw, dec, opts)
+}
+
+// exportObs writes the observations of the decoder with the table writer.
+func exportObs(tw tableWriter, dec *ObsDecoder, opts ExportOptions) error {
+	if !opts.Wide {
+		if err := tw.writeHeader(longColumns); err != nil {
+			return err
+		}
+		for rec, err := range dec.Records(opts) {
+			if err != nil {
+				return err
+			}
+			if err := tw.writeRow([]any{rec.Time, rec.PRN.String(), string(rec.Code), rec.Value, rec.LLI, rec.SNR}); err != nil {
+				return err
+			}
+		}
+		return tw.flush()
+	}
+
+	codes := dec.exportCodes(&opts)
+	cols := make([]column, 0, 2+3*len(codes))
+	cols = append(cols, longColumns[:2]...)
+	for _, code := range codes {
+		cols = append(cols, column{name: string(code), typ: colFloat64, nullable: true},
+			column{name: string(code) + "_lli", typ: colInt8, nullable: true},
+			column{name: string(code) + "_snr", typ: colInt8, nullable: true})
+	}
+	if err := tw.writeHeader(cols); err != nil {
+		return err
+	}
+	row := make([]any, len(cols))
+	for epo, err := range dec.Epochs() {
+		if err != nil {
+			return err
+		}
+		if epo.Flag > EpochFlagPowerFailure {
+			continue
+		}
+		for so := range dec.satObs(epo, &opts) {
+			clear(row)
+			row[0], row[1] = epo.Time, so.Prn.String()
+			typs := dec.ObsTypesOf(so.Prn.Sys)
+			for i, obs := range so.Obs[:min(len(so.Obs), len(typs))] {
+				j := slices.Index(codes, typs[i])
+				if j < 0 || obs == (Obs{}) {
+					continue
+				}
+				row[2+3*j], row[3+3*j], row[4+3*j] = obs.Val, obs.LLI, obs.SNR
+			}
+			if err := tw.writeRow(row); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.flush()
+}
+
+// satObs returns the observations of the selected satellites of the epoch in the order of ObsTypesOf.
+// It supports both the indexed and the map mode.
+func (dec *ObsDecoder) satObs(epo *Epoch, opts *ExportOptions) iter.Seq[IndexedSatObs] {
+	return func(yield func(IndexedSatObs) bool) {
+		for _, so := range epo.IndexedObs {
+			if opts.keepSystem(so.Prn.Sys) && !yield(so) {
+				return
+			}
+		}
+		var buf []Obs
+		for _, so := range epo.ObsList {
+			if !opts.keepSystem(so.Prn.Sys) {
+				continue
+			}
+			buf = buf[:0]
+			for _, typ := range dec.ObsTypesOf(so.Prn.Sys) {
+				buf = append(buf, so.Obss[typ])
+			}
+			if !yield(IndexedSatObs{Prn: so.Prn, Obs: buf}) {
+				return
+			}
+		}
+	}
+}
+
+// exportCodes returns the observation codes of the selected systems for the columns of the wide format.
+func (dec *ObsDecoder) exportCodes(opts *ExportOptions) []ObsCode {
+	codes := []ObsCode{}
+	for _, sys := range slices.Sorted(maps.Keys(dec.obsTypes)) {
+		if dec.Header.RINEXVersion >= 3 && !opts.keepSystem(sys) {
+			continue
+		}
+		for _, typ := range dec.obsTypes[sys] {
+			if opts.keepObsCode(typ) && !slices.Contains(codes, typ) {
+				codes = append(codes, typ)
+			}
+		}
+	}
+	return codes
+}
+
+func (opts *ExportOptions) keepSystem(sys gnss.System) bool {
+	return len(opts.SatSystems) == 0 || slices.Contains(opts.SatSystems, sys)
+}
+
+func (opts *ExportOptions) keepObsCode(code ObsCode) bool {
+	if len(opts.ObsCodes) == 0 {
+		return true
+	}
+	for _, prefix := range opts.ObsCodes {
+		if strings.HasPrefix(string(code), string(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// tableWriter writes rows of values with a fixed list of columns. The values are time.Time, string, float64
+// or int8 according to the column type, or nil if missing.
+type tableWriter interface {
+	writeHeader(cols []column) error
+	writeRow(row []any) error
+	flush() error
+}
+
+// column is a column of a tableWriter.
+type column struct {
+	name     string
+	typ      columnType
+	nullable bool
+}
+
+// columnType is the type of the values of a column.
+type columnType int
+
+const (
+	colTime    columnType = iota // time.Time
+	colString                    // string
+	colFloat64                   // float64
+	colInt8                      // int8
+)
+
+// csvWriter writes comma-separated values. Missing values are left empty.
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (cw *csvWriter) writeHeader(cols []column) error {
+	cw.record = make([]string, len(cols))
+	for i, col := range cols {
+		cw.record[i] = col.name
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) writeRow(row []any) error {
+	for i, v := range row {
+		cw.record[i] = formatValue(v)
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// jsonlWriter writes JSON Lines with the keys in the order of the columns. Missing values are null.
+type jsonlWriter struct {
+	w    *bufio.Writer
+	keys []string
+}
+
+func (jw *jsonlWriter) writeHeader(cols []column) error {
+	jw.keys = make([]string, len(cols))
+	for i, col := range cols {
+		key, _ := json.Marshal(col.name)
+		jw.keys[i] = string(key)
+	}
+	return nil
+}
+
+func (jw *jsonlWriter) writeRow(row []any) error {
+	jw.w.WriteByte('{')
+	for i, v := range row {
+		if i > 0 {
+			jw.w.WriteByte(',')
+		}
+		jw.w.WriteString(jw.keys[i])
+		jw.w.WriteByte(':')
+		switch v := v.(type) {
+		case nil:
+			jw.w.WriteString("null")
+		case time.Time, string:
+			s, _ := json.Marshal(formatValue(v))
+			jw.w.Write(s)
+		default:
+			jw.w.WriteString(formatValue(v))
+		}
+	}
+	_, err := jw.w.WriteString("}\n")
+	return err
+}
+
+func (jw *jsonlWriter) flush() error {
+	return jw.w.Flush()
+}
+
+// formatValue formats a value of a tableWriter row.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int8:
+		return strconv.Itoa(int(v))
+	}
+	return fmt.Sprint(v)
+}
+
+// defaultBatchRows is the number of rows of an Arrow record batch or a Parquet row group.
+const defaultBatchRows = 1 << 16
+
+// columnBatch buffers rows column by column for the columnar formats.
+type columnBatch struct {
+	cols []column
+	data []columnData
+	n    int // the number of rows
+}
+
+// columnData are the values of a column. Missing values are stored as zero values.
+type columnData struct {
+	valid  []bool
+	nulls  int
+	ints   []int64 // the time in ns since 1970 or the int8 values
+	floats []float64
+	strs   []string
+}
+
+func newColumnBatch(cols []column) *columnBatch {
+	return &columnBatch{cols: cols, data: make([]columnData, len(cols))}
+}
+
+// append adds the row to the batch.
+func (b *columnBatch) append(row []any) error {
+	for i, v := range row {
+		col, cd := &b.cols[i], &b.data[i]
+		if v == nil {
+			if !col.nullable {
+				return fmt.Errorf("rinex: export: missing value in column %q", col.name)
+			}
+			cd.nulls++
+		}
+		cd.valid = append(cd.valid, v != nil)
+		var ok bool
+		switch col.typ {
+		case colTime:
+			var ti time.Time
+			var ns int64
+			if ti, ok = v.(time.Time); ok {
+				ns = ti.UnixNano()
+			}
+			cd.ints = append(cd.ints, ns)
+		case colString:
+			var s string
+			s, ok = v.(string)
+			cd.strs = append(cd.strs, s)
+		case colFloat64:
+			var f float64
+			f, ok = v.(float64)
+			cd.floats = append(cd.floats, f)
+		case colInt8:
+			var i8 int8
+			i8, ok = v.(int8)
+			cd.ints = append(cd.ints, int64(i8))
+		}
+		if !ok && v != nil {
+			return fmt.Errorf("rinex: export: invalid value %T in column %q", v, col.name)
+		}
+	}
+	b.n++
+	return nil
+}
+
+// reset removes all rows.
+func (b *columnBatch) reset() {
+	for i := range b.data {
+		cd := &b.data[i]
+		cd.valid, cd.ints, cd.floats, cd.strs = cd.valid[:0], cd.ints[:0], cd.floats[:0], cd.strs[:0]
+		cd.nulls = 0
+	}
+	b.n = 0
+}
+
+// countWriter is a buffered writer that counts the bytes written, for the file offsets of the columnar formats.
+type countWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func newCountWriter(w io.Writer) *countWriter {
+	return &countWriter{w: bufio.NewWriter(w)}
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
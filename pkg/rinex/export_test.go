@@ -0,0 +1,287 @@
+package rinex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/stretchr/testify/assert"
+)
+
+const exportData = `     3.04           OBSERVATION DATA    M                   RINEX VERSION / TYPE
+TEST                                                        MARKER NAME
+G    3 C1C L1C S1C                                          SYS / # / OBS TYPES
+E    2 C1C L1C                                              SYS / # / OBS TYPES
+                                                            END OF HEADER
+> 2020 06 18 00 00  0.0000000  0  2
+G01  20000000.123   105123456.789 7        45.000
+E05  23000000.123
+>                              4  1
+START EXPORT                                                COMMENT
+> 2020 06 18 00 00 30.0000000  0  1
+G01  20000030.123   105123486.78917
+`
+
+func TestExportObs(t *testing.T) {
+	tests := []struct {
+		name   string
+		format ExportFormat
+		opts   ExportOptions
+		want   string
+	}{
+		{name: "csv", format: ExportCSV, want: `time,prn,code,value,lli,snr
+2020-06-18T00:00:00Z,G01,C1C,20000000.123,0,0
+2020-06-18T00:00:00Z,G01,L1C,105123456.789,0,7
+2020-06-18T00:00:00Z,G01,S1C,45,0,0
+2020-06-18T00:00:00Z,E05,C1C,23000000.123,0,0
+2020-06-18T00:00:30Z,G01,C1C,20000030.123,0,0
+2020-06-18T00:00:30Z,G01,L1C,105123486.789,1,7
+`},
+		{name: "jsonl-filter", format: ExportJSONL, opts: ExportOptions{SatSystems: gnss.Systems{gnss.SysGPS}, ObsCodes: []ObsCode{"L"}},
+			want: `{"time":"2020-06-18T00:00:00Z","prn":"G01","code":"L1C","value":105123456.789,"lli":0,"snr":7}
+{"time":"2020-06-18T00:00:30Z","prn":"G01","code":"L1C","value":105123486.789,"lli":1,"snr":7}
+`},
+		{name: "csv-wide", format: ExportCSV, opts: ExportOptions{Wide: true, ObsCodes: []ObsCode{"C", "L"}}, want: `time,prn,C1C,C1C_lli,C1C_snr,L1C,L1C_lli,L1C_snr
+2020-06-18T00:00:00Z,G01,20000000.123,0,0,105123456.789,0,7
+2020-06-18T00:00:00Z,E05,23000000.123,0,0,,,
+2020-06-18T00:00:30Z,G01,20000030.123,0,0,105123486.789,1,7
+`},
+		{name: "jsonl-wide", format: ExportJSONL, opts: ExportOptions{Wide: true, SatSystems: gnss.Systems{gnss.SysGAL}}, want: `{"time":"2020-06-18T00:00:00Z","prn":"E05","C1C":23000000.123,"C1C_lli":0,"C1C_snr":0,"L1C":null,"L1C_lli":null,"L1C_snr":null}
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			dec, err := NewObsDecoder(strings.NewReader(exportData))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			assert.NoError(ExportObs(&buf, dec, tt.format, tt.opts))
+			assert.Equal(tt.want, buf.String())
+			if tt.format == ExportJSONL {
+				for line := range strings.Lines(buf.String()) {
+					assert.True(json.Valid([]byte(line)), "valid JSON: %s", line)
+				}
+			}
+		})
+	}
+
+	dec, err := NewObsDecoder(strings.NewReader(exportData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualError(t, ExportObs(&bytes.Buffer{}, dec, "xlsx", ExportOptions{}), `rinex: export format "xlsx" not supported`)
+}
+
+func TestExportObs_arrow(t *testing.T) {
+	assert := assert.New(t)
+	dec, err := NewObsDecoder(strings.NewReader(exportData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	assert.NoError(exportObs(&arrowWriter{w: newCountWriter(&buf), batchRows: 4}, dec, ExportOptions{}))
+	data := buf.Bytes()
+	assert.True(bytes.HasPrefix(data, []byte("ARROW1\x00\x00")))
+	assert.True(bytes.HasSuffix(data, []byte("ARROW1")))
+
+	// the slot of a field of a flatbuffers table
+	slot := func(tab *flatbuffers.Table, i int) flatbuffers.UOffsetT {
+		o := tab.Offset(flatbuffers.VOffsetT(4 + 2*i))
+		if o == 0 {
+			t.Fatalf("missing field %d", i)
+		}
+		return tab.Pos + flatbuffers.UOffsetT(o)
+	}
+	table := func(tab *flatbuffers.Table, pos flatbuffers.UOffsetT) *flatbuffers.Table {
+		return &flatbuffers.Table{Bytes: tab.Bytes, Pos: tab.Indirect(pos)}
+	}
+
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-10:]))
+	footerBytes := data[len(data)-10-footerLen : len(data)-10]
+	footer := &flatbuffers.Table{Bytes: footerBytes, Pos: flatbuffers.GetUOffsetT(footerBytes)}
+	schema := table(footer, slot(footer, 1))
+	fields := schema.Vector(slot(schema, 1) - schema.Pos)
+	names := []string{}
+	for i := range schema.VectorLen(slot(schema, 1) - schema.Pos) {
+		field := table(schema, fields+flatbuffers.UOffsetT(4*i))
+		names = append(names, string(field.ByteVector(slot(field, 0))))
+		assert.False(field.GetBoolSlot(6, false), "nullable")
+	}
+	assert.Equal([]string{"time", "prn", "code", "value", "lli", "snr"}, names)
+
+	// the record batches
+	blocks := footer.Vector(slot(footer, 3) - footer.Pos)
+	lengths := []int64{}
+	for i := range footer.VectorLen(slot(footer, 3) - footer.Pos) {
+		offset := footer.GetInt64(blocks + flatbuffers.UOffsetT(24*i))
+		metaLen := footer.GetInt32(blocks + flatbuffers.UOffsetT(24*i+8))
+		assert.Equal(uint32(0xffffffff), binary.LittleEndian.Uint32(data[offset:]))
+		metaBytes := data[offset+8 : offset+int64(metaLen)]
+		msg := &flatbuffers.Table{Bytes: metaBytes, Pos: flatbuffers.GetUOffsetT(metaBytes)}
+		assert.Equal(byte(3), msg.GetByte(slot(msg, 1)), "record batch")
+		batch := table(msg, slot(msg, 2))
+		lengths = append(lengths, batch.GetInt64(slot(batch, 0)))
+		if i > 0 {
+			continue
+		}
+		// the data buffer of the value column follows the buffers of time, prn and code
+		buffers := batch.Vector(slot(batch, 2) - batch.Pos)
+		bufOffset := batch.GetInt64(buffers + 16*9)
+		body := data[offset+int64(metaLen):]
+		values := []float64{}
+		for j := range 4 {
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(body[bufOffset+int64(8*j):])))
+		}
+		assert.Equal([]float64{20000000.123, 105123456.789, 45, 23000000.123}, values)
+	}
+	assert.Equal([]int64{4, 2}, lengths)
+}
+
+func TestExportObs_parquet(t *testing.T) {
+	assert := assert.New(t)
+	dec, err := NewObsDecoder(strings.NewReader(exportData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	assert.NoError(exportObs(&parquetWriter{w: newCountWriter(&buf), batchRows: 4}, dec, ExportOptions{Wide: true}))
+	data := buf.Bytes()
+	assert.True(bytes.HasPrefix(data, []byte("PAR1")))
+	assert.True(bytes.HasSuffix(data, []byte("PAR1")))
+
+	metaLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	r := &thriftReader{buf: data, pos: len(data) - 8 - metaLen}
+	meta := r.readStruct()
+	assert.Equal(len(data)-8, r.pos)
+	assert.Equal(int64(3), meta[3], "num_rows")
+
+	names := []string{}
+	for _, elem := range meta[2].([]any)[1:] {
+		names = append(names, elem.(map[int16]any)[4].(string))
+	}
+	assert.Equal([]string{"time", "prn", "C1C", "C1C_lli", "C1C_snr", "L1C", "L1C_lli", "L1C_snr", "S1C", "S1C_lli", "S1C_snr"}, names)
+
+	rowGroups := meta[4].([]any)
+	if !assert.Len(rowGroups, 1) {
+		return
+	}
+	// the nullable column L1C with a missing value in the second row
+	chunk := rowGroups[0].(map[int16]any)[1].([]any)[5].(map[int16]any)
+	r = &thriftReader{buf: data, pos: int(chunk[3].(map[int16]any)[9].(int64))}
+	pageHeader := r.readStruct()
+	page := data[r.pos : r.pos+int(pageHeader[2].(int64))]
+	levelsLen := int(binary.LittleEndian.Uint32(page))
+	assert.Equal([]byte{0x03, 0b101}, page[4:4+levelsLen], "definition levels")
+	values := []float64{}
+	for v := page[4+levelsLen:]; len(v) > 0; v = v[8:] {
+		values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(v)))
+	}
+	assert.Equal([]float64{105123456.789, 105123486.789}, values)
+}
+
+// thriftReader decodes the Thrift compact protocol of the Parquet metadata. Structs are returned as maps
+// by field id.
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *thriftReader) readStruct() map[int16]any {
+	fields := map[int16]any{}
+	var id int16
+	for {
+		b := r.buf[r.pos]
+		r.pos++
+		if b == 0 {
+			return fields
+		}
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			u := r.varint()
+			id = int16(int64(u>>1) ^ -int64(u&1))
+		}
+		fields[id] = r.value(b & 0x0f)
+	}
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftByte:
+		r.pos++
+		return int8(r.buf[r.pos-1])
+	case thriftI32, thriftI64:
+		u := r.varint()
+		return int64(u>>1) ^ -int64(u&1)
+	case thriftBinary:
+		n := int(r.varint())
+		r.pos += n
+		return string(r.buf[r.pos-n : r.pos])
+	case thriftList:
+		h := r.buf[r.pos]
+		r.pos++
+		list := make([]any, h>>4)
+		if len(list) == 15 {
+			list = make([]any, r.varint())
+		}
+		for i := range list {
+			list[i] = r.value(h & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d", typ))
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	r.pos += n
+	return v
+}
+
+func TestObsDecoder_Records(t *testing.T) {
+	assert := assert.New(t)
+	records := func(indexed bool) []ObsRecord {
+		dec := newObsDecoder(t, "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+		dec.SetIndexed(indexed)
+		recs := []ObsRecord{}
+		for rec, err := range dec.Records(ExportOptions{SatSystems: gnss.Systems{gnss.SysGAL}}) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			recs = append(recs, rec)
+		}
+		return recs
+	}
+	recs := records(false)
+	assert.NotEmpty(recs)
+	for _, rec := range recs {
+		if rec.PRN.Sys != gnss.SysGAL {
+			t.Fatalf("unexpected satellite %s", rec.PRN)
+		}
+	}
+	assert.Equal(recs, records(true), "indexed mode")
+
+	// RINEX-2
+	dec := newObsDecoder(t, "testdata/white/brst155h.20o")
+	n := 0
+	for rec, err := range dec.Records(ExportOptions{ObsCodes: []ObsCode{"L1"}}) {
+		assert.NoError(err)
+		assert.Equal(ObsCode("L1"), rec.Code)
+		n++
+	}
+	assert.Positive(n)
+}
@@ -0,0 +1,284 @@
+package rinex
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// decimationTolerance is the maximum offset of an epoch from the decimation interval.
+const decimationTolerance = time.Millisecond
+
+// ObsFilter selects epochs, satellites and observations of RINEX observation data.
+// The zero value selects everything. Use it with ObsDecoder.SetFilter, or call Filter for each epoch.
+type ObsFilter struct {
+	Start, End time.Time     // The time window, both inclusive. Zero values mean no limit.
+	Interval   time.Duration // Decimate to this interval, e.g. 30s. Epochs are aligned to full multiples of the interval, e.g. to full minutes for 60s.
+
+	SatSystems  gnss.Systems              // Keep only these satellite systems.
+	ExcludePRNs []gnss.PRN                // Drop these satellites.
+	ObsCodes    map[gnss.System][]ObsCode // Keep only these observation types per system. Systems without entry keep all types.
+}
+
+// FilterHeader returns a copy of the header with the observation types, the satellite system, the interval and the
+// times of the first and last observation adjusted to the filter. The records of the dropped satellite systems,
+// observation types and satellites are removed. The number of satellites and of observations per satellite are
+// cleared, as they are not known before the filtered data is read.
+func (filt *ObsFilter) FilterHeader(hdr ObsHeader) ObsHeader {
+	hdr = *hdr.clone()
+	if filt.Interval > 0 && filt.Interval.Seconds() > hdr.Interval {
+		hdr.Interval = filt.Interval.Seconds()
+	}
+	if !filt.Start.IsZero() && hdr.TimeOfFirstObs.Before(filt.Start) {
+		hdr.TimeOfFirstObs = filt.Start
+	}
+	if !filt.End.IsZero() && hdr.TimeOfLastObs.After(filt.End) {
+		hdr.TimeOfLastObs = filt.End
+	}
+	filt.filterHeaderRecords(&hdr)
+
+	obsTypes := make(map[gnss.System][]ObsCode, len(hdr.ObsTypes))
+	if hdr.RINEXVersion < 3 {
+		// In RINEX-2 the types are valid for all systems. Keep a type if any selected system uses it.
+		for sys, typs := range hdr.ObsTypes {
+			obsTypes[sys] = slices.DeleteFunc(slices.Clone(typs), func(typ ObsCode) bool { return !filt.keepObsCodeV2(hdr.SatSystem, typ) })
+		}
+		hdr.ObsTypes = obsTypes
+		return hdr
+	}
+
+	for sys, typs := range hdr.ObsTypes {
+		if !filt.keepSystem(sys) {
+			continue
+		}
+		obsTypes[sys] = slices.DeleteFunc(slices.Clone(typs), func(typ ObsCode) bool { return !filt.keepObsCode(sys, typ) })
+	}
+	hdr.ObsTypes = obsTypes
+	if len(obsTypes) == 1 {
+		for sys := range obsTypes {
+			hdr.SatSystem = sys
+		}
+	}
+	return hdr
+}
+
+// filterHeaderRecords removes the per system records of the header hdr, that refer to the dropped satellite systems,
+// observation types and satellites, and clears the satellite and observation counts. The lists of hdr are modified.
+func (filt *ObsFilter) filterHeaderRecords(hdr *ObsHeader) {
+	hdr.NSatellites = 0
+	hdr.ObsPerSat = nil
+	hdr.Labels = slices.DeleteFunc(hdr.Labels, func(label string) bool { return label == "# OF SATELLITES" || label == "PRN / # OF OBS" })
+
+	keepSat := func(prn gnss.PRN) bool { return filt.keepSystem(prn.Sys) && !slices.Contains(filt.ExcludePRNs, prn) }
+	hdr.AntennaPhaseCenters = slices.DeleteFunc(hdr.AntennaPhaseCenters, func(pc PhaseCenter) bool { return !filt.keepSystem(pc.Sys) })
+	hdr.DCBsApplied = slices.DeleteFunc(hdr.DCBsApplied, func(corr AppliedCorrection) bool { return !filt.keepSystem(corr.Sys) })
+	hdr.PCVsApplied = slices.DeleteFunc(hdr.PCVsApplied, func(corr AppliedCorrection) bool { return !filt.keepSystem(corr.Sys) })
+	scaleFactors := hdr.ScaleFactors[:0]
+	for _, sf := range hdr.ScaleFactors {
+		if !filt.keepSystem(sf.Sys) {
+			continue
+		}
+		if len(sf.Codes) > 0 {
+			sf.Codes = slices.DeleteFunc(slices.Clone(sf.Codes), func(typ ObsCode) bool { return !filt.keepObsCode(sf.Sys, typ) })
+			if len(sf.Codes) == 0 {
+				continue
+			}
+		}
+		scaleFactors = append(scaleFactors, sf)
+	}
+	hdr.ScaleFactors = scaleFactors
+	phaseShifts := hdr.PhaseShifts[:0]
+	for _, ps := range hdr.PhaseShifts {
+		if !filt.keepSystem(ps.Sys) || !filt.keepObsCode(ps.Sys, ps.Code) {
+			continue
+		}
+		if len(ps.Sats) > 0 {
+			ps.Sats = slices.DeleteFunc(slices.Clone(ps.Sats), func(prn gnss.PRN) bool { return !keepSat(prn) })
+			if len(ps.Sats) == 0 {
+				continue
+			}
+		}
+		phaseShifts = append(phaseShifts, ps)
+	}
+	hdr.PhaseShifts = phaseShifts
+	maps.DeleteFunc(hdr.GloSlots, func(prn gnss.PRN, _ int) bool { return !keepSat(prn) })
+	if !filt.keepSystem(gnss.SysGLO) {
+		hdr.GloCodPhsBias = nil
+	}
+}
+
+// filterRecords returns the special records of an event without the records referring to the dropped satellite
+// systems and observation types, and without the satellite and observation counts.
+func (filt *ObsFilter) filterRecords(records []string) []string {
+	out := make([]string, 0, len(records))
+	var sys gnss.System
+	var obsTypes []ObsCode // the observation types of sys in SYS / # / OBS TYPES, written at the end of the record
+	flushObsTypes := func() {
+		if obsTypes == nil {
+			return
+		}
+		codes := slices.DeleteFunc(obsTypes, func(typ ObsCode) bool { return !filt.keepObsCode(sys, typ) })
+		var sb strings.Builder
+		(&ObsHeader{ObsTypes: map[gnss.System][]ObsCode{sys: codes}}).writeObsCodes(&sb)
+		if sb.Len() > 0 {
+			out = append(out, strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")...)
+		}
+		obsTypes = nil
+	}
+	for _, line := range records {
+		key := ""
+		if len(line) >= 60 {
+			key = strings.TrimSpace(line[60:])
+		}
+		if key != "SYS / # / OBS TYPES" || line[0] != ' ' {
+			flushObsTypes()
+		}
+		switch key {
+		case "# OF SATELLITES", "PRN / # OF OBS":
+			continue
+		case "GLONASS SLOT / FRQ #", "GLONASS COD/PHS/BIS":
+			if !filt.keepSystem(gnss.SysGLO) {
+				continue
+			}
+		case "SYS / # / OBS TYPES", "SYS / SCALE FACTOR", "SYS / PHASE SHIFT", "SYS / PHASE SHIFTS",
+			"SYS / DCBS APPLIED", "SYS / PCVS APPLIED", "ANTENNA: PHASECENTER":
+			// Continuation lines start with a blank and belong to the system of the previous line.
+			if s, ok := gnss.ByAbbr[line[:1]]; ok {
+				sys = s
+			}
+			if !filt.keepSystem(sys) {
+				continue
+			}
+			if key == "SYS / # / OBS TYPES" {
+				for _, f := range strings.Fields(line[6:60]) {
+					obsTypes = append(obsTypes, ObsCode(f))
+				}
+				continue
+			}
+		}
+		out = append(out, line)
+	}
+	flushObsTypes()
+	return out
+}
+
+// Filter returns the epoch with the selected satellites and observations, or nil if the epoch is not selected.
+// Epochs that have no satellites left are dropped. The given epoch is not modified.
+// Special events are selected if they are within the time window or have no time. Their header records are
+// adjusted to the filter like the header, see FilterHeader.
+func (filt *ObsFilter) Filter(epo *Epoch) *Epoch {
+	isEvent := epo.Flag > EpochFlagPowerFailure && epo.Flag != EpochFlagCycleSlip
+	if !(isEvent && epo.Time.IsZero()) {
+		if !filt.Start.IsZero() && epo.Time.Before(filt.Start) {
+			return nil
+		}
+		if !filt.End.IsZero() && epo.Time.After(filt.End) {
+			return nil
+		}
+	}
+	if isEvent {
+		return filt.filterEvent(epo)
+	}
+
+	if filt.Interval > 0 {
+		if diff := epo.Time.Sub(epo.Time.Round(filt.Interval)).Abs(); diff > decimationTolerance {
+			return nil
+		}
+	}
+
+	if len(filt.SatSystems) == 0 && len(filt.ExcludePRNs) == 0 && len(filt.ObsCodes) == 0 {
+		return epo
+	}
+	newEpo := *epo
+	newEpo.ObsList = make([]SatObs, 0, len(epo.ObsList))
+	for _, satObs := range epo.ObsList {
+		if !filt.keepSystem(satObs.Prn.Sys) || slices.Contains(filt.ExcludePRNs, satObs.Prn) {
+			continue
+		}
+		if _, ok := filt.ObsCodes[satObs.Prn.Sys]; ok {
+			obss := make(map[ObsCode]Obs, len(satObs.Obss))
+			for typ, obs := range satObs.Obss {
+				if filt.keepObsCode(satObs.Prn.Sys, typ) {
+					obss[typ] = obs
+				}
+			}
+			satObs = SatObs{Prn: satObs.Prn, Obss: obss}
+		}
+		newEpo.ObsList = append(newEpo.ObsList, satObs)
+	}
+	if epo.IndexedObs != nil {
+		newEpo.IndexedObs = slices.DeleteFunc(slices.Clone(epo.IndexedObs), func(so IndexedSatObs) bool {
+			return !filt.keepSystem(so.Prn.Sys) || slices.Contains(filt.ExcludePRNs, so.Prn)
+		})
+	}
+	nSat := len(newEpo.ObsList) + len(newEpo.IndexedObs)
+	if nSat == 0 && len(epo.ObsList)+len(epo.IndexedObs) > 0 {
+		return nil
+	}
+	newEpo.NumSat = uint8(nSat)
+	return &newEpo
+}
+
+// filterEvent returns the special event with the header records adjusted to the filter, see filterRecords.
+func (filt *ObsFilter) filterEvent(epo *Epoch) *Epoch {
+	if len(epo.Records) == 0 {
+		return epo
+	}
+	newEpo := *epo
+	newEpo.Records = filt.filterRecords(epo.Records)
+	if epo.Event != nil && epo.Event.Header != nil {
+		ev := *epo.Event
+		ev.Header = epo.Event.Header.clone()
+		filt.filterHeaderRecords(ev.Header)
+		if ev.Header.RINEXVersion >= 3 {
+			for sys, typs := range ev.Header.ObsTypes {
+				if !filt.keepSystem(sys) {
+					delete(ev.Header.ObsTypes, sys)
+					continue
+				}
+				ev.Header.ObsTypes[sys] = slices.DeleteFunc(slices.Clone(typs), func(typ ObsCode) bool { return !filt.keepObsCode(sys, typ) })
+			}
+		}
+		newEpo.Event = &ev
+	}
+	return &newEpo
+}
+
+// isAfterWindow returns true if the epoch is later than the end of the time window.
+func (filt *ObsFilter) isAfterWindow(epo *Epoch) bool {
+	return !filt.End.IsZero() && epo.Time.After(filt.End)
+}
+
+func (filt *ObsFilter) keepSystem(sys gnss.System) bool {
+	return len(filt.SatSystems) == 0 || slices.Contains(filt.SatSystems, sys)
+}
+
+func (filt *ObsFilter) keepObsCode(sys gnss.System, typ ObsCode) bool {
+	codes, ok := filt.ObsCodes[sys]
+	return !ok || slices.Contains(codes, typ)
+}
+
+// keepObsCodeV2 returns true if any of the selected systems keeps the RINEX-2 observation type.
+// All systems are considered for mixed files.
+func (filt *ObsFilter) keepObsCodeV2(hdrSys gnss.System, typ ObsCode) bool {
+	if hdrSys != gnss.SysMIXED {
+		return filt.keepObsCode(hdrSys, typ)
+	}
+	for sys := gnss.SysGPS; sys < gnss.SysMIXED; sys++ {
+		if filt.keepSystem(sys) && filt.keepObsCode(sys, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFilter sets the filter for the following epochs. The Header is adjusted to the filter, see FilterHeader.
+// Epochs that are not selected are skipped by NextEpoch. Reading stops with the first epoch after the end
+// of the time window.
+func (dec *ObsDecoder) SetFilter(filt ObsFilter) {
+	dec.filter = &filt
+	dec.Header = filt.FilterHeader(dec.Header)
+}
@@ -0,0 +1,218 @@
+package rinex
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/stretchr/testify/assert"
+)
+
+// readFilteredObsFile returns the header and the epochs of the RINEX obs file read with the filter.
+// withFilter returns a decoder setup that sets the filter.
+func withFilter(filt ObsFilter) func(dec *ObsDecoder) {
+	return func(dec *ObsDecoder) { dec.SetFilter(filt) }
+}
+
+func TestObsFilter_window(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2018, 11, 6, 19, 10, 0, 0, time.UTC)
+	end := time.Date(2018, 11, 6, 19, 20, 0, 0, time.UTC)
+	hdr, epochs := readObsFile(t, "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", withFilter(ObsFilter{Start: start, End: end, Interval: time.Minute}))
+	assert.Equal(60.0, hdr.Interval)
+	if assert.Len(epochs, 11) {
+		assert.Equal(start, epochs[0].Time)
+		assert.Equal(end, epochs[10].Time)
+	}
+	for _, epo := range epochs {
+		assert.Zero(epo.Time.Second(), "epoch %s", epo.Time)
+	}
+
+	// decimation interval smaller than the data interval
+	hdr, epochs = readObsFile(t, "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", withFilter(ObsFilter{Interval: time.Second}))
+	assert.Equal(30.0, hdr.Interval)
+	assert.Len(epochs, 120)
+}
+
+func TestObsFilter_satellites(t *testing.T) {
+	assert := assert.New(t)
+	excl := gnss.PRN{Sys: gnss.SysGPS, Num: 5}
+	filt := ObsFilter{SatSystems: gnss.Systems{gnss.SysGPS, gnss.SysGAL}, ExcludePRNs: []gnss.PRN{excl}}
+	hdr, epochs := readObsFile(t, "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", withFilter(filt))
+	assert.ElementsMatch([]gnss.System{gnss.SysGPS, gnss.SysGAL}, hdr.SatSystems())
+	assert.Equal(gnss.SysMIXED, hdr.SatSystem)
+	assert.Len(epochs, 120)
+
+	foundGAL := false
+	for _, epo := range epochs {
+		assert.Equal(int(epo.NumSat), len(epo.ObsList))
+		for _, satObs := range epo.ObsList {
+			assert.Contains([]gnss.System{gnss.SysGPS, gnss.SysGAL}, satObs.Prn.Sys)
+			assert.NotEqual(excl, satObs.Prn)
+			if satObs.Prn.Sys == gnss.SysGAL {
+				foundGAL = true
+			}
+		}
+	}
+	assert.True(foundGAL)
+
+	// a single system
+	hdr, _ = readObsFile(t, "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", withFilter(ObsFilter{SatSystems: gnss.Systems{gnss.SysGAL}}))
+	assert.Equal(gnss.SysGAL, hdr.SatSystem)
+}
+
+func TestObsFilter_obsCodes(t *testing.T) {
+	assert := assert.New(t)
+	filt := ObsFilter{ObsCodes: map[gnss.System][]ObsCode{gnss.SysGPS: {"L1C", "C1C", "C5Q"}}}
+	origHdr, origEpochs := readObsFile(t, "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+	hdr, epochs := readObsFile(t, "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", withFilter(filt))
+	assert.Equal([]ObsCode{"C1C", "L1C", "C5Q"}, hdr.ObsTypes[gnss.SysGPS], "order of the file")
+	assert.Equal(origHdr.ObsTypes[gnss.SysGAL], hdr.ObsTypes[gnss.SysGAL])
+	assert.Len(origHdr.ObsTypes[gnss.SysGPS], 14, "header of the file must not be modified")
+
+	for _, satObs := range epochs[0].ObsList {
+		if satObs.Prn.Sys == gnss.SysGPS {
+			assert.LessOrEqual(len(satObs.Obss), 3)
+		}
+	}
+
+	// The filtered data can be encoded with the filtered header.
+	var buf bytes.Buffer
+	enc, err := NewObsEncoder(&buf, hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, epo := range epochs {
+		assert.NoError(enc.Encode(epo))
+	}
+	assert.NoError(enc.Flush())
+	dec, err := NewObsDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readAllEpochs(t, dec)
+	if assert.Len(got, len(origEpochs)) {
+		assert.Equal(withoutBlankObs(epochs[10]), withoutBlankObs(got[10]))
+	}
+}
+
+func TestObsFilter_v2(t *testing.T) {
+	assert := assert.New(t)
+	filt := ObsFilter{SatSystems: gnss.Systems{gnss.SysGPS}, ObsCodes: map[gnss.System][]ObsCode{gnss.SysGPS: {"C1", "L1", "L2"}}}
+	hdr, epochs := readObsFile(t, "testdata/white/brst155h.20o", withFilter(filt))
+	assert.Equal([]ObsCode{"L1", "L2", "C1"}, hdr.ObsTypes[gnss.SysMIXED])
+	for _, epo := range epochs {
+		for _, satObs := range epo.ObsList {
+			assert.Equal(gnss.SysGPS, satObs.Prn.Sys)
+			assert.LessOrEqual(len(satObs.Obss), 3)
+		}
+	}
+}
+
+func TestObsFilter_Filter(t *testing.T) {
+	assert := assert.New(t)
+	filt := ObsFilter{Start: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Interval: 30 * time.Second}
+	ti := time.Date(2020, 1, 1, 0, 0, 30, 0, time.UTC)
+
+	assert.NotNil(filt.Filter(&Epoch{Time: ti}))
+	assert.NotNil(filt.Filter(&Epoch{Time: ti.Add(100 * time.Nanosecond)}), "within tolerance")
+	assert.Nil(filt.Filter(&Epoch{Time: ti.Add(time.Second)}), "decimated")
+	assert.Nil(filt.Filter(&Epoch{Time: ti.Add(-time.Hour)}), "before start")
+	assert.NotNil(filt.Filter(&Epoch{Flag: EpochFlagHeaderInfo}), "event without time")
+	assert.NotNil(filt.Filter(&Epoch{Time: ti.Add(time.Second), Flag: EpochFlagExternalEvent}), "event not decimated")
+}
+
+func TestObsFilter_FilterHeader(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2019, 9, 27, 10, 10, 0, 0, time.UTC)
+	end := time.Date(2019, 9, 27, 10, 20, 0, 0, time.UTC)
+	filt := ObsFilter{Start: start, End: end, SatSystems: gnss.Systems{gnss.SysGPS}, ObsCodes: map[gnss.System][]ObsCode{gnss.SysGPS: {"C1C", "L2S"}}}
+	origHdr, _ := readObsFile(t, "testdata/white/REYK00ISL_R_20192701000_01H_30S_MO.rnx")
+	hdr, epochs := readObsFile(t, "testdata/white/REYK00ISL_R_20192701000_01H_30S_MO.rnx", withFilter(filt))
+	assert.Equal(map[gnss.System][]ObsCode{gnss.SysGPS: {"C1C", "L2S"}}, hdr.ObsTypes)
+	assert.Equal(start, hdr.TimeOfFirstObs)
+	assert.Zero(hdr.NSatellites)
+	assert.Nil(hdr.ObsPerSat)
+	assert.Equal([]PhaseShift{{Sys: gnss.SysGPS, Code: "L2S", Correction: -0.25}}, hdr.PhaseShifts)
+	assert.Empty(hdr.GloSlots)
+	assert.Nil(hdr.GloCodPhsBias)
+	assert.Equal(49, origHdr.NSatellites, "header of the file must not be modified")
+	assert.Len(origHdr.GloSlots, 24, "header of the file must not be modified")
+
+	var buf bytes.Buffer
+	enc, err := NewObsEncoder(&buf, hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, epo := range epochs {
+		assert.NoError(enc.Encode(epo))
+	}
+	assert.NoError(enc.Flush())
+	head, _, _ := strings.Cut(buf.String(), "END OF HEADER")
+	assert.NotContains(head, "# OF SATELLITES")
+	assert.NotContains(head, "PRN / # OF OBS")
+	assert.NotContains(head, "GLONASS")
+	assert.NotContains(head, "R L2P")
+
+	dec, err := NewObsDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(hdr.ObsTypes, dec.Header.ObsTypes)
+	assert.Len(readAllEpochs(t, dec), 21)
+
+	// The time of the last obs is limited to the end of the window.
+	hdr = filt.FilterHeader(ObsHeader{RINEXVersion: 3.04, TimeOfFirstObs: start.Add(-time.Hour), TimeOfLastObs: end.Add(time.Hour)})
+	assert.Equal(start, hdr.TimeOfFirstObs)
+	assert.Equal(end, hdr.TimeOfLastObs)
+}
+
+func TestObsFilter_events(t *testing.T) {
+	// The satellite and observation counts of events are dropped.
+	assert := assert.New(t)
+	_, epochs := readObsFile(t, "testdata/white/kais329w.18o", withFilter(ObsFilter{SatSystems: gnss.Systems{gnss.SysGAL}}))
+	nEvents := 0
+	for _, epo := range epochs {
+		if epo.Flag != EpochFlagHeaderInfo {
+			continue
+		}
+		nEvents++
+		for _, rec := range epo.Records {
+			assert.NotContains(rec, "PRN / # OF OBS")
+			assert.NotContains(rec, "# OF SATELLITES")
+		}
+		assert.Zero(epo.Event.Header.NSatellites)
+		assert.Nil(epo.Event.Header.ObsPerSat)
+	}
+	assert.Equal(1, nEvents)
+}
+
+func TestObsFilter_eventObsTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		filt ObsFilter
+		want []string
+	}{
+		{name: "obs codes", filt: ObsFilter{ObsCodes: map[gnss.System][]ObsCode{gnss.SysGPS: {"C1C"}}}, want: []string{
+			"3001376             SEPT POLARX5TR      5.4.0               REC # / TYPE / VERS",
+			"G    1 C1C                                                  SYS / # / OBS TYPES",
+			"G    1                                                      SYS / SCALE FACTOR",
+			"CHANGE OF FIRMWARE                                          COMMENT",
+		}},
+		{name: "systems", filt: ObsFilter{SatSystems: gnss.Systems{gnss.SysGAL}}, want: []string{
+			"3001376             SEPT POLARX5TR      5.4.0               REC # / TYPE / VERS",
+			"CHANGE OF FIRMWARE                                          COMMENT",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			epochs, _ := decodeEpochs(t, obsWithHeaderRecords, func(dec *ObsDecoder) { dec.SetFilter(tt.filt) })
+			if assert.Len(epochs, 3) {
+				assert.Equal(tt.want, epochs[1].Records)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package rinex
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HeaderEdits is a declarative set of header edits. The keys are the names of the header fields, e.g. "MarkerName",
+// "AntennaType" or "AntennaDelta", the values are given like in the JSON encoding of the field, e.g.
+//
+//	{"AntennaType": "TRM59800.00     SCIS", "AntennaDelta": {"Up": 0.0083, "N": 0, "E": 0}}
+//
+// The field names are case-insensitive. See EditHeader for the fields that can be edited.
+type HeaderEdits map[string]any
+
+// LoadHeaderEdits reads the header edits from a JSON or YAML file. The format is chosen by the filename
+// extension .json, .yaml or .yml.
+func LoadHeaderEdits(path string) (HeaderEdits, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var edits map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(b, &edits)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &edits)
+	default:
+		return nil, fmt.Errorf("rinex: header edits: unknown file format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rinex: header edits: %s: %v", path, err)
+	}
+	return HeaderEdits(edits), nil
+}
+
+// HeaderChange describes the change of a header field.
+type HeaderChange struct {
+	Field string `json:"field"` // The name of the header field.
+	Old   any    `json:"old"`   // The old value.
+	New   any    `json:"new"`   // The new value.
+}
+
+// String returns the change in the form of `MarkerName: "BRUX" -> "BRUS"`.
+func (c HeaderChange) String() string {
+	format := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Field, format(c.Old), format(c.New))
+}
+
+// headerRecord is a header record that can be edited.
+type headerRecord[H any] struct {
+	labels []string // the labels of the header lines
+	fields []string // the header fields written to the lines
+	multi  bool     // the record can span several lines
+	format func(hdr *H) []string
+	valid  func(hdr *H) bool // reports whether the header can have the record, nil for all headers
+}
+
+// headerLine returns a header line with the value in the first 60 columns and the label.
+func headerLine(val, label string) string {
+	return fmt.Sprintf("%-60.60s%s", val, label)
+}
+
+// optionalLine returns the header line for the value, or nil if the value is empty.
+func optionalLine(val, label string) []string {
+	if val == "" {
+		return nil
+	}
+	return []string{headerLine(val, label)}
+}
+
+// multiLines returns the header lines for the values.
+func multiLines(vals []string, label string) []string {
+	lines := make([]string, 0, len(vals))
+	for _, val := range vals {
+		lines = append(lines, headerLine(val, label))
+	}
+	return lines
+}
+
+func pgmRecord(pgm, runBy string, date time.Time) []string {
+	dateStr := ""
+	if !date.IsZero() {
+		dateStr = date.Format("20060102 150405 UTC")
+	}
+	return []string{fmt.Sprintf("%-20.20s%-20.20s%-20.20s%s", pgm, runBy, dateStr, "PGM / RUN BY / DATE")}
+}
+
+// The editable records of the RINEX headers.
+var (
+	obsHeaderRecords = []headerRecord[ObsHeader]{
+		{labels: []string{"PGM / RUN BY / DATE"}, fields: []string{"Pgm", "RunBy", "Date"},
+			format: func(hdr *ObsHeader) []string { return pgmRecord(hdr.Pgm, hdr.RunBy, hdr.Date) }},
+		{labels: []string{"MARKER NAME"}, fields: []string{"MarkerName"},
+			format: func(hdr *ObsHeader) []string { return []string{headerLine(hdr.MarkerName, "MARKER NAME")} }},
+		{labels: []string{"MARKER NUMBER"}, fields: []string{"MarkerNumber"},
+			format: func(hdr *ObsHeader) []string { return optionalLine(hdr.MarkerNumber, "MARKER NUMBER") }},
+		{labels: []string{"MARKER TYPE"}, fields: []string{"MarkerType"},
+			format: func(hdr *ObsHeader) []string { return optionalLine(hdr.MarkerType, "MARKER TYPE") }},
+		{labels: []string{"OBSERVER / AGENCY"}, fields: []string{"Observer", "Agency"},
+			format: func(hdr *ObsHeader) []string {
+				return []string{fmt.Sprintf("%-20.20s%-40.40s%s", hdr.Observer, hdr.Agency, "OBSERVER / AGENCY")}
+			}},
+		{labels: []string{"REC # / TYPE / VERS"}, fields: []string{"ReceiverNumber", "ReceiverType", "ReceiverVersion"},
+			format: func(hdr *ObsHeader) []string { return []string{hdr.receiverRecord()} }},
+		{labels: []string{"ANT # / TYPE"}, fields: []string{"AntennaNumber", "AntennaType"},
+			format: func(hdr *ObsHeader) []string { return []string{hdr.antennaRecord()} }},
+		{labels: []string{"APPROX POSITION XYZ"}, fields: []string{"Position"},
+			format: func(hdr *ObsHeader) []string {
+				return []string{headerLine(fmt.Sprintf("%14.4f%14.4f%14.4f", hdr.Position.X, hdr.Position.Y, hdr.Position.Z), "APPROX POSITION XYZ")}
+			}},
+		{labels: []string{"ANTENNA: DELTA H/E/N"}, fields: []string{"AntennaDelta"},
+			format: func(hdr *ObsHeader) []string { return []string{hdr.antennaDeltaRecord()} }},
+		{labels: []string{"DOI"}, fields: []string{"DOI"},
+			format: func(hdr *ObsHeader) []string { return optionalLine(hdr.DOI, "DOI") }},
+		{labels: []string{"LICENSE OF USE"}, fields: []string{"Licenses"}, multi: true,
+			format: func(hdr *ObsHeader) []string { return multiLines(hdr.Licenses, "LICENSE OF USE") }},
+		{labels: []string{"STATION INFORMATION"}, fields: []string{"StationInfos"}, multi: true,
+			format: func(hdr *ObsHeader) []string { return multiLines(hdr.StationInfos, "STATION INFORMATION") }},
+		{labels: []string{"SIGNAL STRENGTH UNIT"}, fields: []string{"SignalStrengthUnit"},
+			format: func(hdr *ObsHeader) []string { return optionalLine(hdr.SignalStrengthUnit, "SIGNAL STRENGTH UNIT") }},
+		{labels: []string{"INTERVAL"}, fields: []string{"Interval"},
+			format: func(hdr *ObsHeader) []string {
+				if hdr.Interval == 0 {
+					return nil
+				}
+				return []string{headerLine(fmt.Sprintf("%10.3f", hdr.Interval), "INTERVAL")}
+			}},
+	}
+
+	navHeaderRecords = []headerRecord[NavHeader]{
+		{labels: []string{"PGM / RUN BY / DATE"}, fields: []string{"Pgm", "RunBy", "Date"},
+			format: func(hdr *NavHeader) []string { return pgmRecord(hdr.Pgm, hdr.RunBy, hdr.Date) }},
+		{labels: []string{"DOI"}, fields: []string{"DOI"},
+			format: func(hdr *NavHeader) []string { return optionalLine(hdr.DOI, "DOI") }},
+		{labels: []string{"LICENSE OF USE"}, fields: []string{"Licenses"}, multi: true,
+			format: func(hdr *NavHeader) []string { return multiLines(hdr.Licenses, "LICENSE OF USE") }},
+		{labels: []string{"STATION INFORMATION"}, fields: []string{"StationInfos"}, multi: true,
+			format: func(hdr *NavHeader) []string { return multiLines(hdr.StationInfos, "STATION INFORMATION") }},
+		{labels: []string{"MERGED FILE"}, fields: []string{"MergedFiles"},
+			format: func(hdr *NavHeader) []string { return hdr.mergedFileRecord() },
+			valid:  func(hdr *NavHeader) bool { return hdr.RINEXVersion >= 4 }},
+		{labels: []string{"IONOSPHERIC CORR", "ION ALPHA", "ION BETA"}, fields: []string{"IonoCorrs"}, multi: true,
+			format: func(hdr *NavHeader) []string { return hdr.ionoCorrRecords() },
+			valid:  func(hdr *NavHeader) bool { return hdr.RINEXVersion < 4 }},
+		{labels: []string{"TIME SYSTEM CORR", "DELTA-UTC: A0,A1,T,W"}, fields: []string{"TimeCorrs"}, multi: true,
+			format: func(hdr *NavHeader) []string { return hdr.timeCorrRecords() },
+			valid:  func(hdr *NavHeader) bool { return hdr.RINEXVersion < 4 }},
+		{labels: []string{"LEAP SECONDS"}, fields: []string{"LeapSeconds"},
+			format: func(hdr *NavHeader) []string { return hdr.leapSecondsRecord() }},
+	}
+
+	meteoHeaderRecords = []headerRecord[MeteoHeader]{
+		{labels: []string{"PGM / RUN BY / DATE"}, fields: []string{"Pgm", "RunBy", "Date"},
+			format: func(hdr *MeteoHeader) []string { return pgmRecord(hdr.Pgm, hdr.RunBy, hdr.Date) }},
+		{labels: []string{"MARKER NAME"}, fields: []string{"MarkerName"},
+			format: func(hdr *MeteoHeader) []string { return []string{headerLine(hdr.MarkerName, "MARKER NAME")} }},
+		{labels: []string{"MARKER NUMBER"}, fields: []string{"MarkerNumber"},
+			format: func(hdr *MeteoHeader) []string { return optionalLine(hdr.MarkerNumber, "MARKER NUMBER") }},
+		{labels: []string{"DOI"}, fields: []string{"DOI"},
+			format: func(hdr *MeteoHeader) []string { return optionalLine(hdr.DOI, "DOI") }},
+		{labels: []string{"LICENSE OF USE"}, fields: []string{"Licenses"}, multi: true,
+			format: func(hdr *MeteoHeader) []string { return multiLines(hdr.Licenses, "LICENSE OF USE") }},
+		{labels: []string{"STATION INFORMATION"}, fields: []string{"StationInfos"}, multi: true,
+			format: func(hdr *MeteoHeader) []string { return multiLines(hdr.StationInfos, "STATION INFORMATION") }},
+		{labels: []string{"SENSOR MOD/TYPE/ACC", "SENSOR POS XYZ/H"}, fields: []string{"Sensors"}, multi: true,
+			format: func(hdr *MeteoHeader) []string {
+				lines := make([]string, 0, 2*len(hdr.Sensors))
+				for _, s := range hdr.Sensors {
+					lines = append(lines, fmt.Sprintf("%-20.20s%-20.20s%6s%7.1f%4s%-2.2s%1s%s", s.Model, s.Type, "", s.Accuracy, "",
+						s.ObservationType, "", "SENSOR MOD/TYPE/ACC"))
+				}
+				for _, s := range hdr.Sensors {
+					if s.Position == (Coord{}) && s.Height == 0 {
+						continue
+					}
+					lines = append(lines, fmt.Sprintf("%14.4f%14.4f%14.4f%14.4f%1s%-2.2s%1s%s", s.Position.X, s.Position.Y, s.Position.Z,
+						s.Height, "", s.ObservationType, "", "SENSOR POS XYZ/H"))
+				}
+				return lines
+			}},
+	}
+)
+
+// EditHeader copies the RINEX data from r to w with the header edited. The data records are copied unchanged.
+// Observation, navigation and meteo files are supported, also in the Compact RINEX format.
+//
+// The following fields can be edited:
+//   - ObsHeader: Pgm, RunBy, Date, MarkerName, MarkerNumber, MarkerType, Observer, Agency, ReceiverNumber,
+//     ReceiverType, ReceiverVersion, AntennaNumber, AntennaType, Position, AntennaDelta, DOI, Licenses,
+//     StationInfos, SignalStrengthUnit and Interval.
+//   - NavHeader: Pgm, RunBy, Date, DOI, Licenses, StationInfos, IonoCorrs, TimeCorrs, LeapSeconds and MergedFiles.
+//     The IonoCorrs and TimeCorrs are no header records in RINEX-4, MergedFiles is one since RINEX-4.
+//     In RINEX-2 only the corrections of GPS can be written.
+//   - MeteoHeader: Pgm, RunBy, Date, MarkerName, MarkerNumber, DOI, Licenses, StationInfos and Sensors.
+//
+// Only the header records of changed fields are rewritten, all other lines are kept as they are. For each change
+// a COMMENT line is added at the end of the header. The changes are returned.
+func EditHeader(w io.Writer, r io.Reader, edits HeaderEdits) ([]HeaderChange, error) {
+	br := bufio.NewReader(r)
+	var prefix, lines []string // prefix are the Compact RINEX lines
+	for {
+		line, err := br.ReadString('\n')
+		if line == "" && err != nil {
+			if err == io.EOF {
+				return nil, ErrNoHeader
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		label := headerLabel(line)
+		if strings.HasPrefix(label, "CRINEX") {
+			prefix = append(prefix, line)
+			continue
+		}
+		lines = append(lines, line)
+		if label == "END OF HEADER" {
+			break
+		}
+		if err != nil {
+			return nil, ErrNoHeader
+		}
+	}
+	if len(lines[0]) < 21 || headerLabel(lines[0]) != "RINEX VERSION / TYPE" {
+		return nil, fmt.Errorf("rinex: edit header: invalid first header line: %q", lines[0])
+	}
+
+	var changes []HeaderChange
+	var err error
+	hdrText := strings.Join(lines, "\n") + "\n"
+	switch typ := lines[0][20]; typ {
+	case 'O':
+		var dec *ObsDecoder
+		if dec, err = NewObsDecoder(strings.NewReader(hdrText)); err == nil {
+			lines, changes, err = editHeaderLines(lines, dec.Header, edits, obsHeaderRecords)
+		}
+	case 'N', 'G', 'H':
+		var dec *NavDecoder
+		if dec, err = NewNavDecoder(strings.NewReader(hdrText)); err == nil {
+			lines, changes, err = editHeaderLines(lines, dec.Header, edits, navHeaderRecords)
+		}
+	case 'M':
+		var dec *MetDecoder
+		if dec, err = NewMetDecoder(strings.NewReader(hdrText)); err == nil {
+			lines, changes, err = editHeaderLines(lines, dec.Header, edits, meteoHeaderRecords)
+		}
+	default:
+		return nil, fmt.Errorf("rinex: edit header: unsupported RINEX type %q", typ)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rinex: edit header: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, line := range slices.Concat(prefix, lines) {
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+	if _, err := io.Copy(bw, br); err != nil {
+		return changes, err
+	}
+	return changes, bw.Flush()
+}
+
+// EditHeaderFile edits the header of the RINEX file in place, see EditHeader. Files compressed with gzip or
+// Unix compress (.Z) are written with the same compression. The file is not modified if nothing changes.
+func EditHeaderFile(path string, edits HeaderEdits) ([]HeaderChange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(f)
+	comp, err := detectCompression(br, path)
+	if err != nil {
+		return nil, err
+	}
+	if comp != "" && comp != "gz" && comp != "gzip" && comp != "Z" {
+		return nil, fmt.Errorf("rinex: edit header: writing %s compressed files is not supported", comp)
+	}
+	r, err := decompress(br, comp)
+	if err != nil {
+		return nil, fmt.Errorf("rinex: open %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+
+	var w io.WriteCloser
+	switch comp {
+	case "gz", "gzip":
+		w = gzip.NewWriter(tmp)
+	case "Z":
+		if w, err = NewZWriter(tmp, zMaxBits); err != nil {
+			tmp.Close()
+			return nil, err
+		}
+	default:
+		w = nopWriteCloser{tmp}
+	}
+	changes, err := EditHeader(w, r, edits)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode().Perm()); err != nil {
+		return changes, err
+	}
+	return changes, os.Rename(tmp.Name(), path)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// headerLabel returns the label of the header line.
+func headerLabel(line string) string {
+	if len(line) <= 60 {
+		return ""
+	}
+	return strings.TrimSpace(line[60:])
+}
+
+// editHeaderLines applies the edits to the header hdr and rewrites the records of the changed fields in lines.
+func editHeaderLines[H any](lines []string, hdr H, edits HeaderEdits, records []headerRecord[H]) ([]string, []HeaderChange, error) {
+	records = slices.DeleteFunc(slices.Clone(records), func(rec headerRecord[H]) bool { return rec.valid != nil && !rec.valid(&hdr) })
+	newHdr, err := applyHeaderEdits(hdr, edits, records)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oldVal, newVal := reflect.ValueOf(&hdr).Elem(), reflect.ValueOf(&newHdr).Elem()
+	changes := []HeaderChange{}
+	for _, rec := range records {
+		changed := false
+		for _, field := range rec.fields {
+			o, n := oldVal.FieldByName(field).Interface(), newVal.FieldByName(field).Interface()
+			if !reflect.DeepEqual(o, n) {
+				changes = append(changes, HeaderChange{Field: field, Old: o, New: n})
+				changed = true
+			}
+		}
+		if changed {
+			lines = replaceRecord(lines, rec.labels, rec.multi, rec.format(&newHdr))
+		}
+	}
+
+	// audit comments
+	comments := []string{}
+	for _, c := range changes {
+		for chunk := range slices.Chunk([]rune(c.String()), 60) {
+			comments = append(comments, headerLine(string(chunk), "COMMENT"))
+		}
+	}
+	end := len(lines) - 1 // END OF HEADER
+	lines = slices.Insert(lines, end, comments...)
+	return lines, changes, nil
+}
+
+// applyHeaderEdits returns a copy of the header with the edits applied.
+func applyHeaderEdits[H any](hdr H, edits HeaderEdits, records []headerRecord[H]) (H, error) {
+	fields := []string{}
+	for _, rec := range records {
+		fields = append(fields, rec.fields...)
+	}
+
+	newVal := reflect.ValueOf(&hdr).Elem()
+	normalized := make(map[string]any, len(edits))
+	for key, val := range edits {
+		i := slices.IndexFunc(fields, func(field string) bool { return strings.EqualFold(field, key) })
+		if i < 0 {
+			return hdr, fmt.Errorf("field %q can not be edited in a %T, the editable fields are: %s", key, hdr, strings.Join(fields, ", "))
+		}
+		if _, exists := normalized[fields[i]]; exists {
+			return hdr, fmt.Errorf("field %q given twice", fields[i])
+		}
+		normalized[fields[i]] = val
+		// Reset the field, so that slices are not modified in place.
+		f := newVal.FieldByName(fields[i])
+		f.Set(reflect.Zero(f.Type()))
+	}
+
+	b, err := json.Marshal(normalized)
+	if err != nil {
+		return hdr, err
+	}
+	if err := json.Unmarshal(b, &hdr); err != nil {
+		return hdr, err
+	}
+	return hdr, nil
+}
+
+// replaceRecord replaces the lines with the labels by newLines. If multi is false, only the first line with one of
+// the labels is replaced. Missing records are inserted before the observation types, or at the end of the header.
+func replaceRecord(lines []string, labels []string, multi bool, newLines []string) []string {
+	idx := -1
+	out := make([]string, 0, len(lines)+len(newLines))
+	for _, line := range lines {
+		if slices.Contains(labels, headerLabel(line)) && (multi || idx < 0) {
+			if idx < 0 {
+				idx = len(out)
+			}
+			continue
+		}
+		out = append(out, line)
+	}
+	if idx < 0 {
+		idx = slices.IndexFunc(out, func(line string) bool {
+			label := headerLabel(line)
+			return label == "SYS / # / OBS TYPES" || label == "# / TYPES OF OBSERV" || label == "END OF HEADER"
+		})
+		if slices.Contains(labels, "PGM / RUN BY / DATE") {
+			idx = 1
+		}
+	}
+	return slices.Insert(out, idx, newLines...)
+}
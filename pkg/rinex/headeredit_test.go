@@ -0,0 +1,330 @@
+package rinex
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// splitHeader splits RINEX data after the END OF HEADER line.
+func splitHeader(t *testing.T, data []byte) (hdr, body []byte) {
+	t.Helper()
+	i := bytes.Index(data, []byte("END OF HEADER"))
+	if i < 0 {
+		t.Fatal("no END OF HEADER")
+	}
+	i += bytes.IndexByte(data[i:], '\n') + 1
+	return data[:i], data[i:]
+}
+
+func TestEditHeader_obs(t *testing.T) {
+	assert := assert.New(t)
+	data, err := os.ReadFile("testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edits := HeaderEdits{
+		"markername":   "BRUS",
+		"AntennaType":  "JAVRINGANT_DM   SCIS",
+		"AntennaDelta": map[string]any{"Up": 0.4711, "E": 0, "N": 0.001},
+		"ObserverName": nil,
+	}
+	var buf bytes.Buffer
+	_, err = EditHeader(&buf, bytes.NewReader(data), edits)
+	assert.ErrorContains(err, `"ObserverName" can not be edited`)
+
+	delete(edits, "ObserverName")
+	edits["MarkerNumber"] = "13101M010" // unchanged
+	edits["Licenses"] = []string{"CC BY 4.0"}
+	buf.Reset()
+	changes, err := EditHeader(&buf, bytes.NewReader(data), edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(changes, 4) {
+		assert.Equal("MarkerName", changes[0].Field)
+		assert.Equal(`MarkerName: "BRUX" -> "BRUS"`, changes[0].String())
+		assert.Equal("AntennaType", changes[1].Field)
+		assert.Equal("AntennaDelta", changes[2].Field)
+		assert.Equal(CoordNEU{N: 0.001, E: 0, Up: 0.4711}, changes[2].New)
+		assert.Equal("Licenses", changes[3].Field)
+	}
+
+	gotHdr, gotBody := splitHeader(t, buf.Bytes())
+	_, wantBody := splitHeader(t, data)
+	assert.Equal(wantBody, gotBody, "body unchanged")
+
+	lines := strings.Split(strings.TrimSuffix(string(gotHdr), "\n"), "\n")
+	assert.Equal("BRUS                                                        MARKER NAME", lines[2])
+	assert.Contains(string(gotHdr), "CC BY 4.0                                                   LICENSE OF USE\n")
+	assert.Contains(string(gotHdr), "MarkerName: \"BRUX\" -> \"BRUS\"                                COMMENT\n")
+	assert.Equal("END OF HEADER", strings.TrimSpace(lines[len(lines)-1]))
+
+	dec, err := NewObsDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr := dec.Header
+	assert.Equal("BRUS", hdr.MarkerName)
+	assert.Equal("13101M010", hdr.MarkerNumber)
+	assert.Equal("JAVRINGANT_DM", hdr.AntennaType[:13])
+	assert.Equal(CoordNEU{N: 0.001, E: 0, Up: 0.4711}, hdr.AntennaDelta)
+	assert.Equal([]string{"CC BY 4.0"}, hdr.Licenses)
+	assert.Equal("SEPT POLARX4TR", hdr.ReceiverType)
+	assert.Len(hdr.ObsTypes, 4)
+
+	_, err = EditHeader(&buf, bytes.NewReader(data), HeaderEdits{"Interval": "thirty"})
+	assert.Error(err, "invalid type")
+}
+
+func TestEditHeader_crx(t *testing.T) {
+	assert := assert.New(t)
+	f, err := os.Open("testdata/white/BRUX00BEL_R_20202302000_01H_30S_MO.crx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	changes, err := EditHeader(&buf, f, HeaderEdits{"ReceiverVersion": "5.4.0", "Agency": "BKG"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(changes, 2)
+	assert.True(strings.HasPrefix(buf.String(), "3.0                 COMPACT RINEX FORMAT"))
+
+	r, err := NewCrxReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := NewObsDecoder(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("5.4.0", dec.Header.ReceiverVersion)
+	assert.Equal("BKG", dec.Header.Agency)
+	nEpochs := 0
+	for dec.NextEpoch() {
+		nEpochs++
+	}
+	assert.NoError(dec.Err())
+	assert.Equal(120, nEpochs)
+}
+
+func TestEditHeader_nav(t *testing.T) {
+	assert := assert.New(t)
+	data, err := os.ReadFile("testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	changes, err := EditHeader(&buf, bytes.NewReader(data), HeaderEdits{"DOI": "10.1234/abcd", "MarkerName": "AREG"})
+	assert.ErrorContains(err, `"MarkerName" can not be edited`)
+
+	changes, err = EditHeader(&buf, bytes.NewReader(data), HeaderEdits{"DOI": "10.1234/abcd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(changes, 1)
+	_, wantBody := splitHeader(t, data)
+	_, gotBody := splitHeader(t, buf.Bytes())
+	assert.Equal(wantBody, gotBody, "body unchanged")
+
+	dec, err := NewNavDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("10.1234/abcd", dec.Header.DOI)
+	assert.Equal("sbf2rin-13.4.3", dec.Header.Pgm)
+}
+
+func TestEditHeader_navCorrections(t *testing.T) {
+	areg, err := os.ReadFile("testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gput := map[string]any{"Type": "GPUT", "A0": 1.5e-9, "A1": -2e-14, "T": 589824, "W": 2110}
+	tests := []struct {
+		name    string
+		data    string
+		edits   HeaderEdits
+		wantErr string
+		check   func(t *testing.T, hdr NavHeader)
+	}{
+		{name: "v3 time corrections", data: string(areg), edits: HeaderEdits{"TimeCorrs": []any{gput}},
+			check: func(t *testing.T, hdr NavHeader) {
+				assert.Equal(t, []TimeSystemCorr{{Type: "GPUT", A0: 1.5e-9, A1: -2e-14, T: 589824, W: 2110}}, hdr.TimeCorrs)
+				assert.Len(t, hdr.IonoCorrs, 3)
+			}},
+		{name: "v3 leap seconds", data: string(areg), edits: HeaderEdits{"LeapSeconds": map[string]any{"Current": 18, "Future": 19, "Week": 2200, "Day": 7}},
+			check: func(t *testing.T, hdr NavHeader) {
+				assert.Equal(t, LeapSeconds{Current: 18, Future: 19, Week: 2200, Day: 7}, hdr.LeapSeconds)
+			}},
+		{name: "v2 iono corrections", data: navDataV2,
+			edits: HeaderEdits{"IonoCorrs": []any{map[string]any{"Type": "GPSA", "Params": []float64{1e-8, 2e-8, 0, 0}}}},
+			check: func(t *testing.T, hdr NavHeader) {
+				assert.Equal(t, []IonoCorr{{Type: "GPSA", Params: [4]float64{1e-8, 2e-8}}}, hdr.IonoCorrs)
+				assert.Len(t, hdr.TimeCorrs, 1)
+			}},
+		{name: "v4 merged files", data: navDataV4, edits: HeaderEdits{"MergedFiles": 25},
+			check: func(t *testing.T, hdr NavHeader) { assert.Equal(t, 25, hdr.MergedFiles) }},
+		{name: "v4 iono corrections", data: navDataV4, edits: HeaderEdits{"IonoCorrs": []any{}},
+			wantErr: `"IonoCorrs" can not be edited in a rinex.NavHeader, the editable fields are: Pgm, RunBy, Date, DOI, Licenses, StationInfos, MergedFiles, LeapSeconds`},
+		{name: "v3 merged files", data: string(areg), edits: HeaderEdits{"MergedFiles": 2}, wantErr: `"MergedFiles" can not be edited`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			changes, err := EditHeader(&buf, strings.NewReader(tt.data), tt.edits)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Len(t, changes, 1)
+			_, wantBody := splitHeader(t, []byte(tt.data))
+			_, gotBody := splitHeader(t, buf.Bytes())
+			assert.Equal(t, wantBody, gotBody, "body unchanged")
+
+			dec, err := NewNavDecoder(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, dec.Header)
+		})
+	}
+}
+
+func TestEditHeader_meteo(t *testing.T) {
+	assert := assert.New(t)
+	f, err := os.Open("testdata/white/func3060.19m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	sensors := []map[string]any{
+		{"Model": "Vaisala", "Type": "PTU307", "Accuracy": 0.1, "ObservationType": "PR",
+			"Position": map[string]float64{"X": 5143339.4259, "Y": -1563412.8716, "Z": 3421191.4391}, "Height": 1.5},
+		{"Model": "Vaisala", "Type": "PTU307", "Accuracy": 0.2, "ObservationType": "TD"},
+		{"Model": "Vaisala", "Type": "PTU307", "Accuracy": 1, "ObservationType": "HR"},
+	}
+	var buf bytes.Buffer
+	changes, err := EditHeader(&buf, f, HeaderEdits{"Sensors": sensors, "MarkerNumber": "13911S002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(changes, 2)
+
+	dec, err := NewMetDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr := dec.Header
+	assert.Equal("13911S002", hdr.MarkerNumber)
+	if assert.Len(hdr.Sensors, 3) {
+		assert.Equal("Vaisala", hdr.Sensors[0].Model)
+		assert.Equal("PTU307", hdr.Sensors[0].Type)
+		assert.Equal(0.1, hdr.Sensors[0].Accuracy)
+		assert.Equal(1.5, hdr.Sensors[0].Height)
+		assert.Equal(5143339.4259, hdr.Sensors[0].Position.X)
+		assert.Equal(MeteoObsType("HR"), hdr.Sensors[2].ObservationType)
+	}
+	nEpochs := 0
+	for dec.NextEpoch() {
+		nEpochs++
+	}
+	assert.NoError(dec.Err())
+	assert.Equal(95, nEpochs)
+}
+
+func TestEditHeaderFile(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "plain", file: "testdata/white/kais329w.18o"},
+		{name: "gzip", file: "testdata/white/kais329w.18o.gz"},
+		{name: "Z", file: "testdata/white/kais329w.18o.Z"},
+		{name: "crx Z", file: "testdata/white/brst155h.20d.Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := copyToTempDir(tt.file, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes, err := EditHeaderFile(path, HeaderEdits{"MarkerName": "TEST"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Len(changes, 1)
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			orig, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(orig[:2], data[:2], "same compression")
+
+			dec := newObsDecoder(t, path)
+			assert.Equal("TEST", dec.Header.MarkerName)
+			for dec.NextEpoch() {
+			}
+			assert.NoError(dec.Err())
+
+			// no changes, the file is not rewritten
+			changes, err = EditHeaderFile(path, HeaderEdits{"MarkerName": "TEST"})
+			assert.NoError(err)
+			assert.Empty(changes)
+			data2, err := os.ReadFile(path)
+			assert.NoError(err)
+			assert.Equal(data, data2)
+		})
+	}
+
+	path, err := copyToTempDir("testdata/white/kais329w.18o.bz2", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = EditHeaderFile(path, HeaderEdits{"MarkerName": "TEST"})
+	assert.Error(err, "bz2 is not supported")
+}
+
+func TestLoadHeaderEdits(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	want := HeaderEdits{"MarkerName": "BRUS", "AntennaDelta": map[string]any{"Up": 0.0083, "N": 0.0, "E": 0.0}}
+
+	jsonPath := filepath.Join(dir, "edits.json")
+	err := os.WriteFile(jsonPath, []byte(`{"MarkerName": "BRUS", "AntennaDelta": {"Up": 0.0083, "N": 0, "E": 0}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits, err := LoadHeaderEdits(jsonPath)
+	assert.NoError(err)
+	assert.Equal(want, edits)
+
+	yamlPath := filepath.Join(dir, "edits.yaml")
+	err = os.WriteFile(yamlPath, []byte("MarkerName: BRUS\nAntennaDelta:\n  Up: 0.0083\n  N: 0.0\n  E: 0.0\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits, err = LoadHeaderEdits(yamlPath)
+	assert.NoError(err)
+	assert.Equal(want, edits)
+
+	_, err = LoadHeaderEdits(filepath.Join(dir, "edits.txt"))
+	assert.Error(err)
+}
@@ -0,0 +1,191 @@
+package rinex
+
+import (
+	"errors"
+	"iter"
+	"slices"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// Timed is implemented by all time-tagged data records: *Epoch, Eph, *MeteoEpoch and *ClockRecord.
+type Timed interface {
+	GetTime() time.Time
+}
+
+// Epochs returns an iterator over the epochs of the observation stream.
+// A decoding error is yielded with a nil epoch as last element.
+// It returns a single-use iterator.
+func (dec *ObsDecoder) Epochs() iter.Seq2[*Epoch, error] {
+	return func(yield func(*Epoch, error) bool) {
+		for dec.NextEpoch() {
+			if !yield(dec.Epoch(), nil) {
+				return
+			}
+		}
+		if err := dec.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Ephemerides returns an iterator over the ephemerides of the navigation stream.
+// A decoding error is yielded with a nil ephemeris as last element.
+// It returns a single-use iterator.
+func (dec *NavDecoder) Ephemerides() iter.Seq2[Eph, error] {
+	return func(yield func(Eph, error) bool) {
+		for dec.NextEphemeris() {
+			if !yield(dec.Ephemeris(), nil) {
+				return
+			}
+		}
+		if err := dec.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// MeteoEpochs returns an iterator over the epochs of the meteo stream.
+// A decoding error is yielded with a nil epoch as last element.
+// It returns a single-use iterator.
+func (dec *MetDecoder) MeteoEpochs() iter.Seq2[*MeteoEpoch, error] {
+	return func(yield func(*MeteoEpoch, error) bool) {
+		for dec.NextEpoch() {
+			if !yield(dec.Epoch(), nil) {
+				return
+			}
+		}
+		if err := dec.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// ClockRecords returns an iterator over the data records of the clock stream.
+// A decoding error is yielded with a nil record as last element.
+// It returns a single-use iterator.
+func (dec *ClockDecoder) ClockRecords() iter.Seq2[*ClockRecord, error] {
+	return func(yield func(*ClockRecord, error) bool) {
+		for dec.NextRecord() {
+			if !yield(dec.Record(), nil) {
+				return
+			}
+		}
+		if err := dec.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// filterSeq returns an iterator over the records of seq for which keep returns true. Errors are passed through.
+func filterSeq[T any](seq iter.Seq2[T, error], keep func(T) bool) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for rec, err := range seq {
+			if err != nil {
+				if !yield(rec, err) {
+					return
+				}
+				continue
+			}
+			if keep(rec) && !yield(rec, nil) {
+				return
+			}
+		}
+	}
+}
+
+// TimeWindow returns an iterator over the records of seq within the time window, both inclusive.
+// Zero values mean no limit.
+func TimeWindow[T Timed](seq iter.Seq2[T, error], start, end time.Time) iter.Seq2[T, error] {
+	return filterSeq(seq, func(rec T) bool {
+		t := rec.GetTime()
+		return (start.IsZero() || !t.Before(start)) && (end.IsZero() || !t.After(end))
+	})
+}
+
+// Decimate returns an iterator over the records of seq that are aligned to full multiples of the interval,
+// e.g. to full minutes for 60s.
+func Decimate[T Timed](seq iter.Seq2[T, error], interval time.Duration) iter.Seq2[T, error] {
+	return filterSeq(seq, func(rec T) bool {
+		t := rec.GetTime()
+		return t.Sub(t.Round(interval)).Abs() <= decimationTolerance
+	})
+}
+
+// FilterSystems returns an iterator over the ephemerides of seq for the given satellite systems.
+// Use FilterEpochs to select the satellite systems of observation epochs.
+func FilterSystems[T interface{ GetPRN() gnss.PRN }](seq iter.Seq2[T, error], systems ...gnss.System) iter.Seq2[T, error] {
+	return filterSeq(seq, func(rec T) bool {
+		return slices.Contains(systems, rec.GetPRN().Sys)
+	})
+}
+
+// FilterEpochs returns an iterator over the epochs of seq filtered with filt, see ObsFilter.Filter.
+func FilterEpochs(seq iter.Seq2[*Epoch, error], filt ObsFilter) iter.Seq2[*Epoch, error] {
+	return func(yield func(*Epoch, error) bool) {
+		for epo, err := range seq {
+			if err == nil {
+				if epo = filt.Filter(epo); epo == nil {
+					continue
+				}
+			}
+			if !yield(epo, err) {
+				return
+			}
+		}
+	}
+}
+
+// Zip returns an iterator over the time-synchronized epochs of two observation streams, e.g. to compare two files.
+// Epochs that are missing in one of the streams and special events are skipped.
+func Zip(seq1, seq2 iter.Seq2[*Epoch, error]) iter.Seq2[SyncEpochs, error] {
+	return func(yield func(SyncEpochs, error) bool) {
+		next1, stop1 := iter.Pull2(seq1)
+		defer stop1()
+		next2, stop2 := iter.Pull2(seq2)
+		defer stop2()
+
+		// nextObs returns the next epoch with observations.
+		nextObs := func(next func() (*Epoch, error, bool)) (*Epoch, error, bool) {
+			for {
+				epo, err, ok := next()
+				if !ok || err != nil || epo.Flag <= EpochFlagPowerFailure {
+					return epo, err, ok
+				}
+			}
+		}
+
+		epo1, err1, ok1 := nextObs(next1)
+		epo2, err2, ok2 := nextObs(next2)
+		for ok1 && ok2 {
+			if err1 != nil || err2 != nil {
+				yield(SyncEpochs{}, errors.Join(err1, err2))
+				return
+			}
+			switch {
+			case epo1.Time.Equal(epo2.Time):
+				if !yield(SyncEpochs{epo1, epo2}, nil) {
+					return
+				}
+				epo1, err1, ok1 = nextObs(next1)
+				epo2, err2, ok2 = nextObs(next2)
+			case epo1.Time.Before(epo2.Time):
+				epo1, err1, ok1 = nextObs(next1)
+			default:
+				epo2, err2, ok2 = nextObs(next2)
+			}
+		}
+
+		// report an error of the remaining stream
+		for ok1 && err1 == nil {
+			_, err1, ok1 = next1()
+		}
+		for ok2 && err2 == nil {
+			_, err2, ok2 = next2()
+		}
+		if err := errors.Join(err1, err2); err != nil {
+			yield(SyncEpochs{}, err)
+		}
+	}
+}
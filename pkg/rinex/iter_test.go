@@ -0,0 +1,191 @@
+package rinex
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObsDecoder_Epochs(t *testing.T) {
+	assert := assert.New(t)
+	const path = "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx"
+
+	n := 0
+	for epo, err := range newObsDecoder(t, path).Epochs() {
+		assert.NoError(err)
+		assert.NotNil(epo)
+		n++
+	}
+	assert.Equal(120, n)
+
+	n = 0
+	for range newObsDecoder(t, path).Epochs() {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	assert.Equal(3, n)
+
+	// error is yielded last
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := strings.Replace(string(data), "> 2018 11 06 19 01  0.0000000  0", "> 2018 11 06 19 01  0.0000000  x", 1)
+	dec, err := NewObsDecoder(strings.NewReader(corrupt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n = 0
+	for epo, err := range dec.Epochs() {
+		if err != nil {
+			assert.Nil(epo)
+			assert.ErrorIs(err, ErrParser)
+			break
+		}
+		n++
+	}
+	assert.Equal(2, n)
+}
+
+func TestIterAdapters_obs(t *testing.T) {
+	assert := assert.New(t)
+	const path = "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx"
+	start := time.Date(2018, 11, 6, 19, 10, 0, 0, time.UTC)
+	end := time.Date(2018, 11, 6, 19, 20, 0, 0, time.UTC)
+
+	var times []time.Time
+	for epo, err := range Decimate(TimeWindow(newObsDecoder(t, path).Epochs(), start, end), 5*time.Minute) {
+		assert.NoError(err)
+		times = append(times, epo.Time)
+	}
+	assert.Equal([]time.Time{start, start.Add(5 * time.Minute), end}, times)
+
+	filt := ObsFilter{SatSystems: gnss.Systems{gnss.SysGAL}}
+	for epo, err := range FilterEpochs(newObsDecoder(t, path).Epochs(), filt) {
+		assert.NoError(err)
+		for _, satObs := range epo.ObsList {
+			assert.Equal(gnss.SysGAL, satObs.Prn.Sys)
+		}
+	}
+
+	// Zip with a decimated stream.
+	n := 0
+	for sync, err := range Zip(newObsDecoder(t, path).Epochs(), Decimate(newObsDecoder(t, path).Epochs(), time.Minute)) {
+		assert.NoError(err)
+		assert.Equal(sync.Epo1.Time, sync.Epo2.Time)
+		assert.Equal(0, sync.Epo1.Time.Second())
+		n++
+	}
+	assert.Equal(60, n)
+}
+
+func TestZip(t *testing.T) {
+	assert := assert.New(t)
+	const path1 = "testdata/white/REYK00ISL_R_20192701000_01H_30S_MO.rnx"
+	const path2 = "testdata/white/REYK00ISL_S_20192701000_01H_30S_MO.rnx"
+
+	// the same epochs as the deprecated SyncEpoch
+	var want []time.Time
+	dec := newObsDecoder(t, path1)
+	for dec.sync(newObsDecoder(t, path2)) {
+		want = append(want, dec.SyncEpoch().Epo1.Time)
+	}
+	assert.NoError(dec.Err())
+
+	var got []time.Time
+	for sync, err := range Zip(newObsDecoder(t, path1).Epochs(), newObsDecoder(t, path2).Epochs()) {
+		assert.NoError(err)
+		assert.Equal(sync.Epo1.Time, sync.Epo2.Time)
+		got = append(got, sync.Epo1.Time)
+	}
+	assert.Len(got, 115)
+	assert.Equal(want, got)
+
+	// stop early
+	n := 0
+	for range Zip(newObsDecoder(t, path1).Epochs(), newObsDecoder(t, path2).Epochs()) {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	assert.Equal(2, n)
+
+	// the error of the second stream
+	data, err := os.ReadFile(path2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := strings.Replace(string(data), "> 2019 09 27 10 30  0.0000000  0", "> 2019 09 27 10 30  0.0000000  x", 1)
+	dec2, err := NewObsDecoder(strings.NewReader(corrupt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n = 0
+	var lastErr error
+	for _, err := range Zip(newObsDecoder(t, path1).Epochs(), dec2.Epochs()) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		n++
+	}
+	assert.ErrorIs(lastErr, ErrParser)
+	assert.Less(n, 115)
+}
+
+func TestIterAdapters_nav(t *testing.T) {
+	assert := assert.New(t)
+	newDec := func() *NavDecoder { return newNavDecoder(t, "testdata/white/AREG00PER_R_20201690000_01D_MN.rnx") }
+
+	nTotal, nGPS := 0, 0
+	for eph, err := range newDec().Ephemerides() {
+		assert.NoError(err)
+		nTotal++
+		if eph.GetPRN().Sys == gnss.SysGPS {
+			nGPS++
+		}
+	}
+	assert.Greater(nGPS, 0)
+	assert.Greater(nTotal, nGPS)
+
+	n := 0
+	for eph, err := range FilterSystems(newDec().Ephemerides(), gnss.SysGPS) {
+		assert.NoError(err)
+		assert.Equal(gnss.SysGPS, eph.GetPRN().Sys)
+		n++
+	}
+	assert.Equal(nGPS, n)
+
+	start := time.Date(2020, 6, 17, 12, 0, 0, 0, time.UTC)
+	for eph, err := range TimeWindow(newDec().Ephemerides(), start, time.Time{}) {
+		assert.NoError(err)
+		assert.False(eph.GetTime().Before(start))
+	}
+}
+
+func TestMetDecoder_MeteoEpochs(t *testing.T) {
+	assert := assert.New(t)
+	r, err := os.Open("testdata/white/func3060.19m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	dec, err := NewMetDecoder(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for epo, err := range TimeWindow(dec.MeteoEpochs(), time.Time{}, time.Date(2019, 11, 2, 1, 0, 0, 0, time.UTC)) {
+		assert.NoError(err)
+		assert.Equal(0, epo.Time.Hour())
+		n++
+	}
+	assert.Equal(4, n)
+}
@@ -2,7 +2,6 @@ package rinex
 
 import (
 	"fmt"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -81,7 +80,7 @@ func NewMeteoFile(filepath string) (*MeteoFile, error) {
 
 // Parse and return the Header lines.
 func (f *MeteoFile) ReadHeader() (MeteoHeader, error) {
-	r, err := os.Open(f.Path)
+	r, err := OpenFile(f.Path)
 	if err != nil {
 		return MeteoHeader{}, err
 	}
@@ -162,7 +161,7 @@ func (f *MeteoFile) Rnx3Filename() (string, error) {
 
 // ComputeObsStats reads the file and computes some statistics on the observations.
 func (f *MeteoFile) ComputeObsStats() (stats MeteoStats, err error) {
-	r, err := os.Open(f.Path)
+	r, err := OpenFile(f.Path)
 	if err != nil {
 		return
 	}
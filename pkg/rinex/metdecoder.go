@@ -25,6 +25,11 @@ type MeteoEpoch struct {
 	Obs  []float64 // The observations in the same sequence as given in the header.
 }
 
+// GetTime returns the epoch time.
+func (epo *MeteoEpoch) GetTime() time.Time {
+	return epo.Time
+}
+
 // MetDecoder reads and decodes header and data records from a RINEX Meteo input stream.
 type MetDecoder struct {
 	// The Header is valid after NewMetDecoder or Reader.Reset. The header must exist,
@@ -34,6 +39,7 @@ type MetDecoder struct {
 	epo     *MeteoEpoch // the current epoch
 	lineNum int
 	err     error
+	decoderDiag
 }
 
 // NewMetDecoder creates a new decoder for RINEX Meteo data.
@@ -42,6 +48,7 @@ type MetDecoder struct {
 // It is the caller's responsibility to call Close on the underlying reader when done!
 func NewMetDecoder(r io.Reader) (*MetDecoder, error) {
 	dec := &MetDecoder{sc: bufio.NewScanner(r)}
+	dec.init(r)
 	dec.Header, dec.err = dec.readHeader()
 	return dec, dec.err
 }
@@ -49,7 +56,11 @@ func NewMetDecoder(r io.Reader) (*MetDecoder, error) {
 // readHeader reads a RINEX Observation header. If the Header does not exist,
 // a ErrNoHeader error will be returned. Only maxLines header lines are read if maxLines > 0 (see epoch flags).
 func (dec *MetDecoder) readHeader() (hdr MeteoHeader, err error) {
-	sensPositions := []string{}
+	type sensPosition struct {
+		val     string
+		lineNum int
+	}
+	sensPositions := []sensPosition{}
 readln:
 	for dec.readLine() {
 		line := dec.line()
@@ -67,11 +78,11 @@ readln:
 			if f64, err := strconv.ParseFloat(strings.TrimSpace(val[:20]), 32); err == nil {
 				hdr.RINEXVersion = float32(f64)
 			} else {
-				return hdr, fmt.Errorf("parse RINEX VERSION: %v", err)
+				return hdr, dec.newError(dec.lineNum, key, 1, 20, err)
 			}
 			hdr.RINEXType = val[20:21]
 			if hdr.RINEXType != "M" {
-				return hdr, fmt.Errorf("invalid RINEX TYPE: %q", hdr.RINEXType)
+				return hdr, dec.newError(dec.lineNum, key, 21, 21, fmt.Errorf("invalid RINEX TYPE: %q", hdr.RINEXType))
 			}
 		case "PGM / RUN BY / DATE":
 			// Additional lines of this type can appear together after the second line, if needed to preserve the history of previous actions on the file.
@@ -114,7 +125,7 @@ readln:
 			hdr.Sensors = append(hdr.Sensors, sens)
 		case "SENSOR POS XYZ/H":
 			// Process them at the end as they can appear before the sensor model line.
-			sensPositions = append(sensPositions, val)
+			sensPositions = append(sensPositions, sensPosition{val: val, lineNum: dec.lineNum})
 		case "END OF HEADER":
 			break readln
 		default:
@@ -123,11 +134,11 @@ readln:
 	}
 
 	// At the end store the sensor positions.
-	for _, posline := range sensPositions {
-		obstype := MeteoObsType(posline[57:59])
-		xyz, height, err := parseSensorPosition(posline)
+	for _, pos := range sensPositions {
+		obstype := MeteoObsType(pos.val[57:59])
+		xyz, height, err := parseSensorPosition(pos.val)
 		if err != nil {
-			return hdr, err
+			return hdr, dec.newError(pos.lineNum, "SENSOR POS XYZ/H", 1, 56, err)
 		}
 
 		found := false
@@ -141,7 +152,7 @@ readln:
 		}
 
 		if !found {
-			return hdr, fmt.Errorf("position, but no model defined for %q", string(obstype))
+			return hdr, dec.newError(pos.lineNum, "SENSOR POS XYZ/H", 58, 59, fmt.Errorf("position, but no model defined for %q", string(obstype)))
 		}
 	}
 
@@ -165,6 +176,29 @@ func (dec *MetDecoder) setErr(err error) {
 	dec.err = errors.Join(dec.err, err)
 }
 
+// SetLenient sets the lenient mode. In lenient mode a corrupt epoch is skipped and added to the warnings,
+// and the decoder continues with the next epoch. By default the decoder stops at the first corrupt epoch.
+func (dec *MetDecoder) SetLenient(lenient bool) {
+	dec.lenient = lenient
+}
+
+// Warnings returns the warnings that occurred while decoding the data, e.g. the epochs skipped in lenient mode.
+func (dec *MetDecoder) Warnings() []*RinexError {
+	return dec.warnings
+}
+
+// fail handles a corrupt epoch in the current line. In lenient mode the error is added to the warnings.
+// Otherwise the error is set and fail returns true, i.e. the decoder must stop.
+func (dec *MetDecoder) fail(record string, startCol, endCol int, err error) (stop bool) {
+	e := dec.newError(dec.lineNum, record, startCol, endCol, err)
+	if dec.lenient {
+		dec.warn(e)
+		return false
+	}
+	dec.setErr(e)
+	return true
+}
+
 // readLine reads the next line into buffer. It returns false if an error
 // occurs or EOF was reached.
 func (dec *MetDecoder) readLine() bool {
@@ -191,17 +225,19 @@ readln:
 			continue
 		}
 
+		pos := 20 // end of the epoch time
+		if dec.Header.RINEXVersion < 3 {
+			pos = 18
+		}
 		epoTime, err := dec.parseEpochTime(line)
 		if err != nil {
-			dec.setErr(fmt.Errorf("rinex meteo: line %d: %v", dec.lineNum, err))
-			return false
+			if dec.fail(recordEpoch, 2, pos, err) {
+				return false
+			}
+			continue
 		}
 
 		obsList := make([]float64, 0, numObs)
-		pos := 20
-		if dec.Header.RINEXVersion < 3 {
-			pos = 18
-		}
 		for iObs := 0; iObs < numObs; iObs++ {
 			if iObs > 0 && iObs%8 == 0 { // read continuation line
 				if ok := dec.readLine(); !ok {
@@ -217,8 +253,10 @@ readln:
 
 			obs, err := parseFloat(line[pos : pos+7])
 			if err != nil {
-				dec.setErr(fmt.Errorf("rinex met: line %d: %v", dec.lineNum, err))
-				return false
+				if dec.fail(recordObservation, pos+1, pos+7, fmt.Errorf("%s: %v", dec.Header.ObsTypes[iObs], err)) {
+					return false
+				}
+				continue readln
 			}
 			obsList = append(obsList, obs)
 			pos += 7
@@ -229,7 +267,7 @@ readln:
 	}
 
 	if err := dec.sc.Err(); err != nil {
-		dec.setErr(fmt.Errorf("rinex: read epoch: %v", err))
+		dec.setErr(dec.readError(dec.lineNum, err))
 	}
 
 	return false // EOF
@@ -2,6 +2,7 @@ package rinex
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -43,3 +44,45 @@ func Test_decodeMeteoLineHlp(t *testing.T) {
 	assert.Equal(t, "  993.4", line[20:27], "1st obs")
 	assert.Equal(t, "   12.1", line[27:34], "2st obs")
 }
+
+func TestMetDecoder_lenient(t *testing.T) {
+	assert := assert.New(t)
+	data, err := os.ReadFile("testdata/white/func3060.19m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt the temperature of the 2nd epoch (line 15).
+	corrupt := strings.Replace(string(data), " 19 11  2  0 15  3 1022.8   22.8", " 19 11  2  0 15  3 1022.8   2x.8", 1)
+
+	dec, err := NewMetDecoder(strings.NewReader(corrupt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nEpochs := 0
+	for dec.NextEpoch() {
+		nEpochs++
+	}
+	assert.Equal(1, nEpochs)
+	var rerr *RinexError
+	if assert.ErrorAs(dec.Err(), &rerr) {
+		assert.Equal(15, rerr.Line)
+		assert.Equal(26, rerr.StartCol)
+		assert.Equal(32, rerr.EndCol)
+		assert.Equal("OBSERVATION", rerr.Record)
+	}
+
+	dec, err = NewMetDecoder(strings.NewReader(corrupt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec.SetLenient(true)
+	nEpochs = 0
+	for dec.NextEpoch() {
+		nEpochs++
+	}
+	assert.NoError(dec.Err())
+	assert.Equal(94, nEpochs)
+	if assert.Len(dec.Warnings(), 1) {
+		assert.Equal(15, dec.Warnings()[0].Line)
+	}
+}
@@ -1,9 +1,11 @@
 package rinex
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -34,6 +36,18 @@ type Eph interface {
 	// Returns the ephemermis' time of clock (toc).
 	GetTime() time.Time
 
+	// Position returns the earth-fixed satellite position in m and the velocity in m/s at the time t.
+	// The time t is given in the time system of the ephemeris, e.g. GPS time for GPS and UTC for GLONASS.
+	Position(t time.Time) (pos, vel Coord, err error)
+
+	// Clock returns the satellite clock offset in seconds at the time t, including the relativistic correction.
+	Clock(t time.Time) float64
+
+	// GroupDelay returns the broadcast group delay in seconds of the signal, i.e. TGD or BGD, that is subtracted
+	// from the clock offset for single-frequency users. It is 0 for the signal the clock refers to and NaN if the
+	// message has no correction for the signal.
+	GroupDelay(sig gnss.Signal) float64
+
 	//unmarshal(data []byte) error
 }
 
@@ -105,50 +119,247 @@ type EphGPS struct {
 
 	Tom         float64 // transmission time of message, seconds of GPS week
 	FitInterval float64 // Fit interval in hours
+
+	// The CNAV and CNV2 messages of RINEX-4 give the orbit parameters above, except for IODE, L2Codes, L2PFlag,
+	// URA, IODC and FitInterval, and the following. Toe and ToeWeek are not given, they are those of the TOC.
+	ADOT      float64 // change rate of the semi-major axis in meters/sec
+	DeltaNDot float64 // rate of the mean motion difference in radians/sec2
+	Top       float64 // time of prediction, seconds of GPS week
+	WNop      float64 // GPS week of the time of prediction
+	URAIED    float64 // elevation dependent user range accuracy index
+	URAINED0  float64 // non-elevation dependent user range accuracy indices
+	URAINED1  float64
+	URAINED2  float64
+	ISCL1CA   float64 // inter-signal corrections of CNAV in seconds
+	ISCL2C    float64
+	ISCL5I5   float64
+	ISCL5Q5   float64
+	ISCL1CD   float64 // inter-signal corrections of CNV2 in seconds
+	ISCL1CP   float64
 }
 
 func (eph *EphGPS) GetPRN() gnss.PRN   { return eph.PRN }
 func (eph *EphGPS) GetTime() time.Time { return eph.TOC }
 func (EphGPS) Validate() error         { return nil }
 
-// EphGLO describes a GLONASS ephemeris.
+// EphGLO describes a GLONASS FDMA ephemeris. The satellite state is given in the PZ-90 frame.
 type EphGLO struct {
 	PRN         gnss.PRN
-	MessageType string // Navigation Message Type.
-	TOC         time.Time
+	MessageType string    // Navigation Message Type.
+	TOC         time.Time // Time of Clock in UTC.
+
+	ClockBias   float64 // SV clock bias in seconds (-TauN)
+	RelFreqBias float64 // SV relative frequency bias (+GammaN)
+	FrameTime   float64 // Message frame time tk in seconds of the UTC week, in RINEX-2 in seconds of the UTC day
+
+	X      float64 // satellite position X in km
+	VelX   float64 // velocity X dot in km/s
+	AccX   float64 // X acceleration in km/s2
+	Health float64 // health, 0 = OK (Bn)
+
+	Y       float64 // satellite position Y in km
+	VelY    float64 // velocity Y dot in km/s
+	AccY    float64 // Y acceleration in km/s2
+	FreqNum float64 // frequency number (-7...+13)
+
+	Z         float64 // satellite position Z in km
+	VelZ      float64 // velocity Z dot in km/s
+	AccZ      float64 // Z acceleration in km/s2
+	AgeOpInfo float64 // age of operation information in days (E)
+
+	// Since RINEX 3.05
+	StatusFlags float64 // status flags, see RINEX spec
+	DelayL1L2   float64 // L1/L2 group delay difference in seconds, .999999999999E+09 if unknown
+	URAI        float64 // GLONASS URAI, the raw accuracy index FT
+	HealthFlags float64 // health flags, see RINEX spec
 }
 
 func (eph *EphGLO) GetPRN() gnss.PRN   { return eph.PRN }
 func (eph *EphGLO) GetTime() time.Time { return eph.TOC }
 func (EphGLO) Validate() error         { return nil }
 
-// EphGAL describes a Galileo ephemeris.
+// EphGAL describes a Galileo I/NAV or F/NAV ephemeris.
 type EphGAL struct {
 	PRN         gnss.PRN
-	MessageType string // Navigation Message Type.
-	TOC         time.Time
+	MessageType string // Navigation Message Type, INAV or FNAV.
+
+	// Clock
+	TOC            time.Time // Time of Clock, clock reference epoch
+	ClockBias      float64   // SV clock bias in seconds (af0)
+	ClockDrift     float64   // sec/sec (af1)
+	ClockDriftRate float64   // sec/sec2 (af2)
+
+	IODnav float64 // Issue of Data of the nav batch
+	Crs    float64 // meters
+	DeltaN float64 // radians/sec
+	M0     float64 // radians
+
+	Cuc   float64 // radians
+	Ecc   float64 // Eccentricity
+	Cus   float64 // radians
+	SqrtA float64 // sqrt(m)
+
+	Toe    float64 // time of ephemeris (sec of GAL week)
+	Cic    float64 // radians
+	Omega0 float64 // radians
+	Cis    float64 // radians
+
+	I0       float64 // radians
+	Crc      float64 // meters
+	Omega    float64 // radians
+	OmegaDot float64 // radians/sec
+
+	IDOT        float64 // radians/sec
+	DataSources float64 // Data sources, bits 0-2 the message (I/NAV E1-B, F/NAV E5a-I, I/NAV E5b-I), bits 8-9 the clock frequencies
+	ToeWeek     float64 // GAL week (to go with TOE), aligned to GPS week
+
+	SISA     float64 // Signal in space accuracy in meters
+	Health   float64 // SV health, bits of E1-B and E5b data validity and signal health, see RINEX spec
+	BGDE5aE1 float64 // BGD E5a/E1 in seconds
+	BGDE5bE1 float64 // BGD E5b/E1 in seconds
+
+	Tom float64 // transmission time of message, seconds of GAL week
 }
 
 func (eph *EphGAL) GetPRN() gnss.PRN   { return eph.PRN }
 func (eph *EphGAL) GetTime() time.Time { return eph.TOC }
 func (EphGAL) Validate() error         { return nil }
 
-// EphQZSS describes a QZSS ephemeris.
+// IsFNAV reports whether the ephemeris is from the F/NAV message, otherwise it is from the I/NAV message.
+func (eph *EphGAL) IsFNAV() bool {
+	if eph.MessageType != "" {
+		return eph.MessageType == "FNAV"
+	}
+	return int(eph.DataSources)&(1<<1) != 0
+}
+
+// EphQZSS describes a QZSS ephemeris. The parameters are the same as of the GPS LNAV message.
 type EphQZSS struct {
 	PRN         gnss.PRN
-	MessageType string // Navigation Message Type.
-	TOC         time.Time
+	MessageType string // Navigation Message Type, LNAV etc.
+
+	// Clock
+	TOC            time.Time // Time of Clock, clock reference epoch
+	ClockBias      float64   // sc clock bias in seconds
+	ClockDrift     float64   // sec/sec
+	ClockDriftRate float64   // sec/sec2
+
+	IODE   float64 // Issue of Data, Ephemeris
+	Crs    float64 // meters
+	DeltaN float64 // radians/sec
+	M0     float64 // radians
+
+	Cuc   float64 // radians
+	Ecc   float64 // Eccentricity
+	Cus   float64 // radians
+	SqrtA float64 // sqrt(m)
+
+	Toe    float64 // time of ephemeris (sec of GPS week)
+	Cic    float64 // radians
+	Omega0 float64 // radians
+	Cis    float64 // radians
+
+	I0       float64 // radians
+	Crc      float64 // meters
+	Omega    float64 // radians
+	OmegaDot float64 // radians/sec
+
+	IDOT    float64 // radians/sec
+	L2Codes float64
+	ToeWeek float64 // GPS week (to go with TOE) Continuous
+	L2PFlag float64 // L2P data flag, set to 1 since QZSS does not track L2P
+
+	URA    float64 // SV accuracy in meters
+	Health float64 // SV health (6 bits)
+	TGD    float64 // seconds
+	IODC   float64 // Issue of Data, clock
+
+	Tom         float64 // transmission time of message, seconds of GPS week
+	FitInterval float64 // Fit interval flag, 0 for two hours, 1 for more than two hours
+
+	// The CNAV and CNV2 messages of RINEX-4 give the orbit parameters above, except for IODE, L2Codes, L2PFlag,
+	// URA, IODC and FitInterval, and the following. Toe and ToeWeek are not given, they are those of the TOC.
+	ADOT      float64 // change rate of the semi-major axis in meters/sec
+	DeltaNDot float64 // rate of the mean motion difference in radians/sec2
+	Top       float64 // time of prediction, seconds of GPS week
+	WNop      float64 // GPS week of the time of prediction
+	URAIED    float64 // elevation dependent user range accuracy index
+	URAINED0  float64 // non-elevation dependent user range accuracy indices
+	URAINED1  float64
+	URAINED2  float64
+	ISCL1CA   float64 // inter-signal corrections of CNAV in seconds
+	ISCL2C    float64
+	ISCL5I5   float64
+	ISCL5Q5   float64
+	ISCL1CD   float64 // inter-signal corrections of CNV2 in seconds
+	ISCL1CP   float64
 }
 
 func (eph *EphQZSS) GetPRN() gnss.PRN   { return eph.PRN }
 func (eph *EphQZSS) GetTime() time.Time { return eph.TOC }
 func (EphQZSS) Validate() error         { return nil }
 
-// EphBDS describes a chinese BDS ephemeris.
+// EphBDS describes a chinese BDS ephemeris of the D1 or D2 message.
 type EphBDS struct {
 	PRN         gnss.PRN
-	MessageType string // Navigation Message Type.
-	TOC         time.Time
+	MessageType string // Navigation Message Type, D1 or D2 etc.
+
+	// Clock
+	TOC            time.Time // Time of Clock in BDT, clock reference epoch
+	ClockBias      float64   // SV clock bias in seconds (a0)
+	ClockDrift     float64   // sec/sec (a1)
+	ClockDriftRate float64   // sec/sec2 (a2)
+
+	AODE   float64 // Age of Data, Ephemeris
+	Crs    float64 // meters
+	DeltaN float64 // radians/sec
+	M0     float64 // radians
+
+	Cuc   float64 // radians
+	Ecc   float64 // Eccentricity
+	Cus   float64 // radians
+	SqrtA float64 // sqrt(m)
+
+	Toe    float64 // time of ephemeris (sec of BDT week)
+	Cic    float64 // radians
+	Omega0 float64 // radians
+	Cis    float64 // radians
+
+	I0       float64 // radians
+	Crc      float64 // meters
+	Omega    float64 // radians
+	OmegaDot float64 // radians/sec
+
+	IDOT    float64 // radians/sec
+	ToeWeek float64 // BDT week (to go with TOE)
+
+	URA    float64 // SV accuracy in meters
+	Health float64 // SV health (SatH1), 0 = OK
+	TGD1   float64 // TGD1 B1/B3 in seconds
+	TGD2   float64 // TGD2 B2/B3 in seconds
+
+	Tom  float64 // transmission time of message, seconds of BDT week
+	AODC float64 // Age of Data, Clock
+
+	// The CNV1, CNV2 and CNV3 messages of RINEX-4 give the orbit parameters above, except for AODE, URA, TGD1,
+	// TGD2 and AODC, and the following. ToeWeek is not given, it is the week of the TOC.
+	ADOT           float64 // change rate of the semi-major axis in meters/sec
+	DeltaNDot      float64 // rate of the mean motion difference in radians/sec2
+	SatType        float64 // orbit type, 1 = GEO, 2 = IGSO, 3 = MEO
+	Top            float64 // time of prediction, seconds of BDT week
+	SISAIOE        float64 // signal in space accuracy index of the orbit along-track and cross-track
+	SISAIOCB       float64 // signal in space accuracy indices of the orbit radial and the clock
+	SISAIOC1       float64
+	SISAIOC2       float64
+	SISMAI         float64 // signal in space monitoring accuracy index
+	IntegrityFlags float64 // integrity flags of B1C, B2a or B2b
+	ISCB1CD        float64 // inter-signal correction of B1C data in seconds, CNV1
+	ISCB2AD        float64 // inter-signal correction of B2a data in seconds, CNV2
+	TGDB1CP        float64 // group delay of the B1C pilot in seconds, CNV1 and CNV2
+	TGDB2AP        float64 // group delay of the B2a pilot in seconds, CNV1 and CNV2
+	TGDB2BI        float64 // group delay of B2b I in seconds, CNV3
+	IODC           float64 // Issue of Data, Clock
+	IODE           float64 // Issue of Data, Ephemeris
 }
 
 func (eph *EphBDS) GetPRN() gnss.PRN   { return eph.PRN }
@@ -159,18 +370,78 @@ func (EphBDS) Validate() error         { return nil }
 type EphNavIC struct {
 	PRN         gnss.PRN
 	MessageType string // EPH Navigation Message Type.
-	TOC         time.Time
+
+	// Clock
+	TOC            time.Time // Time of Clock, clock reference epoch
+	ClockBias      float64   // SV clock bias in seconds (af0)
+	ClockDrift     float64   // sec/sec (af1)
+	ClockDriftRate float64   // sec/sec2 (af2)
+
+	IODEC  float64 // Issue of Data, Ephemeris and Clock
+	Crs    float64 // meters
+	DeltaN float64 // radians/sec
+	M0     float64 // radians
+
+	Cuc   float64 // radians
+	Ecc   float64 // Eccentricity
+	Cus   float64 // radians
+	SqrtA float64 // sqrt(m)
+
+	Toe    float64 // time of ephemeris (sec of IRNSS week)
+	Cic    float64 // radians
+	Omega0 float64 // radians
+	Cis    float64 // radians
+
+	I0       float64 // radians
+	Crc      float64 // meters
+	Omega    float64 // radians
+	OmegaDot float64 // radians/sec
+
+	IDOT    float64 // radians/sec
+	ToeWeek float64 // IRNSS week (to go with TOE), aligned to GPS week
+
+	URA    float64 // User range accuracy in meters
+	Health float64 // SV health, 0 = OK
+	TGD    float64 // seconds
+
+	Tom float64 // transmission time of message, seconds of IRNSS week
+
+	// The L1NV message of RINEX-4 gives the orbit parameters above, except for IODEC, and the following.
+	ADOT      float64 // change rate of the semi-major axis in meters/sec
+	DeltaNDot float64 // rate of the mean motion difference in radians/sec2
+	ISCS      float64 // inter-signal corrections of S, L1 pilot and L1 data in seconds
+	ISCL1P    float64
+	ISCL1D    float64
 }
 
 func (eph *EphNavIC) GetPRN() gnss.PRN   { return eph.PRN }
 func (eph *EphNavIC) GetTime() time.Time { return eph.TOC }
 func (EphNavIC) Validate() error         { return nil }
 
-// EphSBAS describes a SBAS payload.
+// EphSBAS describes a SBAS payload with the state of the geostationary satellite in the WGS84 frame.
 type EphSBAS struct {
 	PRN         gnss.PRN
-	MessageType string // EPH Navigation Message Type.
-	TOC         time.Time
+	MessageType string    // EPH Navigation Message Type.
+	TOC         time.Time // Time of Clock in GPS time.
+
+	ClockBias   float64 // SV clock bias in seconds (aGf0)
+	RelFreqBias float64 // SV relative frequency bias (aGf1)
+	FrameTime   float64 // Transmission time of message in seconds of the GPS week
+
+	X      float64 // satellite position X in km
+	VelX   float64 // velocity X dot in km/s
+	AccX   float64 // X acceleration in km/s2
+	Health float64 // health, see RINEX spec
+
+	Y    float64 // satellite position Y in km
+	VelY float64 // velocity Y dot in km/s
+	AccY float64 // Y acceleration in km/s2
+	URA  float64 // accuracy code (URA, meters)
+
+	Z    float64 // satellite position Z in km
+	VelZ float64 // velocity Z dot in km/s
+	AccZ float64 // Z acceleration in km/s2
+	IODN float64 // Issue of Data Navigation, the 8 first bits after the message type of MT9
 }
 
 func (eph *EphSBAS) GetPRN() gnss.PRN   { return eph.PRN }
@@ -196,9 +467,398 @@ type NavHeader struct {
 	Comments    []string // Comment lines
 	MergedFiles int      // The number of files merged, if any.
 
+	IonoCorrs   []IonoCorr       // Ionospheric correction parameters, in RINEX-4 given by ION records.
+	TimeCorrs   []TimeSystemCorr // Corrections to transform the system time to UTC or other time systems, in RINEX-4 given by STO records.
+	LeapSeconds LeapSeconds      // The leap seconds.
+
 	Labels []string // all Header Labels found
 }
 
+// nav2Types are the file types of RINEX-2 navigation files, see NavDecoder.
+var nav2Types = map[gnss.System]string{
+	gnss.SysGPS:  "N: GPS NAV DATA",
+	gnss.SysGLO:  "G: GLONASS NAV DATA",
+	gnss.SysGAL:  "E: GALILEO NAV DATA",
+	gnss.SysQZSS: "J: QZSS NAV DATA",
+	gnss.SysBDS:  "C: BDS NAV DATA",
+	gnss.SysSBAS: "S: SBAS NAV DATA",
+}
+
+// Write writes the RINEX Navigation header. The format is determined by the RINEX version.
+// The IonoCorrs and TimeCorrs are written up to version 3. An error is returned for those the version can not
+// hold: in RINEX-2 all but those of GPS, and in RINEX-4, where they are ION and STO records written by NavEncoder.
+func (hdr *NavHeader) Write(w io.Writer) error {
+	if err := hdr.checkCorrections(); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+
+	switch {
+	case hdr.RINEXVersion < 3:
+		typ, ok := nav2Types[hdr.SatSystem]
+		if !ok {
+			return fmt.Errorf("rinex: invalid satellite system for RINEX-2 navigation data: %s", hdr.SatSystem)
+		}
+		fmt.Fprintf(bw, "%9.2f%-11s%-40s%-s\n", hdr.RINEXVersion, " ", typ, "RINEX VERSION / TYPE")
+	case hdr.RINEXVersion < 4:
+		fmt.Fprintf(bw, "%9.2f%-11s%-20s%-20s%-s\n", hdr.RINEXVersion, " ", "N: GNSS NAV DATA", hdr.SatSystem.Abbr()+": "+hdr.SatSystem.String(), "RINEX VERSION / TYPE")
+	default:
+		fmt.Fprintf(bw, "%9.2f%-11s%-20s%-20s%-s\n", hdr.RINEXVersion, " ", "NAVIGATION DATA", hdr.SatSystem.Abbr(), "RINEX VERSION / TYPE")
+	}
+	if hdr.Pgm != "" {
+		fmt.Fprintln(bw, pgmRecord(hdr.Pgm, hdr.RunBy, hdr.Date)[0])
+	}
+	for _, c := range hdr.Comments {
+		fmt.Fprintln(bw, headerLine(c, "COMMENT"))
+	}
+	if hdr.DOI != "" {
+		fmt.Fprintln(bw, headerLine(hdr.DOI, "DOI"))
+	}
+	for _, l := range hdr.Licenses {
+		fmt.Fprintln(bw, headerLine(l, "LICENSE OF USE"))
+	}
+	for _, l := range hdr.StationInfos {
+		fmt.Fprintln(bw, headerLine(l, "STATION INFORMATION"))
+	}
+	for _, line := range slices.Concat(hdr.mergedFileRecord(), hdr.ionoCorrRecords(), hdr.timeCorrRecords(), hdr.leapSecondsRecord()) {
+		fmt.Fprintln(bw, line)
+	}
+
+	fmt.Fprintf(bw, "%-60s%-s\n", " ", "END OF HEADER")
+
+	return bw.Flush()
+}
+
+// mergedFileRecord returns the header record MERGED FILE of RINEX-4, or nil if not set.
+func (hdr *NavHeader) mergedFileRecord() []string {
+	if hdr.MergedFiles == 0 || hdr.RINEXVersion < 4 {
+		return nil
+	}
+	return []string{headerLine(fmt.Sprintf("%9d", hdr.MergedFiles), "MERGED FILE")}
+}
+
+// ionoCorrRecords returns the header records IONOSPHERIC CORR, or ION ALPHA and ION BETA of GPS in RINEX-2.
+func (hdr *NavHeader) ionoCorrRecords() []string {
+	lines := make([]string, 0, len(hdr.IonoCorrs))
+	for _, corr := range hdr.IonoCorrs {
+		switch {
+		case hdr.RINEXVersion < 3:
+			label := ""
+			switch corr.Type {
+			case "GPSA":
+				label = "ION ALPHA"
+			case "GPSB":
+				label = "ION BETA"
+			default:
+				continue
+			}
+			val := "  "
+			for _, p := range corr.Params {
+				val += formatFloatv2(p, 12, 4)
+			}
+			lines = append(lines, headerLine(val, label))
+		case hdr.RINEXVersion < 4:
+			val := fmt.Sprintf("%-4s %12.4E%12.4E%12.4E%12.4E", corr.Type, corr.Params[0], corr.Params[1], corr.Params[2], corr.Params[3])
+			if corr.TimeMark != "" || corr.SVID != 0 {
+				val += fmt.Sprintf(" %1s %2d", corr.TimeMark, corr.SVID)
+			}
+			lines = append(lines, headerLine(val, "IONOSPHERIC CORR"))
+		}
+	}
+	return lines
+}
+
+// timeCorrRecords returns the header records TIME SYSTEM CORR, or DELTA-UTC: A0,A1,T,W of GPS in RINEX-2.
+func (hdr *NavHeader) timeCorrRecords() []string {
+	lines := make([]string, 0, len(hdr.TimeCorrs))
+	for _, corr := range hdr.TimeCorrs {
+		switch {
+		case hdr.RINEXVersion < 3:
+			if corr.Type != "GPUT" {
+				continue
+			}
+			val := fmt.Sprintf("   %s%s%9d%9d", formatFloatv2(corr.A0, 19, 12), formatFloatv2(corr.A1, 19, 12), corr.T, corr.W)
+			lines = append(lines, headerLine(val, "DELTA-UTC: A0,A1,T,W"))
+		case hdr.RINEXVersion < 4:
+			val := fmt.Sprintf("%-4s %17.10E%16.9E %6d %4d", corr.Type, corr.A0, corr.A1, corr.T, corr.W)
+			if corr.Source != "" || corr.UTCID != 0 {
+				val += fmt.Sprintf(" %-5s %2d", corr.Source, corr.UTCID)
+			}
+			lines = append(lines, headerLine(val, "TIME SYSTEM CORR"))
+		}
+	}
+	return lines
+}
+
+// checkCorrections returns an error if the header records of the RINEX version can not hold the IonoCorrs
+// and TimeCorrs.
+func (hdr *NavHeader) checkCorrections() error {
+	switch {
+	case hdr.RINEXVersion >= 4 && (len(hdr.IonoCorrs) > 0 || len(hdr.TimeCorrs) > 0):
+		return fmt.Errorf("rinex: the corrections are no RINEX-4 header records, but ION and STO records written by the NavEncoder")
+	case hdr.RINEXVersion < 3:
+		for _, corr := range hdr.IonoCorrs {
+			if corr.Type != "GPSA" && corr.Type != "GPSB" {
+				return fmt.Errorf("rinex: the ionospheric correction %s can not be written in RINEX-2", corr.Type)
+			}
+		}
+		for _, corr := range hdr.TimeCorrs {
+			if corr.Type != "GPUT" {
+				return fmt.Errorf("rinex: the time system correction %s can not be written in RINEX-2", corr.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// addIonoCorr adds the ionospheric correction, unless its type is already given.
+func (hdr *NavHeader) addIonoCorr(corr IonoCorr) {
+	if !slices.ContainsFunc(hdr.IonoCorrs, func(c IonoCorr) bool { return c.Type == corr.Type }) {
+		hdr.IonoCorrs = append(hdr.IonoCorrs, corr)
+	}
+}
+
+// addTimeCorr adds the time system correction, replacing an older one of the same type.
+func (hdr *NavHeader) addTimeCorr(corr TimeSystemCorr) {
+	i := slices.IndexFunc(hdr.TimeCorrs, func(c TimeSystemCorr) bool { return c.Type == corr.Type })
+	if i < 0 {
+		hdr.TimeCorrs = append(hdr.TimeCorrs, corr)
+	} else if c := hdr.TimeCorrs[i]; corr.W > c.W || corr.W == c.W && corr.T > c.T {
+		hdr.TimeCorrs[i] = corr
+	}
+}
+
+// corrSystems are the satellite systems of the correction types by their prefix, e.g. GP of GPSA or GPUT.
+var corrSystems = map[string]gnss.System{
+	"GP": gnss.SysGPS, "GL": gnss.SysGLO, "GA": gnss.SysGAL, "QZ": gnss.SysQZSS, "BD": gnss.SysBDS, "IR": gnss.SysNavIC, "SB": gnss.SysSBAS,
+}
+
+// klobucharTypes are the prefixes of the Klobuchar correction types A and B per system, e.g. GPS of GPSA.
+var klobucharTypes = map[gnss.System]string{gnss.SysGPS: "GPS", gnss.SysQZSS: "QZS", gnss.SysBDS: "BDS", gnss.SysNavIC: "IRN"}
+
+// corrMessageTypes are the RINEX-4 message types of the records of the header corrections.
+var corrMessageTypes = map[gnss.System]string{
+	gnss.SysGPS: "LNAV", gnss.SysGLO: "FDMA", gnss.SysGAL: "IFNV", gnss.SysQZSS: "LNAV", gnss.SysBDS: "D1", gnss.SysNavIC: "LNAV", gnss.SysSBAS: "SBAS",
+}
+
+// utcIDs are the UTC identifiers of the TIME SYSTEM CORR record by their number, the first one is unknown.
+var utcIDs = []string{"", "UTC(NIST)", "UTC(USNO)", "UTC(SU)", "UTC(BIPM)", "UTC(Europe Lab)", "UTC(CRL)", "UTC(NTSC)"}
+
+// corrSystem returns the satellite system of the correction type.
+func corrSystem(typ string) (gnss.System, error) {
+	if len(typ) >= 2 {
+		if sys, ok := corrSystems[typ[:2]]; ok {
+			return sys, nil
+		}
+	}
+	return 0, fmt.Errorf("rinex: unknown correction type: %q", typ)
+}
+
+// weekEpoch returns the epoch of the week numbers of the system.
+func weekEpoch(sys gnss.System) time.Time {
+	if sys == gnss.SysBDS {
+		return bdsEpoch
+	}
+	return gpsEpoch
+}
+
+// correctionRecords returns the IonoCorrs and TimeCorrs as RINEX-4 ION and STO records, the Klobuchar types
+// A and B joined into one record. The header does not give the date of the ION records, their time is the hour
+// of the time mark only, and the transmitting satellite of the STO records, their satellite number is 0.
+func (hdr *NavHeader) correctionRecords() ([]NavRecord, error) {
+	recs := make([]NavRecord, 0, len(hdr.IonoCorrs)+len(hdr.TimeCorrs))
+	klobuchar := map[gnss.System]*IonoModel{}
+	for _, corr := range hdr.IonoCorrs {
+		sys, err := corrSystem(corr.Type)
+		if err != nil {
+			return nil, err
+		}
+		var hour time.Duration
+		if mark := corr.TimeMark; mark >= "A" && mark <= "X" {
+			hour = time.Duration(mark[0]-'A') * time.Hour
+		}
+		rec := &IonoModel{PRN: gnss.PRN{Sys: sys, Num: int8(corr.SVID)}, MessageType: corrMessageTypes[sys], Time: time.Time{}.Add(hour)}
+		switch {
+		case corr.Type == "GAL":
+			rec.Params = slices.Clone(corr.Params[:])
+			recs = append(recs, rec)
+		case corr.Type == klobucharTypes[sys]+"A" || corr.Type == klobucharTypes[sys]+"B":
+			model, ok := klobuchar[sys]
+			if !ok {
+				model, rec.Params = rec, make([]float64, 9)
+				klobuchar[sys] = model
+				recs = append(recs, model)
+			}
+			if corr.Type[3] == 'A' {
+				copy(model.Params[:4], corr.Params[:])
+			} else {
+				copy(model.Params[4:8], corr.Params[:])
+			}
+		default:
+			return nil, fmt.Errorf("rinex: unknown ionospheric correction: %q", corr.Type)
+		}
+	}
+	for _, corr := range hdr.TimeCorrs {
+		sys, err := corrSystem(corr.Type)
+		if err != nil {
+			return nil, err
+		}
+		rec := &TimeOffset{PRN: gnss.PRN{Sys: sys}, MessageType: corrMessageTypes[sys], Type: corr.Type, SBASID: corr.Source,
+			Time: weekTime(weekEpoch(sys), float64(corr.W), float64(corr.T)), TransTime: unknownTom, A0: corr.A0, A1: corr.A1}
+		if corr.UTCID > 0 && corr.UTCID < len(utcIDs) {
+			rec.UTCID = utcIDs[corr.UTCID]
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// AddCorrections adds the RINEX-4 STO and ION records to the TimeCorrs and IonoCorrs, e.g. to write RINEX-4
+// navigation data in an older version. An ionospheric correction of a type already given is kept, a time
+// system correction replaced by a newer one. An error is returned for the records without header record,
+// i.e. EOP records, the BDGIM parameters and STO records with a quadratic term.
+func (hdr *NavHeader) AddCorrections(recs []NavRecord) error {
+	for _, rec := range recs {
+		switch rec := rec.(type) {
+		case *TimeOffset:
+			sys, err := corrSystem(rec.Type)
+			if err != nil {
+				return err
+			}
+			if rec.A2 != 0 {
+				return fmt.Errorf("rinex: %s %s %s: the time system correction has a quadratic term", rec.RecordType(), rec.PRN, rec.Type)
+			}
+			week, sow := weekSeconds(weekEpoch(sys), rec.Time)
+			hdr.addTimeCorr(TimeSystemCorr{Type: rec.Type, A0: rec.A0, A1: rec.A1, T: int(sow), W: week, Source: rec.SBASID,
+				UTCID: max(0, slices.Index(utcIDs, rec.UTCID))})
+		case *IonoModel:
+			var mark string
+			var svid int
+			if sys := rec.PRN.Sys; sys == gnss.SysQZSS || sys == gnss.SysBDS || sys == gnss.SysNavIC {
+				mark, svid = string(rune('A'+rec.Time.Hour())), int(rec.PRN.Num)
+			}
+			typ, ok := klobucharTypes[rec.PRN.Sys]
+			switch {
+			case rec.PRN.Sys == gnss.SysGAL && len(rec.Params) == 4:
+				hdr.addIonoCorr(IonoCorr{Type: "GAL", Params: [4]float64(rec.Params)})
+			case ok && len(rec.Params) >= 8:
+				hdr.addIonoCorr(IonoCorr{Type: typ + "A", Params: [4]float64(rec.Params[:4]), TimeMark: mark, SVID: svid})
+				hdr.addIonoCorr(IonoCorr{Type: typ + "B", Params: [4]float64(rec.Params[4:8]), TimeMark: mark, SVID: svid})
+			default:
+				return fmt.Errorf("rinex: %s %s %s: the ionospheric model has no header record", rec.RecordType(), rec.PRN, rec.MessageType)
+			}
+		default:
+			return fmt.Errorf("rinex: %s %s: the record has no header record", rec.RecordType(), rec.GetPRN())
+		}
+	}
+	return nil
+}
+
+// leapSecondsRecord returns the header record LEAP SECONDS, or nil if not set.
+func (hdr *NavHeader) leapSecondsRecord() []string {
+	leap := hdr.LeapSeconds
+	if leap.Current == 0 {
+		return nil
+	}
+	val := fmt.Sprintf("%6d", leap.Current)
+	if hdr.RINEXVersion >= 3 && (leap.Future != 0 || leap.Week != 0 || leap.Day != 0 || leap.TimeSys != "") {
+		val += fmt.Sprintf("%6d%6d%6d%-3s", leap.Future, leap.Week, leap.Day, leap.TimeSys)
+	}
+	return []string{headerLine(val, "LEAP SECONDS")}
+}
+
+// IonoCorr are the ionospheric correction parameters of the header record IONOSPHERIC CORR.
+// In RINEX-2 these are the records ION ALPHA and ION BETA for GPS.
+type IonoCorr struct {
+	Type     string     // Correction type, e.g. GAL, GPSA, GPSB, QZSA, BDSA or IRNA.
+	Params   [4]float64 // The parameters, e.g. alpha0-alpha3 of GPSA or ai0-ai2 of GAL.
+	TimeMark string     // Time mark, the transmission time of BDS, QZSS and NavIC parameters.
+	SVID     int        // Satellite number of the transmitting satellite.
+}
+
+// TimeSystemCorr is the correction of the header record TIME SYSTEM CORR, e.g. to transform the GPS time to UTC.
+// In RINEX-2 this is the record DELTA-UTC: A0,A1,T,W for GPS.
+type TimeSystemCorr struct {
+	Type   string  // Correction type, e.g. GPUT, GAUT or GAGP.
+	A0, A1 float64 // The coefficients of the linear polynomial in s and s/s.
+	T      int     // Reference time for the polynomial in seconds into the week.
+	W      int     // Reference week number.
+	Source string  // The source of the SBAS parameters, e.g. EGNOS or WAAS, or the SBAS satellite.
+	UTCID  int     // UTC identifier, see RINEX spec.
+}
+
+// LeapSeconds is the header record LEAP SECONDS.
+type LeapSeconds struct {
+	Current int    // The current number of leap seconds.
+	Future  int    // The future or past number of leap seconds, at the week and day.
+	Week    int    // The week number of the future or past leap seconds.
+	Day     int    // The day number of the future or past leap seconds.
+	TimeSys string // The time system of the week and day, empty for GPS and BDS for BDS.
+}
+
+// NavRecord is a RINEX-4 navigation data record that is not an ephemeris, i.e. a STO, EOP or ION record.
+type NavRecord interface {
+	// RecordType returns the record type STO, EOP or ION.
+	RecordType() NavRecordType
+
+	// Returns the PRN of the transmitting satellite.
+	GetPRN() gnss.PRN
+
+	// Returns the epoch of the record.
+	GetTime() time.Time
+}
+
+// TimeOffset is a system time and UTC offset record (STO).
+type TimeOffset struct {
+	PRN         gnss.PRN
+	MessageType string    // Navigation Message Type, LNAV etc.
+	Time        time.Time // Reference epoch of the parameters (tot).
+	Type        string    // Time offset type, e.g. GPUT or GAGP.
+	SBASID      string    // SBAS ID, e.g. EGNOS.
+	UTCID       string    // UTC ID, e.g. UTC(USNO).
+
+	TransTime  float64 // transmission time of message, seconds of the week
+	A0, A1, A2 float64 // the polynomial coefficients in s, s/s and s/s2
+}
+
+func (rec *TimeOffset) RecordType() NavRecordType { return NavRecordTypeSTO }
+func (rec *TimeOffset) GetPRN() gnss.PRN          { return rec.PRN }
+func (rec *TimeOffset) GetTime() time.Time        { return rec.Time }
+
+// EarthOrientation is an earth orientation parameters record (EOP).
+type EarthOrientation struct {
+	PRN         gnss.PRN
+	MessageType string    // Navigation Message Type, CNAV etc.
+	Time        time.Time // Reference epoch of the parameters.
+
+	XP, DXP, DDXP float64 // pole x in arc-seconds, its rate in arc-sec/day and arc-sec/day2
+	YP, DYP, DDYP float64 // pole y in arc-seconds, its rate in arc-sec/day and arc-sec/day2
+
+	TransTime           float64 // transmission time of message, seconds of the week
+	DUT1, DDUT1, DDDUT1 float64 // UT1-UTC in s, its rate in s/day and s/day2
+}
+
+func (rec *EarthOrientation) RecordType() NavRecordType { return NavRecordTypeEOP }
+func (rec *EarthOrientation) GetPRN() gnss.PRN          { return rec.PRN }
+func (rec *EarthOrientation) GetTime() time.Time        { return rec.Time }
+
+// IonoModel is an ionospheric model parameters record (ION).
+type IonoModel struct {
+	PRN         gnss.PRN
+	MessageType string    // Navigation Message Type, LNAV, IFNV etc.
+	Time        time.Time // Transmission time of the message.
+
+	// The parameters of the model in the order of the record:
+	// Klobuchar: alpha0-alpha3, beta0-beta3 and the region code,
+	// NeQuick-G of Galileo: ai0-ai2 and the disturbance flags,
+	// BDGIM of BDS CNVX: alpha1-alpha9.
+	Params []float64
+}
+
+func (rec *IonoModel) RecordType() NavRecordType { return NavRecordTypeION }
+func (rec *IonoModel) GetPRN() gnss.PRN          { return rec.PRN }
+func (rec *IonoModel) GetTime() time.Time        { return rec.Time }
+
 // NavStats holds some statistics about a RINEX nav file, derived from the data.
 type NavStats struct {
 	NumEphemeris    int          `json:"numEphemeris"`    // The number of epochs in the file.
@@ -228,7 +888,7 @@ func NewNavFile(filepath string) (*NavFile, error) {
 
 // Parse and return the Header lines.
 func (f *NavFile) ReadHeader() (NavHeader, error) {
-	r, err := os.Open(f.Path)
+	r, err := OpenFile(f.Path)
 	if err != nil {
 		return NavHeader{}, err
 	}
@@ -246,7 +906,7 @@ func (f *NavFile) ReadHeader() (NavHeader, error) {
 
 // GetStats reads the file and retuns some statistics.
 func (f *NavFile) GetStats() (stats NavStats, err error) {
-	r, err := os.Open(f.Path)
+	r, err := OpenFile(f.Path)
 	if err != nil {
 		return
 	}
@@ -31,6 +31,9 @@ type NavDecoder struct {
 	lineNum  int
 	fastMode bool // In fast mode, only the eph type and TOC are read.
 	err      error
+	resync   bool // skip lines until the next record, after a corrupt ephemeris in lenient mode
+	records  []NavRecord
+	decoderDiag
 }
 
 // NewNavDecoder creates a new decoder for RINEX Navigation data.
@@ -48,6 +51,7 @@ func NewNavDecoder(r io.Reader) (*NavDecoder, error) {
 		fastMode: false,
 		err:      err,
 	}
+	dec.init(r)
 	dec.Header, err = dec.readHeader()
 	return dec, err
 }
@@ -75,7 +79,7 @@ readln:
 			}
 		}
 		if dec.lineNum > maxLines {
-			return hdr, fmt.Errorf("reading header failed: line %d reached without finding end of header", maxLines)
+			return hdr, dec.newError(dec.lineNum, "", 0, 0, fmt.Errorf("line %d reached without finding end of header", maxLines))
 		}
 		if len(line) < 60 {
 			continue
@@ -92,7 +96,7 @@ readln:
 			if f64, err := strconv.ParseFloat(strings.TrimSpace(val[:20]), 32); err == nil {
 				hdr.RINEXVersion = float32(f64)
 			} else {
-				return hdr, fmt.Errorf("could not parse RINEX VERSION: %v", err)
+				return hdr, dec.newError(dec.lineNum, key, 1, 20, err)
 			}
 			hdr.RINEXType = strings.TrimSpace(val[20:21])
 
@@ -112,7 +116,7 @@ readln:
 				case "S":
 					hdr.SatSystem = gnss.SysSBAS
 				default:
-					return hdr, fmt.Errorf("read RINEX-2 header: invalid satellite system: %s", hdr.RINEXType)
+					return hdr, dec.newError(dec.lineNum, key, 21, 21, fmt.Errorf("invalid satellite system: %q", hdr.RINEXType))
 				}
 				continue
 			}
@@ -122,7 +126,7 @@ readln:
 			if sys, ok := gnss.ByAbbr[s]; ok {
 				hdr.SatSystem = sys
 			} else {
-				return hdr, fmt.Errorf("read RINEX-3 header: invalid satellite system: %s", s)
+				return hdr, dec.newError(dec.lineNum, key, 41, 41, fmt.Errorf("invalid satellite system: %q", s))
 			}
 		case "PGM / RUN BY / DATE":
 			// Additional lines of this type can appear together after the second line, if needed to preserve the history of previous actions on the file.
@@ -144,7 +148,7 @@ readln:
 			if nStr != "" {
 				n, err := strconv.Atoi(nStr)
 				if err != nil {
-					return hdr, fmt.Errorf("parse %q: %v", key, err)
+					return hdr, dec.newError(dec.lineNum, key, 1, 9, err)
 				}
 				hdr.MergedFiles = n
 			}
@@ -152,14 +156,31 @@ readln:
 			hdr.DOI = strings.TrimSpace(val)
 		case "LICENSE OF USE":
 			hdr.Licenses = append(hdr.Licenses, strings.TrimSpace(val))
-		case "IONOSPHERIC CORR":
-			// TODO
-		case "TIME SYSTEM CORR":
-			// TODO
+		case "STATION INFORMATION":
+			hdr.StationInfos = append(hdr.StationInfos, strings.TrimSpace(val))
+		case "IONOSPHERIC CORR", "ION ALPHA", "ION BETA":
+			corr, err := dec.parseIonoCorr(key, val)
+			if err != nil {
+				return hdr, err
+			}
+			hdr.IonoCorrs = append(hdr.IonoCorrs, corr)
+		case "TIME SYSTEM CORR", "DELTA-UTC: A0,A1,T,W":
+			corr, err := dec.parseTimeSystemCorr(key, val)
+			if err != nil {
+				return hdr, err
+			}
+			hdr.TimeCorrs = append(hdr.TimeCorrs, corr)
 		case "LEAP SECONDS":
-			// TODO
-			// my @lsecs = split ( " ", trim($val) );
-			// $self->leapSecs( $lsecs[0] );    # ab Vers. 3 hier mehrere Werte moeglich!
+			// Since RINEX 3 the future or past leap seconds may follow.
+			var nums [4]int
+			for i := range nums {
+				n, err := parseInt(val[6*i : 6*i+6])
+				if err != nil {
+					return hdr, dec.newError(dec.lineNum, key, 6*i+1, 6*i+6, err)
+				}
+				nums[i] = n
+			}
+			hdr.LeapSeconds = LeapSeconds{Current: nums[0], Future: nums[1], Week: nums[2], Day: nums[3], TimeSys: strings.TrimSpace(val[24:27])}
 		case "END OF HEADER":
 			break readln
 		default:
@@ -174,10 +195,58 @@ readln:
 	return hdr, err
 }
 
+// parseIonoCorr parses the header record IONOSPHERIC CORR, or ION ALPHA and ION BETA of RINEX-2.
+func (dec *NavDecoder) parseIonoCorr(key, val string) (corr IonoCorr, err error) {
+	start := 5
+	switch key {
+	case "ION ALPHA":
+		corr.Type, start = "GPSA", 2
+	case "ION BETA":
+		corr.Type, start = "GPSB", 2
+	default:
+		corr.Type, corr.TimeMark = strings.TrimSpace(val[:4]), strings.TrimSpace(val[53:55])
+		if corr.SVID, err = parseInt(val[55:58]); err != nil {
+			return corr, dec.newError(dec.lineNum, key, 56, 58, err)
+		}
+	}
+	for i := range corr.Params {
+		col := start + 12*i
+		if corr.Params[i], err = parseFloat(val[col : col+12]); err != nil {
+			return corr, dec.newError(dec.lineNum, key, col+1, col+12, err)
+		}
+	}
+	return corr, nil
+}
+
+// parseTimeSystemCorr parses the header record TIME SYSTEM CORR, or DELTA-UTC: A0,A1,T,W of RINEX-2.
+func (dec *NavDecoder) parseTimeSystemCorr(key, val string) (corr TimeSystemCorr, err error) {
+	// the columns of A0, A1, T and W
+	cols := [5]int{5, 22, 38, 45, 50}
+	if key == "DELTA-UTC: A0,A1,T,W" {
+		corr.Type, cols = "GPUT", [5]int{3, 22, 41, 50, 59}
+	} else {
+		corr.Type, corr.Source = strings.TrimSpace(val[:4]), strings.TrimSpace(val[51:56])
+		if corr.UTCID, err = parseInt(val[56:59]); err != nil {
+			return corr, dec.newError(dec.lineNum, key, 57, 59, err)
+		}
+	}
+	if corr.A0, err = parseFloat(val[cols[0]:cols[1]]); err != nil {
+		return corr, dec.newError(dec.lineNum, key, cols[0]+1, cols[1], err)
+	}
+	if corr.A1, err = parseFloat(val[cols[1]:cols[2]]); err != nil {
+		return corr, dec.newError(dec.lineNum, key, cols[1]+1, cols[2], err)
+	}
+	if corr.T, err = parseInt(val[cols[2]:cols[3]]); err != nil {
+		return corr, dec.newError(dec.lineNum, key, cols[2]+1, cols[3], err)
+	}
+	if corr.W, err = parseInt(val[cols[3]:cols[4]]); err != nil {
+		return corr, dec.newError(dec.lineNum, key, cols[3]+1, cols[4], err)
+	}
+	return corr, nil
+}
+
 // NextEphemeris reads the next Ephemeris into the buffer.
 // It returns false when the scan stops, either by reaching the end of the input or an error.
-//
-// TODO: read all values
 func (dec *NavDecoder) NextEphemeris() bool {
 	if dec.Header.RINEXVersion < 3 {
 		return dec.nextEphemerisv2()
@@ -202,14 +271,17 @@ func (dec *NavDecoder) nextEphemerisv2() bool {
 
 		err := dec.decodeEPH(dec.Header.SatSystem)
 		if err != nil {
-			dec.setErr(err)
-			return false
+			if dec.fail(err) {
+				return false
+			}
+			continue
 		}
+		dec.resync = false
 		return true
 	}
 
 	if err := dec.sc.Err(); err != nil {
-		dec.setErr(fmt.Errorf("rinex: read epochs: %v", err))
+		dec.setErr(dec.readError(dec.lineNum, err))
 	}
 
 	return false // EOF
@@ -224,31 +296,42 @@ func (dec *NavDecoder) nextEphemerisv3() bool {
 		}
 
 		if !strings.ContainsAny(line[:1], "GREJCIS") {
-			log.Printf("rinex: line %d: stream does not start with epoch line: %q", dec.lineNum, line) // must not be an error
+			// must not be an error
+			if !dec.resync {
+				dec.warn(dec.newError(dec.lineNum, recordEphemeris, 1, 1, fmt.Errorf("stream does not start with epoch line: %q", line)))
+				dec.resync = true
+			}
 			continue
 		}
 
 		sys, ok := gnss.ByAbbr[line[:1]]
 		if !ok {
-			dec.setErr(fmt.Errorf("rinex: line %d: invalid satellite system: %q", dec.lineNum, line[:1]))
-			return false
+			if dec.fail(dec.newError(dec.lineNum, recordEphemeris, 1, 1, fmt.Errorf("invalid satellite system: %q", line[:1]))) {
+				return false
+			}
+			continue
 		}
 
 		if len(line) < 23 { // ToC. simple test to prevent panicing.
-			dec.setErr(fmt.Errorf("rinex: invalid line %d: %q", dec.lineNum, line))
+			if dec.fail(dec.newError(dec.lineNum, recordEphemeris, 1, len(line), fmt.Errorf("invalid line: %q", line))) {
+				return false
+			}
 			continue
 		}
 
 		err := dec.decodeEPH(sys)
 		if err != nil {
-			dec.setErr(err)
-			return false
+			if dec.fail(err) {
+				return false
+			}
+			continue
 		}
+		dec.resync = false
 		return true
 	}
 
 	if err := dec.sc.Err(); err != nil {
-		dec.setErr(fmt.Errorf("rinex: read epochs: %v", err))
+		dec.setErr(dec.readError(dec.lineNum, err))
 	}
 
 	return false // EOF
@@ -270,29 +353,152 @@ func (dec *NavDecoder) nextEphemerisv4() bool {
 		if rectyp == string(NavRecordTypeEPH) {
 			sys, ok := gnss.ByAbbr[line[6:7]]
 			if !ok {
-				dec.setErr(fmt.Errorf("rinex: invalid satellite system in: %q (line %d)", line, dec.lineNum))
-				return false
+				if dec.fail(dec.newError(dec.lineNum, recordEphemeris, 7, 7, fmt.Errorf("invalid satellite system: %q", line[6:7]))) {
+					return false
+				}
+				continue
 			}
 
 			err := dec.decodeEPH(sys)
 			if err != nil {
-				dec.setErr(err)
-				return false
+				if dec.fail(err) {
+					return false
+				}
+				continue
 			}
+			dec.resync = false
 			return true
 		}
 
-		// TODO read other record types
-
+		if dec.fastMode {
+			continue
+		}
+		var err error
+		switch NavRecordType(rectyp) {
+		case NavRecordTypeSTO:
+			err = dec.decodeSTO()
+		case NavRecordTypeEOP:
+			err = dec.decodeEOP()
+		case NavRecordTypeION:
+			err = dec.decodeION()
+		default:
+			err = dec.newError(dec.lineNum, recordEphemeris, 3, 5, fmt.Errorf("invalid record type: %q", rectyp))
+		}
+		if err != nil && dec.fail(err) {
+			return false
+		}
 	}
 
 	if err := dec.sc.Err(); err != nil {
-		dec.setErr(fmt.Errorf("rinex: read epochs: %v", err))
+		dec.setErr(dec.readError(dec.lineNum, err))
 	}
 
 	return false // EOF
 }
 
+// Records returns the STO, EOP and ION records of RINEX-4 that were read by the calls to NextEphemeris so far.
+func (dec *NavDecoder) Records() []NavRecord {
+	return dec.records
+}
+
+// decodeRecordLine decodes the record line of a RINEX-4 STO, EOP or ION record, and reads the next line.
+func (dec *NavDecoder) decodeRecordLine() (prn gnss.PRN, msgType string, err error) {
+	line := dec.line()
+	if len(line) < 9 {
+		return prn, msgType, dec.newError(dec.lineNum, recordEphemeris, 1, len(line), fmt.Errorf("invalid line: %q", line))
+	}
+	if prn, err = gnss.NewPRN(line[6:9]); err != nil {
+		return prn, msgType, dec.newError(dec.lineNum, recordEphemeris, 7, 9, err)
+	}
+	if len(line) > 10 {
+		msgType = strings.TrimSpace(line[10:])
+	}
+	if ok := dec.readLine(); !ok {
+		return prn, msgType, fmt.Errorf("could not read line")
+	}
+	return prn, msgType, nil
+}
+
+// decodeSTO decodes a RINEX-4 system time offset record.
+func (dec *NavDecoder) decodeSTO() (err error) {
+	rec := &TimeOffset{}
+	if rec.PRN, rec.MessageType, err = dec.decodeRecordLine(); err != nil {
+		return err
+	}
+	if rec.Time, err = dec.parseToC(); err != nil {
+		return err
+	}
+	line := dec.line()
+	field := func(start int) string {
+		if len(line) <= start {
+			return ""
+		}
+		return strings.TrimSpace(line[start:min(start+19, len(line))])
+	}
+	rec.Type, rec.SBASID, rec.UTCID = field(24), field(43), field(62)
+	if rec.TransTime, rec.A0, rec.A1, rec.A2, err = dec.readFloatsLine(0); err != nil {
+		return err
+	}
+	dec.records = append(dec.records, rec)
+	return nil
+}
+
+// decodeEOP decodes a RINEX-4 earth orientation parameters record.
+func (dec *NavDecoder) decodeEOP() (err error) {
+	rec := &EarthOrientation{}
+	if rec.PRN, rec.MessageType, err = dec.decodeRecordLine(); err != nil {
+		return err
+	}
+	if rec.Time, err = dec.parseToC(); err != nil {
+		return err
+	}
+	if rec.XP, rec.DXP, rec.DDXP, err = dec.parseClockFromLine(0); err != nil {
+		return err
+	}
+	if _, rec.YP, rec.DYP, rec.DDYP, err = dec.readFloatsLine(0); err != nil {
+		return err
+	}
+	if rec.TransTime, rec.DUT1, rec.DDUT1, rec.DDDUT1, err = dec.readFloatsLine(0); err != nil {
+		return err
+	}
+	dec.records = append(dec.records, rec)
+	return nil
+}
+
+// decodeION decodes a RINEX-4 ionospheric model record. The NeQuick-G model of Galileo has two data lines,
+// the Klobuchar and BDGIM models have three.
+func (dec *NavDecoder) decodeION() (err error) {
+	rec := &IonoModel{}
+	if rec.PRN, rec.MessageType, err = dec.decodeRecordLine(); err != nil {
+		return err
+	}
+	if rec.Time, err = dec.parseToC(); err != nil {
+		return err
+	}
+	nLines := 3
+	if rec.PRN.Sys == gnss.SysGAL {
+		nLines = 2
+	}
+	for i := range nLines {
+		if i > 0 {
+			if ok := dec.readLine(); !ok {
+				return fmt.Errorf("could not read line")
+			}
+		}
+		first := 0
+		if i == 0 {
+			first = 1
+		}
+		vals, err := dec.parseFloatList(first)
+		if err != nil {
+			return err
+		}
+		rec.Params = append(rec.Params, vals...)
+	}
+	dec.records = append(dec.records, rec)
+	return nil
+}
+
 // Ephemeris returns the most recent ephemeris generated by a call to NextEphemeris.
 func (dec *NavDecoder) Ephemeris() Eph {
 	return dec.eph
@@ -303,6 +509,36 @@ func (dec *NavDecoder) setErr(err error) {
 	dec.err = errors.Join(dec.err, err)
 }
 
+// SetLenient sets the lenient mode. In lenient mode a corrupt ephemeris is skipped and added to the warnings,
+// and the decoder continues with the next ephemeris. By default the decoder stops at the first corrupt ephemeris.
+func (dec *NavDecoder) SetLenient(lenient bool) {
+	dec.lenient = lenient
+}
+
+// Warnings returns the warnings that occurred while decoding the data, e.g. the ephemerides skipped in lenient mode.
+func (dec *NavDecoder) Warnings() []*RinexError {
+	return dec.warnings
+}
+
+// fail handles a corrupt ephemeris. Errors that are not yet a *RinexError are attributed to the current line.
+// In lenient mode the error is added to the warnings, and the lines are skipped until the next record.
+// Otherwise the error is set and fail returns true, i.e. the decoder must stop.
+func (dec *NavDecoder) fail(err error) (stop bool) {
+	var e *RinexError
+	if !errors.As(err, &e) {
+		e = dec.newError(dec.lineNum, recordEphemeris, 0, 0, err)
+	}
+	if !dec.lenient {
+		dec.setErr(e)
+		return true
+	}
+	if !dec.resync {
+		dec.warn(e)
+		dec.resync = true
+	}
+	return false
+}
+
 // readLine reads the next line into buffer. It returns false if an error
 // occurs or EOF was reached.
 func (dec *NavDecoder) readLine() bool {
@@ -332,9 +568,17 @@ func (dec *NavDecoder) line() string {
 func (dec *NavDecoder) parsePRN() (gnss.PRN, error) {
 	line := dec.line()
 	if dec.Header.RINEXVersion < 3 {
-		return gnss.NewPRN(fmt.Sprintf("%s%s", dec.Header.SatSystem.Abbr(), line[0:2]))
+		prn, err := gnss.NewPRN(fmt.Sprintf("%s%s", dec.Header.SatSystem.Abbr(), line[0:2]))
+		if err != nil {
+			return prn, dec.newError(dec.lineNum, recordEphemeris, 1, 2, err)
+		}
+		return prn, nil
+	}
+	prn, err := gnss.NewPRN(line[0:3])
+	if err != nil {
+		return prn, dec.newError(dec.lineNum, recordEphemeris, 1, 3, err)
 	}
-	return gnss.NewPRN(line[0:3])
+	return prn, nil
 }
 
 // parse the time of eph from the data record.
@@ -345,39 +589,73 @@ func (dec *NavDecoder) parseToC() (time.Time, error) {
 		if toc[0] == ' ' { // year can be only 1 char, so pad with 0.
 			toc = strings.Replace(toc, " ", "0", 1)
 		}
-		return time.Parse(TimeOfClockFormatv2, toc)
+		t, err := time.Parse(TimeOfClockFormatv2, toc)
+		if err != nil {
+			return t, dec.newError(dec.lineNum, recordEphemeris, 4, 22, err)
+		}
+		return t, nil
 	}
 
-	return time.Parse(TimeOfClockFormat, line[4:23])
+	t, err := time.Parse(TimeOfClockFormat, line[4:23])
+	if err != nil {
+		return t, dec.newError(dec.lineNum, recordEphemeris, 5, 23, err)
+	}
+	return t, nil
 }
 
 // parseFloatsFromLine parses a common data line of a nav file, having 4 floats 4X,4D19.12.
-// For RINEX-2 it is 3X,4D19.12, so we have a shift of -1.
+// For RINEX-2 it is 3X,4D19.12, so we have a shift of -1. Missing values at the end of the line are zero.
 func (dec *NavDecoder) parseFloatsFromLine(shift int) (f1, f2, f3, f4 float64, err error) {
-	line := dec.line()
-	f1, err = parseFloat(line[4+shift : 4+shift+19])
-	if err != nil {
-		return
-	}
+	return dec.parseFloatFields(shift, 0)
+}
 
-	f2, err = parseFloat(line[23+shift : 23+shift+19])
-	if err != nil {
-		return
-	}
+// parseClockFromLine parses the 3 clock parameters that follow the PRN and the TOC in the first line of an ephemeris.
+func (dec *NavDecoder) parseClockFromLine(shift int) (f1, f2, f3 float64, err error) {
+	_, f1, f2, f3, err = dec.parseFloatFields(shift, 1)
+	return
+}
 
-	if len(line) < 45+shift {
-		return
+// readFloatsLine reads the next data line of an ephemeris and parses its 4 floats, see parseFloatsFromLine.
+func (dec *NavDecoder) readFloatsLine(shift int) (f1, f2, f3, f4 float64, err error) {
+	if ok := dec.readLine(); !ok {
+		return 0, 0, 0, 0, fmt.Errorf("could not read line")
 	}
-	f3, err = parseFloat(line[42+shift : 42+shift+19])
-	if err != nil {
-		return
+	return dec.parseFloatsFromLine(shift)
+}
+
+// parseFloatFields parses the floats of a data line beginning with the field number first, the fields before are zero.
+func (dec *NavDecoder) parseFloatFields(shift, first int) (f1, f2, f3, f4 float64, err error) {
+	line := dec.line()
+	var vals [4]float64
+	for i := first; i < len(vals); i++ {
+		start := 4 + shift + 19*i
+		if len(line) < start+3 {
+			break
+		}
+		vals[i], err = parseFloat(line[start:min(start+19, len(line))])
+		if err != nil {
+			return 0, 0, 0, 0, dec.newError(dec.lineNum, recordEphemeris, start+1, start+19, err)
+		}
 	}
+	return vals[0], vals[1], vals[2], vals[3], nil
+}
 
-	if len(line) < 64+shift {
-		return
+// parseFloatList parses the floats of a data line beginning with the field number first up to the end of the line.
+func (dec *NavDecoder) parseFloatList(first int) ([]float64, error) {
+	line := dec.line()
+	vals := []float64{}
+	for i := first; i < 4; i++ {
+		start := 4 + 19*i
+		if len(line) < start+3 {
+			break
+		}
+		val, err := parseFloat(line[start:min(start+19, len(line))])
+		if err != nil {
+			return nil, dec.newError(dec.lineNum, recordEphemeris, start+1, start+19, err)
+		}
+		vals = append(vals, val)
 	}
-	f4, err = parseFloat(line[61+shift : 61+shift+19])
-	return
+	return vals, nil
 }
 
 func (dec *NavDecoder) decodeEPH(sys gnss.System) (err error) {
@@ -401,6 +679,7 @@ func (dec *NavDecoder) decodeEPH(sys gnss.System) (err error) {
 	return fmt.Errorf("rinex: not supported satellite system: %v", sys)
 }
 
+// decodeGPS decodes a GPS ephemeris of the LNAV, or of the CNAV and CNV2 messages of RINEX-4.
 func (dec *NavDecoder) decodeGPS() (err error) {
 	eph := &EphGPS{}
 	dec.eph = eph
@@ -418,12 +697,12 @@ func (dec *NavDecoder) decodeGPS() (err error) {
 
 	eph.PRN, err = dec.parsePRN()
 	if err != nil {
-		return fmt.Errorf("parse prn: '%s': %v", line, err)
+		return err
 	}
 
 	eph.TOC, err = dec.parseToC()
 	if err != nil {
-		return fmt.Errorf("parse ToC: '%s': %v", line, err)
+		return err
 	}
 
 	// In fast mode we only read only the TOC.
@@ -451,6 +730,12 @@ func (dec *NavDecoder) decodeGPS() (err error) {
 	if err != nil {
 		return
 	}
+	switch {
+	case eph.MessageType == "CNAV" || eph.MessageType == "CNV2":
+		return dec.decodeGPSCNAV(eph)
+	case !isLegacyMessage(eph.MessageType):
+		return nil
+	}
 
 	// Line 2
 	if ok := dec.readLine(); !ok {
@@ -518,34 +803,90 @@ func (dec *NavDecoder) decodeGPS() (err error) {
 	return nil
 }
 
-func (dec *NavDecoder) decodeGLO() (err error) {
-	eph := &EphGLO{}
-	dec.eph = eph
-
-	nLines := 4
-	if dec.Header.RINEXVersion >= 3.05 {
-		nLines = 5
+// decodeGPSCNAV decodes the data lines of the GPS CNAV and CNV2 messages of RINEX-4.
+func (dec *NavDecoder) decodeGPSCNAV(eph *EphGPS) (err error) {
+	if eph.ADOT, eph.Crs, eph.DeltaN, eph.M0, err = dec.readFloatsLine(0); err != nil {
+		return
 	}
+	if eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.Top, eph.Cic, eph.Omega0, eph.Cis, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.I0, eph.Crc, eph.Omega, eph.OmegaDot, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.IDOT, eph.DeltaNDot, eph.URAINED0, eph.URAINED1, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.URAIED, eph.Health, eph.TGD, eph.URAINED2, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.MessageType == "CNAV" {
+		eph.ISCL1CA, eph.ISCL2C, eph.ISCL5I5, eph.ISCL5Q5, err = dec.readFloatsLine(0)
+	} else {
+		eph.ISCL1CD, eph.ISCL1CP, _, _, err = dec.readFloatsLine(0)
+	}
+	if err != nil {
+		return
+	}
+	if eph.Tom, eph.WNop, _, _, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	week, toe := weekSeconds(gpsEpoch, eph.TOC)
+	eph.ToeWeek, eph.Toe = float64(week), toe
+	return nil
+}
 
-	// reread first line
-	line := dec.line()
-
+// decodeFirstLine decodes the start of an ephemeris, i.e. the message type of the RINEX-4 record line,
+// and the PRN and TOC of the first data line.
+func (dec *NavDecoder) decodeFirstLine() (msgType string, prn gnss.PRN, toc time.Time, err error) {
 	if dec.Header.RINEXVersion >= 4 {
-		eph.MessageType = strings.TrimSpace(line[10:])
+		msgType = strings.TrimSpace(dec.line()[10:])
 		if ok := dec.readLine(); !ok {
-			return fmt.Errorf("could not read line")
+			return msgType, prn, toc, fmt.Errorf("could not read line")
 		}
-		line = dec.line()
 	}
 
-	eph.PRN, err = dec.parsePRN()
+	prn, err = dec.parsePRN()
 	if err != nil {
-		return fmt.Errorf("parse prn: '%s': %v", line, err)
+		return
+	}
+	toc, err = dec.parseToC()
+	return
+}
+
+// shift returns the column shift of the data lines, see parseFloatsFromLine.
+func (dec *NavDecoder) shift() int {
+	if dec.Header.RINEXVersion < 3 {
+		return -1
 	}
+	return 0
+}
 
-	eph.TOC, err = dec.parseToC()
+// isLegacyMessage reports whether the RINEX-4 navigation message type has the data records of the
+// RINEX 3 ephemerides. This is also true for the empty message type of RINEX 2 and 3.
+func isLegacyMessage(msgType string) bool {
+	switch msgType {
+	case "", "LNAV", "FDMA", "INAV", "FNAV", "D1", "D2", "SBAS":
+		return true
+	}
+	return false
+}
+
+func (dec *NavDecoder) decodeGLO() (err error) {
+	eph := &EphGLO{}
+	dec.eph = eph
+
+	eph.MessageType, eph.PRN, eph.TOC, err = dec.decodeFirstLine()
 	if err != nil {
-		return fmt.Errorf("parse ToC: '%s': %v", line, err)
+		return err
+	}
+
+	nLines := 4
+	if dec.Header.RINEXVersion >= 3.05 {
+		nLines = 5
 	}
 
 	// In fast mode we read only the TOC.
@@ -554,8 +895,24 @@ func (dec *NavDecoder) decodeGLO() (err error) {
 		return nil
 	}
 
-	// TODO parse remaining lines
-	dec.skipLines(nLines - 1)
+	shift := dec.shift()
+	if eph.ClockBias, eph.RelFreqBias, eph.FrameTime, err = dec.parseClockFromLine(shift); err != nil {
+		return
+	}
+	if eph.X, eph.VelX, eph.AccX, eph.Health, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Y, eph.VelY, eph.AccY, eph.FreqNum, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Z, eph.VelZ, eph.AccZ, eph.AgeOpInfo, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if nLines > 4 {
+		if eph.StatusFlags, eph.DelayL1L2, eph.URAI, eph.HealthFlags, err = dec.readFloatsLine(shift); err != nil {
+			return
+		}
+	}
 
 	return nil
 }
@@ -564,25 +921,9 @@ func (dec *NavDecoder) decodeGAL() (err error) {
 	eph := &EphGAL{}
 	dec.eph = eph
 
-	// reread first line
-	line := dec.line()
-
-	if dec.Header.RINEXVersion >= 4 {
-		eph.MessageType = strings.TrimSpace(line[10:])
-		if ok := dec.readLine(); !ok {
-			return fmt.Errorf("could not read line")
-		}
-		line = dec.line()
-	}
-
-	eph.PRN, err = dec.parsePRN()
+	eph.MessageType, eph.PRN, eph.TOC, err = dec.decodeFirstLine()
 	if err != nil {
-		return fmt.Errorf("parse prn: '%s': %v", line, err)
-	}
-
-	eph.TOC, err = dec.parseToC()
-	if err != nil {
-		return fmt.Errorf("parse ToC: '%s': %v", line, err)
+		return err
 	}
 
 	// In fast mode we read only the TOC.
@@ -591,35 +932,43 @@ func (dec *NavDecoder) decodeGAL() (err error) {
 		return nil
 	}
 
-	// TODO parse remaining lines
-	dec.skipLines(7)
+	shift := dec.shift()
+	if eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate, err = dec.parseClockFromLine(shift); err != nil {
+		return
+	}
+	if eph.IODnav, eph.Crs, eph.DeltaN, eph.M0, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Toe, eph.Cic, eph.Omega0, eph.Cis, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.I0, eph.Crc, eph.Omega, eph.OmegaDot, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.IDOT, eph.DataSources, eph.ToeWeek, _, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.SISA, eph.Health, eph.BGDE5aE1, eph.BGDE5bE1, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Tom, _, _, _, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
 
 	return nil
 }
 
+// decodeQZSS decodes a QZSS ephemeris of the LNAV, or of the CNAV and CNV2 messages of RINEX-4.
 func (dec *NavDecoder) decodeQZSS() (err error) {
 	eph := &EphQZSS{}
 	dec.eph = eph
 
-	// reread first line
-	line := dec.line()
-
-	if dec.Header.RINEXVersion >= 4 {
-		eph.MessageType = strings.TrimSpace(line[10:])
-		if ok := dec.readLine(); !ok {
-			return fmt.Errorf("could not read line")
-		}
-		line = dec.line()
-	}
-
-	eph.PRN, err = dec.parsePRN()
+	eph.MessageType, eph.PRN, eph.TOC, err = dec.decodeFirstLine()
 	if err != nil {
-		return fmt.Errorf("parse prn: '%s': %v", line, err)
-	}
-
-	eph.TOC, err = dec.parseToC()
-	if err != nil {
-		return fmt.Errorf("parse ToC: '%s': %v", line, err)
+		return err
 	}
 
 	// In fast mode we read only the TOC.
@@ -628,35 +977,85 @@ func (dec *NavDecoder) decodeQZSS() (err error) {
 		return nil
 	}
 
-	// TODO parse remaining lines
-	dec.skipLines(7)
+	shift := dec.shift()
+	if eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate, err = dec.parseClockFromLine(shift); err != nil {
+		return
+	}
+	switch {
+	case eph.MessageType == "CNAV" || eph.MessageType == "CNV2":
+		return dec.decodeQZSSCNAV(eph)
+	case !isLegacyMessage(eph.MessageType):
+		return nil
+	}
+	if eph.IODE, eph.Crs, eph.DeltaN, eph.M0, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Toe, eph.Cic, eph.Omega0, eph.Cis, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.I0, eph.Crc, eph.Omega, eph.OmegaDot, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.IDOT, eph.L2Codes, eph.ToeWeek, eph.L2PFlag, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.URA, eph.Health, eph.TGD, eph.IODC, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Tom, eph.FitInterval, _, _, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
 
 	return nil
 }
 
-func (dec *NavDecoder) decodeBDS() (err error) {
-	eph := &EphBDS{}
-	dec.eph = eph
-
-	// reread first line
-	line := dec.line()
-
-	if dec.Header.RINEXVersion >= 4 {
-		eph.MessageType = strings.TrimSpace(line[10:])
-		if ok := dec.readLine(); !ok {
-			return fmt.Errorf("could not read line")
-		}
-		line = dec.line()
+// decodeQZSSCNAV decodes the data lines of the QZSS CNAV and CNV2 messages of RINEX-4.
+func (dec *NavDecoder) decodeQZSSCNAV(eph *EphQZSS) (err error) {
+	if eph.ADOT, eph.Crs, eph.DeltaN, eph.M0, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.Top, eph.Cic, eph.Omega0, eph.Cis, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.I0, eph.Crc, eph.Omega, eph.OmegaDot, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.IDOT, eph.DeltaNDot, eph.URAINED0, eph.URAINED1, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.URAIED, eph.Health, eph.TGD, eph.URAINED2, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.MessageType == "CNAV" {
+		eph.ISCL1CA, eph.ISCL2C, eph.ISCL5I5, eph.ISCL5Q5, err = dec.readFloatsLine(0)
+	} else {
+		eph.ISCL1CD, eph.ISCL1CP, _, _, err = dec.readFloatsLine(0)
 	}
-
-	eph.PRN, err = dec.parsePRN()
 	if err != nil {
-		return fmt.Errorf("parse prn: '%s': %v", line, err)
+		return
 	}
+	if eph.Tom, eph.WNop, _, _, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	week, toe := weekSeconds(gpsEpoch, eph.TOC)
+	eph.ToeWeek, eph.Toe = float64(week), toe
+	return nil
+}
 
-	eph.TOC, err = dec.parseToC()
+// decodeBDS decodes a BDS ephemeris of the D1 or D2, or of the CNV1, CNV2 and CNV3 messages of RINEX-4.
+func (dec *NavDecoder) decodeBDS() (err error) {
+	eph := &EphBDS{}
+	dec.eph = eph
+
+	eph.MessageType, eph.PRN, eph.TOC, err = dec.decodeFirstLine()
 	if err != nil {
-		return fmt.Errorf("parse ToC: '%s': %v", line, err)
+		return err
 	}
 
 	// In fast mode we read only the TOC.
@@ -665,35 +1064,93 @@ func (dec *NavDecoder) decodeBDS() (err error) {
 		return nil
 	}
 
-	// TODO parse remaining lines
-	dec.skipLines(7)
+	shift := dec.shift()
+	if eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate, err = dec.parseClockFromLine(shift); err != nil {
+		return
+	}
+	switch {
+	case eph.MessageType == "CNV1" || eph.MessageType == "CNV2" || eph.MessageType == "CNV3":
+		return dec.decodeBDSCNAV(eph)
+	case !isLegacyMessage(eph.MessageType):
+		return nil
+	}
+	if eph.AODE, eph.Crs, eph.DeltaN, eph.M0, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Toe, eph.Cic, eph.Omega0, eph.Cis, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.I0, eph.Crc, eph.Omega, eph.OmegaDot, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.IDOT, _, eph.ToeWeek, _, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.URA, eph.Health, eph.TGD1, eph.TGD2, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Tom, eph.AODC, _, _, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+
+	return nil
+}
 
+// decodeBDSCNAV decodes the data lines of the BDS CNV1, CNV2 and CNV3 messages of RINEX-4.
+func (dec *NavDecoder) decodeBDSCNAV(eph *EphBDS) (err error) {
+	if eph.ADOT, eph.Crs, eph.DeltaN, eph.M0, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.Toe, eph.Cic, eph.Omega0, eph.Cis, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.I0, eph.Crc, eph.Omega, eph.OmegaDot, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.IDOT, eph.DeltaNDot, eph.SatType, eph.Top, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.SISAIOE, eph.SISAIOCB, eph.SISAIOC1, eph.SISAIOC2, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	switch eph.MessageType {
+	case "CNV1":
+		eph.ISCB1CD, _, eph.TGDB1CP, eph.TGDB2AP, err = dec.readFloatsLine(0)
+	case "CNV2":
+		_, eph.ISCB2AD, eph.TGDB1CP, eph.TGDB2AP, err = dec.readFloatsLine(0)
+	default:
+		eph.SISMAI, eph.Health, eph.IntegrityFlags, eph.TGDB2BI, err = dec.readFloatsLine(0)
+	}
+	if err != nil {
+		return
+	}
+	if eph.MessageType == "CNV3" {
+		eph.Tom, _, _, _, err = dec.readFloatsLine(0)
+	} else if eph.SISMAI, eph.Health, eph.IntegrityFlags, eph.IODC, err = dec.readFloatsLine(0); err == nil {
+		eph.Tom, _, _, eph.IODE, err = dec.readFloatsLine(0)
+	}
+	if err != nil {
+		return
+	}
+	week, _ := weekSeconds(bdsEpoch, nearestWeekTime(eph.TOC, eph.Toe))
+	eph.ToeWeek = float64(week)
 	return nil
 }
 
+// decodeNavIC decodes a NavIC ephemeris of the LNAV, or of the L1NV message of RINEX-4.
 func (dec *NavDecoder) decodeNavIC() (err error) {
 	eph := &EphNavIC{}
 	dec.eph = eph
 
-	// reread first line
-	line := dec.line()
-
-	if dec.Header.RINEXVersion >= 4 {
-		eph.MessageType = strings.TrimSpace(line[10:])
-		if ok := dec.readLine(); !ok {
-			return fmt.Errorf("could not read line")
-		}
-		line = dec.line()
-	}
-
-	eph.PRN, err = dec.parsePRN()
-	if err != nil {
-		return fmt.Errorf("parse prn: '%s': %v", line, err)
-	}
-
-	eph.TOC, err = dec.parseToC()
+	eph.MessageType, eph.PRN, eph.TOC, err = dec.decodeFirstLine()
 	if err != nil {
-		return fmt.Errorf("parse ToC: '%s': %v", line, err)
+		return err
 	}
 
 	// In fast mode we read only the TOC.
@@ -702,9 +1159,67 @@ func (dec *NavDecoder) decodeNavIC() (err error) {
 		return nil
 	}
 
-	// TODO parse remaining lines
-	dec.skipLines(7)
+	shift := dec.shift()
+	if eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate, err = dec.parseClockFromLine(shift); err != nil {
+		return
+	}
+	switch {
+	case eph.MessageType == "L1NV":
+		return dec.decodeNavICL1NV(eph)
+	case !isLegacyMessage(eph.MessageType):
+		return nil
+	}
+	if eph.IODEC, eph.Crs, eph.DeltaN, eph.M0, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Toe, eph.Cic, eph.Omega0, eph.Cis, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.I0, eph.Crc, eph.Omega, eph.OmegaDot, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.IDOT, _, eph.ToeWeek, _, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.URA, eph.Health, eph.TGD, _, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Tom, _, _, _, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+
+	return nil
+}
 
+// decodeNavICL1NV decodes the data lines of the NavIC L1NV message of RINEX-4.
+func (dec *NavDecoder) decodeNavICL1NV(eph *EphNavIC) (err error) {
+	if eph.ADOT, eph.Crs, eph.DeltaN, eph.M0, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.Toe, eph.Cic, eph.Omega0, eph.Cis, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.I0, eph.Crc, eph.Omega, eph.OmegaDot, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.IDOT, eph.DeltaNDot, eph.ToeWeek, _, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.URA, eph.Health, eph.TGD, eph.ISCS, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.ISCL1P, eph.ISCL1D, _, _, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
+	if eph.Tom, _, _, _, err = dec.readFloatsLine(0); err != nil {
+		return
+	}
 	return nil
 }
 
@@ -712,25 +1227,9 @@ func (dec *NavDecoder) decodeSBAS() (err error) {
 	eph := &EphSBAS{}
 	dec.eph = eph
 
-	// reread first line
-	line := dec.line()
-
-	if dec.Header.RINEXVersion >= 4 {
-		eph.MessageType = strings.TrimSpace(line[10:])
-		if ok := dec.readLine(); !ok {
-			return fmt.Errorf("could not read line")
-		}
-		line = dec.line()
-	}
-
-	eph.PRN, err = dec.parsePRN()
+	eph.MessageType, eph.PRN, eph.TOC, err = dec.decodeFirstLine()
 	if err != nil {
-		return fmt.Errorf("parse prn: '%s': %v", line, err)
-	}
-
-	eph.TOC, err = dec.parseToC()
-	if err != nil {
-		return fmt.Errorf("parse ToC: '%s': %v", line, err)
+		return err
 	}
 
 	// In fast mode we read only the TOC.
@@ -739,8 +1238,19 @@ func (dec *NavDecoder) decodeSBAS() (err error) {
 		return nil
 	}
 
-	// TODO parse remaining lines
-	dec.skipLines(3)
+	shift := dec.shift()
+	if eph.ClockBias, eph.RelFreqBias, eph.FrameTime, err = dec.parseClockFromLine(shift); err != nil {
+		return
+	}
+	if eph.X, eph.VelX, eph.AccX, eph.Health, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Y, eph.VelY, eph.AccY, eph.URA, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
+	if eph.Z, eph.VelZ, eph.AccZ, eph.IODN, err = dec.readFloatsLine(shift); err != nil {
+		return
+	}
 
 	return nil
 }
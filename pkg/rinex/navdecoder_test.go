@@ -13,6 +13,41 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// newNavDecoder opens the RINEX nav file and returns a decoder for it.
+func newNavDecoder(t *testing.T, path string) *NavDecoder {
+	t.Helper()
+	r, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	dec, err := NewNavDecoder(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dec
+}
+
+// readNavFile returns the header and the ephemerides of the RINEX nav file.
+func readNavFile(t *testing.T, path string) (NavHeader, []Eph) {
+	t.Helper()
+	dec := newNavDecoder(t, path)
+	return dec.Header, readAllEphemerides(t, dec)
+}
+
+// readAllEphemerides decodes all ephemerides of the decoder.
+func readAllEphemerides(t *testing.T, dec *NavDecoder) []Eph {
+	t.Helper()
+	ephs := []Eph{}
+	for dec.NextEphemeris() {
+		ephs = append(ephs, dec.Ephemeris())
+	}
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ephs
+}
+
 func TestNavDecoder_readHeader(t *testing.T) {
 	assert := assert.New(t)
 	filepath := "testdata/white/AREG00PER_R_20201690000_01D_MN.rnx"
@@ -27,6 +62,13 @@ func TestNavDecoder_readHeader(t *testing.T) {
 	assert.Equal(float32(3.04), dec.Header.RINEXVersion, "RINEX Version")
 	assert.Equal("N", dec.Header.RINEXType, "RINEX Type")
 	assert.Equal(gnss.SysMIXED, dec.Header.SatSystem, "Sat System")
+	if assert.Len(dec.Header.IonoCorrs, 3) {
+		assert.Equal(IonoCorr{Type: "GAL", Params: [4]float64{2.375e+01, 1.5625e-02, 1.2329e-02, 0}}, dec.Header.IonoCorrs[2])
+	}
+	if assert.Len(dec.Header.TimeCorrs, 3) {
+		assert.Equal(TimeSystemCorr{Type: "GPUT", A0: 3.4924596548e-10, A1: -1.154631946e-14, T: 503808, W: 2110}, dec.Header.TimeCorrs[0])
+	}
+	assert.Equal(LeapSeconds{Current: 18}, dec.Header.LeapSeconds)
 
 	t.Logf("RINEX Header: %+v\n", dec)
 }
@@ -60,7 +102,7 @@ https://doi.org/10.57677/BRD400DLR                          DOI
 
 	assert.Equal("https://doi.org/10.57677/BRD400DLR", hdr.DOI)
 	assert.Equal(98, dec.Header.MergedFiles)
-	// TODO assert.Equal(, hdr.Leap)
+	assert.Equal(LeapSeconds{Current: 18, Future: 18, Week: 1929, Day: 7}, hdr.LeapSeconds)
 }
 
 func BenchmarkNavDecoder_Ephemerides(b *testing.B) {
@@ -380,7 +422,14 @@ E26 2020 06 17 04 20 00 3.064073505811E-03-4.352784799266E-11 0.000000000000E+00
 		URA: 2.0, Health: 0, TGD: -8.847564458847e-09, IODC: 83,
 		Tom: 3.393480000000e+05, FitInterval: 4}
 
-	wantGAL := &EphGAL{PRN: gnss.PRN{Sys: gnss.SysGAL, Num: 26}, TOC: time.Date(2020, 6, 17, 4, 20, 0, 0, time.UTC)}
+	wantGAL := &EphGAL{PRN: gnss.PRN{Sys: gnss.SysGAL, Num: 26}, TOC: time.Date(2020, 6, 17, 4, 20, 0, 0, time.UTC), ClockBias: 3.064073505811e-03, ClockDrift: -4.352784799266e-11, ClockDriftRate: 0,
+		IODnav: 74, Crs: -1.238437500000e+02, DeltaN: 2.376527563341e-09, M0: 3.130998000440,
+		Cuc: -5.731359124184e-06, Ecc: 2.621184103191e-05, Cus: 1.052953302860e-05, SqrtA: 5.440627540588e+03,
+		Toe: 2.748000000000e+05, Cic: 1.303851604462e-08, Omega0: 2.421956189340, Cis: -2.607703208923e-08,
+		I0: 9.848811109258e-01, Crc: 1.224062500000e+02, Omega: 1.660149314991, OmegaDot: -5.262004897911e-09,
+		IDOT: 8.571785620706e-11, DataSources: 517, ToeWeek: 2110,
+		SISA: 3.12, Health: 0, BGDE5aE1: 3.958120942116e-09, BGDE5bE1: 4.423782229424e-09,
+		Tom: 2.754650000000e+05}
 
 	dec, err := NewNavDecoder(strings.NewReader(navdata))
 	assert.NoError(err)
@@ -448,3 +497,240 @@ func TestNavDecoder_parseToC(t *testing.T) {
 		fmt.Printf("RINEX-2: %s\n", epTime)
 	}
 }
+
+func TestNavDecoder_lenient(t *testing.T) {
+	assert := assert.New(t)
+	data, err := os.ReadFile("testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	countEphs := func(dec *NavDecoder) int {
+		n := 0
+		for dec.NextEphemeris() {
+			n++
+		}
+		return n
+	}
+	dec, err := NewNavDecoder(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nEphs := countEphs(dec)
+	assert.NoError(dec.Err())
+
+	// Corrupt the 2nd broadcast orbit value of the first ephemeris (line 12).
+	corrupt := strings.Replace(string(data), "-3.531250000000E+01", "-3.531250000000X+01", 1)
+
+	dec, err = NewNavDecoder(strings.NewReader(corrupt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(0, countEphs(dec))
+	var rerr *RinexError
+	if assert.ErrorAs(dec.Err(), &rerr) {
+		assert.Equal(12, rerr.Line)
+		assert.Equal(24, rerr.StartCol)
+		assert.Equal(42, rerr.EndCol)
+		assert.Equal("EPHEMERIS", rerr.Record)
+	}
+
+	dec, err = NewNavDecoder(strings.NewReader(corrupt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec.SetLenient(true)
+	assert.Equal(nEphs-1, countEphs(dec))
+	assert.NoError(dec.Err())
+	if assert.Len(dec.Warnings(), 1) {
+		assert.Equal(12, dec.Warnings()[0].Line)
+		assert.Equal(SeverityWarning, dec.Warnings()[0].Severity)
+	}
+}
+
+func TestNavDecoder_decodeSystems(t *testing.T) {
+	header := func(version, typ string) string {
+		return fmt.Sprintf("%-60sRINEX VERSION / TYPE\n%60sEND OF HEADER\n", fmt.Sprintf("%9s%11s%s", version, "", typ), "")
+	}
+	tests := []struct {
+		name string
+		data string
+		want []Eph
+	}{
+		{name: "glo-v2", data: header("2.11", "G: GLONASS NAV DATA") +
+			` 3 20  6 16 23 45  0.0 1.691374927759D-05 9.094947017729D-13 8.550000000000D+04
+   -1.154444042969D+04-2.667160034180D-01 9.313225746155D-10 0.000000000000D+00
+   -2.267781884766D+04-1.926689147949D-01 9.313225746155D-10 5.000000000000D+00
+   -2.048565917969D+03 3.550684928894D+00 0.000000000000D+00 0.000000000000D+00
+`,
+			want: []Eph{&EphGLO{PRN: gnss.PRN{Sys: gnss.SysGLO, Num: 3}, TOC: time.Date(2020, 6, 16, 23, 45, 0, 0, time.UTC),
+				ClockBias: 1.691374927759e-05, RelFreqBias: 9.094947017729e-13, FrameTime: 8.55e+04,
+				X: -1.154444042969e+04, VelX: -2.667160034180e-01, AccX: 9.313225746155e-10, Health: 0,
+				Y: -2.267781884766e+04, VelY: -1.926689147949e-01, AccY: 9.313225746155e-10, FreqNum: 5,
+				Z: -2.048565917969e+03, VelZ: 3.550684928894, AccZ: 0, AgeOpInfo: 0}},
+		},
+		{name: "glo-v305", data: header("3.05", "N: GNSS NAV DATA    M: MIXED") +
+			`R02 2020 06 16 23 45 00 4.319325089455E-04 1.818989403546E-12 2.574000000000E+05
+    -1.896841796875E+03 7.037382125854E-01 9.313225746155E-10 0.000000000000E+00
+    -2.086132714844E+04 1.870455741882E+00 0.000000000000E+00-4.000000000000E+00
+     1.464041699219E+04 2.759790420532E+00-9.313225746155E-10 0.000000000000E+00
+     1.790000000000E+02-2.793967723846E-09 2.000000000000E+00 0.000000000000E+00
+`,
+			want: []Eph{&EphGLO{PRN: gnss.PRN{Sys: gnss.SysGLO, Num: 2}, TOC: time.Date(2020, 6, 16, 23, 45, 0, 0, time.UTC),
+				ClockBias: 4.319325089455e-04, RelFreqBias: 1.818989403546e-12, FrameTime: 2.574e+05,
+				X: -1.896841796875e+03, VelX: 7.037382125854e-01, AccX: 9.313225746155e-10, Health: 0,
+				Y: -2.086132714844e+04, VelY: 1.870455741882, AccY: 0, FreqNum: -4,
+				Z: 1.464041699219e+04, VelZ: 2.759790420532, AccZ: -9.313225746155e-10, AgeOpInfo: 0,
+				StatusFlags: 179, DelayL1L2: -2.793967723846e-09, URAI: 2, HealthFlags: 0}},
+		},
+		{name: "qzss-v3", data: header("3.04", "N: GNSS NAV DATA    M: MIXED") +
+			`J02 2020 06 17 00 00 00-3.547295928001E-06 0.000000000000E+00 0.000000000000E+00
+     1.450000000000E+02-3.071875000000E+02 2.326168317870E-09-2.908427745155E+00
+    -1.064874231815E-05 7.528412691317E-02 7.294118404388E-06 6.493415298462E+03
+     2.592000000000E+05-6.798654794693E-07 2.354361295716E+00-1.434236764908E-06
+     7.283914074627E-01-1.340625000000E+02-1.562393487491E+00-1.743643487040E-09
+     1.385772007766E-09 2.000000000000E+00 2.110000000000E+03 1.000000000000E+00
+     2.800000000000E+00 0.000000000000E+00-4.656612873077E-10 9.010000000000E+02
+     2.556180000000E+05 0.000000000000E+00
+`,
+			want: []Eph{&EphQZSS{PRN: gnss.PRN{Sys: gnss.SysQZSS, Num: 2}, TOC: time.Date(2020, 6, 17, 0, 0, 0, 0, time.UTC),
+				ClockBias: -3.547295928001e-06, ClockDrift: 0, ClockDriftRate: 0,
+				IODE: 145, Crs: -3.071875e+02, DeltaN: 2.326168317870e-09, M0: -2.908427745155,
+				Cuc: -1.064874231815e-05, Ecc: 7.528412691317e-02, Cus: 7.294118404388e-06, SqrtA: 6.493415298462e+03,
+				Toe: 2.592e+05, Cic: -6.798654794693e-07, Omega0: 2.354361295716, Cis: -1.434236764908e-06,
+				I0: 7.283914074627e-01, Crc: -1.340625e+02, Omega: -1.562393487491, OmegaDot: -1.743643487040e-09,
+				IDOT: 1.385772007766e-09, L2Codes: 2, ToeWeek: 2110, L2PFlag: 1,
+				URA: 2.8, Health: 0, TGD: -4.656612873077e-10, IODC: 901,
+				Tom: 2.55618e+05, FitInterval: 0}},
+		},
+		{name: "bds-v3", data: header("3.04", "N: GNSS NAV DATA    M: MIXED") +
+			`C19 2020 06 16 21 00 00 4.463285440579E-04 1.173905417318E-11 0.000000000000E+00
+     1.000000000000E+00-1.514062500000E+01 4.204818004690E-09 1.632116350643E+00
+    -7.376074790955E-07 1.003372715786E-03 4.552770406008E-06 5.282614295959E+03
+     2.484000000000E+05-2.421438694000E-08-2.208411781489E+00-6.286427378654E-08
+     9.634262571993E-01 2.647031250000E+02-1.136484493518E+00-6.996720012901E-09
+    -1.328626771209E-10 0.000000000000E+00 7.540000000000E+02
+     2.000000000000E+00 0.000000000000E+00 1.230000000000E-08 1.230000000000E-08
+     2.484180000000E+05 1.000000000000E+00
+`,
+			want: []Eph{&EphBDS{PRN: gnss.PRN{Sys: gnss.SysBDS, Num: 19}, TOC: time.Date(2020, 6, 16, 21, 0, 0, 0, time.UTC),
+				ClockBias: 4.463285440579e-04, ClockDrift: 1.173905417318e-11, ClockDriftRate: 0,
+				AODE: 1, Crs: -1.514062500000e+01, DeltaN: 4.204818004690e-09, M0: 1.632116350643,
+				Cuc: -7.376074790955e-07, Ecc: 1.003372715786e-03, Cus: 4.552770406008e-06, SqrtA: 5.282614295959e+03,
+				Toe: 2.484e+05, Cic: -2.421438694000e-08, Omega0: -2.208411781489, Cis: -6.286427378654e-08,
+				I0: 9.634262571993e-01, Crc: 2.647031250000e+02, Omega: -1.136484493518, OmegaDot: -6.996720012901e-09,
+				IDOT: -1.328626771209e-10, ToeWeek: 754,
+				URA: 2, Health: 0, TGD1: 1.23e-08, TGD2: 1.23e-08,
+				Tom: 2.48418e+05, AODC: 1}},
+		},
+		{name: "navic-v3", data: header("3.04", "N: GNSS NAV DATA    M: MIXED") +
+			`I03 2020 06 16 22 00 00 9.119268506765E-04 1.125499693526E-11 0.000000000000E+00
+     0.000000000000E+00-1.150000000000E+02-1.110046239547E-09 2.617468617945E+00
+    -4.261732101440E-06 2.112752548419E-03 1.557171344757E-05 6.493531251907E+03
+     2.520000000000E+05 1.229345798492E-07-1.396419003367E+00-1.434236764908E-07
+     7.612917339017E-02-3.243125000000E+02 3.056773941237E+00 1.107188402018E-08
+     3.471573075460E-10 0.000000000000E+00 2.110000000000E+03 0.000000000000E+00
+     2.000000000000E+00 0.000000000000E+00-9.313225746155E-10 0.000000000000E+00
+     2.496480000000E+05
+`,
+			want: []Eph{&EphNavIC{PRN: gnss.PRN{Sys: gnss.SysNavIC, Num: 3}, TOC: time.Date(2020, 6, 16, 22, 0, 0, 0, time.UTC),
+				ClockBias: 9.119268506765e-04, ClockDrift: 1.125499693526e-11, ClockDriftRate: 0,
+				IODEC: 0, Crs: -1.15e+02, DeltaN: -1.110046239547e-09, M0: 2.617468617945,
+				Cuc: -4.261732101440e-06, Ecc: 2.112752548419e-03, Cus: 1.557171344757e-05, SqrtA: 6.493531251907e+03,
+				Toe: 2.52e+05, Cic: 1.229345798492e-07, Omega0: -1.396419003367, Cis: -1.434236764908e-07,
+				I0: 7.612917339017e-02, Crc: -3.243125e+02, Omega: 3.056773941237, OmegaDot: 1.107188402018e-08,
+				IDOT: 3.471573075460e-10, ToeWeek: 2110,
+				URA: 2, Health: 0, TGD: -9.313225746155e-10,
+				Tom: 2.49648e+05}},
+		},
+		{name: "sbas-v3", data: header("3.04", "N: GNSS NAV DATA    M: MIXED") +
+			`S31 2020 06 16 23 58 56-3.166496753693E-08-3.637978807092E-11 2.591790000000E+05
+    -1.914652816000E+04-4.375000000000E-05 1.250000000000E-08 3.100000000000E+01
+    -3.756715288000E+04 8.937500000000E-05 0.000000000000E+00 4.000000000000E+00
+    -2.368000000000E+00-2.400000000000E-05 0.000000000000E+00 7.200000000000E+01
+`,
+			want: []Eph{&EphSBAS{PRN: gnss.PRN{Sys: gnss.SysSBAS, Num: 31}, TOC: time.Date(2020, 6, 16, 23, 58, 56, 0, time.UTC),
+				ClockBias: -3.166496753693e-08, RelFreqBias: -3.637978807092e-11, FrameTime: 2.59179e+05,
+				X: -1.914652816000e+04, VelX: -4.375e-05, AccX: 1.25e-08, Health: 31,
+				Y: -3.756715288000e+04, VelY: 8.9375e-05, AccY: 0, URA: 4,
+				Z: -2.368, VelZ: -2.4e-05, AccZ: 0, IODN: 72}},
+		},
+		{name: "v4", data: header("4.00", "NAVIGATION DATA     MIXED") +
+			`> EPH R22 FDMA
+R22 2022 11 29 10 45 00 1.968629658222e-05 0.000000000000e+00 2.106300000000e+05
+    -1.174041748047e+04-7.016086578369e-01 0.000000000000e+00 1.000000000000e+00
+     2.063836816406e+04 1.077777862549e+00 3.725290298462e-09-3.000000000000e+00
+    -9.277129882813e+03 3.275458335876e+00-9.313225746155e-10 0.000000000000e+00
+                         .999999999999e+09 1.500000000000e+01 
+> EPH C45 CNV1
+C45 2022 11 29 12 00 00-4.519214760512e-04-1.154631945610e-13 0.000000000000e+00
+     1.000000000000e+00 2.306250000000e+01 3.506932217166e-09 2.094412207603e+00
+     5.606561899185e-07 6.217302381992e-04 1.081265509129e-05 5.282626131058e+03
+     2.160000000000e+05-1.024454832077e-08 1.021580517292e+00 3.818422555923e-08
+     9.608811736107e-01 1.594687500000e+02-1.095289707184e+00-6.617775363638e-09
+    -3.353711082489e-10 0.000000000000e+00 3.000000000000e+00 2.160000000000e+05
+     0.000000000000e+00 0.000000000000e+00 0.000000000000e+00 0.000000000000e+00
+    -2.910383045673e-09 0.000000000000e+00 3.201421350241e-09-1.140497624874e-08
+     0.000000000000e+00 0.000000000000e+00 0.000000000000e+00 8.000000000000e+00
+     2.160000000000e+05 0.000000000000e+00 0.000000000000e+00 8.000000000000e+00
+> EPH C01 D1
+C01 2022 11 29 12 00 00 9.344602003694e-04-3.997691067070e-12 0.000000000000e+00
+     1.000000000000e+00-3.418906250000e+02-3.509431896211e-09-3.073247862435e+00
+    -1.103850081563e-05 6.337405648082e-04-9.746756404638e-06 6.493360338211e+03
+     2.160000000000e+05 2.626329660416e-07 8.175155760052e-02 1.629814505577e-08
+     1.088206710577e-01 2.943281250000e+02 2.415359474789e+00 4.576262048254e-09
+     4.846630453041e-10 0.000000000000e+00 8.820000000000e+02 0.000000000000e+00
+     2.000000000000e+00 0.000000000000e+00-4.700000000000e-09-1.000000000000e-08
+     2.182470000000e+05 0.000000000000e+00 0.000000000000e+00 0.000000000000e+00
+`,
+			want: []Eph{
+				&EphGLO{PRN: gnss.PRN{Sys: gnss.SysGLO, Num: 22}, MessageType: "FDMA", TOC: time.Date(2022, 11, 29, 10, 45, 0, 0, time.UTC),
+					ClockBias: 1.968629658222e-05, RelFreqBias: 0, FrameTime: 2.1063e+05,
+					X: -1.174041748047e+04, VelX: -7.016086578369e-01, AccX: 0, Health: 1,
+					Y: 2.063836816406e+04, VelY: 1.077777862549, AccY: 3.725290298462e-09, FreqNum: -3,
+					Z: -9.277129882813e+03, VelZ: 3.275458335876, AccZ: -9.313225746155e-10, AgeOpInfo: 0,
+					StatusFlags: 0, DelayL1L2: .999999999999e+09, URAI: 15, HealthFlags: 0},
+				&EphBDS{PRN: gnss.PRN{Sys: gnss.SysBDS, Num: 45}, MessageType: "CNV1", TOC: time.Date(2022, 11, 29, 12, 0, 0, 0, time.UTC),
+					ClockBias: -4.519214760512e-04, ClockDrift: -1.154631945610e-13, ClockDriftRate: 0,
+					ADOT: 1, Crs: 2.306250000000e+01, DeltaN: 3.506932217166e-09, M0: 2.094412207603,
+					Cuc: 5.606561899185e-07, Ecc: 6.217302381992e-04, Cus: 1.081265509129e-05, SqrtA: 5.282626131058e+03,
+					Toe: 2.16e+05, Cic: -1.024454832077e-08, Omega0: 1.021580517292, Cis: 3.818422555923e-08,
+					I0: 9.608811736107e-01, Crc: 1.594687500000e+02, Omega: -1.095289707184, OmegaDot: -6.617775363638e-09,
+					IDOT: -3.353711082489e-10, DeltaNDot: 0, SatType: 3, Top: 2.16e+05,
+					ISCB1CD: -2.910383045673e-09, TGDB1CP: 3.201421350241e-09, TGDB2AP: -1.140497624874e-08,
+					IODC: 8, Tom: 2.16e+05, IODE: 8, ToeWeek: 882},
+				&EphBDS{PRN: gnss.PRN{Sys: gnss.SysBDS, Num: 1}, MessageType: "D1", TOC: time.Date(2022, 11, 29, 12, 0, 0, 0, time.UTC),
+					ClockBias: 9.344602003694e-04, ClockDrift: -3.997691067070e-12, ClockDriftRate: 0,
+					AODE: 1, Crs: -3.418906250000e+02, DeltaN: -3.509431896211e-09, M0: -3.073247862435,
+					Cuc: -1.103850081563e-05, Ecc: 6.337405648082e-04, Cus: -9.746756404638e-06, SqrtA: 6.493360338211e+03,
+					Toe: 2.16e+05, Cic: 2.626329660416e-07, Omega0: 8.175155760052e-02, Cis: 1.629814505577e-08,
+					I0: 1.088206710577e-01, Crc: 2.943281250000e+02, Omega: 2.415359474789, OmegaDot: 4.576262048254e-09,
+					IDOT: 4.846630453041e-10, ToeWeek: 882,
+					URA: 2, Health: 0, TGD1: -4.7e-09, TGD2: -1e-08,
+					Tom: 2.18247e+05, AODC: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			dec, err := NewNavDecoder(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []Eph{}
+			for dec.NextEphemeris() {
+				got = append(got, dec.Ephemeris())
+			}
+			assert.NoError(dec.Err())
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestEphGAL_IsFNAV(t *testing.T) {
+	assert := assert.New(t)
+	assert.True((&EphGAL{DataSources: 258}).IsFNAV())
+	assert.False((&EphGAL{DataSources: 517}).IsFNAV())
+	assert.False((&EphGAL{MessageType: "INAV", DataSources: 258}).IsFNAV())
+	assert.True((&EphGAL{MessageType: "FNAV"}).IsFNAV())
+}
@@ -0,0 +1,384 @@
+package rinex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// NavEncoder writes RINEX Navigation data to an output stream.
+// The format of the data records is determined by the RINEX version of the header.
+type NavEncoder struct {
+	// The Header is written by NewNavEncoder.
+	Header NavHeader
+	w      *bufio.Writer
+	corrs  []NavRecord // the header corrections not yet written as RINEX-4 records
+}
+
+// NewNavEncoder creates a new encoder for RINEX Navigation data.
+// The RINEX header hdr will be written implicitly. RINEX-2 files must have a single satellite system.
+//
+// In RINEX-4 the IonoCorrs and TimeCorrs of the header are written as ION and STO records, each before the first
+// ephemeris or record of its satellite system. As the header gives neither the date nor the transmitting satellite,
+// these are taken from that ephemeris or record.
+//
+// Flush must be called to write any buffered data to the underlying writer.
+func NewNavEncoder(w io.Writer, hdr NavHeader) (*NavEncoder, error) {
+	enc := &NavEncoder{Header: hdr, w: bufio.NewWriter(w)}
+	if hdr.RINEXVersion >= 4 {
+		corrs, err := hdr.correctionRecords()
+		if err != nil {
+			return nil, err
+		}
+		enc.corrs = corrs
+		hdr.IonoCorrs, hdr.TimeCorrs = nil, nil
+	}
+	if err := hdr.Write(enc.w); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+// Encode writes the ephemeris in the D19.12 layout of the RINEX version, in RINEX-4 with its EPH record line.
+// The message type of RINEX-4 defaults to the legacy message of the system, e.g. LNAV for GPS.
+// Ephemerides of the modernized messages like CNAV can only be encoded in RINEX-4.
+func (enc *NavEncoder) Encode(eph Eph) error {
+	prn := eph.GetPRN()
+	isV2 := enc.Header.RINEXVersion < 3
+	if isV2 && prn.Sys != enc.Header.SatSystem {
+		return fmt.Errorf("rinex2: ephemeris %s does not match the satellite system %s", prn, enc.Header.SatSystem)
+	}
+
+	msgType, lines, err := ephRecord(eph, enc.Header.RINEXVersion)
+	if err != nil {
+		return err
+	}
+	if enc.Header.RINEXVersion >= 4 {
+		enc.writeCorrections(prn, eph.GetTime())
+		enc.writeLine(fmt.Sprintf("> %s %s %s", NavRecordTypeEPH, prn, msgType))
+	}
+
+	toc := eph.GetTime()
+	var first string
+	if isV2 {
+		sec := float64(toc.Second()) + float64(toc.Nanosecond())/1e9
+		first = fmt.Sprintf("%2d %02d %2d %2d %2d %2d%5.1f", prn.Num, toc.Year()%100, toc.Month(), toc.Day(), toc.Hour(), toc.Minute(), sec)
+	} else {
+		first = fmt.Sprintf("%s %s", prn, toc.Format("2006 01 02 15 04 05"))
+	}
+	enc.writeFloats(first, lines[0]...)
+	for _, vals := range lines[1:] {
+		enc.writeFloats(enc.indent(), vals...)
+	}
+	return nil
+}
+
+// EncodeRecord writes the RINEX-4 STO, EOP or ION record. For older versions add the records to the
+// header instead, see NavHeader.AddCorrections.
+func (enc *NavEncoder) EncodeRecord(rec NavRecord) error {
+	if enc.Header.RINEXVersion < 4 {
+		return fmt.Errorf("rinex: %s records require RINEX version 4: version %.2f", rec.RecordType(), enc.Header.RINEXVersion)
+	}
+	enc.writeCorrections(rec.GetPRN(), rec.GetTime())
+	return enc.writeRecord(rec)
+}
+
+// writeCorrections writes the header corrections of the satellite system as RINEX-4 records, with the
+// satellite prn if their transmitting satellite is unknown, and the ION records at the day of the time t.
+func (enc *NavEncoder) writeCorrections(prn gnss.PRN, t time.Time) {
+	rest := enc.corrs[:0]
+	for _, rec := range enc.corrs {
+		if rec.GetPRN().Sys != prn.Sys {
+			rest = append(rest, rec)
+			continue
+		}
+		switch rec := rec.(type) {
+		case *TimeOffset:
+			if rec.PRN.Num == 0 {
+				rec.PRN.Num = prn.Num
+			}
+		case *IonoModel:
+			if rec.PRN.Num == 0 {
+				rec.PRN.Num = prn.Num
+			}
+			rec.Time = time.Date(t.Year(), t.Month(), t.Day(), rec.Time.Hour(), 0, 0, 0, time.UTC)
+		}
+		enc.writeRecord(rec)
+	}
+	enc.corrs = rest
+}
+
+// writeRecord writes the RINEX-4 record.
+func (enc *NavEncoder) writeRecord(rec NavRecord) error {
+	switch rec := rec.(type) {
+	case *TimeOffset:
+		enc.writeLine(fmt.Sprintf("> %s %s %s", rec.RecordType(), rec.PRN, rec.MessageType))
+		enc.writeLine(fmt.Sprintf("    %s %-18s %-18s %-18s", rec.Time.Format("2006 01 02 15 04 05"), rec.Type, rec.SBASID, rec.UTCID))
+		enc.writeFloats(enc.indent(), rec.TransTime, rec.A0, rec.A1, rec.A2)
+	case *EarthOrientation:
+		enc.writeLine(fmt.Sprintf("> %s %s %s", rec.RecordType(), rec.PRN, rec.MessageType))
+		enc.writeFloats("    "+rec.Time.Format("2006 01 02 15 04 05"), rec.XP, rec.DXP, rec.DDXP)
+		enc.writeFloats(enc.indent()+strings.Repeat(" ", 19), rec.YP, rec.DYP, rec.DDYP)
+		enc.writeFloats(enc.indent(), rec.TransTime, rec.DUT1, rec.DDUT1, rec.DDDUT1)
+	case *IonoModel:
+		enc.writeLine(fmt.Sprintf("> %s %s %s", rec.RecordType(), rec.PRN, rec.MessageType))
+		params := rec.Params
+		n := min(3, len(params))
+		enc.writeFloats("    "+rec.Time.Format("2006 01 02 15 04 05"), params[:n]...)
+		for params = params[n:]; len(params) > 0; params = params[n:] {
+			n = min(4, len(params))
+			enc.writeFloats(enc.indent(), params[:n]...)
+		}
+	default:
+		return fmt.Errorf("rinex: unknown navigation record: %T", rec)
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer. In RINEX-4 an error is returned if there was no
+// ephemeris or record of the satellite system of a header correction, see NewNavEncoder.
+func (enc *NavEncoder) Flush() error {
+	if err := enc.w.Flush(); err != nil {
+		return err
+	}
+	if len(enc.corrs) > 0 {
+		return fmt.Errorf("rinex: no %s data to write the header corrections as RINEX-4 records", enc.corrs[0].GetPRN().Sys)
+	}
+	return nil
+}
+
+// indent returns the indentation of the data lines, 4X or 3X in RINEX-2.
+func (enc *NavEncoder) indent() string {
+	if enc.Header.RINEXVersion < 3 {
+		return "   "
+	}
+	return "    "
+}
+
+// writeFloats writes a data line with the prefix, e.g. PRN and TOC, followed by the floats.
+func (enc *NavEncoder) writeFloats(prefix string, vals ...float64) {
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	for _, val := range vals {
+		if enc.Header.RINEXVersion < 3 {
+			sb.WriteString(formatFloatv2(val, 19, 12))
+		} else {
+			fmt.Fprintf(&sb, "%19.12E", val)
+		}
+	}
+	enc.writeLine(sb.String())
+}
+
+// writeLine writes the line without trailing blanks.
+func (enc *NavEncoder) writeLine(line string) {
+	enc.w.WriteString(strings.TrimRight(line, " "))
+	enc.w.WriteByte('\n')
+}
+
+// formatFloatv2 formats the float in the Fortran D format of RINEX-2, e.g. D19.12 gives "-0.473249237984D-03".
+func formatFloatv2(val float64, width, prec int) string {
+	sign := " "
+	if val < 0 {
+		sign, val = "-", -val
+	}
+	// 4.73249237984E-04 is shifted to 0.473249237984D-03
+	s := strconv.FormatFloat(val, 'E', prec-1, 64)
+	mant, expStr, _ := strings.Cut(s, "E")
+	exp, _ := strconv.Atoi(expStr)
+	if val != 0 {
+		exp++
+	}
+	return fmt.Sprintf("%*s", width, fmt.Sprintf("%s0.%s%sD%+03d", sign, mant[:1], mant[2:], exp))
+}
+
+// ephRecord returns the RINEX-4 message type and the data fields of the ephemeris line by line, beginning with the
+// clock parameters of the first line. Spare fields within a line are zero, at the end of a line they are omitted.
+// The modernized messages, e.g. CNAV, require RINEX-4.
+func ephRecord(eph Eph, version float32) (msgType string, lines [][]float64, err error) {
+	modern := false
+	switch eph := eph.(type) {
+	case *EphGPS:
+		msgType = defaultMessageType(eph.MessageType, "LNAV")
+		if msgType == "CNAV" || msgType == "CNV2" {
+			isc := []float64{eph.ISCL1CA, eph.ISCL2C, eph.ISCL5I5, eph.ISCL5Q5}
+			if msgType == "CNV2" {
+				isc = []float64{eph.ISCL1CD, eph.ISCL1CP}
+			}
+			lines = [][]float64{
+				{eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate},
+				{eph.ADOT, eph.Crs, eph.DeltaN, eph.M0},
+				{eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA},
+				{eph.Top, eph.Cic, eph.Omega0, eph.Cis},
+				{eph.I0, eph.Crc, eph.Omega, eph.OmegaDot},
+				{eph.IDOT, eph.DeltaNDot, eph.URAINED0, eph.URAINED1},
+				{eph.URAIED, eph.Health, eph.TGD, eph.URAINED2},
+				isc,
+				{eph.Tom, eph.WNop},
+			}
+			modern = true
+			break
+		}
+		lines = [][]float64{
+			{eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate},
+			{eph.IODE, eph.Crs, eph.DeltaN, eph.M0},
+			{eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA},
+			{eph.Toe, eph.Cic, eph.Omega0, eph.Cis},
+			{eph.I0, eph.Crc, eph.Omega, eph.OmegaDot},
+			{eph.IDOT, eph.L2Codes, eph.ToeWeek, eph.L2PFlag},
+			{eph.URA, eph.Health, eph.TGD, eph.IODC},
+			{eph.Tom, eph.FitInterval},
+		}
+	case *EphGLO:
+		msgType = defaultMessageType(eph.MessageType, "FDMA")
+		lines = [][]float64{
+			{eph.ClockBias, eph.RelFreqBias, eph.FrameTime},
+			{eph.X, eph.VelX, eph.AccX, eph.Health},
+			{eph.Y, eph.VelY, eph.AccY, eph.FreqNum},
+			{eph.Z, eph.VelZ, eph.AccZ, eph.AgeOpInfo},
+		}
+		if version >= 3.05 {
+			lines = append(lines, []float64{eph.StatusFlags, eph.DelayL1L2, eph.URAI, eph.HealthFlags})
+		}
+	case *EphGAL:
+		msgType = "INAV"
+		if eph.IsFNAV() {
+			msgType = "FNAV"
+		}
+		lines = [][]float64{
+			{eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate},
+			{eph.IODnav, eph.Crs, eph.DeltaN, eph.M0},
+			{eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA},
+			{eph.Toe, eph.Cic, eph.Omega0, eph.Cis},
+			{eph.I0, eph.Crc, eph.Omega, eph.OmegaDot},
+			{eph.IDOT, eph.DataSources, eph.ToeWeek},
+			{eph.SISA, eph.Health, eph.BGDE5aE1, eph.BGDE5bE1},
+			{eph.Tom},
+		}
+	case *EphQZSS:
+		msgType = defaultMessageType(eph.MessageType, "LNAV")
+		if msgType == "CNAV" || msgType == "CNV2" {
+			isc := []float64{eph.ISCL1CA, eph.ISCL2C, eph.ISCL5I5, eph.ISCL5Q5}
+			if msgType == "CNV2" {
+				isc = []float64{eph.ISCL1CD, eph.ISCL1CP}
+			}
+			lines = [][]float64{
+				{eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate},
+				{eph.ADOT, eph.Crs, eph.DeltaN, eph.M0},
+				{eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA},
+				{eph.Top, eph.Cic, eph.Omega0, eph.Cis},
+				{eph.I0, eph.Crc, eph.Omega, eph.OmegaDot},
+				{eph.IDOT, eph.DeltaNDot, eph.URAINED0, eph.URAINED1},
+				{eph.URAIED, eph.Health, eph.TGD, eph.URAINED2},
+				isc,
+				{eph.Tom, eph.WNop},
+			}
+			modern = true
+			break
+		}
+		lines = [][]float64{
+			{eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate},
+			{eph.IODE, eph.Crs, eph.DeltaN, eph.M0},
+			{eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA},
+			{eph.Toe, eph.Cic, eph.Omega0, eph.Cis},
+			{eph.I0, eph.Crc, eph.Omega, eph.OmegaDot},
+			{eph.IDOT, eph.L2Codes, eph.ToeWeek, eph.L2PFlag},
+			{eph.URA, eph.Health, eph.TGD, eph.IODC},
+			{eph.Tom, eph.FitInterval},
+		}
+	case *EphBDS:
+		d := "D1"
+		if eph.IsGEO() {
+			d = "D2"
+		}
+		msgType = defaultMessageType(eph.MessageType, d)
+		if msgType == "CNV1" || msgType == "CNV2" || msgType == "CNV3" {
+			lines = [][]float64{
+				{eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate},
+				{eph.ADOT, eph.Crs, eph.DeltaN, eph.M0},
+				{eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA},
+				{eph.Toe, eph.Cic, eph.Omega0, eph.Cis},
+				{eph.I0, eph.Crc, eph.Omega, eph.OmegaDot},
+				{eph.IDOT, eph.DeltaNDot, eph.SatType, eph.Top},
+				{eph.SISAIOE, eph.SISAIOCB, eph.SISAIOC1, eph.SISAIOC2},
+			}
+			integrity, last := []float64{eph.SISMAI, eph.Health, eph.IntegrityFlags, eph.IODC}, []float64{eph.Tom, 0, 0, eph.IODE}
+			switch msgType {
+			case "CNV1":
+				lines = append(lines, []float64{eph.ISCB1CD, 0, eph.TGDB1CP, eph.TGDB2AP}, integrity, last)
+			case "CNV2":
+				lines = append(lines, []float64{0, eph.ISCB2AD, eph.TGDB1CP, eph.TGDB2AP}, integrity, last)
+			default:
+				lines = append(lines, []float64{eph.SISMAI, eph.Health, eph.IntegrityFlags, eph.TGDB2BI}, []float64{eph.Tom})
+			}
+			modern = true
+			break
+		}
+		lines = [][]float64{
+			{eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate},
+			{eph.AODE, eph.Crs, eph.DeltaN, eph.M0},
+			{eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA},
+			{eph.Toe, eph.Cic, eph.Omega0, eph.Cis},
+			{eph.I0, eph.Crc, eph.Omega, eph.OmegaDot},
+			{eph.IDOT, 0, eph.ToeWeek},
+			{eph.URA, eph.Health, eph.TGD1, eph.TGD2},
+			{eph.Tom, eph.AODC},
+		}
+	case *EphNavIC:
+		msgType = defaultMessageType(eph.MessageType, "LNAV")
+		if msgType == "L1NV" {
+			lines = [][]float64{
+				{eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate},
+				{eph.ADOT, eph.Crs, eph.DeltaN, eph.M0},
+				{eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA},
+				{eph.Toe, eph.Cic, eph.Omega0, eph.Cis},
+				{eph.I0, eph.Crc, eph.Omega, eph.OmegaDot},
+				{eph.IDOT, eph.DeltaNDot, eph.ToeWeek},
+				{eph.URA, eph.Health, eph.TGD, eph.ISCS},
+				{eph.ISCL1P, eph.ISCL1D},
+				{eph.Tom},
+			}
+			modern = true
+			break
+		}
+		lines = [][]float64{
+			{eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate},
+			{eph.IODEC, eph.Crs, eph.DeltaN, eph.M0},
+			{eph.Cuc, eph.Ecc, eph.Cus, eph.SqrtA},
+			{eph.Toe, eph.Cic, eph.Omega0, eph.Cis},
+			{eph.I0, eph.Crc, eph.Omega, eph.OmegaDot},
+			{eph.IDOT, 0, eph.ToeWeek},
+			{eph.URA, eph.Health, eph.TGD},
+			{eph.Tom},
+		}
+	case *EphSBAS:
+		msgType = defaultMessageType(eph.MessageType, "SBAS")
+		lines = [][]float64{
+			{eph.ClockBias, eph.RelFreqBias, eph.FrameTime},
+			{eph.X, eph.VelX, eph.AccX, eph.Health},
+			{eph.Y, eph.VelY, eph.AccY, eph.URA},
+			{eph.Z, eph.VelZ, eph.AccZ, eph.IODN},
+		}
+	default:
+		return "", nil, fmt.Errorf("rinex: unknown ephemeris: %T", eph)
+	}
+	switch {
+	case modern && version < 4:
+		return "", nil, fmt.Errorf("rinex: %s: the %s message requires RINEX version 4: version %.2f", eph.GetPRN(), msgType, version)
+	case !modern && !isLegacyMessage(msgType):
+		return "", nil, fmt.Errorf("rinex: %s: encoding of the %s message not supported", eph.GetPRN(), msgType)
+	}
+	return msgType, lines, nil
+}
+
+// defaultMessageType returns the message type, or the default message type of the system if it is not set.
+func defaultMessageType(msgType, def string) string {
+	if msgType == "" {
+		return def
+	}
+	return msgType
+}
@@ -0,0 +1,337 @@
+package rinex
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/stretchr/testify/assert"
+)
+
+// reencode decodes the navigation data and encodes it again with the header hdr,
+// the records first and then the ephemerides. Before RINEX-4 the records are added to the header.
+func reencode(t *testing.T, data string, hdr func(NavHeader) NavHeader) (string, *NavDecoder) {
+	t.Helper()
+	dec, err := NewNavDecoder(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ephs := readAllEphemerides(t, dec)
+
+	h, recs := hdr(dec.Header), dec.Records()
+	if h.RINEXVersion < 4 {
+		if err := h.AddCorrections(recs); err != nil {
+			t.Fatal(err)
+		}
+		recs = nil
+	}
+	var buf bytes.Buffer
+	enc, err := NewNavEncoder(&buf, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range recs {
+		if err := enc.EncodeRecord(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, eph := range ephs {
+		if err := enc.Encode(eph); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), dec
+}
+
+// trimLines removes the trailing blanks of the lines.
+func trimLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func keep(hdr NavHeader) NavHeader { return hdr }
+
+func TestNavEncoder_roundTrip(t *testing.T) {
+	data, err := os.ReadFile("testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "v2", data: navDataV2},
+		{name: "v3", data: string(data)},
+		{name: "v4", data: navDataV4},
+		{name: "v4 modernized", data: navDataV4 + navDataV4Modern},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := reencode(t, tt.data, keep)
+			assert.Equal(t, trimLines(tt.data), got)
+		})
+	}
+}
+
+// Convert RINEX-3 to RINEX-4 and back.
+func TestNavEncoder_convert(t *testing.T) {
+	assert := assert.New(t)
+	data, err := os.ReadFile("testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v4, _ := reencode(t, string(data), func(hdr NavHeader) NavHeader {
+		hdr.RINEXVersion = 4.01
+		return hdr
+	})
+	assert.Contains(v4, "> EPH G02 LNAV\nG02 2020 06 17 00 00 00")
+	assert.Contains(v4, "> EPH E01 INAV\nE01 2020 06 16 23 30 00")
+	assert.Contains(v4, "> EPH E01 FNAV\nE01 2020 06 16 23 30 00")
+	assert.Contains(v4, "> EPH C19 D1\n")
+	assert.Contains(v4, "> EPH R02 FDMA\n")
+	assert.Contains(v4, "> EPH S31 SBAS\n")
+	assert.NotContains(v4, "IONOSPHERIC CORR")
+	// The header corrections are records of the first satellite of their system.
+	assert.Contains(v4, `> ION G02 LNAV
+    2020 06 17 00 00 00 5.587900000000E-09 1.490100000000E-08-5.960500000000E-08
+    -1.192100000000E-07 8.396800000000E+04 9.830400000000E+04-6.553600000000E+04
+    -5.242900000000E+05 0.000000000000E+00
+> STO G02 LNAV
+    2020 06 19 19 56 48 GPUT
+     9.999000000000E+08 3.492459654800E-10-1.154631946000E-14 0.000000000000E+00
+> EPH G02 LNAV
+`)
+	assert.Contains(v4, `> ION E01 IFNV
+    2020 06 16 00 00 00 2.375000000000E+01 1.562500000000E-02 1.232900000000E-02
+     0.000000000000E+00
+> STO E01 IFNV
+    2020 06 17 00 00 00 GAUT
+`)
+
+	v3, _ := reencode(t, v4, func(hdr NavHeader) NavHeader {
+		hdr.RINEXVersion = 3.04
+		return hdr
+	})
+	assert.Equal(trimLines(string(data)), v3)
+}
+
+func TestNavEncoder_headerCorrections(t *testing.T) {
+	assert := assert.New(t)
+	hdr := NavHeader{RINEXVersion: 4.01, RINEXType: "N", SatSystem: gnss.SysMIXED,
+		IonoCorrs: []IonoCorr{{Type: "BDSA", Params: [4]float64{1, 2, 3, 4}, TimeMark: "C", SVID: 6}}}
+	var buf bytes.Buffer
+	enc, err := NewNavEncoder(&buf, hdr)
+	assert.NoError(err)
+	assert.Error(enc.Flush(), "no BDS data")
+
+	assert.Error(hdr.Write(&bytes.Buffer{}), "no RINEX-4 header record")
+	hdr.RINEXVersion, hdr.SatSystem = 2.11, gnss.SysGPS
+	assert.Error(hdr.Write(&bytes.Buffer{}), "BDS in RINEX-2")
+
+	_, dec := reencode(t, navDataV4, keep)
+	hdr = NavHeader{RINEXVersion: 3.04}
+	assert.Error(hdr.AddCorrections(dec.Records()), "EOP")
+	recs := slices.DeleteFunc(dec.Records(), func(rec NavRecord) bool { return rec.RecordType() == NavRecordTypeEOP })
+	assert.NoError(hdr.AddCorrections(recs))
+	assert.Equal([]string{"GPSA", "GPSB", "GAL"}, []string{hdr.IonoCorrs[0].Type, hdr.IonoCorrs[1].Type, hdr.IonoCorrs[2].Type})
+	assert.Equal([]TimeSystemCorr{{Type: "GPUT", A0: 3.4924596548e-10, A1: -1.154631946e-14, T: 259200, W: 2110, UTCID: 2}}, hdr.TimeCorrs)
+}
+
+func TestNavEncoder_Records(t *testing.T) {
+	assert := assert.New(t)
+	_, dec := reencode(t, navDataV4, keep)
+	recs := dec.Records()
+	if !assert.Len(recs, 4) {
+		return
+	}
+
+	g02 := gnss.PRN{Sys: gnss.SysGPS, Num: 2}
+	epoch := time.Date(2020, 6, 17, 0, 0, 0, 0, time.UTC)
+	assert.Equal(&TimeOffset{PRN: g02, MessageType: "LNAV", Time: epoch, Type: "GPUT", UTCID: "UTC(USNO)",
+		TransTime: 503808, A0: 3.4924596548e-10, A1: -1.154631946e-14}, recs[0])
+	assert.Equal(&EarthOrientation{PRN: g02, MessageType: "CNAV", Time: epoch, XP: 1.105880737305e-01, DXP: 1.033234596252e-03,
+		YP: 3.887615203857e-01, DYP: 2.079963684082e-03, TransTime: 259200, DUT1: -2.447187900543e-01, DDUT1: 3.557205200195e-04}, recs[1])
+	if ion, ok := recs[2].(*IonoModel); assert.True(ok) {
+		assert.Equal(NavRecordTypeION, ion.RecordType())
+		assert.Len(ion.Params, 9, "Klobuchar")
+		assert.Equal(-5.24288e5, ion.Params[7])
+	}
+	if ion, ok := recs[3].(*IonoModel); assert.True(ok) {
+		assert.Equal([]float64{23.75, 1.5625e-02, 1.23291015625e-02, 0}, ion.Params, "NeQuick-G")
+	}
+
+	var buf bytes.Buffer
+	enc, err := NewNavEncoder(&buf, NavHeader{RINEXVersion: 3.04, RINEXType: "N", SatSystem: gnss.SysMIXED})
+	assert.NoError(err)
+	assert.Error(enc.EncodeRecord(recs[0]), "RINEX-3")
+}
+
+func TestNavEncoder_Encode(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	_, err := NewNavEncoder(&buf, NavHeader{RINEXVersion: 2.11, RINEXType: "N", SatSystem: gnss.SysMIXED})
+	assert.Error(err, "mixed RINEX-2")
+
+	buf.Reset()
+	enc, err := NewNavEncoder(&buf, NavHeader{RINEXVersion: 2.11, RINEXType: "N", SatSystem: gnss.SysGPS})
+	assert.NoError(err)
+	assert.Error(enc.Encode(&EphGAL{PRN: gnss.PRN{Sys: gnss.SysGAL, Num: 1}}), "system of RINEX-2")
+
+	enc, err = NewNavEncoder(&buf, NavHeader{RINEXVersion: 3.05, RINEXType: "N", SatSystem: gnss.SysMIXED})
+	assert.NoError(err)
+	assert.Error(enc.Encode(&EphGPS{PRN: gnss.PRN{Sys: gnss.SysGPS, Num: 1}, MessageType: "CNAV"}), "CNAV in RINEX-3")
+
+	enc, err = NewNavEncoder(&buf, NavHeader{RINEXVersion: 4.01, RINEXType: "N", SatSystem: gnss.SysMIXED})
+	assert.NoError(err)
+	assert.NoError(enc.Encode(&EphGPS{PRN: gnss.PRN{Sys: gnss.SysGPS, Num: 1}, MessageType: "CNAV"}))
+	assert.Error(enc.Encode(&EphGPS{PRN: gnss.PRN{Sys: gnss.SysGPS, Num: 1}, MessageType: "CNV1"}), "unknown message")
+}
+
+func Test_formatFloatv2(t *testing.T) {
+	tests := []struct {
+		val         float64
+		width, prec int
+		want        string
+	}{
+		{val: -4.732492379844e-04, width: 19, prec: 12, want: "-0.473249237984D-03"},
+		{val: 2.592e5, width: 19, prec: 12, want: " 0.259200000000D+06"},
+		{val: 0, width: 19, prec: 12, want: " 0.000000000000D+00"},
+		{val: 0.99999999999999, width: 19, prec: 12, want: " 0.100000000000D+01"},
+		{val: 5.5879e-09, width: 12, prec: 4, want: "  0.5588D-08"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatFloatv2(tt.val, tt.width, tt.prec))
+	}
+}
+
+const navDataV2 = `     2.11           N: GPS NAV DATA                         RINEX VERSION / TYPE
+sbf2rin-13.4.3                          20200618 001127 UTC PGM / RUN BY / DATE
+    0.5588D-08  0.1490D-07 -0.5960D-07 -0.1192D-06          ION ALPHA
+    0.8397D+05  0.9830D+05 -0.6554D+05 -0.5243D+06          ION BETA
+    0.349245965480D-09-0.115463194600D-13   503808     2110 DELTA-UTC: A0,A1,T,W
+    18                                                      LEAP SECONDS
+                                                            END OF HEADER
+ 2 20  6 17  0  0  0.0-0.473249237984D-03-0.591171556152D-11 0.000000000000D+00
+    0.730000000000D+02-0.353125000000D+02 0.444125642473D-08-0.150504395521D+01
+   -0.139325857163D-05 0.196952408878D-01 0.981055200100D-05 0.515372329903D+04
+    0.259200000000D+06 0.447034835815D-07 0.262209955514D+01 0.493600964546D-06
+    0.959644249698D+00 0.190062500000D+03-0.162373849676D+01-0.790211486909D-08
+    0.230723896291D-09 0.100000000000D+01 0.211000000000D+04 0.000000000000D+00
+    0.200000000000D+01 0.000000000000D+00-0.176951289177D-07 0.730000000000D+02
+    0.252018000000D+06 0.400000000000D+01
+`
+
+const navDataV4 = `     4.01           NAVIGATION DATA     M                   RINEX VERSION / TYPE
+sbf2rin-13.4.3      BKG                 20200618 001127 UTC PGM / RUN BY / DATE
+Merged navigation file of AREG                              COMMENT
+https://doi.org/10.57677/BRD400DLR                          DOI
+        2                                                   MERGED FILE
+    18    18  1929     7                                    LEAP SECONDS
+                                                            END OF HEADER
+> STO G02 LNAV
+    2020 06 17 00 00 00 GPUT                                  UTC(USNO)
+     5.038080000000E+05 3.492459654800E-10-1.154631946000E-14 0.000000000000E+00
+> EOP G02 CNAV
+    2020 06 17 00 00 00 1.105880737305E-01 1.033234596252E-03 0.000000000000E+00
+                        3.887615203857E-01 2.079963684082E-03 0.000000000000E+00
+     2.592000000000E+05-2.447187900543E-01 3.557205200195E-04 0.000000000000E+00
+> ION G02 LNAV
+    2020 06 17 00 00 00 5.587935447693E-09 1.490116119385E-08-5.960464477539E-08
+    -1.192092895508E-07 8.396800000000E+04 9.830400000000E+04-6.553600000000E+04
+    -5.242880000000E+05 0.000000000000E+00
+> ION E01 IFNV
+    2020 06 16 23 30 00 2.375000000000E+01 1.562500000000E-02 1.232910156250E-02
+     0.000000000000E+00
+> EPH G02 LNAV
+G02 2020 06 17 00 00 00-4.732492379844E-04-5.911715561524E-12 0.000000000000E+00
+     7.300000000000E+01-3.531250000000E+01 4.441256424728E-09-1.505043955213E+00
+    -1.393258571625E-06 1.969524088781E-02 9.810552000999E-06 5.153723299026E+03
+     2.592000000000E+05 4.470348358154E-08 2.622099555143E+00 4.936009645462E-07
+     9.596442496978E-01 1.900625000000E+02-1.623738496757E+00-7.902114869088E-09
+     2.307238962907E-10 1.000000000000E+00 2.110000000000E+03 0.000000000000E+00
+     2.000000000000E+00 0.000000000000E+00-1.769512891769E-08 7.300000000000E+01
+     2.520180000000E+05 4.000000000000E+00
+> EPH R02 FDMA
+R02 2020 06 16 23 45 00 4.319325089455E-04 1.818989403546E-12 2.574000000000E+05
+    -1.896841796875E+03 7.037382125854E-01 9.313225746155E-10 0.000000000000E+00
+    -2.086132714844E+04 1.870455741882E+00 0.000000000000E+00-4.000000000000E+00
+     1.464041699219E+04 2.759790420532E+00-9.313225746155E-10 0.000000000000E+00
+     0.000000000000E+00 9.999999999990E+08 0.000000000000E+00 0.000000000000E+00
+> EPH E01 INAV
+E01 2020 06 16 23 30 00-8.792143198662E-04-7.901235221652E-12 0.000000000000E+00
+     4.500000000000E+01 1.352187500000E+02 2.709398571611E-09 6.610656584610E-01
+     6.422400474548E-06 8.445885032415E-05 4.425644874573E-06 5.440607093811E+03
+     2.574000000000E+05-7.264316082001E-08 3.363753502635E-01 7.636845111847E-08
+     9.828940541683E-01 2.570312500000E+02-2.999066396799E+00-5.532730460432E-09
+     1.921508609975E-10 5.170000000000E+02 2.110000000000E+03
+     3.120000000000E+00 0.000000000000E+00-1.862645149231E-09-2.095475792885E-09
+     2.580950000000E+05
+`
+
+// cnavG01 is a GPS CNAV ephemeris of RINEX-4.
+const cnavG01 = `> EPH G01 CNAV
+G01 2020 06 17 00 00 00-4.732492379844E-04-5.911715561524E-12 0.000000000000E+00
+     1.281738281250E-02-3.531250000000E+01 4.441256424728E-09-1.505043955213E+00
+    -1.393258571625E-06 1.969524088781E-02 9.810552000999E-06 5.153723299026E+03
+     2.574000000000E+05 4.470348358154E-08 2.622099555143E+00 4.936009645462E-07
+     9.596442496978E-01 1.900625000000E+02-1.623738496757E+00-7.902114869088E-09
+     2.307238962907E-10-1.297000000000E-14 0.000000000000E+00-3.000000000000E+00
+     1.000000000000E+00 0.000000000000E+00-1.769512891769E-08 2.000000000000E+00
+     1.164153218269E-10-1.862645149231E-09 3.491202369332E-09 3.579864278436E-09
+     2.574180000000E+05 2.110000000000E+03
+`
+
+// navDataV4Modern are ephemerides of the modernized messages of RINEX-4, to be appended to navDataV4.
+const navDataV4Modern = cnavG01 + `> EPH J02 CNV2
+J02 2020 06 17 00 00 00 1.204460859299E-04 1.136868377216E-13 0.000000000000E+00
+    -4.394531250000E-03 4.534375000000E+02 1.786503271961E-09 1.223424195004E+00
+    -1.571327447891E-05 7.471898361109E-02 1.016817986965E-05 6.493104301453E+03
+     2.574000000000E+05 1.434236764908E-07 2.118613135815E+00-1.329928636551E-06
+     7.091062831879E-01-3.156250000000E+01-1.567286610603E+00-3.078356770614E-09
+     1.800075010895E-10 2.101430000000E-14 0.000000000000E+00 1.000000000000E+00
+     0.000000000000E+00 0.000000000000E+00-4.656612873077E-09 0.000000000000E+00
+    -5.820766091347E-10 1.164153218269E-10
+     2.574180000000E+05 2.110000000000E+03
+> EPH C45 CNV1
+C45 2022 11 29 12 00 00-4.519214760512E-04-1.154631945610E-13 0.000000000000E+00
+     1.000000000000E+00 2.306250000000E+01 3.506932217166E-09 2.094412207603E+00
+     5.606561899185E-07 6.217302381992E-04 1.081265509129E-05 5.282626131058E+03
+     2.160000000000E+05-1.024454832077E-08 1.021580517292E+00 3.818422555923E-08
+     9.608811736107E-01 1.594687500000E+02-1.095289707184E+00-6.617775363638E-09
+    -3.353711082489E-10 0.000000000000E+00 3.000000000000E+00 2.160000000000E+05
+     0.000000000000E+00 0.000000000000E+00 0.000000000000E+00 0.000000000000E+00
+    -2.910383045673E-09 0.000000000000E+00 3.201421350241E-09-1.140497624874E-08
+     0.000000000000E+00 0.000000000000E+00 0.000000000000E+00 8.000000000000E+00
+     2.160000000000E+05 0.000000000000E+00 0.000000000000E+00 8.000000000000E+00
+> EPH C45 CNV3
+C45 2022 11 29 12 00 00-4.519214760512E-04-1.154631945610E-13 0.000000000000E+00
+     1.000000000000E+00 2.306250000000E+01 3.506932217166E-09 2.094412207603E+00
+     5.606561899185E-07 6.217302381992E-04 1.081265509129E-05 5.282626131058E+03
+     2.160000000000E+05-1.024454832077E-08 1.021580517292E+00 3.818422555923E-08
+     9.608811736107E-01 1.594687500000E+02-1.095289707184E+00-6.617775363638E-09
+    -3.353711082489E-10 0.000000000000E+00 3.000000000000E+00 2.160000000000E+05
+     0.000000000000E+00 0.000000000000E+00 0.000000000000E+00 0.000000000000E+00
+     0.000000000000E+00 0.000000000000E+00 0.000000000000E+00-6.984919309616E-09
+     2.160000000000E+05
+> EPH I10 L1NV
+I10 2022 11 29 12 00 00 3.062617126852E-04 1.728039933369E-11 0.000000000000E+00
+     2.441406250000E-04-6.434375000000E+02-3.039412282675E-09 2.512541157417E+00
+    -2.055615186691E-05 2.099117194302E-03 1.204758882523E-05 6.493593645096E+03
+     2.160000000000E+05-1.117587089539E-08 1.554370021820E+00 3.911554813385E-08
+     4.889512136557E-01-3.015625000000E+02-1.633461167407E+00-2.860475337282E-09
+     1.039328489639E-10 1.500000000000E-14 2.238000000000E+03
+     2.000000000000E+00 0.000000000000E+00-1.396983861923E-09 2.328306436539E-10
+    -4.656612873077E-10 6.984919309616E-10
+     2.172480000000E+05
+`
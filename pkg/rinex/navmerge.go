@@ -0,0 +1,341 @@
+package rinex
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// RemovalReason specifies why MergeNav removed an ephemeris.
+type RemovalReason string
+
+// The reasons for removing an ephemeris.
+const (
+	RemovedDuplicate    RemovalReason = "duplicate"    // The ephemeris was already given by another file or upload.
+	RemovedInconsistent RemovalReason = "inconsistent" // A duplicate with the same issue of data but different parameters.
+	RemovedUnhealthy    RemovalReason = "unhealthy"    // The satellite is flagged unhealthy.
+	RemovedOutlier      RemovalReason = "outlier"      // The orbit disagrees with the neighbouring ephemerides of the satellite.
+	RemovedUnsupported  RemovalReason = "unsupported"  // A modernized RINEX-4 message, e.g. CNAV, in an older version.
+)
+
+// NavMergeOptions for merging RINEX navigation files.
+type NavMergeOptions struct {
+	// The RINEX version of the merged file. By default the highest version of the input files,
+	// at least 3.04 if RINEX-2 files of different satellite systems are merged.
+	RINEXVersion float32
+
+	// Keep the ephemerides of unhealthy satellites.
+	Unhealthy bool
+
+	// MaxOrbitDiff is the maximum difference in m of the satellite position of an ephemeris to the positions of
+	// its preceding and succeeding ephemerides at its reference time, e.g. 500. An ephemeris that differs from both
+	// is an outlier. 0 disables the outlier check.
+	MaxOrbitDiff float64
+}
+
+// RemovedEph is an ephemeris removed by MergeNav for another reason than being a duplicate.
+type RemovedEph struct {
+	PRN    gnss.PRN      `json:"prn"`
+	TOC    time.Time     `json:"toc"`
+	File   int           `json:"file"` // The number of the input file, starting with 1.
+	Reason RemovalReason `json:"reason"`
+}
+
+// NavMergeStats reports the ephemerides removed by MergeNav and why.
+type NavMergeStats struct {
+	NumFiles  int                   `json:"numFiles"`  // The number of input files.
+	NumInput  int                   `json:"numInput"`  // The number of ephemerides read.
+	NumOutput int                   `json:"numOutput"` // The number of ephemerides written.
+	Removed   map[RemovalReason]int `json:"removed"`   // The number of removed ephemerides per reason.
+	Flagged   []RemovedEph          `json:"flagged"`   // The removed ephemerides, except for the plain duplicates.
+
+	// The number of RINEX-4 records without header record in the RINEX version of the merged file, e.g. EOP,
+	// see NavHeader.AddCorrections.
+	UnsupportedRecords int `json:"unsupportedRecords"`
+}
+
+// remove counts the removed ephemeris.
+func (stats *NavMergeStats) remove(e *mergeEph, reason RemovalReason) {
+	stats.Removed[reason]++
+	if reason != RemovedDuplicate {
+		stats.Flagged = append(stats.Flagged, RemovedEph{PRN: e.eph.GetPRN(), TOC: e.eph.GetTime(), File: e.file, Reason: reason})
+	}
+}
+
+// mergeEph is an ephemeris of an input file.
+type mergeEph struct {
+	eph    Eph
+	file   int
+	params []float64
+	ephMeta
+}
+
+// mergeKey identifies the duplicates of an ephemeris.
+type mergeKey struct {
+	prn     gnss.PRN
+	toc     time.Time
+	msgType string
+	iod     int
+}
+
+// MergeNav merges the navigation data of several files, e.g. of many stations into a daily multi-GNSS BRDC file,
+// and writes the merged data to w, ordered by satellite and time.
+//
+// Ephemerides with the same PRN, TOC, message type and issue of data are duplicates, of which one is kept.
+// Duplicates with different parameters, besides the transmission time, are inconsistent: the parameters given
+// by most files are kept, on a tie those of the earlier file. Unhealthy satellites and outliers are removed,
+// see NavMergeOptions. The satellite health is evaluated as in EphStore.
+//
+// The header of the first file is merged with the others: the ionospheric and time system corrections are
+// united per correction type, keeping the latest time system corrections, the leap seconds are the maximum
+// and MergedFiles is set to the number of input files. The RINEX-4 STO, EOP and ION records are merged likewise.
+// The corrections are written as records in RINEX-4 and the records as corrections in older versions, see
+// NavHeader.AddCorrections; the records without header record are counted as unsupported.
+//
+// The merged header and the report of the removed ephemerides are returned.
+func MergeNav(w io.Writer, decs []*NavDecoder, opts NavMergeOptions) (NavHeader, *NavMergeStats, error) {
+	if len(decs) == 0 {
+		return NavHeader{}, nil, fmt.Errorf("rinex: merge: no input")
+	}
+	stats := &NavMergeStats{NumFiles: len(decs), Removed: map[RemovalReason]int{}}
+	hdr := mergeNavHeaders(decs, opts)
+
+	groups := map[mergeKey][][]*mergeEph{} // the variants of the duplicates
+	keys := []mergeKey{}
+	records := []NavRecord{}
+	seenRecords := map[string]bool{}
+	for i, dec := range decs {
+		for dec.NextEphemeris() {
+			eph := dec.Ephemeris()
+			stats.NumInput++
+			e := &mergeEph{eph: eph, file: i + 1}
+			msgType, _, err := ephRecord(eph, hdr.RINEXVersion)
+			if err != nil {
+				stats.remove(e, RemovedUnsupported)
+				continue
+			}
+			e.params, e.ephMeta = ephParams(eph), newEphMeta(eph)
+
+			key := mergeKey{prn: eph.GetPRN(), toc: eph.GetTime(), msgType: msgType, iod: e.iod}
+			variants, ok := groups[key]
+			if !ok {
+				keys = append(keys, key)
+			}
+			j := slices.IndexFunc(variants, func(v []*mergeEph) bool { return equalParams(v[0].params, e.params) })
+			if j < 0 {
+				groups[key] = append(variants, []*mergeEph{e})
+			} else {
+				variants[j] = append(variants[j], e)
+			}
+		}
+		if err := dec.Err(); err != nil {
+			return NavHeader{}, nil, fmt.Errorf("rinex: merge: file %d: %w", i+1, err)
+		}
+		for _, rec := range dec.Records() {
+			id := fmt.Sprintf("%s %s %s %s", rec.RecordType(), rec.GetPRN(), rec.GetTime(), recordMessageType(rec))
+			if !seenRecords[id] {
+				seenRecords[id] = true
+				records = append(records, rec)
+			}
+		}
+	}
+
+	// Keep the most frequent variant of the duplicates.
+	ephs := map[gnss.PRN][]*mergeEph{}
+	for _, key := range keys {
+		variants := groups[key]
+		best := 0
+		for j, v := range variants {
+			if len(v) > len(variants[best]) {
+				best = j
+			}
+		}
+		for j, v := range variants {
+			for k, e := range v {
+				switch {
+				case j != best:
+					stats.remove(e, RemovedInconsistent)
+				case k > 0:
+					stats.remove(e, RemovedDuplicate)
+				}
+			}
+		}
+		e := variants[best][0]
+		if !e.healthy && !opts.Unhealthy {
+			stats.remove(e, RemovedUnhealthy)
+			continue
+		}
+		ephs[key.prn] = append(ephs[key.prn], e)
+	}
+
+	prns := make([]gnss.PRN, 0, len(ephs))
+	for prn, list := range ephs {
+		slices.SortStableFunc(list, func(a, b *mergeEph) int { return a.eph.GetTime().Compare(b.eph.GetTime()) })
+		prns = append(prns, prn)
+	}
+	slices.SortFunc(prns, comparePRN)
+
+	if hdr.RINEXVersion < 4 {
+		for _, rec := range records {
+			if err := hdr.AddCorrections([]NavRecord{rec}); err != nil {
+				stats.UnsupportedRecords++
+			}
+		}
+		records = nil
+	}
+	enc, err := NewNavEncoder(w, hdr)
+	if err != nil {
+		return NavHeader{}, nil, err
+	}
+	for _, rec := range records {
+		if err := enc.EncodeRecord(rec); err != nil {
+			return NavHeader{}, nil, err
+		}
+	}
+	for _, prn := range prns {
+		list := ephs[prn]
+		outliers := findOutliers(list, opts.MaxOrbitDiff)
+		for i, e := range list {
+			if outliers[i] {
+				stats.remove(e, RemovedOutlier)
+				continue
+			}
+			if err := enc.Encode(e.eph); err != nil {
+				return NavHeader{}, nil, err
+			}
+			stats.NumOutput++
+		}
+	}
+	return hdr, stats, enc.Flush()
+}
+
+// mergeNavHeaders merges the headers of the decoders into the header of the merged file.
+func mergeNavHeaders(decs []*NavDecoder, opts NavMergeOptions) NavHeader {
+	first := &decs[0].Header
+	hdr := *first
+	hdr.Comments = slices.Clone(first.Comments)
+	hdr.IonoCorrs, hdr.TimeCorrs = nil, nil
+	hdr.Labels = nil
+
+	for _, dec := range decs {
+		h := &dec.Header
+		if h.RINEXVersion > hdr.RINEXVersion {
+			hdr.RINEXVersion = h.RINEXVersion
+		}
+		if h.SatSystem != hdr.SatSystem {
+			hdr.SatSystem = gnss.SysMIXED
+		}
+		for _, corr := range h.IonoCorrs {
+			hdr.addIonoCorr(corr)
+		}
+		for _, corr := range h.TimeCorrs {
+			hdr.addTimeCorr(corr)
+		}
+		if h.LeapSeconds.Current > hdr.LeapSeconds.Current {
+			hdr.LeapSeconds = h.LeapSeconds
+		}
+	}
+
+	if hdr.RINEXVersion < 3 && hdr.SatSystem == gnss.SysMIXED {
+		hdr.RINEXVersion = 3.04
+	}
+	if opts.RINEXVersion != 0 {
+		hdr.RINEXVersion = opts.RINEXVersion
+	}
+	hdr.RINEXType = "N"
+	hdr.MergedFiles = len(decs)
+	hdr.Comments = append(hdr.Comments, fmt.Sprintf("MERGED FROM %d FILES", len(decs)))
+	return hdr
+}
+
+// ephParams returns the parameters of the ephemeris without the transmission time, which differs between
+// the stations that received the same upload, and without the GLONASS status line of RINEX 3.05.
+func ephParams(eph Eph) []float64 {
+	_, lines, err := ephRecord(eph, 4)
+	if err != nil {
+		return nil
+	}
+	switch eph.(type) {
+	case *EphGLO:
+		lines = lines[:4]
+		lines[0][2] = 0
+	case *EphSBAS:
+		lines[0][2] = 0
+	default:
+		lines[len(lines)-1][0] = 0
+	}
+	return slices.Concat(lines...)
+}
+
+// equalParams reports whether the parameters are equal within the precision of the RINEX formats.
+func equalParams(p1, p2 []float64) bool {
+	if len(p1) != len(p2) {
+		return false
+	}
+	for i := range p1 {
+		if math.Abs(p1[i]-p2[i]) > 1e-10*math.Max(math.Abs(p1[i]), math.Abs(p2[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// findOutliers returns the outliers of the ephemerides of a satellite ordered by time. An ephemeris is an outlier
+// if its position at its reference time differs by more than maxDiff from the positions of both the preceding
+// and the succeeding ephemeris valid at that time. Ephemerides without orbit are outliers too.
+func findOutliers(list []*mergeEph, maxDiff float64) []bool {
+	outliers := make([]bool, len(list))
+	if maxDiff <= 0 {
+		return outliers
+	}
+	// differs reports whether the position of the ephemeris j differs from pos at the reference time of i,
+	// and whether j is valid at that time.
+	differs := func(i, j int, pos Coord) (diff, valid bool) {
+		if j < 0 || j >= len(list) || list[i].ref.Sub(list[j].ref).Abs() > list[j].validity {
+			return false, false
+		}
+		p, _, err := list[j].eph.Position(list[i].ref)
+		if err != nil {
+			return false, false
+		}
+		return math.Sqrt(math.Pow(p.X-pos.X, 2)+math.Pow(p.Y-pos.Y, 2)+math.Pow(p.Z-pos.Z, 2)) > maxDiff, true
+	}
+
+	for i, e := range list {
+		pos, _, err := e.eph.Position(e.ref)
+		if err != nil {
+			outliers[i] = true
+			continue
+		}
+		// the neighbours with another reference time, e.g. not the F/NAV ephemeris of an I/NAV one
+		prev := i - 1
+		for prev >= 0 && list[prev].ref.Equal(e.ref) {
+			prev--
+		}
+		next := i + 1
+		for next < len(list) && list[next].ref.Equal(e.ref) {
+			next++
+		}
+		prevDiff, prevValid := differs(i, prev, pos)
+		nextDiff, nextValid := differs(i, next, pos)
+		outliers[i] = prevValid && nextValid && prevDiff && nextDiff
+	}
+	return outliers
+}
+
+// recordMessageType returns the message type of the RINEX-4 record.
+func recordMessageType(rec NavRecord) string {
+	switch rec := rec.(type) {
+	case *TimeOffset:
+		return rec.MessageType
+	case *EarthOrientation:
+		return rec.MessageType
+	case *IonoModel:
+		return rec.MessageType
+	}
+	return ""
+}
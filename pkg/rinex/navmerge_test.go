@@ -0,0 +1,174 @@
+package rinex
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeNav encodes the ephemerides with the header and returns a decoder for the encoded data.
+func encodeNav(t *testing.T, hdr NavHeader, ephs []Eph) *NavDecoder {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := NewNavEncoder(&buf, hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, eph := range ephs {
+		if err := enc.Encode(eph); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := NewNavDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dec
+}
+
+func TestMergeNav(t *testing.T) {
+	hdr, ephs := readNavFile(t, "testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	opts := NavMergeOptions{MaxOrbitDiff: 500}
+
+	// the file of a single station as reference
+	_, base, err := MergeNav(&bytes.Buffer{}, []*NavDecoder{encodeNav(t, hdr, ephs)}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(ephs), base.NumInput)
+	assert.Greater(t, base.Removed[RemovedUnhealthy], 0)
+
+	// A second station has the G02 ephemerides too, a G05 ephemeris with another clock bias and the same IODE,
+	// and a G07 ephemeris shifted by one hour, i.e. an outlier. Its header has a later GAUT correction.
+	var second []Eph
+	var g05, g07 *EphGPS
+	for _, eph := range ephs {
+		gps, ok := eph.(*EphGPS)
+		if !ok {
+			continue
+		}
+		switch gps.PRN.Num {
+		case 2:
+			second = append(second, gps)
+		case 5:
+			if g05 == nil {
+				g05 = gps
+			}
+		case 7:
+			if gps.TOC.Hour() == 18 {
+				g07 = gps
+			}
+		}
+	}
+	nG02 := len(second)
+	inconsistent, outlier := *g05, *g07
+	inconsistent.ClockBias += 1e-9
+	outlier.TOC, outlier.Toe, outlier.IODE = outlier.TOC.Add(time.Hour), outlier.Toe+3600, 200
+	second = append(second, &inconsistent, &outlier)
+
+	hdr2 := NavHeader{RINEXVersion: 3.04, RINEXType: "N", SatSystem: gnss.SysGPS, LeapSeconds: LeapSeconds{Current: 18},
+		IonoCorrs: []IonoCorr{{Type: "GPSA", Params: [4]float64{1e-8}}, {Type: "BDSA", Params: [4]float64{2e-8}}},
+		TimeCorrs: []TimeSystemCorr{{Type: "GAUT", A0: 1e-9, T: 345600, W: 2110}},
+	}
+
+	// A RINEX-4 file with the same G02, R02 and E01 ephemerides and a CNAV message.
+	tests := []struct {
+		name    string
+		version float32
+	}{
+		{name: "default", version: 0},
+		{name: "RINEX-3", version: 3.04},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			third, err := NewNavDecoder(strings.NewReader(navDataV4 + cnavG01))
+			if err != nil {
+				t.Fatal(err)
+			}
+			decs := []*NavDecoder{encodeNav(t, hdr, ephs), encodeNav(t, hdr2, second), third}
+			opts := opts
+			opts.RINEXVersion = tt.version
+
+			var buf bytes.Buffer
+			merged, stats, err := MergeNav(&buf, decs, opts)
+			if !assert.NoError(err) {
+				return
+			}
+			assert.Equal(3, stats.NumFiles)
+			assert.Equal(len(ephs)+len(second)+4, stats.NumInput)
+			cnavCnt := 0
+			if tt.version == 0 {
+				cnavCnt = 1
+			}
+			assert.Equal(base.NumOutput+cnavCnt, stats.NumOutput)
+			assert.Equal(base.Removed[RemovedDuplicate]+nG02+3, stats.Removed[RemovedDuplicate])
+			assert.Equal(1, stats.Removed[RemovedInconsistent])
+			assert.Equal(base.Removed[RemovedUnhealthy], stats.Removed[RemovedUnhealthy])
+			assert.Equal(base.Removed[RemovedOutlier]+1, stats.Removed[RemovedOutlier])
+			assert.Equal(1-cnavCnt, stats.Removed[RemovedUnsupported])
+			assert.Contains(stats.Flagged, RemovedEph{PRN: g05.PRN, TOC: g05.TOC, File: 2, Reason: RemovedInconsistent})
+			assert.Contains(stats.Flagged, RemovedEph{PRN: g07.PRN, TOC: outlier.TOC, File: 2, Reason: RemovedOutlier})
+			cnavRemoved := RemovedEph{PRN: gnss.PRN{Sys: gnss.SysGPS, Num: 1}, TOC: g05.TOC, File: 3, Reason: RemovedUnsupported}
+			if tt.version == 0 {
+				assert.NotContains(stats.Flagged, cnavRemoved)
+			} else {
+				assert.Contains(stats.Flagged, cnavRemoved)
+			}
+
+			if tt.version == 0 {
+				assert.Equal(float32(4.01), merged.RINEXVersion)
+			} else {
+				assert.Equal(tt.version, merged.RINEXVersion)
+			}
+			assert.Equal(gnss.SysMIXED, merged.SatSystem)
+			assert.Equal(3, merged.MergedFiles)
+			assert.Contains(merged.Comments, "MERGED FROM 3 FILES")
+			if assert.Len(merged.IonoCorrs, 4) {
+				assert.Equal(hdr.IonoCorrs[0], merged.IonoCorrs[0], "first GPSA")
+				assert.Equal("BDSA", merged.IonoCorrs[3].Type)
+			}
+			if assert.Len(merged.TimeCorrs, 3) {
+				assert.Equal(hdr2.TimeCorrs[0], merged.TimeCorrs[1], "latest GAUT")
+			}
+
+			dec, err := NewNavDecoder(&buf)
+			if !assert.NoError(err) {
+				return
+			}
+			n := 0
+			var last Eph
+			for dec.NextEphemeris() {
+				eph := dec.Ephemeris()
+				if last != nil && last.GetPRN() == eph.GetPRN() {
+					assert.False(eph.GetTime().Before(last.GetTime()), "ordered by time")
+				}
+				last = eph
+				n++
+			}
+			assert.NoError(dec.Err())
+			assert.Equal(stats.NumOutput, n)
+			if tt.version == 0 {
+				assert.Equal(3, dec.Header.MergedFiles)
+				assert.Len(dec.Records(), 4+6, "the records and the ION and STO records of the corrections")
+				assert.Zero(stats.UnsupportedRecords)
+			} else {
+				assert.Equal(1, stats.UnsupportedRecords, "EOP")
+				assert.Len(dec.Header.IonoCorrs, 4)
+				assert.Len(dec.Header.TimeCorrs, 3)
+			}
+		})
+	}
+}
+
+func TestMergeNav_noInput(t *testing.T) {
+	_, _, err := MergeNav(&bytes.Buffer{}, nil, NavMergeOptions{})
+	assert.Error(t, err)
+}
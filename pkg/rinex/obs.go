@@ -1,26 +1,18 @@
 package rinex
 
 // Note: fmt.Scanf is pretty slow in Go!? https://github.com/golang/go/issues/12275#issuecomment-133796990
-//
-// TODO: read headers that may occur at any position in the file.
 
 import (
 	"bufio"
-	"bytes"
-	"errors"
 	"fmt"
 	"io"
-	"log"
-	"math"
-	"math/big"
+	"maps"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/de-bkg/gognss/pkg/gnss"
@@ -46,17 +38,56 @@ func (flag EpochFlag) String() string {
 // ObsCode is the RINEX observation code that specifies frequency, signal and tracking mode like "L1C".
 type ObsCode string
 
+// Type returns the observation type of the code, i.e. 'C' for pseudorange, 'L' for carrier phase, 'D' for doppler
+// and 'S' for signal strength. The RINEX-2 P-code pseudoranges like "P1" are returned as 'C'. 0 is returned for an empty code.
+func (code ObsCode) Type() byte {
+	if code == "" {
+		return 0
+	}
+	if code[0] == 'P' {
+		return 'C'
+	}
+	return code[0]
+}
+
+// Band returns the RINEX frequency band of the code, e.g. '1' for "L1C", or 0 if missing.
+func (code ObsCode) Band() byte {
+	if len(code) < 2 {
+		return 0
+	}
+	return code[1]
+}
+
+// Attribute returns the attribute of the code that specifies the tracking mode, e.g. 'C' for "L1C".
+// 0 is returned for RINEX-2 codes, that have no attribute.
+func (code ObsCode) Attribute() byte {
+	if len(code) < 3 {
+		return 0
+	}
+	return code[2]
+}
+
+// Signal returns the signal of the code for the satellite system.
+func (code ObsCode) Signal(sys gnss.System) gnss.Signal {
+	return gnss.Signal{Sys: sys, Band: code.Band(), Attribute: code.Attribute()}
+}
+
+// Frequency returns the carrier frequency in Hz of the code for the satellite, or 0 if unknown.
+// For GLONASS FDMA signals the channel number is taken from gloSlots, usually ObsHeader.GloSlots.
+func (code ObsCode) Frequency(prn gnss.PRN, gloSlots map[gnss.PRN]int) float64 {
+	return code.Signal(prn.Sys).FrequencyOf(prn, gloSlots)
+}
+
+// Wavelength returns the carrier wavelength in m of the code for the satellite, or 0 if unknown, see Frequency.
+func (code ObsCode) Wavelength(prn gnss.PRN, gloSlots map[gnss.PRN]int) float64 {
+	return code.Signal(prn.Sys).WavelengthOf(prn, gloSlots)
+}
+
 // Options for global settings.
 type Options struct {
 	SatSys string // satellite systems GRE... Why not gnss.System?
 }
 
-// DiffOptions sets options for file comparison.
-type DiffOptions struct {
-	SatSys      string // satellite systems GRE...
-	CheckHeader bool   // also compare the RINEX header
-}
-
 // Coord defines a XYZ coordinate.
 type Coord struct {
 	X, Y, Z float64
@@ -67,6 +98,46 @@ type CoordNEU struct {
 	N, E, Up float64
 }
 
+// PhaseShift specifies the phase shift correction applied to a carrier phase observation type (SYS / PHASE SHIFT).
+type PhaseShift struct {
+	Sys        gnss.System // The satellite system.
+	Code       ObsCode     // The carrier phase observation code.
+	Correction float64     // The correction applied in cycles.
+	Sats       []gnss.PRN  // The satellites the correction applies to. Empty for all satellites of the system.
+}
+
+// ScaleFactor specifies the factor the observations were multiplied with before storing them in the file
+// (SYS / SCALE FACTOR). The decoder divides the observations by the factor.
+type ScaleFactor struct {
+	Sys    gnss.System // The satellite system.
+	Factor int         // The scale factor 1, 10, 100 or 1000.
+	Codes  []ObsCode   // The observation types the factor applies to. Empty for all types of the system.
+}
+
+// WavelengthFactor specifies the wavelength factors of the L1 and L2 carrier phases in RINEX-2 (WAVELENGTH FACT L1/2):
+// 1 for full cycle ambiguities, 2 for half cycle ambiguities (squaring), 0 for single frequency receivers (L2 only).
+type WavelengthFactor struct {
+	L1, L2 int
+	Sats   []gnss.PRN // The satellites the factors apply to. Empty for the default factors.
+}
+
+// PhaseCenter specifies the average phase center position of a signal w.r.t. the antenna reference point
+// (ANTENNA: PHASECENTER). The position is North/East/Up for fixed stations, and X/Y/Z in the body-fixed
+// coordinate system for vehicles.
+type PhaseCenter struct {
+	Sys  gnss.System // The satellite system.
+	Code ObsCode     // The observation code.
+	Pos  Coord       // The position in [m].
+}
+
+// AppliedCorrection specifies the program and the source of corrections applied to the observations,
+// e.g. differential code biases (SYS / DCBS APPLIED) or phase center variations (SYS / PCVS APPLIED).
+type AppliedCorrection struct {
+	Sys     gnss.System // The satellite system.
+	Program string      // The program used to apply the corrections.
+	Source  string      // The source of the corrections, e.g. an URL.
+}
+
 // Obs specifies a RINEX observation.
 type Obs struct {
 	Val float64 // The observation itself.
@@ -80,6 +151,24 @@ type SatObs struct {
 	Obss map[ObsCode]Obs // A map of observations with the obs-code as key. L1C: Obs{Val:0, LLI:0, SNR:0}, L2C: Obs{Val:...},...
 }
 
+// IndexedSatObs contains all observations for a satellite per epoch, in the sequence of the observation types
+// of the satellite system, see ObsDecoder.SetIndexed. It needs much less allocations than SatObs.
+type IndexedSatObs struct {
+	Prn gnss.PRN // The satellite number or PRN.
+	Obs []Obs    // The observations in the sequence of the observation types.
+}
+
+// SatObs returns the observations as SatObs, with obsTypes being the observation types the observations refer to.
+func (so IndexedSatObs) SatObs(obsTypes []ObsCode) SatObs {
+	obss := make(map[ObsCode]Obs, len(so.Obs))
+	for i, obs := range so.Obs {
+		if i < len(obsTypes) {
+			obss[obsTypes[i]] = obs
+		}
+	}
+	return SatObs{Prn: so.Prn, Obss: obss}
+}
+
 // SyncEpochs contains two epochs from different files with the same timestamp.
 type SyncEpochs struct {
 	Epo1 *Epoch
@@ -88,13 +177,43 @@ type SyncEpochs struct {
 
 // Epoch contains a RINEX obs data epoch.
 type Epoch struct {
-	Time    time.Time // The epoch time.
-	Flag    EpochFlag // The epoch flag 0:OK, 1:power failure between previous and current epoch, >1 : Special event.
-	NumSat  uint8     // The number of satellites per epoch.
-	ObsList []SatObs  // The list of observations per epoch.
+	Time        time.Time       // The epoch time. The time is optional for special events.
+	Flag        EpochFlag       // The epoch flag 0:OK, 1:power failure between previous and current epoch, >1 : Special event.
+	NumSat      uint8           // The number of satellites per epoch.
+	ClockOffset float64         // The receiver clock offset in seconds (optional).
+	ObsList     []SatObs        // The list of observations per epoch.
+	IndexedObs  []IndexedSatObs // The list of observations per epoch, in indexed mode instead of ObsList.
+	Records     []string        // The special records of an event epoch (flag 2-5), e.g. header lines.
+	Event       *Event          // The decoded special records of an event epoch (flag 2-5), nil for observation epochs.
 	//Error   error // e.g. parse error
 }
 
+// Event is the payload of a special event epoch with the flag 2-5, decoded from its special records:
+//   - EpochFlagMovingAntenna: start of moving antenna, optional header records may follow, e.g. ANTENNA: DELTA H/E/N.
+//   - EpochFlagNewSite: new site occupation at the end of kinematic data, at least the MARKER NAME record follows.
+//   - EpochFlagHeaderInfo: header records follow.
+//   - EpochFlagExternalEvent: external event, the epoch time is significant, comments may follow.
+type Event struct {
+	Flag     EpochFlag // The event flag.
+	Comments []string  // The COMMENT records.
+
+	// The other header records of the event. Only the fields of the records given are set, see Header.Labels,
+	// except RINEXVersion and SatSystem that are taken from the file header. Header is nil if there are no header records.
+	Header *ObsHeader
+}
+
+// PowerFailure is a period with a power failure of the receiver, indicated by the epoch flag 1.
+// Start is the last epoch before and End the first epoch after the power failure.
+type PowerFailure struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// GetTime returns the epoch time.
+func (epo *Epoch) GetTime() time.Time {
+	return epo.Time
+}
+
 // Print pretty prints the epoch.
 func (epo *Epoch) Print() {
 	//fmt.Printf("%+v\n", epo)
@@ -108,6 +227,8 @@ func (epo *Epoch) Print() {
 }
 
 // PrintTab prints the epoch in a tabular format.
+//
+// Deprecated: Use ExportObs, which writes the observations in a defined order with column headers.
 func (epo *Epoch) PrintTab(opts Options) {
 	for _, obsPerSat := range epo.ObsList {
 		printSys := false
@@ -138,6 +259,7 @@ type ObsStats struct {
 	TimeOfFirstObs time.Time                    `json:"timeOfFirstObs"` // Time of the first observation.
 	TimeOfLastObs  time.Time                    `json:"timeOfLastObs"`  // Time of the last observation.
 	ObsPerSat      map[gnss.PRN]map[ObsCode]int `json:"obsstats"`       // Number of observations per PRN and observation-type.
+	PowerFailures  []PowerFailure               `json:"powerFailures"`  // The power failures between epochs.
 }
 
 // A ObsHeader provides the RINEX Observation Header information.
@@ -165,19 +287,36 @@ type ObsHeader struct {
 	Position     Coord    // Geocentric approximate marker position [m]
 	AntennaDelta CoordNEU // North,East,Up deltas in [m]
 
+	WavelengthFactors []WavelengthFactor // The wavelength factors of L1 and L2, the default first (RINEX-2 only).
+
+	AntennaDeltaXYZ     Coord         // Position of the antenna reference point for an antenna on a vehicle [m].
+	AntennaPhaseCenters []PhaseCenter // Average phase center positions w.r.t. the antenna reference point.
+	AntennaBSight       Coord         // Direction of the vertical antenna axis towards the GNSS satellites.
+	AntennaZeroDirAzi   float64       // Azimuth of the zero-direction of a fixed antenna [deg].
+	AntennaZeroDirXYZ   Coord         // Zero-direction of an antenna on a vehicle.
+	CenterOfMass        Coord         // Current center of mass of a vehicle in the body-fixed coordinate system [m].
+
 	DOI          string   // Digital Object Identifier (DOI) for data citation i.e. https://doi.org/<DOI-number>.
 	Licenses     []string // Line(s) with the data license of use. Name of the license plus link to the specific version of the license. Using standard data license as from https://creativecommons.org/licenses/
 	StationInfos []string // Line(s) with the link(s) to persistent URL with the station metadata (site log, GeodesyML, etc).
 
 	ObsTypes map[gnss.System][]ObsCode // List of all observation types per GNSS.
 
-	SignalStrengthUnit string
-	Interval           float64 // Observation interval in seconds
-	TimeOfFirstObs     time.Time
-	TimeOfLastObs      time.Time
-	GloSlots           map[gnss.PRN]int // GLONASS slot and frequency numbers.
-	LeapSeconds        int              // The current number of leap seconds
-	NSatellites        int              // Number of satellites, for which observations are stored in the file
+	SignalStrengthUnit  string
+	Interval            float64 // Observation interval in seconds
+	TimeOfFirstObs      time.Time
+	TimeOfLastObs       time.Time
+	RcvClockOffsApplied bool                // Epochs, code and phase are corrected by the receiver clock offset.
+	DCBsApplied         []AppliedCorrection // Differential code bias corrections applied to the observations.
+	PCVsApplied         []AppliedCorrection // Phase center variation corrections applied to the observations.
+	ScaleFactors        []ScaleFactor       // Scale factors of the observations in the file.
+	PhaseShifts         []PhaseShift        // Phase shift corrections applied to the carrier phases.
+	GloSlots            map[gnss.PRN]int    // GLONASS slot and frequency numbers.
+	GloCodPhsBias       map[ObsCode]float64 // GLONASS code-phase alignment biases in [m] (deprecated).
+	LeapSeconds         int                 // The current number of leap seconds
+	NSatellites         int                 // Number of satellites, for which observations are stored in the file
+	ObsPerSat           map[gnss.PRN][]int  // The number of observations per satellite and observation type.
+	MergedFiles         int                 // The number of files merged, if any.
 
 	Labels []string // all Header Labels found.
 }
@@ -195,6 +334,38 @@ func (hdr *ObsHeader) SatSystems() []gnss.System {
 	return sysList
 }
 
+// ScaleFactorOf returns the factor the observations of type typ of the satellite system sys are scaled with
+// in the file, according to the SYS / SCALE FACTOR records. It returns 1 if the observations are not scaled.
+func (hdr *ObsHeader) ScaleFactorOf(sys gnss.System, typ ObsCode) int {
+	factor := 1
+	for _, sf := range hdr.ScaleFactors {
+		if sf.Sys == sys && (len(sf.Codes) == 0 || slices.Contains(sf.Codes, typ)) {
+			factor = sf.Factor
+		}
+	}
+	return factor
+}
+
+// clone returns a copy of the header, whose lists and maps can be changed without changing hdr.
+func (hdr *ObsHeader) clone() *ObsHeader {
+	c := *hdr
+	c.Comments = slices.Clone(hdr.Comments)
+	c.Licenses = slices.Clone(hdr.Licenses)
+	c.StationInfos = slices.Clone(hdr.StationInfos)
+	c.ObsTypes = maps.Clone(hdr.ObsTypes)
+	c.AntennaPhaseCenters = slices.Clone(hdr.AntennaPhaseCenters)
+	c.WavelengthFactors = slices.Clone(hdr.WavelengthFactors)
+	c.DCBsApplied = slices.Clone(hdr.DCBsApplied)
+	c.PCVsApplied = slices.Clone(hdr.PCVsApplied)
+	c.ScaleFactors = slices.Clone(hdr.ScaleFactors)
+	c.PhaseShifts = slices.Clone(hdr.PhaseShifts)
+	c.GloSlots = maps.Clone(hdr.GloSlots)
+	c.GloCodPhsBias = maps.Clone(hdr.GloCodPhsBias)
+	c.ObsPerSat = maps.Clone(hdr.ObsPerSat)
+	c.Labels = slices.Clone(hdr.Labels)
+	return &c
+}
+
 // Write the header to w.
 func (hdr *ObsHeader) Write(w io.Writer) error {
 	bw := bufio.NewWriter(w)
@@ -213,16 +384,37 @@ func (hdr *ObsHeader) Write(w io.Writer) error {
 	if hdr.MarkerNumber != "" {
 		fmt.Fprintf(bw, "%-20s%-40s%-s\n", hdr.MarkerNumber, " ", "MARKER NUMBER")
 	}
-	fmt.Fprintf(bw, "%-20s%-40s%-s\n", hdr.MarkerType, " ", "MARKER TYPE")
+	if hdr.RINEXVersion >= 3 {
+		fmt.Fprintf(bw, "%-20s%-40s%-s\n", hdr.MarkerType, " ", "MARKER TYPE")
+	}
 	fmt.Fprintf(bw, "%-20s%-20.20s%-20.20s%-s\n", hdr.Observer, hdr.Agency, " ", "OBSERVER / AGENCY")
-	fmt.Fprintf(bw, "%-20.20s%-20.20s%-20.20s%-s\n", hdr.ReceiverNumber, hdr.ReceiverType, hdr.ReceiverVersion, "REC # / TYPE / VERS")
-	fmt.Fprintf(bw, "%-20s%-20.20s%-20.20s%-s\n", hdr.AntennaNumber, hdr.AntennaType, "", "ANT # / TYPE")
+	fmt.Fprintln(bw, hdr.receiverRecord())
+	fmt.Fprintln(bw, hdr.antennaRecord())
 	fmt.Fprintf(bw, "%14.4f%14.4f%14.4f%-18s%-s\n", hdr.Position.X, hdr.Position.Y, hdr.Position.Z, " ", "APPROX POSITION XYZ")
-	fmt.Fprintf(bw, "%14.4f%14.4f%14.4f%-18s%-s\n", hdr.AntennaDelta.Up, hdr.AntennaDelta.E, hdr.AntennaDelta.N, " ", "ANTENNA: DELTA H/E/N")
+	fmt.Fprintln(bw, hdr.antennaDeltaRecord())
+	if hdr.RINEXVersion >= 3 {
+		hdr.writeAntennaRecords(bw)
+	}
+	if hdr.RINEXVersion < 3 {
+		hdr.writeWavelengthFactors(bw)
+	}
 	if hdr.DOI != "" {
 		fmt.Fprintf(bw, "%-60s%-s\n", hdr.DOI, "DOI")
 	}
-	hdr.writeObsCodes(bw)
+	for _, l := range hdr.Licenses {
+		fmt.Fprintf(bw, "%-60.60s%-s\n", l, "LICENSE OF USE")
+	}
+	for _, l := range hdr.StationInfos {
+		fmt.Fprintf(bw, "%-60.60s%-s\n", l, "STATION INFORMATION")
+	}
+	if hdr.RINEXVersion < 3 {
+		hdr.writeObsCodesv2(bw)
+	} else {
+		hdr.writeObsCodes(bw)
+	}
+	if hdr.SignalStrengthUnit != "" && hdr.RINEXVersion >= 3 {
+		fmt.Fprintf(bw, "%-20s%-40s%-s\n", hdr.SignalStrengthUnit, " ", "SIGNAL STRENGTH UNIT")
+	}
 	if hdr.Interval != 0 {
 		fmt.Fprintf(bw, "%10.3f%-50s%-s\n", hdr.Interval, " ", "INTERVAL")
 	}
@@ -236,16 +428,75 @@ func (hdr *ObsHeader) Write(w io.Writer) error {
 		fmt.Fprintf(bw, "%s%-5s%-12s%-s\n", hdr.formatFirstObsTime(hdr.TimeOfLastObs), " ", "GPS", "TIME OF LAST OBS")
 	}
 
-	hdr.writeGloSlotsAndFreqs(bw)
+	if hdr.RcvClockOffsApplied {
+		fmt.Fprintf(bw, "%6d%-54s%-s\n", 1, " ", "RCV CLOCK OFFS APPL")
+	}
+
+	if hdr.RINEXVersion >= 3 {
+		writeAppliedCorrections(bw, hdr.DCBsApplied, "SYS / DCBS APPLIED")
+		writeAppliedCorrections(bw, hdr.PCVsApplied, "SYS / PCVS APPLIED")
+		hdr.writeScaleFactors(bw)
+		hdr.writePhaseShifts(bw)
+		hdr.writeGloSlotsAndFreqs(bw)
+		hdr.writeGloCodPhsBias(bw)
+	}
+	if hdr.LeapSeconds != 0 {
+		fmt.Fprintf(bw, "%6d%-54s%-s\n", hdr.LeapSeconds, " ", "LEAP SECONDS")
+	}
+	if hdr.NSatellites != 0 {
+		fmt.Fprintf(bw, "%6d%-54s%-s\n", hdr.NSatellites, " ", "# OF SATELLITES")
+	}
+	hdr.writeObsPerSat(bw)
 
 	fmt.Fprintf(bw, "%-60s%-s\n", " ", "END OF HEADER")
 
 	return bw.Flush()
 }
 
+// receiverRecord returns the header line "REC # / TYPE / VERS".
+func (hdr *ObsHeader) receiverRecord() string {
+	return fmt.Sprintf("%-20.20s%-20.20s%-20.20s%-s", hdr.ReceiverNumber, hdr.ReceiverType, hdr.ReceiverVersion, "REC # / TYPE / VERS")
+}
+
+// antennaRecord returns the header line "ANT # / TYPE".
+func (hdr *ObsHeader) antennaRecord() string {
+	return fmt.Sprintf("%-20s%-20.20s%-20.20s%-s", hdr.AntennaNumber, hdr.AntennaType, "", "ANT # / TYPE")
+}
+
+// antennaDeltaRecord returns the header line "ANTENNA: DELTA H/E/N".
+func (hdr *ObsHeader) antennaDeltaRecord() string {
+	return fmt.Sprintf("%14.4f%14.4f%14.4f%-18s%-s", hdr.AntennaDelta.Up, hdr.AntennaDelta.E, hdr.AntennaDelta.N, " ", "ANTENNA: DELTA H/E/N")
+}
+
+// writeWavelengthFactors writes the "WAVELENGTH FACT L1/2" records, with up to 7 satellites per line.
+// The default factors 1 1 are written if there are none.
+func (hdr *ObsHeader) writeWavelengthFactors(w io.Writer) {
+	if len(hdr.WavelengthFactors) == 0 {
+		fmt.Fprintf(w, "%6d%6d%-48s%-s\n", 1, 1, " ", "WAVELENGTH FACT L1/2")
+		return
+	}
+	for _, wf := range hdr.WavelengthFactors {
+		if len(wf.Sats) == 0 {
+			fmt.Fprintf(w, "%6d%6d%-48s%-s\n", wf.L1, wf.L2, " ", "WAVELENGTH FACT L1/2")
+			continue
+		}
+		for sats := range slices.Chunk(wf.Sats, 7) {
+			var sb strings.Builder
+			fmt.Fprintf(&sb, "%6d%6d%6d", wf.L1, wf.L2, len(sats))
+			for _, prn := range sats {
+				fmt.Fprintf(&sb, "   %s", prn)
+			}
+			fmt.Fprintf(w, "%-60s%-s\n", sb.String(), "WAVELENGTH FACT L1/2")
+		}
+	}
+}
+
 // writes the Observation Types to w, in the format of a RINEX header.
 func (hdr *ObsHeader) writeObsCodes(w io.Writer) {
-	for sys, codes := range hdr.ObsTypes {
+	syss := hdr.SatSystems()
+	slices.Sort(syss)
+	for _, sys := range syss {
+		codes := hdr.ObsTypes[sys]
 		numCodes := len(codes)
 		if numCodes < 1 {
 			continue
@@ -268,6 +519,55 @@ func (hdr *ObsHeader) writeObsCodes(w io.Writer) {
 	}
 }
 
+// writes the RINEX-2 Observation Types to w.
+func (hdr *ObsHeader) writeObsCodesv2(w io.Writer) {
+	codes := hdr.ObsTypes[hdr.SatSystem]
+	fmt.Fprintf(w, "%6d", len(codes))
+	if len(codes) == 0 {
+		fmt.Fprintf(w, "%-54s%-s\n", " ", "# / TYPES OF OBSERV")
+		return
+	}
+
+	iChunk := 0
+	for chunk := range slices.Chunk(codes, 9) {
+		if iChunk > 0 {
+			fmt.Fprint(w, "      ")
+		}
+		for _, code := range chunk {
+			fmt.Fprintf(w, "    %2s", code)
+		}
+		pad := strings.Repeat("      ", 9-len(chunk))
+		fmt.Fprintf(w, "%s%-s\n", pad, "# / TYPES OF OBSERV")
+		iChunk++
+	}
+}
+
+// writes the phase shift corrections to w.
+func (hdr *ObsHeader) writePhaseShifts(w io.Writer) {
+	for _, ps := range hdr.PhaseShifts {
+		fmt.Fprintf(w, "%-1s %-3s %8.5f", ps.Sys.Abbr(), ps.Code, ps.Correction)
+		if len(ps.Sats) == 0 {
+			fmt.Fprintf(w, "%-46s%-s\n", " ", "SYS / PHASE SHIFT")
+			continue
+		}
+
+		iChunk := 0
+		for chunk := range slices.Chunk(ps.Sats, 10) {
+			if iChunk == 0 {
+				fmt.Fprintf(w, "  %02d", len(ps.Sats))
+			} else {
+				fmt.Fprintf(w, "%18s", " ")
+			}
+			for _, prn := range chunk {
+				fmt.Fprintf(w, " %-3s", prn)
+			}
+			pad := strings.Repeat("    ", 10-len(chunk))
+			fmt.Fprintf(w, "%s  %-s\n", pad, "SYS / PHASE SHIFT")
+			iChunk++
+		}
+	}
+}
+
 // writes the GLONASS slots & frequency header to w.
 func (hdr *ObsHeader) writeGloSlotsAndFreqs(w io.Writer) {
 	// Sort by prn
@@ -295,6 +595,92 @@ func (hdr *ObsHeader) writeGloSlotsAndFreqs(w io.Writer) {
 	}
 }
 
+// writes the optional antenna records of RINEX-3 to w.
+func (hdr *ObsHeader) writeAntennaRecords(w io.Writer) {
+	writeCoord := func(c Coord, label string) {
+		if c != (Coord{}) {
+			fmt.Fprintf(w, "%14.4f%14.4f%14.4f%-18s%-s\n", c.X, c.Y, c.Z, " ", label)
+		}
+	}
+	writeCoord(hdr.AntennaDeltaXYZ, "ANTENNA: DELTA X/Y/Z")
+	for _, pc := range hdr.AntennaPhaseCenters {
+		fmt.Fprintf(w, "%-1s %-3s%9.4f%14.4f%14.4f%-18s%-s\n", pc.Sys.Abbr(), pc.Code, pc.Pos.X, pc.Pos.Y, pc.Pos.Z, " ", "ANTENNA: PHASECENTER")
+	}
+	writeCoord(hdr.AntennaBSight, "ANTENNA: B.SIGHT XYZ")
+	if hdr.AntennaZeroDirAzi != 0 {
+		fmt.Fprintf(w, "%14.4f%-46s%-s\n", hdr.AntennaZeroDirAzi, " ", "ANTENNA: ZERODIR AZI")
+	}
+	writeCoord(hdr.AntennaZeroDirXYZ, "ANTENNA: ZERODIR XYZ")
+	writeCoord(hdr.CenterOfMass, "CENTER OF MASS: XYZ")
+}
+
+// writes the corrections applied, i.e. the records SYS / DCBS APPLIED or SYS / PCVS APPLIED, to w.
+func writeAppliedCorrections(w io.Writer, corrs []AppliedCorrection, label string) {
+	for _, corr := range corrs {
+		fmt.Fprintf(w, "%-1s %-17.17s %-40.40s%-s\n", corr.Sys.Abbr(), corr.Program, corr.Source, label)
+	}
+}
+
+// writes the scale factors to w.
+func (hdr *ObsHeader) writeScaleFactors(w io.Writer) {
+	for _, sf := range hdr.ScaleFactors {
+		fmt.Fprintf(w, "%-1s %4d", sf.Sys.Abbr(), sf.Factor)
+		if len(sf.Codes) == 0 {
+			fmt.Fprintf(w, "%-54s%-s\n", " ", "SYS / SCALE FACTOR")
+			continue
+		}
+
+		iChunk := 0
+		for chunk := range slices.Chunk(sf.Codes, 12) {
+			if iChunk == 0 {
+				fmt.Fprintf(w, "  %2d", len(sf.Codes))
+			} else {
+				fmt.Fprintf(w, "%10s", " ")
+			}
+			for _, code := range chunk {
+				fmt.Fprintf(w, " %-3s", code)
+			}
+			pad := strings.Repeat("    ", 12-len(chunk))
+			fmt.Fprintf(w, "%s  %-s\n", pad, "SYS / SCALE FACTOR")
+			iChunk++
+		}
+	}
+}
+
+// writes the GLONASS code-phase biases to w.
+func (hdr *ObsHeader) writeGloCodPhsBias(w io.Writer) {
+	codes := slices.Sorted(maps.Keys(hdr.GloCodPhsBias))
+	for chunk := range slices.Chunk(codes, 4) {
+		for _, code := range chunk {
+			fmt.Fprintf(w, " %-3s %8.3f", code, hdr.GloCodPhsBias[code])
+		}
+		pad := strings.Repeat(" ", 60-13*len(chunk))
+		fmt.Fprintf(w, "%s%-s\n", pad, "GLONASS COD/PHS/BIS")
+	}
+}
+
+// writes the number of observations per satellite to w.
+func (hdr *ObsHeader) writeObsPerSat(w io.Writer) {
+	prns := slices.Collect(maps.Keys(hdr.ObsPerSat))
+	sort.Sort(gnss.ByPRN(prns))
+	for _, prn := range prns {
+		iChunk := 0
+		for chunk := range slices.Chunk(hdr.ObsPerSat[prn], 9) {
+			if iChunk == 0 {
+				fmt.Fprintf(w, "   %-3s", prn)
+			} else {
+				fmt.Fprintf(w, "%6s", " ")
+			}
+			for _, n := range chunk {
+				fmt.Fprintf(w, "%6d", n)
+			}
+			pad := strings.Repeat("      ", 9-len(chunk))
+			fmt.Fprintf(w, "%s%-s\n", pad, "PRN / # OF OBS")
+			iChunk++
+		}
+	}
+}
+
 // Formats the given time ti in the layout of TIME OF FIRST OBS and TIME OF LAST OBS.
 func (hdr *ObsHeader) formatFirstObsTime(ti time.Time) string {
 	return fmt.Sprintf("%6d%6d%6d%6d%6d%13.7f", ti.Year(), ti.Month(), ti.Day(), ti.Hour(), ti.Minute(),
@@ -320,7 +706,7 @@ func NewObsFile(filepath string) (*ObsFile, error) {
 
 // Parse and return the Header lines.
 func (f *ObsFile) ReadHeader() (ObsHeader, error) {
-	r, err := os.Open(f.Path)
+	r, err := OpenFile(f.Path)
 	if err != nil {
 		return ObsHeader{}, err
 	}
@@ -333,50 +719,9 @@ func (f *ObsFile) ReadHeader() (ObsHeader, error) {
 	return dec.Header, nil
 }
 
-// Diff compares two RINEX obs files.
-func (f *ObsFile) Diff(obsFil2 *ObsFile) error {
-	// file 1
-	r, err := os.Open(f.Path)
-	if err != nil {
-		return fmt.Errorf("open obs file: %v", err)
-	}
-	defer r.Close()
-	dec, err := NewObsDecoder(r)
-	if err != nil {
-		return err
-	}
-
-	// file 2
-	r2, err := os.Open(obsFil2.Path)
-	if err != nil {
-		return fmt.Errorf("open obs file: %v", err)
-	}
-	defer r2.Close()
-	dec2, err := NewObsDecoder(r2)
-	if err != nil {
-		return err
-	}
-
-	nSyncEpochs := 0
-	for dec.sync(dec2) {
-		nSyncEpochs++
-		syncEpo := dec.SyncEpoch()
-
-		diff := diffEpo(syncEpo, *f.Opts)
-		if diff != "" {
-			log.Printf("diff: %s", diff)
-		}
-	}
-	if err := dec.Err(); err != nil {
-		return fmt.Errorf("read epochs error: %v", err)
-	}
-
-	return nil
-}
-
 // ComputeObsStats reads the file and computes some statistics on the observations.
 func (f *ObsFile) ComputeObsStats() (stats ObsStats, err error) {
-	r, err := os.Open(f.Path)
+	r, err := OpenFile(f.Path)
 	if err != nil {
 		return
 	}
@@ -398,16 +743,23 @@ func (f *ObsFile) ComputeObsStats() (stats ObsStats, err error) {
 	satmap := make(map[string]int, numSat)
 
 	obsstats := make(map[gnss.PRN]map[ObsCode]int, numSat)
+	stats.PowerFailures = []PowerFailure{}
 	numOfEpochs := 0
 	intervals := make([]time.Duration, 0, 10)
 	var epo, epoPrev *Epoch
 
 	for dec.NextEpoch() {
-		numOfEpochs++
 		epo = dec.Epoch()
+		if epo.Flag > EpochFlagPowerFailure { // special event
+			continue
+		}
+		numOfEpochs++
 		if numOfEpochs == 1 {
 			stats.TimeOfFirstObs = epo.Time
 		}
+		if epo.Flag == EpochFlagPowerFailure && epoPrev != nil {
+			stats.PowerFailures = append(stats.PowerFailures, PowerFailure{Start: epoPrev.Time, End: epo.Time})
+		}
 
 		for _, obsPerSat := range epo.ObsList {
 			prn := obsPerSat.Prn
@@ -431,7 +783,8 @@ func (f *ObsFile) ComputeObsStats() (stats ObsStats, err error) {
 			}
 		}
 
-		if epoPrev != nil && len(intervals) <= 10 {
+		// The interval after a power failure is no regular sampling interval.
+		if epoPrev != nil && epo.Flag != EpochFlagPowerFailure && len(intervals) <= 10 {
 			intervals = append(intervals, epo.Time.Sub(epoPrev.Time))
 		}
 		epoPrev = epo
@@ -480,7 +833,7 @@ func (f *ObsFile) ComputeObsStats() (stats ObsStats, err error) {
 func (f *ObsFile) Rnx3Filename() (string, error) {
 	if f.DataFreq == "" || f.FilePeriod == "" {
 		// Parse header first.
-		r, err := os.Open(f.Path)
+		r, err := OpenFile(f.Path)
 		if err != nil {
 			return "", err
 		}
@@ -491,7 +844,7 @@ func (f *ObsFile) Rnx3Filename() (string, error) {
 		}
 
 		if dec.Header.Interval != 0 {
-			f.DataFreq = fmt.Sprintf("%02d%s", int(dec.Header.Interval), "S")
+			f.DataFreq = FormatDataFreq(time.Duration(dec.Header.Interval * float64(time.Second)).Round(time.Microsecond))
 		}
 
 		f.DataType = fmt.Sprintf("%s%s", dec.Header.SatSystem.Abbr(), "O")
@@ -555,7 +908,7 @@ func (f *ObsFile) Rnx3Filename() (string, error) {
 
 // Compress an observation file using Hatanaka first and then gzip.
 // The source file will be removed if the compression finishes without errors.
-/* func (f *ObsFile) Compress() error {
+func (f *ObsFile) Compress() error {
 	if f.Format == "crx" && f.Compression == "gz" {
 		return nil
 	}
@@ -563,21 +916,25 @@ func (f *ObsFile) Rnx3Filename() (string, error) {
 		return fmt.Errorf("compressed file is not Hatanaka compressed: %s", f.Path)
 	}
 
-	err := f.Rnx2crx()
+	crxPath, err := Rnx2crx(f.Path)
 	if err != nil {
 		return err
 	}
 
-	err = archiver.CompressFile(f.Path, f.Path+".gz")
+	err = gzipFile(crxPath, crxPath+".gz")
 	if err != nil {
 		return err
 	}
-	os.Remove(f.Path)
-	f.Path = f.Path + ".gz"
+	if crxPath != f.Path {
+		os.Remove(f.Path)
+	}
+	os.Remove(crxPath)
+	f.Path = crxPath + ".gz"
+	f.Format = "crx"
 	f.Compression = "gz"
 
 	return nil
-} */
+}
 
 // IsHatanakaCompressed returns true if the obs file is Hatanaka compressed, otherwise false.
 func (f *ObsFile) IsHatanakaCompressed() bool {
@@ -599,18 +956,13 @@ func IsHatanakaCompressed(filename string) bool {
 
 // Rnx2crx Hatanaka compresses a RINEX obs file (compact RINEX) and returns the compressed filename.
 // The rnxFilename must be a valid RINEX filename.
-// see http://terras.gsi.go.jp/ja/crx2rnx.html
+// The compression is done natively, see CrxWriter.
 func Rnx2crx(rnxFilename string) (string, error) {
 	// Check if file is already Hata decompressed.
 	if IsHatanakaCompressed(rnxFilename) {
 		return rnxFilename, nil
 	}
 
-	tool, err := exec.LookPath("RNX2CRX")
-	if err != nil {
-		return "", err
-	}
-
 	dir, rnxFil := filepath.Split(rnxFilename)
 
 	// Build name of target file.
@@ -627,59 +979,46 @@ func Rnx2crx(rnxFilename string) (string, error) {
 		return "", fmt.Errorf("rnx2crx: file has no standard RINEX extension")
 	}
 
-	//fmt.Printf("rnxFil: %s - crxFil: %s\n", rnxFil, crxFil)
-
 	if crxFil == "" || rnxFil == crxFil {
 		return "", fmt.Errorf("rnx2crx: could not build compressed filename")
 	}
 	crxFilePath := filepath.Join(dir, crxFil)
 
-	// Run compression tool
-	cmd := exec.Command(tool, rnxFilename, "-d", "-f")
-	stderr := new(bytes.Buffer)
-	cmd.Stderr = stderr
-
-	// Launch as new process group so that signals (ex: SIGINT) are not sent also the the child process,
-	// see https://stackoverflow.com/questions/66232825/child-process-receives-sigint-which-should-be-handled-only-by-parent-process-re
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		//CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP, // windows
-		Setpgid: true, // linux
+	r, err := os.Open(rnxFilename)
+	if err != nil {
+		return "", err
 	}
+	defer r.Close()
 
-	err = cmd.Run()
+	w, err := os.Create(crxFilePath)
 	if err != nil {
-		rc := cmd.ProcessState.ExitCode()
-		if rc == 2 { // Warning
-			log.Printf("WARN! rnx2crx: %s", stderr.Bytes())
-		} else { // Error
-			if _, err := os.Stat(crxFilePath); !errors.Is(err, os.ErrNotExist) {
-				os.Remove(crxFilePath)
-			}
-			return "", fmt.Errorf("rnx2crx: rc:%d: %v: %s", rc, err, stderr.Bytes())
-		}
+		return "", err
 	}
-
-	// Return filepath
-	if _, err := os.Stat(crxFilePath); errors.Is(err, os.ErrNotExist) {
-		return "", fmt.Errorf("rnx2crx: no such file: %s", crxFilePath)
+	cw := NewCrxWriter(w)
+	_, err = io.Copy(cw, r)
+	if cerr := cw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(crxFilePath)
+		return "", fmt.Errorf("rnx2crx: %v", err)
 	}
+
 	return crxFilePath, nil
 }
 
 // Crx2rnx decompresses a Hatanaka-compressed RINEX obs file and returns the decompressed filename.
 // The crxFilename must be a valid RINEX filename, RINEX v2 lowercase and RINEX v3 uppercase.
-// see http://terras.gsi.go.jp/ja/crx2rnx.html
+// The decompression is done natively, see CrxReader.
 func Crx2rnx(crxFilename string) (string, error) {
 	// Check if file is already Hata decompressed.
 	if !IsHatanakaCompressed(crxFilename) {
 		return crxFilename, nil
 	}
 
-	tool, err := exec.LookPath("CRX2RNX")
-	if err != nil {
-		return "", err
-	}
-
 	dir, crxFil := filepath.Split(crxFilename)
 
 	// Build name of target file
@@ -701,119 +1040,31 @@ func Crx2rnx(crxFilename string) (string, error) {
 	}
 	rnxFilePath := filepath.Join(dir, rnxFil)
 
-	// Run compression tool
-	cmd := exec.Command(tool, crxFilename, "-d", "-f")
-	stderr := new(bytes.Buffer)
-	cmd.Stderr = stderr
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		//CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP, // windows
-		Setpgid: true, // linux
-	}
-
-	err = cmd.Run()
+	r, err := os.Open(crxFilename)
 	if err != nil {
-		rc := cmd.ProcessState.ExitCode()
-		if rc == 2 { // Warning
-			log.Printf("WARN! crx2rnx: %s", stderr.Bytes())
-		} else { // Error
-			if _, err := os.Stat(rnxFilePath); !errors.Is(err, os.ErrNotExist) {
-				os.Remove(rnxFilePath)
-			}
-			return "", fmt.Errorf("crx2rnx: rc:%d: %v: %s", rc, err, stderr.Bytes())
-		}
+		return "", err
 	}
+	defer r.Close()
 
-	// Return filepath
-	if _, err := os.Stat(rnxFilePath); errors.Is(err, os.ErrNotExist) {
-		return "", fmt.Errorf("crx2rnx: no such file: %s", rnxFilePath)
+	cr, err := NewCrxReader(r)
+	if err != nil {
+		return "", fmt.Errorf("crx2rnx: %v", err)
 	}
-	return rnxFilePath, nil
-}
 
-// get decimal part of a float.
-func getDecimal(f float64) float64 {
-	// or big.NewFloat(f).Text("f", 6)
-	fBig := big.NewFloat(f)
-	fint, _ := fBig.Int(nil)
-	intf := new(big.Float).SetInt(fint)
-	//fmt.Printf("accuracy: %d\n", acc)
-	resBig := new(big.Float).Sub(fBig, intf)
-	ff, _ := resBig.Float64()
-	return ff
-}
-
-// compare two epochs
-func diffEpo(epochs SyncEpochs, opts Options) string {
-	epo1, epo2 := epochs.Epo1, epochs.Epo2
-	epoTime := epo1.Time
-	// if epo1.NumSat != epo2.NumSat {
-	// 	return fmt.Sprintf("epo %s: different number of satellites: fil1: %d fil2:%d", epoTime, epo1.NumSat, epo2.NumSat)
-	// }
-
-	for _, obs := range epo1.ObsList {
-		printSys := false
-		for _, useSys := range opts.SatSys {
-			if obs.Prn.Sys.Abbr() == string(useSys) {
-				printSys = true
-				break
-			}
-		}
-
-		if !printSys {
-			continue
-		}
-
-		obs2, err := getObsByPRN(epo2.ObsList, obs.Prn)
-		if err != nil {
-			log.Printf("%v", err)
-			continue
-		}
-
-		diffObs(obs, obs2, epoTime, obs.Prn)
+	w, err := os.Create(rnxFilePath)
+	if err != nil {
+		return "", err
 	}
-
-	return ""
-}
-
-func getObsByPRN(obslist []SatObs, prn gnss.PRN) (SatObs, error) {
-	for _, obs := range obslist {
-		if obs.Prn == prn {
-			return obs, nil
-		}
+	_, err = io.Copy(w, cr)
+	if cerr := w.Close(); err == nil {
+		err = cerr
 	}
-
-	return SatObs{}, fmt.Errorf("no oberservations found for prn %v", prn)
-}
-
-func diffObs(obs1, obs2 SatObs, epoTime time.Time, prn gnss.PRN) string {
-	deltaPhase := 0.005
-	checkSNR := false
-	for k, o1 := range obs1.Obss {
-		if o2, ok := obs2.Obss[k]; ok {
-			val1, val2 := o1.Val, o2.Val
-			if strings.HasPrefix(string(k), "L") { // phase observations
-				val1 = getDecimal(val1)
-				val2 = getDecimal(val2)
-			}
-			if (o1.LLI != o2.LLI) || (math.Abs(val1-val2) > deltaPhase) {
-				log.Printf("%s %v %02d %s %s %14.03f %d %d | %14.03f %d %d", epoTime.Format(time.RFC3339Nano), prn.Sys, prn.Num, k[:1], k, val1, o1.LLI, o1.SNR, val2, o2.LLI, o2.SNR)
-			} else if checkSNR && o1.SNR != o2.SNR {
-				log.Printf("%s %v %02d %s %s %14.03f %d %d | %14.03f %d %d", epoTime.Format(time.RFC3339Nano), prn.Sys, prn.Num, k[:1], k, val1, o1.LLI, o1.SNR, val2, o2.LLI, o2.SNR)
-			}
-
-			// if o1.SNR != o2.SNR {
-			// 	fmt.Printf("%s: SNR: %s: %d %d\n", epoTime.Format(time.RFC3339Nano), k, o1.SNR, o2.SNR)
-			// }
-			// if val1 != val2 {
-			// 	fmt.Printf("%s: val: %s: %14.03f %14.03f\n", epoTime.Format(time.RFC3339Nano), k, val1, val2)
-			// }
-		} else {
-			log.Printf("Key %q does not exist", k)
-		}
-
+	if err != nil {
+		os.Remove(rnxFilePath)
+		return "", fmt.Errorf("crx2rnx: %v", err)
 	}
 
-	return ""
+	return rnxFilePath, nil
 }
 
 // Convert strings to Obscodes.
@@ -196,6 +196,36 @@ func TestObsFile_ComputeObsStatsV211(t *testing.T) {
 	//assert.Equal(map[ObsCode]int{"C1C": 7, "C5Q": 7, "C7Q": 7, "C8Q": 7, "D1C": 7, "D5Q": 7, "D7Q": 7, "D8Q": 7, "L1C": 7, "L5Q": 7, "L7Q": 7, "L8Q": 7, "S1C": 7, "S5Q": 7, "S7Q": 7, "S8Q": 7}, stat.Obsstats[PRN{Sys: gnss.GNSSForAbbr["E"], Num: 7}], "obs E07")
 }
 
+func TestObsFile_ComputeObsStats_powerFailure(t *testing.T) {
+	const data = `     3.04           OBSERVATION DATA    G                   RINEX VERSION / TYPE
+TEST                                                        MARKER NAME
+G    2 C1C L1C                                              SYS / # / OBS TYPES
+                                                            END OF HEADER
+> 2020 06 18 00 00  0.0000000  0  1
+G01  20000000.123   105123456.789 7
+> 2020 06 18 00 00 30.0000000  0  1
+G01  20000030.123   105123486.789 7
+> 2020 06 18 00 02  0.0000000  1  1
+G01  20000120.123   105123576.789 7
+> 2020 06 18 00 02 30.0000000  0  1
+G01  20000150.123   105123606.789 7
+`
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "TEST00DEU_R_20201700000_01H_30S_GO.rnx")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	obsFil, err := NewObsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := obsFil.ComputeObsStats()
+	assert.NoError(err)
+	assert.Equal(4, stat.NumEpochs)
+	assert.Equal(30*time.Second, stat.Sampling)
+	assert.Equal([]PowerFailure{{Start: time.Date(2020, 6, 18, 0, 0, 30, 0, time.UTC), End: time.Date(2020, 6, 18, 0, 2, 0, 0, time.UTC)}}, stat.PowerFailures)
+}
+
 func TestObsFile_ComputeObsStatsV2(t *testing.T) {
 	assert := assert.New(t)
 	filepath := "testdata/white/wtzs3290.06o"
@@ -235,9 +265,16 @@ func TestDiff(t *testing.T) {
 	assert.NotNil(obs2)
 	assert.NoError(err)
 
-	obs1.Opts.SatSys = "GR"
-	err = obs1.Diff(obs2)
+	diff, err := obs1.Diff(obs2, DiffOptions{SatSys: "GR", CheckHeader: true})
 	assert.NoError(err)
+	assert.Equal(120, diff.Summary.Epochs1)
+	assert.Equal(115, diff.Summary.CommonEpochs)
+	assert.Len(diff.OnlyInFile1, 5)
+	assert.Empty(diff.OnlyInFile2)
+	assert.NotEmpty(diff.Header)
+	for _, d := range diff.Obs {
+		assert.Contains([]gnss.System{gnss.SysGPS, gnss.SysGLO}, d.PRN.Sys)
+	}
 }
 
 func TestRnx2crx(t *testing.T) {
@@ -583,3 +620,36 @@ func TestObsHeader_writeGloSlotsAndFreqs(t *testing.T) {
 		})
 	}
 }
+
+func TestObsCode_signal(t *testing.T) {
+	tests := []struct {
+		code     ObsCode
+		wantTyp  byte
+		wantBand byte
+		wantAttr byte
+	}{
+		{code: "L1C", wantTyp: 'L', wantBand: '1', wantAttr: 'C'},
+		{code: "C2W", wantTyp: 'C', wantBand: '2', wantAttr: 'W'},
+		{code: "S7Q", wantTyp: 'S', wantBand: '7', wantAttr: 'Q'},
+		{code: "D5X", wantTyp: 'D', wantBand: '5', wantAttr: 'X'},
+		{code: "P2", wantTyp: 'C', wantBand: '2'},
+		{code: "L1", wantTyp: 'L', wantBand: '1'},
+		{code: ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			assert := assert.New(t)
+			assert.Equal(tt.wantTyp, tt.code.Type(), "type")
+			assert.Equal(tt.wantBand, tt.code.Band(), "band")
+			assert.Equal(tt.wantAttr, tt.code.Attribute(), "attribute")
+		})
+	}
+
+	assert := assert.New(t)
+	gloSlots := map[gnss.PRN]int{{Sys: gnss.SysGLO, Num: 9}: -2}
+	assert.Equal("Z-tracking", ObsCode("C2W").Signal(gnss.SysGPS).TrackingMode())
+	assert.Equal(1575.42e6, ObsCode("C1C").Frequency(gnss.PRN{Sys: gnss.SysGAL, Num: 1}, gloSlots))
+	assert.Equal(1600.875e6, ObsCode("L1C").Frequency(gnss.PRN{Sys: gnss.SysGLO, Num: 9}, gloSlots))
+	assert.InDelta(gnss.SpeedOfLight/1245.125e6, ObsCode("L2").Wavelength(gnss.PRN{Sys: gnss.SysGLO, Num: 9}, gloSlots), 1e-12)
+	assert.Zero(ObsCode("L1C").Frequency(gnss.PRN{Sys: gnss.SysGLO, Num: 10}, gloSlots))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -16,22 +17,47 @@ import (
 // ObsDecoder reads and decodes header and data records from a RINEX Obs input stream.
 type ObsDecoder struct {
 	// The Header is valid after NewObsDecoder or Reader.Reset. The header must exist,
-	// otherwise ErrNoHeader will be returned.
+	// otherwise ErrNoHeader will be returned. Header changes in special events are applied
+	// to the RunningHeader.
 	Header  ObsHeader
 	sc      *bufio.Scanner
 	epo     *Epoch // the current epoch
 	syncEpo *Epoch // the snchronized epoch from a second decoder
-	lineNum int
-	err     error
+	filter  *ObsFilter
+
+	// The observation types of the data. The Header's types differ if a filter is set.
+	obsTypes map[gnss.System][]ObsCode
+	scales   map[gnss.System][]float64 // the scale factors per observation type, nil if no observation is scaled
+	running  *ObsHeader                // the header updated by special events, nil if not updated yet
+	lineNum  int
+	err      error
+	resync   bool // skip lines until the next epoch, after a corrupt epoch in lenient mode
+	indexed  bool // decode the observations into Epoch.IndexedObs
+	chunks   *chunkDecoder
+	decoderDiag
 }
 
 // NewObsDecoder creates a new decoder for RINEX Observation data.
 // The RINEX header will be read implicitly. The header must exist.
+// Compact RINEX (Hatanaka-compressed) data is detected by its header and decompressed on the fly.
 //
 // It is the caller's responsibility to call Close on the underlying reader when done!
 func NewObsDecoder(r io.Reader) (*ObsDecoder, error) {
-	dec := &ObsDecoder{sc: bufio.NewScanner(r)}
+	dec := &ObsDecoder{}
+	dec.init(r)
+	br := bufio.NewReader(r)
+	if isCrx(br) {
+		cr, err := NewCrxReader(br)
+		if err != nil {
+			return nil, err
+		}
+		r = cr
+	} else {
+		r = br
+	}
+	dec.sc = bufio.NewScanner(r)
 	dec.Header, dec.err = dec.readHeader(0)
+	dec.setObsTypes(&dec.Header)
 	return dec, dec.err
 }
 
@@ -46,8 +72,8 @@ func (dec *ObsDecoder) Err() error {
 // readHeader reads a RINEX Observation header. If the Header does not exist,
 // a ErrNoHeader error will be returned. Only maxLines header lines are read if maxLines > 0 (see epoch flags).
 func (dec *ObsDecoder) readHeader(maxLines int) (hdr ObsHeader, err error) {
-	hdr.ObsTypes = map[gnss.System][]ObsCode{} // TODO check Header reread for new ObsTypes
-	var rememberSys gnss.System
+	hdr.ObsTypes = map[gnss.System][]ObsCode{}
+	var st headerState
 	if maxLines == 0 {
 		maxLines = 900
 	}
@@ -69,204 +95,574 @@ readln:
 		key := strings.TrimSpace(line[60:])
 		hdr.Labels = append(hdr.Labels, key)
 
-		switch key {
-		//if strings.EqualFold(key, "RINEX VERSION / TYPE") {
-		case "RINEX VERSION / TYPE":
-			if f64, err := strconv.ParseFloat(strings.TrimSpace(val[:20]), 32); err == nil {
-				hdr.RINEXVersion = float32(f64)
-			} else {
-				return hdr, fmt.Errorf("parse RINEX VERSION: %v", err)
-			}
+		if key == "END OF HEADER" {
+			break readln
+		}
+		if err := dec.parseHeaderRecord(&hdr, val, key, dec.lineNum, &st); err != nil {
+			return hdr, err
+		}
+
+		if maxLines > 0 && dec.lineNum == maxLines {
+			break readln
+		}
+	}
+
+	if err := dec.sc.Err(); err != nil {
+		return hdr, err
+	}
 
-			hdr.RINEXType = strings.TrimSpace(val[20:21])
+	return hdr, err
+}
 
-			sys := strings.TrimSpace(val[40:41])
-			if sys == "" {
-				sys = "G"
+// headerState holds the state of header records that span several lines.
+type headerState struct {
+	sys gnss.System // the satellite system of continued SYS / # / OBS TYPES lines
+	prn gnss.PRN    // the satellite of continued PRN / # OF OBS lines
+}
+
+// parseHeaderRecord parses the header record with the label key and the value val, i.e. the first 60 columns
+// of the line, into hdr. The line number lineNum is used for errors only.
+func (dec *ObsDecoder) parseHeaderRecord(hdr *ObsHeader, val, key string, lineNum int, st *headerState) error {
+	switch key {
+	case "RINEX VERSION / TYPE":
+		if f64, err := strconv.ParseFloat(strings.TrimSpace(val[:20]), 32); err == nil {
+			hdr.RINEXVersion = float32(f64)
+		} else {
+			return dec.newError(lineNum, key, 1, 20, err)
+		}
+
+		hdr.RINEXType = strings.TrimSpace(val[20:21])
+
+		sys := strings.TrimSpace(val[40:41])
+		if sys == "" {
+			sys = "G"
+		}
+		if s, ok := gnss.ByAbbr[sys]; ok {
+			hdr.SatSystem = s
+		} else {
+			return dec.newError(lineNum, key, 41, 41, fmt.Errorf("invalid satellite system: %q", sys))
+		}
+	case "PGM / RUN BY / DATE":
+		// Additional lines of this type can appear together after the second line, if needed to preserve the history of previous actions on the file.
+		if hdr.Pgm != "" {
+			return nil // TODO additional lines
+		}
+		hdr.Pgm = strings.TrimSpace(val[:20])
+		hdr.RunBy = strings.TrimSpace(val[20:40])
+		if date, err := parseHeaderDate(strings.TrimSpace(val[40:])); err == nil {
+			hdr.Date = date
+		} else {
+			log.Printf("parse header date: %q, %v", val[40:], err)
+		}
+	case "COMMENT":
+		hdr.Comments = append(hdr.Comments, strings.TrimSpace(val))
+	case "MARKER NAME":
+		hdr.MarkerName = strings.TrimSpace(val)
+	case "MARKER NUMBER":
+		hdr.MarkerNumber = strings.TrimSpace(val[:20])
+	case "MARKER TYPE":
+		hdr.MarkerType = strings.TrimSpace(val[20:40])
+	case "OBSERVER / AGENCY":
+		hdr.Observer = strings.TrimSpace(val[:20])
+		hdr.Agency = strings.TrimSpace(val[20:])
+	case "REC # / TYPE / VERS":
+		hdr.ReceiverNumber = strings.TrimSpace(val[:20])
+		hdr.ReceiverType = strings.TrimSpace(val[20:40])
+		hdr.ReceiverVersion = strings.TrimSpace(val[40:])
+	case "ANT # / TYPE":
+		hdr.AntennaNumber = strings.TrimSpace(val[:20])
+		hdr.AntennaType = strings.TrimSpace(val[20:40])
+	case "APPROX POSITION XYZ":
+		pos := strings.Fields(val)
+		if len(pos) != 3 {
+			return dec.newError(lineNum, key, 1, 42, fmt.Errorf("invalid position: %q", val))
+		}
+		if f64, err := strconv.ParseFloat(pos[0], 64); err == nil {
+			hdr.Position.X = f64
+		}
+		if f64, err := strconv.ParseFloat(pos[1], 64); err == nil {
+			hdr.Position.Y = f64
+		}
+		if f64, err := strconv.ParseFloat(pos[2], 64); err == nil {
+			hdr.Position.Z = f64
+		}
+	case "ANTENNA: DELTA H/E/N":
+		ecc := strings.Fields(val)
+		if len(ecc) != 3 {
+			return dec.newError(lineNum, key, 1, 42, fmt.Errorf("invalid antenna deltas: %q", val))
+		}
+		if f64, err := strconv.ParseFloat(ecc[0], 64); err == nil {
+			hdr.AntennaDelta.Up = f64
+		}
+		if f64, err := strconv.ParseFloat(ecc[1], 64); err == nil {
+			hdr.AntennaDelta.E = f64
+		}
+		if f64, err := strconv.ParseFloat(ecc[2], 64); err == nil {
+			hdr.AntennaDelta.N = f64
+		}
+	case "ANTENNA: DELTA X/Y/Z": // optional, for vehicles
+		c, err := parseCoord(val)
+		if err != nil {
+			return dec.newError(lineNum, key, 1, 42, err)
+		}
+		hdr.AntennaDeltaXYZ = c
+	case "ANTENNA: PHASECENTER": // optional
+		sys, ok := gnss.ByAbbr[val[:1]]
+		if !ok {
+			return dec.newError(lineNum, key, 1, 1, fmt.Errorf("invalid satellite system: %q", val[:1]))
+		}
+		c, err := parseCoord(val[5:])
+		if err != nil {
+			return dec.newError(lineNum, key, 6, 42, err)
+		}
+		hdr.AntennaPhaseCenters = append(hdr.AntennaPhaseCenters, PhaseCenter{Sys: sys, Code: ObsCode(strings.TrimSpace(val[2:5])), Pos: c})
+	case "ANTENNA: B.SIGHT XYZ": // optional
+		c, err := parseCoord(val)
+		if err != nil {
+			return dec.newError(lineNum, key, 1, 42, err)
+		}
+		hdr.AntennaBSight = c
+	case "ANTENNA: ZERODIR AZI": // optional
+		f64, err := strconv.ParseFloat(strings.TrimSpace(val[:14]), 64)
+		if err != nil {
+			return dec.newError(lineNum, key, 1, 14, err)
+		}
+		hdr.AntennaZeroDirAzi = f64
+	case "ANTENNA: ZERODIR XYZ": // optional
+		c, err := parseCoord(val)
+		if err != nil {
+			return dec.newError(lineNum, key, 1, 42, err)
+		}
+		hdr.AntennaZeroDirXYZ = c
+	case "CENTER OF MASS: XYZ": // optional
+		c, err := parseCoord(val)
+		if err != nil {
+			return dec.newError(lineNum, key, 1, 42, err)
+		}
+		hdr.CenterOfMass = c
+	case "WAVELENGTH FACT L1/2": // optional (RINEX-2 only)
+		var wf WavelengthFactor
+		var err error
+		if wf.L1, err = parseInt(val[:6]); err != nil {
+			return dec.newError(lineNum, key, 1, 6, err)
+		}
+		if wf.L2, err = parseInt(val[6:12]); err != nil {
+			return dec.newError(lineNum, key, 7, 12, err)
+		}
+		nSat, err := parseInt(val[12:18])
+		if err != nil || nSat > 7 {
+			return dec.newError(lineNum, key, 13, 18, fmt.Errorf("invalid number of satellites: %q", val[12:18]))
+		}
+		for i := range nSat {
+			s := val[21+i*6 : 24+i*6]
+			if s[0] == ' ' { // blank for GPS
+				s = "G" + s[1:]
 			}
-			if s, ok := gnss.ByAbbr[sys]; ok {
-				hdr.SatSystem = s
-			} else {
-				return hdr, fmt.Errorf("read header: invalid satellite system in line %d: %s", dec.lineNum, line)
+			prn, err := gnss.NewPRN(s)
+			if err != nil {
+				return dec.newError(lineNum, key, 22+i*6, 24+i*6, err)
 			}
-		case "PGM / RUN BY / DATE":
-			// Additional lines of this type can appear together after the second line, if needed to preserve the history of previous actions on the file.
-			if hdr.Pgm != "" {
-				continue
-				// TODO additional lines
+			wf.Sats = append(wf.Sats, prn)
+		}
+		hdr.WavelengthFactors = append(hdr.WavelengthFactors, wf)
+	case "SYS / # / OBS TYPES":
+		var sys gnss.System
+		if val[:1] == " " { // line continued
+			sys = st.sys
+		} else {
+			ok := false
+			if sys, ok = gnss.ByAbbr[val[:1]]; !ok {
+				return dec.newError(lineNum, key, 1, 1, fmt.Errorf("invalid satellite system: %q", val[:1]))
 			}
-			hdr.Pgm = strings.TrimSpace(val[:20])
-			hdr.RunBy = strings.TrimSpace(val[20:40])
-			if date, err := parseHeaderDate(strings.TrimSpace(val[40:])); err == nil {
-				hdr.Date = date
-			} else {
-				log.Printf("parse header date: %q, %v", val[40:], err)
+			st.sys = sys
+			nTypes, err := strconv.Atoi(strings.TrimSpace(val[3:6]))
+			if err != nil {
+				return dec.newError(lineNum, key, 4, 6, err)
 			}
-		case "COMMENT":
-			hdr.Comments = append(hdr.Comments, strings.TrimSpace(val))
-		case "MARKER NAME":
-			hdr.MarkerName = strings.TrimSpace(val)
-		case "MARKER NUMBER":
-			hdr.MarkerNumber = strings.TrimSpace(val[:20])
-		case "MARKER TYPE":
-			hdr.MarkerType = strings.TrimSpace(val[20:40])
-		case "OBSERVER / AGENCY":
-			hdr.Observer = strings.TrimSpace(val[:20])
-			hdr.Agency = strings.TrimSpace(val[20:])
-		case "REC # / TYPE / VERS":
-			hdr.ReceiverNumber = strings.TrimSpace(val[:20])
-			hdr.ReceiverType = strings.TrimSpace(val[20:40])
-			hdr.ReceiverVersion = strings.TrimSpace(val[40:])
-		case "ANT # / TYPE":
-			hdr.AntennaNumber = strings.TrimSpace(val[:20])
-			hdr.AntennaType = strings.TrimSpace(val[20:40])
-		case "APPROX POSITION XYZ":
-			pos := strings.Fields(val)
-			if len(pos) != 3 {
-				return hdr, fmt.Errorf("parse approx. position from line: %s", line)
-			}
-			if f64, err := strconv.ParseFloat(pos[0], 64); err == nil {
-				hdr.Position.X = f64
-			}
-			if f64, err := strconv.ParseFloat(pos[1], 64); err == nil {
-				hdr.Position.Y = f64
-			}
-			if f64, err := strconv.ParseFloat(pos[2], 64); err == nil {
-				hdr.Position.Z = f64
-			}
-		case "ANTENNA: DELTA H/E/N":
-			ecc := strings.Fields(val)
-			if len(ecc) != 3 {
-				return hdr, fmt.Errorf("parse antenna deltas from line: %s", line)
-			}
-			if f64, err := strconv.ParseFloat(ecc[0], 64); err == nil {
-				hdr.AntennaDelta.Up = f64
-			}
-			if f64, err := strconv.ParseFloat(ecc[1], 64); err == nil {
-				hdr.AntennaDelta.E = f64
-			}
-			if f64, err := strconv.ParseFloat(ecc[2], 64); err == nil {
-				hdr.AntennaDelta.N = f64
-			}
-		case "WAVELENGTH FACT L1/2": // optional (RINEX-2 only)
-		case "SYS / # / OBS TYPES":
-			var sys gnss.System
-			if val[:1] == " " { // line continued
-				sys = rememberSys
-			} else {
-				ok := false
-				if sys, ok = gnss.ByAbbr[val[:1]]; !ok {
-					return hdr, fmt.Errorf("read header: invalid satellite system: %q: line %d", val[:1], dec.lineNum)
-				}
-				rememberSys = sys
-				nTypes, err := strconv.Atoi(strings.TrimSpace(val[3:6]))
-				if err != nil {
-					return hdr, fmt.Errorf("parse %q: %v", key, err)
-				}
-				hdr.ObsTypes[sys] = make([]ObsCode, 0, nTypes)
-			}
-			obscodes := convStringsToObscodes(strings.Fields(val[7:]))
-			hdr.ObsTypes[sys] = append(hdr.ObsTypes[sys], obscodes...)
-		case "# / TYPES OF OBSERV": // RINEX-2
-			sys := hdr.SatSystem
-			if strings.TrimSpace(val[:6]) != "" { // number of obs types
-				nTypes, err := strconv.Atoi(strings.TrimSpace(val[:6]))
-				if err != nil {
-					return hdr, fmt.Errorf("parse %q: %v", key, err)
-				}
-				hdr.ObsTypes[sys] = make([]ObsCode, 0, nTypes)
-			}
-			obscodes := convStringsToObscodes(strings.Fields(val[7:]))
-			hdr.ObsTypes[sys] = append(hdr.ObsTypes[sys], obscodes...)
-		case "DOI":
-			hdr.DOI = strings.TrimSpace(val)
-		case "LICENSE OF USE":
-			hdr.Licenses = append(hdr.Licenses, strings.TrimSpace(val))
-		case "SIGNAL STRENGTH UNIT":
-			hdr.SignalStrengthUnit = strings.TrimSpace(val[:20])
-		case "INTERVAL":
-			if f64, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
-				hdr.Interval = f64
-			}
-		case "TIME OF FIRST OBS":
-			t, err := parseTimeFirstObs(strings.TrimSpace(val[:43]))
+			hdr.ObsTypes[sys] = make([]ObsCode, 0, nTypes)
+		}
+		obscodes := convStringsToObscodes(strings.Fields(val[7:]))
+		hdr.ObsTypes[sys] = append(hdr.ObsTypes[sys], obscodes...)
+	case "# / TYPES OF OBSERV": // RINEX-2
+		sys := hdr.SatSystem
+		if strings.TrimSpace(val[:6]) != "" { // number of obs types
+			nTypes, err := strconv.Atoi(strings.TrimSpace(val[:6]))
 			if err != nil {
-				return hdr, fmt.Errorf("parse %q: %v", key, err)
+				return dec.newError(lineNum, key, 1, 6, err)
 			}
-			hdr.TimeOfFirstObs = t
-		case "TIME OF LAST OBS":
-			t, err := parseTimeFirstObs(strings.TrimSpace(val[:43]))
+			hdr.ObsTypes[sys] = make([]ObsCode, 0, nTypes)
+		}
+		obscodes := convStringsToObscodes(strings.Fields(val[7:]))
+		hdr.ObsTypes[sys] = append(hdr.ObsTypes[sys], obscodes...)
+	case "DOI":
+		hdr.DOI = strings.TrimSpace(val)
+	case "LICENSE OF USE":
+		hdr.Licenses = append(hdr.Licenses, strings.TrimSpace(val))
+	case "STATION INFORMATION":
+		hdr.StationInfos = append(hdr.StationInfos, strings.TrimSpace(val))
+	case "SIGNAL STRENGTH UNIT":
+		hdr.SignalStrengthUnit = strings.TrimSpace(val[:20])
+	case "INTERVAL":
+		if f64, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+			hdr.Interval = f64
+		}
+	case "TIME OF FIRST OBS":
+		t, err := parseTimeFirstObs(strings.TrimSpace(val[:43]))
+		if err != nil {
+			return dec.newError(lineNum, key, 1, 43, err)
+		}
+		hdr.TimeOfFirstObs = t
+	case "TIME OF LAST OBS":
+		t, err := parseTimeFirstObs(strings.TrimSpace(val[:43]))
+		if err != nil {
+			return dec.newError(lineNum, key, 1, 43, err)
+		}
+		hdr.TimeOfLastObs = t
+	case "RCV CLOCK OFFS APPL": // optional
+		i, err := strconv.Atoi(strings.TrimSpace(val[:6]))
+		if err != nil {
+			return dec.newError(lineNum, key, 1, 6, err)
+		}
+		hdr.RcvClockOffsApplied = i == 1
+	case "SYS / DCBS APPLIED", "SYS / PCVS APPLIED": // optional
+		sys, ok := gnss.ByAbbr[val[:1]]
+		if !ok {
+			return dec.newError(lineNum, key, 1, 1, fmt.Errorf("invalid satellite system: %q", val[:1]))
+		}
+		corr := AppliedCorrection{Sys: sys, Program: strings.TrimSpace(val[2:19]), Source: strings.TrimSpace(val[20:])}
+		if key == "SYS / DCBS APPLIED" {
+			hdr.DCBsApplied = append(hdr.DCBsApplied, corr)
+		} else {
+			hdr.PCVsApplied = append(hdr.PCVsApplied, corr)
+		}
+	case "SYS / SCALE FACTOR": // optional
+		if val[:1] != " " {
+			sys, ok := gnss.ByAbbr[val[:1]]
+			if !ok {
+				return dec.newError(lineNum, key, 1, 1, fmt.Errorf("invalid satellite system: %q", val[:1]))
+			}
+			factor, err := strconv.Atoi(strings.TrimSpace(val[2:6]))
 			if err != nil {
-				return hdr, fmt.Errorf("parse %q: %v", key, err)
-			}
-			hdr.TimeOfLastObs = t
-		case "RCV CLOCK OFFS APPL": // TODO implement (field is optional)
-		case "SYS / PHASE SHIFT": // optional. This header line is strongly deprecated and should be ignored by decoders.
-		case "SYS / PHASE SHIFTS": // Rnx 3.01
-		case "GLONASS SLOT / FRQ #":
-			if strings.TrimSpace(val[:3]) != "" { // number of satellites
-				nSat, err := strconv.Atoi(strings.TrimSpace(val[:3]))
-				if err != nil {
-					return hdr, fmt.Errorf("parse %q: %v", key, err)
-				}
-				hdr.GloSlots = make(map[gnss.PRN]int, nSat)
+				return dec.newError(lineNum, key, 3, 6, err)
 			}
-			fields := strings.Fields(val[4:])
-			for i := 0; i < len(fields)-1; i++ {
-				prn, err := gnss.NewPRN(fields[i])
-				if err != nil {
-					return hdr, fmt.Errorf("parse %q: %v", key, err)
-				}
-				frq, err := strconv.Atoi(fields[i+1])
+			if factor < 1 {
+				return dec.newError(lineNum, key, 3, 6, fmt.Errorf("invalid scale factor: %d", factor))
+			}
+			hdr.ScaleFactors = append(hdr.ScaleFactors, ScaleFactor{Sys: sys, Factor: factor})
+		} else if len(hdr.ScaleFactors) == 0 { // line continued
+			return dec.newError(lineNum, key, 1, 1, errors.New("continuation line without system"))
+		}
+		sf := &hdr.ScaleFactors[len(hdr.ScaleFactors)-1]
+		sf.Codes = append(sf.Codes, convStringsToObscodes(strings.Fields(val[10:]))...)
+	case "SYS / PHASE SHIFT", "SYS / PHASE SHIFTS": // Rnx 3.01, deprecated since Rnx 3.05
+		if val[:1] != " " {
+			sys, ok := gnss.ByAbbr[val[:1]]
+			if !ok {
+				return dec.newError(lineNum, key, 1, 1, fmt.Errorf("invalid satellite system: %q", val[:1]))
+			}
+			ps := PhaseShift{Sys: sys, Code: ObsCode(strings.TrimSpace(val[2:5]))}
+			if corr := strings.TrimSpace(val[6:14]); corr != "" {
+				f64, err := strconv.ParseFloat(corr, 64)
 				if err != nil {
-					return hdr, fmt.Errorf("parse %q: %v", key, err)
+					return dec.newError(lineNum, key, 7, 14, err)
 				}
-				hdr.GloSlots[prn] = frq
-				i++
+				ps.Correction = f64
 			}
-		case "GLONASS COD/PHS/BIS": // optional. This header line is strongly deprecated and should be ignored by decoders.
-		case "LEAP SECONDS": // optional. not complete! TODO: extend
-			i, err := strconv.Atoi(strings.TrimSpace(val[:6]))
+			hdr.PhaseShifts = append(hdr.PhaseShifts, ps)
+		} else if len(hdr.PhaseShifts) == 0 { // line continued
+			return dec.newError(lineNum, key, 1, 1, errors.New("continuation line without system"))
+		}
+		ps := &hdr.PhaseShifts[len(hdr.PhaseShifts)-1]
+		for _, s := range strings.Fields(val[18:]) {
+			prn, err := gnss.NewPRN(s)
 			if err != nil {
-				return hdr, fmt.Errorf("parse %q: %v", key, err)
+				return dec.newError(lineNum, key, 19, 60, err)
 			}
-			hdr.LeapSeconds = i
-		case "# OF SATELLITES": // optional
-			i, err := strconv.Atoi(strings.TrimSpace(val[:6]))
+			ps.Sats = append(ps.Sats, prn)
+		}
+	case "GLONASS SLOT / FRQ #":
+		if strings.TrimSpace(val[:3]) != "" { // number of satellites
+			nSat, err := strconv.Atoi(strings.TrimSpace(val[:3]))
 			if err != nil {
-				return hdr, fmt.Errorf("parse %q: %v", key, err)
+				return dec.newError(lineNum, key, 1, 3, err)
 			}
-			hdr.NSatellites = i
-		case "PRN / # OF OBS": // optional
-			// TODO
-		case "END OF HEADER":
-			break readln
-		default:
-			log.Printf("Header field %q not handled yet", key)
+			hdr.GloSlots = make(map[gnss.PRN]int, nSat)
 		}
-
-		if maxLines > 0 && dec.lineNum == maxLines {
-			break readln
+		fields := strings.Fields(val[4:])
+		for i := 0; i < len(fields)-1; i++ {
+			prn, err := gnss.NewPRN(fields[i])
+			if err != nil {
+				return dec.newError(lineNum, key, 5, 60, err)
+			}
+			frq, err := strconv.Atoi(fields[i+1])
+			if err != nil {
+				return dec.newError(lineNum, key, 5, 60, err)
+			}
+			hdr.GloSlots[prn] = frq
+			i++
 		}
+	case "GLONASS COD/PHS/BIS": // optional, deprecated since Rnx 4.00
+		for i := 0; i+13 <= 60; i += 13 {
+			code, bias := strings.TrimSpace(val[i+1:i+4]), strings.TrimSpace(val[i+5:i+13])
+			if code == "" || bias == "" {
+				continue
+			}
+			f64, err := strconv.ParseFloat(bias, 64)
+			if err != nil {
+				return dec.newError(lineNum, key, i+6, i+13, err)
+			}
+			if hdr.GloCodPhsBias == nil {
+				hdr.GloCodPhsBias = make(map[ObsCode]float64, 4)
+			}
+			hdr.GloCodPhsBias[ObsCode(code)] = f64
+		}
+	case "LEAP SECONDS": // optional. not complete! TODO: extend
+		i, err := strconv.Atoi(strings.TrimSpace(val[:6]))
+		if err != nil {
+			return dec.newError(lineNum, key, 1, 6, err)
+		}
+		hdr.LeapSeconds = i
+	case "# OF SATELLITES": // optional
+		i, err := strconv.Atoi(strings.TrimSpace(val[:6]))
+		if err != nil {
+			return dec.newError(lineNum, key, 1, 6, err)
+		}
+		hdr.NSatellites = i
+	case "PRN / # OF OBS": // optional
+		if strings.TrimSpace(val[3:6]) != "" {
+			s := val[3:6]
+			if s[0] == ' ' { // RINEX-2: G or blank
+				s = "G" + s[1:]
+			}
+			prn, err := gnss.NewPRN(s)
+			if err != nil {
+				return dec.newError(lineNum, key, 4, 6, err)
+			}
+			st.prn = prn
+			if hdr.ObsPerSat == nil {
+				hdr.ObsPerSat = map[gnss.PRN][]int{}
+			}
+			hdr.ObsPerSat[prn] = nil
+		} else if st.prn == (gnss.PRN{}) { // line continued
+			return dec.newError(lineNum, key, 4, 6, errors.New("continuation line without satellite"))
+		}
+		for pos := 6; pos+6 <= len(val); pos += 6 {
+			s := strings.TrimSpace(val[pos : pos+6])
+			if s == "" {
+				break
+			}
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return dec.newError(lineNum, key, pos+1, pos+6, err)
+			}
+			hdr.ObsPerSat[st.prn] = append(hdr.ObsPerSat[st.prn], n)
+		}
+	default:
+		log.Printf("Header field %q not handled yet", key)
 	}
+	return nil
+}
 
-	if err := dec.sc.Err(); err != nil {
-		return hdr, err
+// resetRecord clears the values of the header record with the label key, to be redefined by an event.
+// The values of records given per satellite system are cleared for the system sys only.
+func (hdr *ObsHeader) resetRecord(key string, sys gnss.System) {
+	switch key {
+	case "ANTENNA: PHASECENTER":
+		hdr.AntennaPhaseCenters = slices.DeleteFunc(hdr.AntennaPhaseCenters, func(pc PhaseCenter) bool { return pc.Sys == sys })
+	case "SYS / DCBS APPLIED":
+		hdr.DCBsApplied = slices.DeleteFunc(hdr.DCBsApplied, func(corr AppliedCorrection) bool { return corr.Sys == sys })
+	case "SYS / PCVS APPLIED":
+		hdr.PCVsApplied = slices.DeleteFunc(hdr.PCVsApplied, func(corr AppliedCorrection) bool { return corr.Sys == sys })
+	case "SYS / SCALE FACTOR":
+		hdr.ScaleFactors = slices.DeleteFunc(hdr.ScaleFactors, func(sf ScaleFactor) bool { return sf.Sys == sys })
+	case "SYS / PHASE SHIFT", "SYS / PHASE SHIFTS":
+		hdr.PhaseShifts = slices.DeleteFunc(hdr.PhaseShifts, func(ps PhaseShift) bool { return ps.Sys == sys })
+	case "GLONASS COD/PHS/BIS":
+		hdr.GloCodPhsBias = nil
+	case "PRN / # OF OBS":
+		hdr.ObsPerSat = nil
 	}
+}
 
-	return hdr, err
+// parseCoord parses the three values of a header record like ANTENNA: DELTA X/Y/Z.
+func parseCoord(val string) (Coord, error) {
+	fields := strings.Fields(val)
+	if len(fields) != 3 {
+		return Coord{}, fmt.Errorf("invalid coordinates: %q", val)
+	}
+	var xyz [3]float64
+	for i, s := range fields {
+		f64, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Coord{}, err
+		}
+		xyz[i] = f64
+	}
+	return Coord{X: xyz[0], Y: xyz[1], Z: xyz[2]}, nil
 }
 
 // NextEpoch reads the observations for the next epoch.
 // It returns false when the scan stops, either by reaching the end of the input or an error.
+// Special events (epoch flag 2-5) are returned as epochs without observations, but with their special records.
 // TODO: add phase shifts
 func (dec *ObsDecoder) NextEpoch() bool {
+	for dec.next() {
+		if dec.filter == nil {
+			return true
+		}
+		if dec.filter.isAfterWindow(dec.epo) {
+			return false
+		}
+		if epo := dec.filter.Filter(dec.epo); epo != nil {
+			dec.epo = epo
+			return true
+		}
+	}
+	return false
+}
+
+// next reads the next epoch, depending on the RINEX version.
+func (dec *ObsDecoder) next() bool {
 	if dec.Header.RINEXVersion < 3 {
 		return dec.nextEpochv2()
 	}
+	if dec.chunks != nil {
+		return dec.nextChunked()
+	}
 	return dec.nextEpoch()
 }
 
+// SetIndexed sets the indexed mode. In indexed mode the observations are decoded into Epoch.IndexedObs
+// instead of Epoch.ObsList, which needs much less allocations. The observations of a satellite are in the
+// sequence of ObsTypesOf(prn.Sys). Note that an ObsFilter does not select the observation types of indexed observations.
+func (dec *ObsDecoder) SetIndexed(indexed bool) {
+	dec.indexed = indexed
+}
+
+// ObsTypesOf returns the observation types of the satellite system in the data, that the indexed observations refer to.
+// The types may differ from the Header's types if a filter is set.
+func (dec *ObsDecoder) ObsTypesOf(sys gnss.System) []ObsCode {
+	if dec.Header.RINEXVersion < 3 {
+		return dec.obsTypes[dec.Header.SatSystem]
+	}
+	return dec.obsTypes[sys]
+}
+
+// setObsTypes sets the observation types of the data and their scale factors according to the header hdr.
+func (dec *ObsDecoder) setObsTypes(hdr *ObsHeader) {
+	dec.obsTypes = hdr.ObsTypes
+	dec.scales = nil
+	for _, sf := range hdr.ScaleFactors {
+		if sf.Factor <= 1 {
+			continue
+		}
+		if dec.scales == nil {
+			dec.scales = make(map[gnss.System][]float64, len(hdr.ScaleFactors))
+		}
+		typs := hdr.ObsTypes[sf.Sys]
+		scales := make([]float64, len(typs))
+		for i, typ := range typs {
+			scales[i] = float64(hdr.ScaleFactorOf(sf.Sys, typ))
+		}
+		dec.scales[sf.Sys] = scales
+	}
+}
+
+// RunningHeader returns the header that is valid for the current epoch, i.e. the Header updated by the
+// header records of the special events with flag 3 (new site occupation) and 4 (header information) read so far.
+// Records with values per satellite system, like SYS / PHASE SHIFT, replace the values of the systems given,
+// comments are appended. Changed observation types and scale factors apply to the following epochs.
+func (dec *ObsDecoder) RunningHeader() *ObsHeader {
+	if dec.running == nil {
+		return &dec.Header
+	}
+	return dec.running
+}
+
+// updateHeader applies the header records of a special event with flag 3 or 4 to the running header.
+// Corrupt records are skipped, they are reported by decodeEvent.
+func (dec *ObsDecoder) updateHeader(epo *Epoch) {
+	if (epo.Flag != EpochFlagNewSite && epo.Flag != EpochFlagHeaderInfo) || len(epo.Records) == 0 {
+		return
+	}
+	if dec.running == nil {
+		dec.running = dec.Header.clone()
+	}
+	hdr := dec.running
+	var st headerState
+	seen := make(map[string]bool, len(epo.Records))
+	typesChanged := false
+	for _, line := range epo.Records {
+		if len(line) < 60 {
+			continue
+		}
+		val, key := line[:60], strings.TrimSpace(line[60:])
+		if key == "END OF HEADER" {
+			continue
+		}
+		sys, hasSys := gnss.ByAbbr[val[:1]]
+		id := key
+		if hasSys {
+			id += " " + val[:1]
+		}
+		if !seen[id] {
+			seen[id] = true
+			hdr.resetRecord(key, sys)
+		}
+		if !slices.Contains(hdr.Labels, key) {
+			hdr.Labels = append(hdr.Labels, key)
+		}
+		switch key {
+		case "SYS / # / OBS TYPES", "# / TYPES OF OBSERV", "SYS / SCALE FACTOR":
+			typesChanged = true
+		}
+		_ = dec.parseHeaderRecord(hdr, val, key, 0, &st)
+	}
+	if typesChanged {
+		dec.setObsTypes(hdr)
+	}
+}
+
+// decodeEvent decodes the special records of an event epoch with the flag. firstLine is the line number of the
+// first record. Corrupt records are added to the warnings.
+func (dec *ObsDecoder) decodeEvent(flag EpochFlag, records []string, firstLine int) *Event {
+	ev := &Event{Flag: flag}
+	var st headerState
+	for i, line := range records {
+		if len(line) < 60 {
+			continue
+		}
+		val, key := line[:60], strings.TrimSpace(line[60:])
+		switch key {
+		case "COMMENT":
+			ev.Comments = append(ev.Comments, strings.TrimSpace(val))
+			continue
+		case "END OF HEADER":
+			continue
+		}
+		if ev.Header == nil {
+			ev.Header = &ObsHeader{RINEXVersion: dec.Header.RINEXVersion, SatSystem: dec.Header.SatSystem, ObsTypes: map[gnss.System][]ObsCode{}}
+		}
+		ev.Header.Labels = append(ev.Header.Labels, key)
+		if err := dec.parseHeaderRecord(ev.Header, val, key, firstLine+i, &st); err != nil {
+			var rerr *RinexError
+			if errors.As(err, &rerr) {
+				dec.warn(rerr)
+			}
+		}
+	}
+	return ev
+}
+
+// maxObsTypes returns the maximum number of observation types per satellite system.
+func (dec *ObsDecoder) maxObsTypes() int {
+	n := 0
+	for _, typs := range dec.obsTypes {
+		n = max(n, len(typs))
+	}
+	return n
+}
+
 // Read RINEX version 2 obs file.
 func (dec *ObsDecoder) nextEpochv2() bool {
 readln:
@@ -275,50 +671,58 @@ readln:
 		if len(line) < 1 {
 			continue
 		}
+		if len(line) < 32 {
+			if dec.fail(recordEpoch, 1, len(line), fmt.Errorf("invalid epoch line: %q", line)) {
+				return false
+			}
+			continue
+		}
 
 		flag, err := parseEpochFlag(line[28:29])
 		if err != nil {
-			dec.setErr(fmt.Errorf("rinex2: parse epoch flag in line %d: %q: %v", dec.lineNum, line, err))
-			return false
-		}
-
-		// flag == 2: start moving antenna - no action
-		if int8(flag) >= 3 {
-			numSpecialRecords, err := strconv.Atoi(strings.TrimSpace(line[29:32]))
-			if err != nil {
-				dec.setErr(fmt.Errorf("rinex: line %d: %v", dec.lineNum, err))
+			if dec.fail(recordEpoch, 29, 29, err) {
 				return false
 			}
-			if flag == EpochFlagNewSite || flag == EpochFlagHeaderInfo {
-				// TODO reread header
-				// dec.readHeader(numSpecialRecords)
-				for ii := 1; ii <= numSpecialRecords; ii++ {
-					if ok := dec.readLine(); !ok {
-						break readln
-					}
-				}
-			} else {
-				for ii := 1; ii <= numSpecialRecords; ii++ {
-					if ok := dec.readLine(); !ok {
-						break readln
-					}
+			continue
+		}
+
+		// Special events: flag 2-5, the special records are stored as they are.
+		if flag > EpochFlagPowerFailure && flag != EpochFlagCycleSlip {
+			if err := dec.readEvent(line[1:min(len(line), 26)], epochTimeFormatv2, flag, line[29:min(len(line), 32)]); err != nil {
+				if dec.fail(recordEpoch, 2, 32, err) {
+					return false
 				}
+				continue
 			}
-			continue
+			return true
 		}
 
 		epoTime, err := time.Parse(epochTimeFormatv2, line[1:26])
 		if err != nil {
-			dec.setErr(fmt.Errorf("rinex2: line %d: %v", dec.lineNum, err))
-			return false
+			if dec.fail(recordEpoch, 2, 26, err) {
+				return false
+			}
+			continue
 		}
 
 		// Number of satellites
 		numSat, err := strconv.Atoi(strings.TrimSpace(line[29:32]))
 		if err != nil {
-			dec.setErr(fmt.Errorf("rinex2: line %d: %v", dec.lineNum, err))
-			return false
+			if dec.fail(recordEpoch, 30, 32, err) {
+				return false
+			}
+			continue
+		}
+
+		// Receiver clock offset (optional)
+		clock, err := parseClockOffset(line, 68, 80)
+		if err != nil {
+			if dec.fail(recordEpoch, 69, 80, err) {
+				return false
+			}
+			continue
 		}
+		dec.resync = false
 
 		// Read list of PRNs
 		pos := 32
@@ -332,6 +736,13 @@ readln:
 				pos = 32
 			}
 
+			if pos+3 > len(line) {
+				if dec.fail(recordEpoch, pos+1, pos+3, fmt.Errorf("missing satellite %d of %d", iSat+1, numSat)) {
+					return false
+				}
+				continue readln
+			}
+
 			// G or blank: GPS
 			myprn := line[pos : pos+3]
 			if myprn[0] == ' ' {
@@ -340,18 +751,23 @@ readln:
 
 			prn, err := gnss.NewPRN(myprn)
 			if err != nil {
-				dec.setErr(fmt.Errorf("rinex2: new PRN in line %d: %q: %v", dec.lineNum, line, err))
-				return false
+				if dec.fail(recordEpoch, pos+1, pos+3, err) {
+					return false
+				}
+				continue readln
 			}
 			sats = append(sats, prn)
 			pos += 3
 		}
 
-		dec.epo = &Epoch{Time: epoTime, Flag: flag, NumSat: uint8(numSat),
-			ObsList: make([]SatObs, 0, numSat)}
+		dec.epo = dec.newEpoch(epoTime, flag, numSat, clock)
 
 		// Read observations
-		obsTypes := dec.Header.ObsTypes[dec.Header.SatSystem]
+		obsTypes := dec.obsTypes[dec.Header.SatSystem]
+		var obsBuf []Obs
+		if dec.indexed {
+			obsBuf = make([]Obs, 0, numSat*len(obsTypes))
+		}
 		for _, prn := range sats {
 			if ok := dec.readLine(); !ok {
 				break readln
@@ -359,7 +775,11 @@ readln:
 			line = dec.line()
 			linelen := len(line)
 
-			obsPerTyp := make(map[ObsCode]Obs, len(obsTypes))
+			var obsPerTyp map[ObsCode]Obs
+			if !dec.indexed {
+				obsPerTyp = make(map[ObsCode]Obs, len(obsTypes))
+			}
+			start := len(obsBuf)
 			pos := 0
 			for ityp, typ := range obsTypes {
 				if ityp > 0 && ityp%5 == 0 {
@@ -371,7 +791,7 @@ readln:
 					pos = 0
 				}
 				if pos >= linelen {
-					obsPerTyp[typ] = Obs{}
+					obsBuf = addObs(obsPerTyp, obsBuf, typ, Obs{})
 					continue
 				}
 				end := pos + 16
@@ -380,19 +800,21 @@ readln:
 				}
 				obs, err := decodeObs(line[pos:end], flag)
 				if err != nil {
-					dec.setErr(fmt.Errorf("rinex2: parse %s observation in line %d: %q: %v", typ, dec.lineNum, line, err))
-					return false
+					if dec.fail(recordObservation, pos+1, end, fmt.Errorf("%s %s: %v", prn, typ, err)) {
+						return false
+					}
+					continue readln
 				}
-				obsPerTyp[typ] = obs
+				obsBuf = addObs(obsPerTyp, obsBuf, typ, obs)
 				pos += 16
 			}
-			dec.epo.ObsList = append(dec.epo.ObsList, SatObs{Prn: prn, Obss: obsPerTyp})
+			dec.epo.addSatObs(prn, obsPerTyp, obsBuf[start:])
 		}
 		return true
 	}
 
 	if err := dec.sc.Err(); err != nil {
-		dec.setErr(fmt.Errorf("rinex2: read epochs: %v (L%d)", err, dec.lineNum))
+		dec.setErr(dec.readError(dec.lineNum, err))
 	}
 
 	return false // EOF
@@ -407,57 +829,72 @@ readln:
 		}
 
 		if !strings.HasPrefix(line, "> ") {
-			log.Printf("rinex: stream does not start with epoch line: %q", line) // must not be an error
+			// must not be an error
+			if !dec.resync {
+				dec.warn(dec.newError(dec.lineNum, recordEpoch, 1, 2, fmt.Errorf("stream does not start with epoch line: %q", line)))
+				dec.resync = true
+			}
+			continue
+		}
+		if len(line) < 35 {
+			if dec.fail(recordEpoch, 1, len(line), fmt.Errorf("invalid epoch line: %q", line)) {
+				return false
+			}
 			continue
 		}
 
 		flag, err := parseEpochFlag(line[31:32])
 		if err != nil {
-			dec.setErr(fmt.Errorf("rinex: parse epoch flag in line %d: %q: %v", dec.lineNum, line, err))
-			return false
-		}
-
-		// flag == 2: start moving antenna - no action
-		if int8(flag) >= 3 {
-			numSpecialRecords, err := strconv.Atoi(strings.TrimSpace(line[32:35]))
-			if err != nil {
-				dec.setErr(fmt.Errorf("rinex: line %d: %v", dec.lineNum, err))
+			if dec.fail(recordEpoch, 32, 32, err) {
 				return false
 			}
-			if flag == EpochFlagNewSite || flag == EpochFlagHeaderInfo {
-				// TODO reread header
-				// dec.readHeader(numSpecialRecords)
-				for ii := 1; ii <= numSpecialRecords; ii++ {
-					if ok := dec.readLine(); !ok {
-						break readln
-					}
-				}
-			} else {
-				for ii := 1; ii <= numSpecialRecords; ii++ {
-					if ok := dec.readLine(); !ok {
-						break readln
-					}
+			continue
+		}
+
+		// Special events: flag 2-5, the special records are stored as they are.
+		if flag > EpochFlagPowerFailure && flag != EpochFlagCycleSlip {
+			if err := dec.readEvent(line[2:min(len(line), 29)], epochTimeFormat, flag, line[32:min(len(line), 35)]); err != nil {
+				if dec.fail(recordEpoch, 3, 35, err) {
+					return false
 				}
+				continue
 			}
-			continue
+			return true
 		}
 
 		epoTime, err := time.Parse(epochTimeFormat, line[2:29])
 		if err != nil {
-			dec.setErr(fmt.Errorf("rinex: line %d: %v", dec.lineNum, err))
-			return false
+			if dec.fail(recordEpoch, 3, 29, err) {
+				return false
+			}
+			continue
 		}
 
 		numSat, err := strconv.Atoi(strings.TrimSpace(line[32:35]))
 		if err != nil {
-			dec.setErr(fmt.Errorf("rinex: line %d: %v", dec.lineNum, err))
-			return false
+			if dec.fail(recordEpoch, 33, 35, err) {
+				return false
+			}
+			continue
+		}
+
+		// Receiver clock offset (optional)
+		clock, err := parseClockOffset(line, 41, 56)
+		if err != nil {
+			if dec.fail(recordEpoch, 42, 56, err) {
+				return false
+			}
+			continue
 		}
+		dec.resync = false
 
-		dec.epo = &Epoch{Time: epoTime, Flag: flag, NumSat: uint8(numSat),
-			ObsList: make([]SatObs, 0, numSat)}
+		dec.epo = dec.newEpoch(epoTime, flag, numSat, clock)
 
 		// Read observations
+		var obsBuf []Obs
+		if dec.indexed {
+			obsBuf = make([]Obs, 0, numSat*dec.maxObsTypes())
+		}
 		for ii := 1; ii <= numSat; ii++ {
 			if ok := dec.readLine(); !ok {
 				break readln
@@ -465,10 +902,18 @@ readln:
 			line = dec.line()
 			linelen := len(line)
 
+			if linelen < 3 {
+				if dec.fail(recordObservation, 1, 3, fmt.Errorf("invalid observation line: %q", line)) {
+					return false
+				}
+				continue readln
+			}
 			prn, err := gnss.NewPRN(line[0:3])
 			if err != nil {
-				dec.setErr(fmt.Errorf("rinex: parse sat num in line %d: %q: %v", dec.lineNum, line, err))
-				return false
+				if dec.fail(recordObservation, 1, 3, err) {
+					return false
+				}
+				continue readln
 			}
 
 			if strings.TrimSpace(line[3:]) == "" { // ??
@@ -476,12 +921,16 @@ readln:
 			}
 
 			sys := gnss.ByAbbr[line[:1]]
-			ntypes := len(dec.Header.ObsTypes[sys])
-			obsPerTyp := make(map[ObsCode]Obs, ntypes)
-			for ityp, typ := range dec.Header.ObsTypes[sys] {
+			scales := dec.scales[sys]
+			var obsPerTyp map[ObsCode]Obs
+			if !dec.indexed {
+				obsPerTyp = make(map[ObsCode]Obs, len(dec.obsTypes[sys]))
+			}
+			start := len(obsBuf)
+			for ityp, typ := range dec.obsTypes[sys] {
 				pos := 3 + 16*ityp
 				if pos >= linelen {
-					obsPerTyp[typ] = Obs{}
+					obsBuf = addObs(obsPerTyp, obsBuf, typ, Obs{})
 					continue
 				}
 				end := pos + 16
@@ -490,29 +939,97 @@ readln:
 				}
 				obs, err := decodeObs(line[pos:end], flag)
 				if err != nil {
-					dec.setErr(fmt.Errorf("rinex: parse %s observation in line %d: %q: %v", typ, dec.lineNum, line, err))
-					return false
+					if dec.fail(recordObservation, pos+1, end, fmt.Errorf("%s %s: %v", prn, typ, err)) {
+						return false
+					}
+					continue readln
+				}
+				if scales != nil {
+					obs.Val /= scales[ityp]
 				}
-				obsPerTyp[typ] = obs
+				obsBuf = addObs(obsPerTyp, obsBuf, typ, obs)
 			}
-			dec.epo.ObsList = append(dec.epo.ObsList, SatObs{Prn: prn, Obss: obsPerTyp})
+			dec.epo.addSatObs(prn, obsPerTyp, obsBuf[start:])
 		}
 		return true
 	}
 
 	if err := dec.sc.Err(); err != nil {
-		dec.setErr(fmt.Errorf("rinex: read epochs: %v (L%d)", err, dec.lineNum))
+		dec.setErr(dec.readError(dec.lineNum, err))
 	}
 
 	return false // EOF
 }
 
+// newEpoch returns a new epoch with the list of observations allocated for numSat satellites.
+func (dec *ObsDecoder) newEpoch(t time.Time, flag EpochFlag, numSat int, clock float64) *Epoch {
+	epo := &Epoch{Time: t, Flag: flag, NumSat: uint8(numSat), ClockOffset: clock}
+	if dec.indexed {
+		epo.IndexedObs = make([]IndexedSatObs, 0, numSat)
+	} else {
+		epo.ObsList = make([]SatObs, 0, numSat)
+	}
+	return epo
+}
+
+// addObs adds the observation to the map, or appends it to the indexed observations if the map is nil.
+func addObs(obsPerTyp map[ObsCode]Obs, obsBuf []Obs, typ ObsCode, obs Obs) []Obs {
+	if obsPerTyp != nil {
+		obsPerTyp[typ] = obs
+		return obsBuf
+	}
+	return append(obsBuf, obs)
+}
+
+// addSatObs adds the observations of a satellite, either the map or the indexed observations if the map is nil.
+func (epo *Epoch) addSatObs(prn gnss.PRN, obsPerTyp map[ObsCode]Obs, obs []Obs) {
+	if obsPerTyp != nil {
+		epo.ObsList = append(epo.ObsList, SatObs{Prn: prn, Obss: obsPerTyp})
+		return
+	}
+	epo.IndexedObs = append(epo.IndexedObs, IndexedSatObs{Prn: prn, Obs: obs[:len(obs):len(obs)]})
+}
+
+// readEvent reads a special event epoch (flag 2-5) and its special records, e.g. header lines, and decodes the records
+// into the Event. The header records of events with flag 3 and 4 are applied to the running header.
+// The epoch time is optional for events.
+func (dec *ObsDecoder) readEvent(timeStr, layout string, flag EpochFlag, numStr string) error {
+	epo := &Epoch{Flag: flag}
+	if strings.TrimSpace(timeStr) != "" {
+		epoTime, err := time.Parse(layout, timeStr)
+		if err != nil {
+			return err
+		}
+		epo.Time = epoTime
+	}
+
+	numSpecialRecords, err := strconv.Atoi(strings.TrimSpace(numStr))
+	if err != nil {
+		return err
+	}
+	dec.resync = false
+
+	epo.Records = make([]string, 0, numSpecialRecords)
+	for ii := 1; ii <= numSpecialRecords; ii++ {
+		if ok := dec.readLine(); !ok {
+			return errors.New("unexpected EOF in special records")
+		}
+		epo.Records = append(epo.Records, dec.line())
+	}
+	epo.Event = dec.decodeEvent(flag, epo.Records, dec.lineNum-numSpecialRecords+1)
+	dec.epo = epo
+	dec.updateHeader(epo)
+	return nil
+}
+
 // Epoch returns the most recent epoch generated by a call to NextEpoch.
 func (dec *ObsDecoder) Epoch() *Epoch {
 	return dec.epo
 }
 
 // SyncEpoch returns the current pair of time-synchronized epochs from two RINEX Obs input streams.
+//
+// Deprecated: use Zip.
 func (dec *ObsDecoder) SyncEpoch() SyncEpochs {
 	return SyncEpochs{dec.epo, dec.syncEpo}
 }
@@ -522,10 +1039,39 @@ func (dec *ObsDecoder) setErr(err error) {
 	dec.err = errors.Join(dec.err, err)
 }
 
+// SetLenient sets the lenient mode. In lenient mode a corrupt epoch is skipped and added to the warnings,
+// and the decoder continues with the next epoch. By default the decoder stops at the first corrupt epoch.
+func (dec *ObsDecoder) SetLenient(lenient bool) {
+	dec.lenient = lenient
+}
+
+// Warnings returns the warnings that occurred while decoding the data, e.g. the epochs skipped in lenient mode.
+func (dec *ObsDecoder) Warnings() []*RinexError {
+	return dec.warnings
+}
+
+// fail handles a corrupt data record in the current line. In lenient mode the error is added to the warnings,
+// and the lines are skipped until the next valid epoch. Otherwise the error is set and fail returns true,
+// i.e. the decoder must stop.
+func (dec *ObsDecoder) fail(record string, startCol, endCol int, err error) (stop bool) {
+	if !dec.lenient {
+		dec.setErr(dec.newError(dec.lineNum, record, startCol, endCol, err))
+		return true
+	}
+	if !dec.resync { // report only the first error of a corrupt epoch
+		dec.warn(dec.newError(dec.lineNum, record, startCol, endCol, err))
+		dec.resync = true
+	}
+	return false
+}
+
 // sync returns a stream of time-synchronized epochs from two RINEX Obs input streams.
 func (dec *ObsDecoder) sync(dec2 *ObsDecoder) bool {
 	var epoF1, epoF2 *Epoch
 	for dec.NextEpoch() {
+		if dec.Epoch().Flag > EpochFlagPowerFailure { // special event
+			continue
+		}
 		epoF1 = dec.Epoch()
 		//fmt.Printf("%s: got f1\n", epoF1.Time)
 
@@ -540,6 +1086,9 @@ func (dec *ObsDecoder) sync(dec2 *ObsDecoder) bool {
 
 		// now we need the next epo2
 		for dec2.NextEpoch() {
+			if dec2.Epoch().Flag > EpochFlagPowerFailure { // special event
+				continue
+			}
 			epoF2 = dec2.Epoch()
 			//fmt.Printf("%s: got f2\n", epoF2.Time)
 			if epoF2.Time.Equal(epoF1.Time) {
@@ -624,6 +1173,18 @@ func decodeObs(s string, flag EpochFlag) (obs Obs, err error) {
 	return obs, err
 }
 
+// parse the optional receiver clock offset in the epoch line, that is given in the columns start to end.
+func parseClockOffset(line string, start, end int) (float64, error) {
+	if len(line) <= start {
+		return 0, nil
+	}
+	s := strings.TrimSpace(line[start:min(len(line), end)])
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
 // lookup table for epoch flags.
 var epochFlagMap = map[int8]EpochFlag{
 	0: EpochFlagOK,
@@ -1,10 +1,14 @@
 package rinex
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -13,6 +17,68 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// newObsDecoder opens the RINEX obs file, also compressed, and returns a decoder for it. The setup functions
+// configure the decoder, e.g. with a filter.
+func newObsDecoder(t *testing.T, path string, setup ...func(dec *ObsDecoder)) *ObsDecoder {
+	t.Helper()
+	r, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	dec, err := NewObsDecoder(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range setup {
+		f(dec)
+	}
+	return dec
+}
+
+// readObsFile decodes the header and all epochs of the RINEX obs file.
+func readObsFile(t *testing.T, path string, setup ...func(dec *ObsDecoder)) (ObsHeader, []*Epoch) {
+	t.Helper()
+	dec := newObsDecoder(t, path, setup...)
+	return dec.Header, readAllEpochs(t, dec)
+}
+
+// readAllEpochs decodes all epochs of the decoder.
+func readAllEpochs(t *testing.T, dec *ObsDecoder) []*Epoch {
+	t.Helper()
+	epochs := []*Epoch{}
+	for dec.NextEpoch() {
+		epochs = append(epochs, dec.Epoch())
+	}
+	if err := dec.Err(); err != nil {
+		t.Fatalf("read epochs: %v", err)
+	}
+	return epochs
+}
+
+// encodeObs encodes the epochs with the header and returns a decoder for the encoded data.
+func encodeObs(t *testing.T, hdr ObsHeader, epochs []*Epoch) *ObsDecoder {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := NewObsEncoder(&buf, hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, epo := range epochs {
+		if err := enc.Encode(epo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := NewObsDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dec
+}
+
 func TestObsDecoder_readHeader(t *testing.T) {
 	const header = `     3.03           OBSERVATION DATA    M                   RINEX VERSION / TYPE
 sbf2rin-12.3.1                          20181106 200225 UTC PGM / RUN BY / DATE
@@ -173,6 +239,57 @@ Rebisel             BKA                                     OBSERVER / AGENCY
 	t.Logf("RINEX Header: %+v\n", dec.Header)
 }
 
+func TestObsDecoder_wavelengthFactors(t *testing.T) {
+	const header = `     2.11           OBSERVATION DATA    G (GPS)             RINEX VERSION / TYPE
+KLOP                                                        MARKER NAME
+     1     1                                                WAVELENGTH FACT L1/2
+     1     2     7   G14   G15    16   G17   G18   G19   G20WAVELENGTH FACT L1/2
+     1     2     1   G21                                    WAVELENGTH FACT L1/2
+     1     0     1   G22                                    WAVELENGTH FACT L1/2
+     4    C1    L1    P2    L2                              # / TYPES OF OBSERV
+    97    12    30     0     0    0.000000                  TIME OF FIRST OBS
+                                                            END OF HEADER
+`
+	assert := assert.New(t)
+	dec, err := NewObsDecoder(strings.NewReader(header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sats := func(nums ...int8) []gnss.PRN {
+		prns := make([]gnss.PRN, 0, len(nums))
+		for _, num := range nums {
+			prns = append(prns, gnss.PRN{Sys: gnss.SysGPS, Num: num})
+		}
+		return prns
+	}
+	hdr := dec.Header
+	assert.Equal([]WavelengthFactor{
+		{L1: 1, L2: 1},
+		{L1: 1, L2: 2, Sats: sats(14, 15, 16, 17, 18, 19, 20)},
+		{L1: 1, L2: 2, Sats: sats(21)},
+		{L1: 1, L2: 0, Sats: sats(22)},
+	}, hdr.WavelengthFactors)
+
+	// The factors are written and decoded again, with 7 satellites per line.
+	var buf bytes.Buffer
+	assert.NoError(hdr.Write(&buf))
+	assert.Contains(buf.String(), "     1     2     7   G14   G15   G16   G17   G18   G19   G20WAVELENGTH FACT L1/2")
+	dec2, err := NewObsDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]WavelengthFactor{
+		{L1: 1, L2: 1},
+		{L1: 1, L2: 2, Sats: sats(14, 15, 16, 17, 18, 19, 20)},
+		{L1: 1, L2: 2, Sats: sats(21)},
+		{L1: 1, L2: 0, Sats: sats(22)},
+	}, dec2.Header.WavelengthFactors)
+
+	// An invalid number of satellites.
+	_, err = NewObsDecoder(strings.NewReader(strings.Replace(header, "     1     2     1   G21", "     1     2     X   G21", 1)))
+	assert.ErrorIs(err, ErrParser)
+}
+
 func TestReadEpochs(t *testing.T) {
 	assert := assert.New(t)
 	filepath := "testdata/white/REYK00ISL_R_20192701000_01H_30S_MO.rnx"
@@ -505,6 +622,15 @@ func TestReadEpochsWithFlag4(t *testing.T) {
 	}
 	err = dec.Err()
 	assert.NoError(err)
+
+	// The header records at the end of the file update the running header.
+	hdr := dec.RunningHeader()
+	assert.Equal(30, hdr.NSatellites)
+	assert.Equal(0, dec.Header.NSatellites, "header unchanged")
+	assert.Equal([]int{120, 120, 120, 120, 120, 120, 120, 120, 0, 0, 0, 0}, hdr.ObsPerSat[gnss.PRN{Sys: gnss.SysGPS, Num: 2}])
+	assert.Equal(slices.Repeat([]int{53}, 16), hdr.ObsPerSat[gnss.PRN{Sys: gnss.SysGAL, Num: 26}])
+	assert.Equal(map[ObsCode]float64{"C1C": -71.94, "C1P": -71.94, "C2C": -71.94, "C2P": -71.94}, hdr.GloCodPhsBias)
+	assert.False(hdr.RcvClockOffsApplied)
 }
 
 func Test_parseEpochFlag(t *testing.T) {
@@ -535,3 +661,351 @@ func Test_parseEpochFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestObsDecoder_lenient(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		isEpoch  func(line string) bool
+		flagCol  int
+		obsCol   int
+		obsLines func(line string) int // the number of lines to the first observation line
+	}{
+		{
+			name:     "v3",
+			file:     "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx",
+			isEpoch:  func(line string) bool { return strings.HasPrefix(line, "> ") },
+			flagCol:  32,
+			obsCol:   4,
+			obsLines: func(line string) int { return 1 },
+		},
+		{
+			name:     "v2",
+			file:     "testdata/white/brst155h.20o",
+			isEpoch:  func(line string) bool { return strings.HasPrefix(line, " 20  6  3 ") },
+			flagCol:  29,
+			obsCol:   1,
+			obsLines: func(line string) int { n, _ := strconv.Atoi(strings.TrimSpace(line[29:32])); return 1 + (n-1)/12 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			data, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			countEpochs := func(dec *ObsDecoder) int {
+				n := 0
+				for dec.NextEpoch() {
+					n++
+				}
+				return n
+			}
+			dec, err := NewObsDecoder(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			nEpochs := countEpochs(dec)
+			assert.NoError(dec.Err())
+
+			// Corrupt the epoch flag of the 3rd epoch and an observation of the 5th epoch.
+			lines := strings.Split(string(data), "\n")
+			epochLines := []int{}
+			for i, line := range lines {
+				if tt.isEpoch(line) {
+					epochLines = append(epochLines, i)
+				}
+			}
+			flagLine := epochLines[2]
+			lines[flagLine] = lines[flagLine][:tt.flagCol-1] + "x" + lines[flagLine][tt.flagCol:]
+			obsLine := epochLines[4] + tt.obsLines(lines[epochLines[4]])
+			lines[obsLine] = lines[obsLine][:tt.obsCol-1] + "  12x456.789" + lines[obsLine][tt.obsCol+11:]
+			corrupt := strings.Join(lines, "\n")
+
+			dec, err = NewObsDecoder(strings.NewReader(corrupt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(2, countEpochs(dec))
+			err = dec.Err()
+			assert.ErrorIs(err, ErrParser)
+			var rerr *RinexError
+			if assert.ErrorAs(err, &rerr) {
+				assert.Equal(flagLine+1, rerr.Line)
+				assert.Equal(tt.flagCol, rerr.StartCol)
+				assert.Equal("EPOCH", rerr.Record)
+				assert.Equal(SeverityError, rerr.Severity)
+			}
+
+			dec, err = NewObsDecoder(strings.NewReader(corrupt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec.SetLenient(true)
+			assert.Equal(nEpochs-2, countEpochs(dec))
+			assert.NoError(dec.Err())
+			warnings := dec.Warnings()
+			if assert.Len(warnings, 2) {
+				assert.Equal(flagLine+1, warnings[0].Line)
+				assert.Equal("EPOCH", warnings[0].Record)
+				assert.Equal(SeverityWarning, warnings[0].Severity)
+				assert.Equal(obsLine+1, warnings[1].Line)
+				assert.Equal("OBSERVATION", warnings[1].Record)
+				assert.Equal(tt.obsCol, warnings[1].StartCol)
+				assert.Equal(tt.obsCol+15, warnings[1].EndCol)
+			}
+		})
+	}
+}
+
+func TestObsDecoder_headerError(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "test.rnx")
+	const header = `     3.04           OBSERVATION DATA    M                   RINEX VERSION / TYPE
+G    x C1C L1C                                              SYS / # / OBS TYPES
+                                                            END OF HEADER
+`
+	if err := os.WriteFile(path, []byte(header), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	_, err = NewObsDecoder(r)
+	var rerr *RinexError
+	if assert.ErrorAs(err, &rerr) {
+		assert.Equal(path, rerr.File)
+		assert.Equal(2, rerr.Line)
+		assert.Equal("SYS / # / OBS TYPES", rerr.Record)
+		assert.Equal(fmt.Sprintf("rinex: %s:2:4-6: SYS / # / OBS TYPES: strconv.Atoi: parsing \"x\": invalid syntax", path), err.Error())
+	}
+}
+
+func TestObsDecoder_SetIndexed(t *testing.T) {
+	for _, file := range []string{"testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", "testdata/white/brst155h.20o"} {
+		t.Run(file, func(t *testing.T) {
+			assert := assert.New(t)
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := decodeEpochs(t, string(data), func(dec *ObsDecoder) {})
+			got, dec := decodeEpochs(t, string(data), func(dec *ObsDecoder) { dec.SetIndexed(true) })
+			assert.NoError(dec.Err())
+			if !assert.Equal(len(want), len(got)) {
+				return
+			}
+			for i, epo := range got {
+				assert.Nil(epo.ObsList)
+				if !assert.Len(epo.IndexedObs, len(want[i].ObsList)) {
+					continue
+				}
+				for j, so := range epo.IndexedObs {
+					assert.Equal(want[i].ObsList[j], so.SatObs(dec.ObsTypesOf(so.Prn.Sys)))
+				}
+			}
+
+			// filter satellite systems
+			filt := ObsFilter{SatSystems: gnss.Systems{gnss.SysGLO}}
+			got, _ = decodeEpochs(t, string(data), func(dec *ObsDecoder) {
+				dec.SetIndexed(true)
+				dec.SetFilter(filt)
+			})
+			for _, epo := range got {
+				assert.Equal(int(epo.NumSat), len(epo.IndexedObs))
+				for _, so := range epo.IndexedObs {
+					assert.Equal(gnss.SysGLO, so.Prn.Sys)
+				}
+			}
+		})
+	}
+}
+
+// RINEX-4 observations with optional header records, scaled observations and a header change in a special event.
+const obsWithHeaderRecords = `     4.00           OBSERVATION DATA    M                   RINEX VERSION / TYPE
+TEST                                                        MARKER NAME
+GEODETIC                                                    MARKER TYPE
+ROB                 ROB                                     OBSERVER / AGENCY
+3001376             SEPT POLARX5TR      5.3.2               REC # / TYPE / VERS
+00464               JAVRINGANT_DM   NONE                    ANT # / TYPE
+  4027881.8478   306998.2610  4919498.6554                  APPROX POSITION XYZ
+        0.4689        0.0000        0.0010                  ANTENNA: DELTA H/E/N
+G L1C   0.0010        0.0020        0.1100                  ANTENNA: PHASECENTER
+G L2W  -0.0005        0.0012        0.1282                  ANTENNA: PHASECENTER
+        0.0000        0.0000        1.0000                  ANTENNA: B.SIGHT XYZ
+       90.0000                                              ANTENNA: ZERODIR AZI
+G    4 C1C L1C S1C L2W                                      SYS / # / OBS TYPES
+E    2 C1C L1C                                              SYS / # / OBS TYPES
+    30.000                                                  INTERVAL
+  2020     6    18     0     0    0.0000000     GPS         TIME OF FIRST OBS
+     1                                                      RCV CLOCK OFFS APPL
+G CCC               BIAS.SNX @ example.org                  SYS / DCBS APPLIED
+G                   igs20.atx                               SYS / PCVS APPLIED
+G   10  2 L1C L2W                                           SYS / SCALE FACTOR
+E  100                                                      SYS / SCALE FACTOR
+ C1C  -71.940 C1P  -71.940                                  GLONASS COD/PHS/BIS
+   G01     2     2     2     1                              PRN / # OF OBS
+   E05     2     2                                          PRN / # OF OBS
+                                                            END OF HEADER
+> 2020 06 18 00 00  0.0000000  0  2
+G01  20000000.123  1051234567.891 7        45.000   819123456.780
+E052300000012.300  1300000001.234
+>                              4  4
+3001376             SEPT POLARX5TR      5.4.0               REC # / TYPE / VERS
+G    2 C1C L1C                                              SYS / # / OBS TYPES
+G    1                                                      SYS / SCALE FACTOR
+CHANGE OF FIRMWARE                                          COMMENT
+> 2020 06 18 00 00 30.0000000  0  2
+G01  20000030.123   105123467.891 7
+E052300000312.300  1300000301.234
+`
+
+func TestObsDecoder_headerRecords(t *testing.T) {
+	assert := assert.New(t)
+	dec, err := NewObsDecoder(strings.NewReader(obsWithHeaderRecords))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prnG01, prnE05 := gnss.PRN{Sys: gnss.SysGPS, Num: 1}, gnss.PRN{Sys: gnss.SysGAL, Num: 5}
+	hdr := dec.Header
+	assert.Equal([]PhaseCenter{{Sys: gnss.SysGPS, Code: "L1C", Pos: Coord{X: 0.001, Y: 0.002, Z: 0.11}},
+		{Sys: gnss.SysGPS, Code: "L2W", Pos: Coord{X: -0.0005, Y: 0.0012, Z: 0.1282}}}, hdr.AntennaPhaseCenters)
+	assert.Equal(Coord{Z: 1}, hdr.AntennaBSight)
+	assert.Equal(90.0, hdr.AntennaZeroDirAzi)
+	assert.True(hdr.RcvClockOffsApplied)
+	assert.Equal([]AppliedCorrection{{Sys: gnss.SysGPS, Program: "CCC", Source: "BIAS.SNX @ example.org"}}, hdr.DCBsApplied)
+	assert.Equal([]AppliedCorrection{{Sys: gnss.SysGPS, Source: "igs20.atx"}}, hdr.PCVsApplied)
+	assert.Equal([]ScaleFactor{{Sys: gnss.SysGPS, Factor: 10, Codes: []ObsCode{"L1C", "L2W"}}, {Sys: gnss.SysGAL, Factor: 100}}, hdr.ScaleFactors)
+	assert.Equal(map[ObsCode]float64{"C1C": -71.94, "C1P": -71.94}, hdr.GloCodPhsBias)
+	assert.Equal(map[gnss.PRN][]int{prnG01: {2, 2, 2, 1}, prnE05: {2, 2}}, hdr.ObsPerSat)
+	assert.Equal(10, hdr.ScaleFactorOf(gnss.SysGPS, "L1C"))
+	assert.Equal(1, hdr.ScaleFactorOf(gnss.SysGPS, "C1C"))
+	assert.Equal(100, hdr.ScaleFactorOf(gnss.SysGAL, "C1C"))
+
+	// The header records are written and decoded again.
+	var buf bytes.Buffer
+	assert.NoError(hdr.Write(&buf))
+	dec2, err := NewObsDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr2 := dec2.Header
+	hdr.Labels, hdr2.Labels = nil, nil
+	assert.Equal(hdr, hdr2)
+
+	// Scale factors are applied.
+	assert.True(dec.NextEpoch())
+	obs := dec.Epoch().ObsList
+	assert.InDelta(105123456.7891, obs[0].Obss["L1C"].Val, 1e-6)
+	assert.Equal(int8(7), obs[0].Obss["L1C"].SNR)
+	assert.InDelta(81912345.678, obs[0].Obss["L2W"].Val, 1e-6)
+	assert.Equal(20000000.123, obs[0].Obss["C1C"].Val)
+	assert.InDelta(23000000.123, obs[1].Obss["C1C"].Val, 1e-6)
+	assert.Same(&dec.Header, dec.RunningHeader())
+}
+
+func TestObsDecoder_RunningHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(dec *ObsDecoder)
+	}{
+		{name: "sequential", setup: func(dec *ObsDecoder) {}},
+		{name: "parallel", setup: func(dec *ObsDecoder) { dec.SetWorkers(2); dec.chunks.chunkEpochs = 1 }},
+		{name: "event in parallel chunk", setup: func(dec *ObsDecoder) { dec.SetWorkers(3); dec.chunks.chunkEpochs = 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			epochs, dec := decodeEpochs(t, obsWithHeaderRecords, tt.setup)
+			assert.Len(epochs, 3)
+			assert.Equal(EpochFlagHeaderInfo, epochs[1].Flag)
+
+			hdr := dec.RunningHeader()
+			assert.Equal("5.4.0", hdr.ReceiverVersion)
+			assert.Equal("5.3.2", dec.Header.ReceiverVersion, "header unchanged")
+			assert.Equal([]ObsCode{"C1C", "L1C"}, hdr.ObsTypes[gnss.SysGPS])
+			assert.Equal([]ObsCode{"C1C", "L1C", "S1C", "L2W"}, dec.Header.ObsTypes[gnss.SysGPS], "header unchanged")
+			assert.Equal([]ScaleFactor{{Sys: gnss.SysGAL, Factor: 100}, {Sys: gnss.SysGPS, Factor: 1}}, hdr.ScaleFactors)
+			assert.Equal([]string{"CHANGE OF FIRMWARE"}, hdr.Comments)
+			assert.Empty(dec.Header.Comments)
+
+			// The changed observation types and scale factors apply to the epoch after the event.
+			obs := epochs[2].ObsList
+			assert.Equal(map[ObsCode]Obs{"C1C": {Val: 20000030.123}, "L1C": {Val: 105123467.891, SNR: 7}}, obs[0].Obss)
+			assert.InDelta(23000003.123, obs[1].Obss["C1C"].Val, 1e-6)
+			assert.Empty(dec.Warnings())
+		})
+	}
+}
+
+func TestObsDecoder_events(t *testing.T) {
+	const data = `     3.04           OBSERVATION DATA    G                   RINEX VERSION / TYPE
+TEST                                                        MARKER NAME
+G    2 C1C L1C                                              SYS / # / OBS TYPES
+                                                            END OF HEADER
+> 2020 06 18 00 00  0.0000000  0  1       0.000123456789
+G01  20000000.123   105123456.789 7
+> 2020 06 18 00 00 30.0000000  2  2
+        1.2000        0.0000        0.0000                  ANTENNA: DELTA H/E/N
+START KINEMATIC                                             COMMENT
+>                              3  2
+ROVER                                                       MARKER NAME
+12345M001                                                   MARKER NUMBER
+> 2020 06 18 00 01 30.0000000  1  1      -0.000000012345
+G01  20000090.123   105123546.789 7
+> 2020 06 18 00 01 45.5000000  5  1
+CAMERA SHUTTER                                              COMMENT
+`
+	assert := assert.New(t)
+	epochs, dec := decodeEpochs(t, data, func(*ObsDecoder) {})
+	if !assert.Len(epochs, 5) {
+		return
+	}
+	assert.Equal(0.000123456789, epochs[0].ClockOffset)
+	assert.Nil(epochs[0].Event)
+	assert.Equal(-0.000000012345, epochs[3].ClockOffset)
+	assert.Equal(EpochFlagPowerFailure, epochs[3].Flag)
+	assert.Equal(int8(1), epochs[3].ObsList[0].Obss["L1C"].LLI, "power failure sets the LLI")
+
+	// moving antenna
+	ev := epochs[1].Event
+	assert.Equal(EpochFlagMovingAntenna, ev.Flag)
+	assert.Equal([]string{"START KINEMATIC"}, ev.Comments)
+	assert.Equal(CoordNEU{Up: 1.2}, ev.Header.AntennaDelta)
+	assert.Equal([]string{"ANTENNA: DELTA H/E/N"}, ev.Header.Labels)
+
+	// new site occupation
+	ev = epochs[2].Event
+	assert.Equal(EpochFlagNewSite, ev.Flag)
+	assert.True(epochs[2].Time.IsZero())
+	assert.Equal("ROVER", ev.Header.MarkerName)
+	assert.Equal("12345M001", ev.Header.MarkerNumber)
+	assert.Empty(ev.Comments)
+	assert.Equal("ROVER", dec.RunningHeader().MarkerName)
+
+	// external event
+	ev = epochs[4].Event
+	assert.Equal(EpochFlagExternalEvent, ev.Flag)
+	assert.Equal(time.Date(2020, 6, 18, 0, 1, 45, 5e8, time.UTC), epochs[4].Time)
+	assert.Equal([]string{"CAMERA SHUTTER"}, ev.Comments)
+	assert.Nil(ev.Header)
+
+	// RINEX-2
+	const datav2 = `     2.11           OBSERVATION DATA    G (GPS)             RINEX VERSION / TYPE
+TEST                                                        MARKER NAME
+     2    C1    L1                                          # / TYPES OF OBSERV
+                                                            END OF HEADER
+ 20  6 18  0  0  0.0000000  0  1G01                                  0.000123457
+  20000000.123   105123456.789 7
+                            4  1
+     3    C1    L1    S1                                    # / TYPES OF OBSERV
+`
+	epochs, _ = decodeEpochs(t, datav2, func(*ObsDecoder) {})
+	if assert.Len(epochs, 2) {
+		assert.Equal(0.000123457, epochs[0].ClockOffset)
+		assert.Equal(map[gnss.System][]ObsCode{gnss.SysGPS: {"C1", "L1", "S1"}}, epochs[1].Event.Header.ObsTypes)
+	}
+}
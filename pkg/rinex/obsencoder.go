@@ -0,0 +1,184 @@
+package rinex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// ObsEncoder writes RINEX Observation data to an output stream.
+// The format of the data records is determined by the RINEX version of the header.
+type ObsEncoder struct {
+	// The Header is written by NewObsEncoder. Its observation types determine the order of the observations.
+	Header ObsHeader
+	w      *bufio.Writer
+}
+
+// NewObsEncoder creates a new encoder for RINEX Observation data.
+// The RINEX header hdr will be written implicitly.
+//
+// Flush must be called to write any buffered data to the underlying writer.
+func NewObsEncoder(w io.Writer, hdr ObsHeader) (*ObsEncoder, error) {
+	enc := &ObsEncoder{Header: hdr, w: bufio.NewWriter(w)}
+	if err := hdr.Write(enc.w); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+// Encode writes the epoch epo. Special events (epoch flag 2-5) are written with their special records.
+// Missing observations, i.e. Obs with all fields zero, are left blank.
+// The observations are multiplied with the scale factors of the Header, if any.
+func (enc *ObsEncoder) Encode(epo *Epoch) error {
+	if epo.Flag > EpochFlagPowerFailure && epo.Flag != EpochFlagCycleSlip {
+		return enc.encodeEvent(epo)
+	}
+	if enc.Header.RINEXVersion < 3 {
+		return enc.encodev2(epo)
+	}
+	return enc.encode(epo)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (enc *ObsEncoder) Flush() error {
+	return enc.w.Flush()
+}
+
+// Write a RINEX version 3 or 4 epoch.
+func (enc *ObsEncoder) encode(epo *Epoch) error {
+	line := fmt.Sprintf("> %s  %1d%3d", formatEpochTime(epo.Time, false), epo.Flag, len(epo.ObsList))
+	if epo.ClockOffset != 0 {
+		line += fmt.Sprintf("%6s%15.12f", " ", epo.ClockOffset)
+	}
+	enc.writeLine(line)
+
+	var sb strings.Builder
+	for _, satObs := range epo.ObsList {
+		obsTypes, ok := enc.Header.ObsTypes[satObs.Prn.Sys]
+		if !ok {
+			return fmt.Errorf("rinex: epoch %s: no observation types for satellite %s", epo.Time, satObs.Prn)
+		}
+		sb.Reset()
+		sb.WriteString(satObs.Prn.String())
+		for _, typ := range obsTypes {
+			o := satObs.Obss[typ]
+			if len(enc.Header.ScaleFactors) > 0 {
+				o.Val *= float64(enc.Header.ScaleFactorOf(satObs.Prn.Sys, typ))
+			}
+			obs, err := formatObs(o)
+			if err != nil {
+				return fmt.Errorf("rinex: epoch %s: %s %s: %v", epo.Time, satObs.Prn, typ, err)
+			}
+			sb.WriteString(obs)
+		}
+		enc.writeLine(sb.String())
+	}
+	return nil
+}
+
+// Write a RINEX version 2 epoch.
+func (enc *ObsEncoder) encodev2(epo *Epoch) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " %s  %1d%3d", formatEpochTime(epo.Time, true), epo.Flag, len(epo.ObsList))
+	for i, satObs := range epo.ObsList {
+		if i > 0 && i%12 == 0 {
+			if i == 12 && epo.ClockOffset != 0 {
+				fmt.Fprintf(&sb, "%-*s%12.9f", 68-sb.Len(), "", epo.ClockOffset)
+			}
+			enc.writeLine(sb.String())
+			sb.Reset()
+			sb.WriteString(strings.Repeat(" ", 32))
+		}
+		sb.WriteString(satObs.Prn.String())
+	}
+	if len(epo.ObsList) <= 12 && epo.ClockOffset != 0 {
+		fmt.Fprintf(&sb, "%-*s%12.9f", 68-sb.Len(), "", epo.ClockOffset)
+	}
+	enc.writeLine(sb.String())
+
+	obsTypes := enc.Header.ObsTypes[enc.Header.SatSystem]
+	for _, satObs := range epo.ObsList {
+		sb.Reset()
+		for ityp, typ := range obsTypes {
+			if ityp > 0 && ityp%5 == 0 {
+				enc.writeLine(sb.String())
+				sb.Reset()
+			}
+			obs, err := formatObs(satObs.Obss[typ])
+			if err != nil {
+				return fmt.Errorf("rinex2: epoch %s: %s %s: %v", epo.Time, satObs.Prn, typ, err)
+			}
+			sb.WriteString(obs)
+		}
+		enc.writeLine(sb.String())
+	}
+	return nil
+}
+
+// Write a special event epoch with its special records.
+func (enc *ObsEncoder) encodeEvent(epo *Epoch) error {
+	isV2 := enc.Header.RINEXVersion < 3
+	timeStr := ""
+	if !epo.Time.IsZero() {
+		timeStr = formatEpochTime(epo.Time, isV2)
+	}
+	if isV2 {
+		enc.writeLine(fmt.Sprintf(" %-25s  %1d%3d", timeStr, epo.Flag, len(epo.Records)))
+	} else {
+		enc.writeLine(fmt.Sprintf("> %-27s  %1d%3d", timeStr, epo.Flag, len(epo.Records)))
+	}
+	for _, rec := range epo.Records {
+		enc.writeLine(rec)
+	}
+	return nil
+}
+
+// writeLine writes the line without trailing blanks.
+func (enc *ObsEncoder) writeLine(line string) {
+	enc.w.WriteString(strings.TrimRight(line, " "))
+	enc.w.WriteByte('\n')
+}
+
+// formatEpochTime formats the epoch time for the epoch line, with a two-digit year for RINEX version 2.
+func formatEpochTime(t time.Time, isV2 bool) string {
+	sec := float64(t.Second()) + float64(t.Nanosecond())/1e9
+	if isV2 {
+		return fmt.Sprintf("%02d %2d %2d %2d %2d%11.7f", t.Year()%100, t.Month(), t.Day(), t.Hour(), t.Minute(), sec)
+	}
+	return fmt.Sprintf("%4d %02d %02d %02d %02d%11.7f", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), sec)
+}
+
+// formatObs formats an observation with its LLI and SNR flags (F14.3,I1,I1).
+// Missing observations, LLI and SNR values of 0 are left blank.
+func formatObs(obs Obs) (string, error) {
+	if obs == (Obs{}) {
+		return strings.Repeat(" ", 16), nil
+	}
+	// The rounded value must fit F14.3, i.e. 10 digits before the decimal point, or 9 and the sign.
+	if v := math.Round(obs.Val*1e3) / 1e3; math.IsNaN(v) || v <= -1e9 || v >= 1e10 {
+		return "", fmt.Errorf("observation out of range: %f", obs.Val)
+	}
+	lli, err := formatObsFlag(obs.LLI)
+	if err != nil {
+		return "", fmt.Errorf("LLI: %v", err)
+	}
+	snr, err := formatObsFlag(obs.SNR)
+	if err != nil {
+		return "", fmt.Errorf("SNR: %v", err)
+	}
+	return fmt.Sprintf("%14.3f%s%s", obs.Val, lli, snr), nil
+}
+
+// formatObsFlag formats a LLI or SNR flag, 0 is left blank.
+func formatObsFlag(flag int8) (string, error) {
+	if flag < 0 || flag > 9 {
+		return "", fmt.Errorf("invalid value: %d", flag)
+	}
+	if flag == 0 {
+		return " ", nil
+	}
+	return string(rune('0' + flag)), nil
+}
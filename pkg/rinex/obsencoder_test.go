@@ -0,0 +1,154 @@
+package rinex
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObsEncoder_roundtrip(t *testing.T) {
+	files := []string{
+		"testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx",
+		"testdata/white/BRUX00BEL_R_20202302000_01H_30S_MO.crx",
+		"testdata/white/REYK00ISL_R_20192701000_01H_30S_MO.rnx",
+		"testdata/white/REYK00ISL_S_20192701000_01H_30S_MO.rnx",
+		"testdata/white/brst155h.20o",
+		"testdata/white/brst155h.20d",
+		"testdata/white/kais329w.18o",
+	}
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			assert := assert.New(t)
+			dec := newObsDecoder(t, file)
+			epochs := readAllEpochs(t, dec)
+
+			var buf bytes.Buffer
+			enc, err := NewObsEncoder(&buf, dec.Header)
+			assert.NoError(err)
+			for _, epo := range epochs {
+				assert.NoError(enc.Encode(epo))
+			}
+			assert.NoError(enc.Flush())
+
+			dec2, err := NewObsDecoder(&buf)
+			assert.NoError(err)
+			assert.Equal(dec.Header.RINEXVersion, dec2.Header.RINEXVersion, "RINEX version")
+			assert.Equal(dec.Header.ObsTypes, dec2.Header.ObsTypes, "observation types")
+			assert.Equal(dec.Header.MarkerName, dec2.Header.MarkerName, "marker name")
+			assert.Equal(dec.Header.Position, dec2.Header.Position, "position")
+			assert.Equal(dec.Header.TimeOfFirstObs, dec2.Header.TimeOfFirstObs, "time of first obs")
+			assert.Equal(dec.Header.GloSlots, dec2.Header.GloSlots, "GLONASS slots")
+			assert.Equal(dec.Header.ObsPerSat, dec2.Header.ObsPerSat, "number of observations")
+
+			epochs2 := readAllEpochs(t, dec2)
+			assert.Equal(len(epochs), len(epochs2), "#epochs")
+			for i := range min(len(epochs), len(epochs2)) {
+				if !assert.Equal(epochs[i], epochs2[i], "epoch %d", i+1) {
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestObsEncoder_Encode(t *testing.T) {
+	prnG01, prnG02 := gnss.PRN{Sys: gnss.SysGPS, Num: 1}, gnss.PRN{Sys: gnss.SysGPS, Num: 2}
+	epochs := []*Epoch{
+		{Time: time.Date(2020, 6, 3, 7, 0, 30, 0, time.UTC), Flag: EpochFlagPowerFailure, NumSat: 2, ClockOffset: 0.000123456789,
+			ObsList: []SatObs{
+				{Prn: prnG01, Obss: map[ObsCode]Obs{"C1C": {Val: 20182171.481}, "L1C": {Val: 106058033.736, LLI: 1, SNR: 8}}},
+				{Prn: prnG02, Obss: map[ObsCode]Obs{"C1C": {}, "L1C": {Val: -0.5, SNR: 5}}},
+			}},
+		{Flag: EpochFlagHeaderInfo, Records: []string{"new antenna                                                 COMMENT"}},
+	}
+
+	tests := []struct {
+		name    string
+		version float32
+		types   []ObsCode
+		want    string
+	}{
+		{name: "v3", version: 3.04, types: []ObsCode{"C1C", "L1C"}, want: `> 2020 06 03 07 00 30.0000000  1  2       0.000123456789
+G01  20182171.481   106058033.73618
+G02                        -0.500 5
+>                              4  1
+new antenna                                                 COMMENT
+`},
+		{name: "v2", version: 2.11, types: []ObsCode{"C1", "L1"}, want: ` 20  6  3  7  0 30.0000000  1  2G01G02                               0.000123457
+  20182171.481   106058033.73618
+                        -0.500 5
+                            4  1
+new antenna                                                 COMMENT
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			hdr := ObsHeader{RINEXVersion: tt.version, SatSystem: gnss.SysGPS, ObsTypes: map[gnss.System][]ObsCode{gnss.SysGPS: tt.types}}
+			var buf bytes.Buffer
+			enc, err := NewObsEncoder(&buf, hdr)
+			assert.NoError(err)
+			for _, epo := range epochs {
+				if tt.version < 3 {
+					for _, satObs := range epo.ObsList {
+						satObs.Obss["C1"], satObs.Obss["L1"] = satObs.Obss["C1C"], satObs.Obss["L1C"]
+					}
+				}
+				assert.NoError(enc.Encode(epo))
+			}
+			assert.NoError(enc.Flush())
+			_, data, _ := strings.Cut(buf.String(), "END OF HEADER\n")
+			assert.Equal(tt.want, data)
+		})
+	}
+}
+
+func TestObsEncoder_scaleFactors(t *testing.T) {
+	assert := assert.New(t)
+	epochs, dec := decodeEpochs(t, obsWithHeaderRecords, func(*ObsDecoder) {})
+	var buf bytes.Buffer
+	enc, err := NewObsEncoder(&buf, dec.Header)
+	assert.NoError(err)
+	assert.NoError(enc.Encode(epochs[0]))
+	assert.NoError(enc.Flush())
+	_, data, _ := strings.Cut(buf.String(), "END OF HEADER\n")
+	assert.Equal(`> 2020 06 18 00 00  0.0000000  0  2
+G01  20000000.123  1051234567.891 7        45.000   819123456.780
+E052300000012.300  1300000001.234
+`, data)
+}
+
+func TestObsEncoder_errors(t *testing.T) {
+	assert := assert.New(t)
+	hdr := ObsHeader{RINEXVersion: 3.04, SatSystem: gnss.SysMIXED, ObsTypes: map[gnss.System][]ObsCode{gnss.SysGPS: {"C1C"}}}
+	enc, err := NewObsEncoder(&bytes.Buffer{}, hdr)
+	assert.NoError(err)
+
+	epo := &Epoch{Time: time.Date(2020, 6, 3, 7, 0, 30, 0, time.UTC), ObsList: []SatObs{
+		{Prn: gnss.PRN{Sys: gnss.SysGLO, Num: 1}, Obss: map[ObsCode]Obs{"C1C": {Val: 1}}}}}
+	assert.Error(enc.Encode(epo), "no obs types for GLONASS")
+
+	epo.ObsList[0].Prn.Sys = gnss.SysGPS
+	epo.ObsList[0].Obss["C1C"] = Obs{Val: 1, SNR: 10}
+	assert.Error(enc.Encode(epo), "invalid SNR")
+
+	for _, val := range []float64{1e10, 9999999999.9996, -1e9, -999999999.9996, math.NaN(), math.Inf(-1)} {
+		epo.ObsList[0].Obss["C1C"] = Obs{Val: val}
+		assert.Error(enc.Encode(epo), "out of range: %f", val)
+	}
+}
+
+func TestFormatObs(t *testing.T) {
+	assert := assert.New(t)
+	for _, val := range []float64{9999999999.999, -999999999.999, -999999999.9994, 0.0001} {
+		s, err := formatObs(Obs{Val: val})
+		if assert.NoError(err, val) {
+			assert.Len(s, 16, val)
+		}
+	}
+}
@@ -0,0 +1,538 @@
+package rinex
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// Constants of the signal-in-space interface control documents.
+const (
+	gmGPS  = 3.986005e14     // Earth's gravitational constant of GPS, QZSS and NavIC in m³/s²
+	gmGAL  = 3.986004418e14  // Earth's gravitational constant of Galileo in m³/s²
+	gmBDS  = 3.986004418e14  // Earth's gravitational constant of BDS (CGCS2000) in m³/s²
+	gmGLO  = 3.9860044e14    // Earth's gravitational constant of GLONASS (PZ-90) in m³/s²
+	omeGPS = 7.2921151467e-5 // Earth's rotation rate of GPS, QZSS, Galileo and NavIC in rad/s
+	omeBDS = 7.2921150e-5    // Earth's rotation rate of BDS in rad/s
+	omeGLO = 7.292115e-5     // Earth's rotation rate of GLONASS in rad/s
+
+	aeGLO = 6378136.0    // semi-major axis of the PZ-90 ellipsoid in m
+	j2GLO = 1.0826257e-3 // second zonal harmonic of the geopotential of PZ-90
+
+	gloStep = 60.0 // step size of the GLONASS orbit integration in s
+)
+
+// The week numbers of the ephemerides count from these epochs.
+var (
+	gpsEpoch = time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC)
+	bdsEpoch = time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// errNoOrbit is returned for ephemerides without orbit parameters, e.g. of unknown RINEX-4 message types.
+var errNoOrbit = errors.New("rinex: ephemeris has no orbit parameters")
+
+// kepler are the Keplerian orbit parameters with the constants of the satellite system.
+type kepler struct {
+	gm, omegaE float64
+	toe        time.Time // time of ephemeris
+	toeSec     float64   // time of ephemeris in seconds of the week
+	geo        bool      // a BDS geostationary satellite
+
+	sqrtA, ecc, deltaN, m0            float64
+	adot, deltaNDot                   float64 // the rates of the semi-major axis and mean motion of CNAV messages
+	omega0, omegaDot, i0, idot, omega float64
+	cuc, cus, crc, crs, cic, cis      float64
+}
+
+// state returns the earth-fixed position and velocity at the time t following IS-GPS-200, and the eccentric anomaly.
+// The rates of the semi-major axis and the mean motion are those of the CNAV messages, zero for the legacy messages.
+// For BDS GEO satellites the orbit is computed in an inertial frame and rotated, following BDS-SIS-ICD.
+func (k *kepler) state(t time.Time) (pos, vel Coord, ecAnom float64) {
+	tk := t.Sub(k.toe).Seconds()
+	a0 := k.sqrtA * k.sqrtA
+	a := a0 + k.adot*tk
+	n := math.Sqrt(k.gm/(a0*a0*a0)) + k.deltaN + k.deltaNDot*tk/2
+	m := k.m0 + n*tk
+
+	// Kepler's equation
+	e := m
+	for range 10 {
+		de := (m - e + k.ecc*math.Sin(e)) / (1 - k.ecc*math.Cos(e))
+		e += de
+		if math.Abs(de) < 1e-13 {
+			break
+		}
+	}
+	sinE, cosE := math.Sin(e), math.Cos(e)
+
+	v := math.Atan2(math.Sqrt(1-k.ecc*k.ecc)*sinE, cosE-k.ecc)
+	phi := v + k.omega
+	sin2, cos2 := math.Sin(2*phi), math.Cos(2*phi)
+	u := phi + k.cus*sin2 + k.cuc*cos2
+	r := a*(1-k.ecc*cosE) + k.crs*sin2 + k.crc*cos2
+	i := k.i0 + k.cis*sin2 + k.cic*cos2 + k.idot*tk
+
+	// rates
+	eDot := n / (1 - k.ecc*cosE)
+	vDot := eDot * math.Sqrt(1-k.ecc*k.ecc) / (1 - k.ecc*cosE)
+	uDot := vDot * (1 + 2*(k.cus*cos2-k.cuc*sin2))
+	rDot := k.adot*(1-k.ecc*cosE) + a*k.ecc*sinE*eDot + 2*vDot*(k.crs*cos2-k.crc*sin2)
+	iDot := k.idot + 2*vDot*(k.cis*cos2-k.cic*sin2)
+
+	// position and velocity in the orbital plane
+	x, y := r*math.Cos(u), r*math.Sin(u)
+	xDot := rDot*math.Cos(u) - y*uDot
+	yDot := rDot*math.Sin(u) + x*uDot
+
+	omegaRate := k.omegaDot - k.omegaE
+	if k.geo {
+		omegaRate = k.omegaDot
+	}
+	omega := k.omega0 + omegaRate*tk - k.omegaE*k.toeSec
+	sinO, cosO := math.Sin(omega), math.Cos(omega)
+	sinI, cosI := math.Sin(i), math.Cos(i)
+	pos = Coord{
+		X: x*cosO - y*cosI*sinO,
+		Y: x*sinO + y*cosI*cosO,
+		Z: y * sinI,
+	}
+	vel = Coord{
+		X: xDot*cosO - yDot*cosI*sinO + y*sinI*sinO*iDot - pos.Y*omegaRate,
+		Y: xDot*sinO + yDot*cosI*cosO - y*sinI*cosO*iDot + pos.X*omegaRate,
+		Z: yDot*sinI + y*cosI*iDot,
+	}
+	if !k.geo {
+		return pos, vel, e
+	}
+
+	// BDS GEO: rotate by -5° about the x-axis and by the Earth's rotation about the z-axis.
+	sin5, cos5 := math.Sin(-5*math.Pi/180), math.Cos(-5*math.Pi/180)
+	rotX := func(c Coord) Coord { return Coord{X: c.X, Y: c.Y*cos5 + c.Z*sin5, Z: -c.Y*sin5 + c.Z*cos5} }
+	pg, vg := rotX(pos), rotX(vel)
+	sinZ, cosZ := math.Sin(k.omegaE*tk), math.Cos(k.omegaE*tk)
+	pos = Coord{X: pg.X*cosZ + pg.Y*sinZ, Y: -pg.X*sinZ + pg.Y*cosZ, Z: pg.Z}
+	vel = Coord{
+		X: vg.X*cosZ + vg.Y*sinZ + k.omegaE*pos.Y,
+		Y: -vg.X*sinZ + vg.Y*cosZ - k.omegaE*pos.X,
+		Z: vg.Z,
+	}
+	return pos, vel, e
+}
+
+// relativity returns the relativistic clock correction in seconds for the eccentric anomaly.
+func (k *kepler) relativity(ecAnom float64) float64 {
+	return -2 * math.Sqrt(k.gm) / (gnss.SpeedOfLight * gnss.SpeedOfLight) * k.ecc * k.sqrtA * math.Sin(ecAnom)
+}
+
+// keplerClock returns the clock offset of the polynomial at the time t, plus the relativistic correction
+// if there are orbit parameters.
+func keplerClock(k *kepler, toc time.Time, a0, a1, a2 float64, t time.Time) float64 {
+	dt := t.Sub(toc).Seconds()
+	clk := a0 + a1*dt + a2*dt*dt
+	if k.sqrtA == 0 {
+		return clk
+	}
+	_, _, e := k.state(t)
+	return clk + k.relativity(e)
+}
+
+// weekTime returns the time of the week and seconds of week, counting from epoch.
+func weekTime(epoch time.Time, week, sow float64) time.Time {
+	return epoch.Add(time.Duration(week)*7*24*time.Hour + time.Duration(sow*float64(time.Second)))
+}
+
+// weekSeconds returns the week and the seconds of the week of the time t since the epoch, the inverse of weekTime.
+func weekSeconds(epoch, t time.Time) (week int, sow float64) {
+	const w = 7 * 24 * time.Hour
+	d := t.Sub(epoch)
+	return int(d / w), (d % w).Seconds()
+}
+
+// nearestWeekTime returns the time of the seconds of week sow in the week of the time ref or the adjacent week,
+// whichever is closest to ref, so that a week rollover between both is handled. The weeks start on Sunday.
+func nearestWeekTime(ref time.Time, sow float64) time.Time {
+	const week = 7 * 24 * time.Hour
+	t := gpsEpoch.Add(ref.Sub(gpsEpoch) / week * week).Add(time.Duration(sow * float64(time.Second)))
+	switch d := t.Sub(ref); {
+	case d > week/2:
+		t = t.Add(-week)
+	case d < -week/2:
+		t = t.Add(week)
+	}
+	return t
+}
+
+// gamma returns the squared frequency ratio of the bands of the system.
+func gamma(sys gnss.System, band1, band2 byte) float64 {
+	f1 := gnss.Signal{Sys: sys, Band: band1}.Frequency(0)
+	f2 := gnss.Signal{Sys: sys, Band: band2}.Frequency(0)
+	return (f1 / f2) * (f1 / f2)
+}
+
+func (eph *EphGPS) kepler() *kepler {
+	return &kepler{gm: gmGPS, omegaE: omeGPS, toe: weekTime(gpsEpoch, eph.ToeWeek, eph.Toe), toeSec: eph.Toe,
+		sqrtA: eph.SqrtA, ecc: eph.Ecc, deltaN: eph.DeltaN, m0: eph.M0, adot: eph.ADOT, deltaNDot: eph.DeltaNDot,
+		omega0: eph.Omega0, omegaDot: eph.OmegaDot, i0: eph.I0, idot: eph.IDOT, omega: eph.Omega,
+		cuc: eph.Cuc, cus: eph.Cus, crc: eph.Crc, crs: eph.Crs, cic: eph.Cic, cis: eph.Cis}
+}
+
+// Position returns the earth-fixed satellite position in m and the velocity in m/s in WGS84 at the GPS time t.
+func (eph *EphGPS) Position(t time.Time) (pos, vel Coord, err error) {
+	if eph.SqrtA == 0 {
+		return pos, vel, errNoOrbit
+	}
+	pos, vel, _ = eph.kepler().state(t)
+	return pos, vel, nil
+}
+
+// Clock returns the satellite clock offset in seconds at the GPS time t, including the relativistic correction.
+func (eph *EphGPS) Clock(t time.Time) float64 {
+	return keplerClock(eph.kepler(), eph.TOC, eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate, t)
+}
+
+// GroupDelay returns the group delay in seconds following IS-GPS-200, IS-GPS-705 and IS-GPS-800: the TGD for
+// L1 P(Y) and L1 C/A and the TGD scaled by the frequency ratio for L2 P(Y). The CNAV message adds the
+// inter-signal corrections for L1 C/A, L2C and L5, the CNV2 message those for L1C. It returns NaN for signals
+// without a broadcast correction, e.g. L5 with the LNAV message.
+func (eph *EphGPS) GroupDelay(sig gnss.Signal) float64 {
+	return gpsGroupDelay(gnss.SysGPS, eph.MessageType, sig, eph.TGD,
+		[...]float64{eph.ISCL1CA, eph.ISCL2C, eph.ISCL5I5, eph.ISCL5Q5, eph.ISCL1CD, eph.ISCL1CP})
+}
+
+// gpsGroupDelay returns the group delay of a GPS or QZSS signal for the message type, see EphGPS.GroupDelay.
+// The inter-signal corrections are given in the order L1 C/A, L2C, L5I5, L5Q5, L1CD and L1CP.
+// The combined tracking modes, e.g. L5 I+Q, are corrected as the pilot.
+func gpsGroupDelay(sys gnss.System, messageType string, sig gnss.Signal, tgd float64, isc [6]float64) float64 {
+	isPY := sig.Attribute == 0 || strings.IndexByte("PWYM", sig.Attribute) >= 0 // RINEX-2 codes are legacy codes
+	switch messageType {
+	case "", "LNAV":
+		switch {
+		case sig.Band == '1' && (isPY || sig.Attribute == 'C'):
+			return tgd
+		case sig.Band == '2' && isPY:
+			return gamma(sys, '1', '2') * tgd
+		}
+	case "CNAV":
+		switch {
+		case sig.Band == '1' && isPY:
+			return tgd
+		case sig.Band == '1' && sig.Attribute == 'C':
+			return tgd - isc[0]
+		case sig.Band == '2' && isPY:
+			return gamma(sys, '1', '2') * tgd
+		case sig.Band == '2' && strings.IndexByte("SLX", sig.Attribute) >= 0:
+			return tgd - isc[1]
+		case sig.Band == '5' && sig.Attribute == 'I':
+			return tgd - isc[2]
+		case sig.Band == '5' && (sig.Attribute == 'Q' || sig.Attribute == 'X'):
+			return tgd - isc[3]
+		}
+	case "CNV2":
+		switch {
+		case sig.Band == '1' && isPY:
+			return tgd
+		case sig.Band == '1' && sig.Attribute == 'S':
+			return tgd - isc[4]
+		case sig.Band == '1' && (sig.Attribute == 'L' || sig.Attribute == 'X'):
+			return tgd - isc[5]
+		case sig.Band == '2' && isPY:
+			return gamma(sys, '1', '2') * tgd
+		}
+	}
+	return math.NaN()
+}
+
+func (eph *EphGAL) kepler() *kepler {
+	return &kepler{gm: gmGAL, omegaE: omeGPS, toe: weekTime(gpsEpoch, eph.ToeWeek, eph.Toe), toeSec: eph.Toe,
+		sqrtA: eph.SqrtA, ecc: eph.Ecc, deltaN: eph.DeltaN, m0: eph.M0,
+		omega0: eph.Omega0, omegaDot: eph.OmegaDot, i0: eph.I0, idot: eph.IDOT, omega: eph.Omega,
+		cuc: eph.Cuc, cus: eph.Cus, crc: eph.Crc, crs: eph.Crs, cic: eph.Cic, cis: eph.Cis}
+}
+
+// Position returns the earth-fixed satellite position in m and the velocity in m/s in GTRF at the Galileo time t.
+func (eph *EphGAL) Position(t time.Time) (pos, vel Coord, err error) {
+	if eph.SqrtA == 0 {
+		return pos, vel, errNoOrbit
+	}
+	pos, vel, _ = eph.kepler().state(t)
+	return pos, vel, nil
+}
+
+// Clock returns the satellite clock offset in seconds at the Galileo time t, including the relativistic correction.
+func (eph *EphGAL) Clock(t time.Time) float64 {
+	return keplerClock(eph.kepler(), eph.TOC, eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate, t)
+}
+
+// GroupDelay returns the BGD in seconds for E1, E5a and E5b. For E1 the BGD E5a/E1 is used for the F/NAV
+// and the BGD E5b/E1 for the I/NAV message.
+func (eph *EphGAL) GroupDelay(sig gnss.Signal) float64 {
+	switch sig.Band {
+	case '1':
+		if eph.IsFNAV() {
+			return eph.BGDE5aE1
+		}
+		return eph.BGDE5bE1
+	case '5':
+		return gamma(gnss.SysGAL, '1', '5') * eph.BGDE5aE1
+	case '7':
+		return gamma(gnss.SysGAL, '1', '7') * eph.BGDE5bE1
+	}
+	return math.NaN()
+}
+
+func (eph *EphQZSS) kepler() *kepler {
+	return &kepler{gm: gmGPS, omegaE: omeGPS, toe: weekTime(gpsEpoch, eph.ToeWeek, eph.Toe), toeSec: eph.Toe,
+		sqrtA: eph.SqrtA, ecc: eph.Ecc, deltaN: eph.DeltaN, m0: eph.M0, adot: eph.ADOT, deltaNDot: eph.DeltaNDot,
+		omega0: eph.Omega0, omegaDot: eph.OmegaDot, i0: eph.I0, idot: eph.IDOT, omega: eph.Omega,
+		cuc: eph.Cuc, cus: eph.Cus, crc: eph.Crc, crs: eph.Crs, cic: eph.Cic, cis: eph.Cis}
+}
+
+// Position returns the earth-fixed satellite position in m and the velocity in m/s at the GPS time t.
+func (eph *EphQZSS) Position(t time.Time) (pos, vel Coord, err error) {
+	if eph.SqrtA == 0 {
+		return pos, vel, errNoOrbit
+	}
+	pos, vel, _ = eph.kepler().state(t)
+	return pos, vel, nil
+}
+
+// Clock returns the satellite clock offset in seconds at the GPS time t, including the relativistic correction.
+func (eph *EphQZSS) Clock(t time.Time) float64 {
+	return keplerClock(eph.kepler(), eph.TOC, eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate, t)
+}
+
+// GroupDelay returns the group delay in seconds following IS-QZSS-PNT, as for GPS, see EphGPS.GroupDelay.
+func (eph *EphQZSS) GroupDelay(sig gnss.Signal) float64 {
+	return gpsGroupDelay(gnss.SysQZSS, eph.MessageType, sig, eph.TGD,
+		[...]float64{eph.ISCL1CA, eph.ISCL2C, eph.ISCL5I5, eph.ISCL5Q5, eph.ISCL1CD, eph.ISCL1CP})
+}
+
+// IsGEO reports whether the satellite is a geostationary BDS satellite, i.e. C01 to C05 and C59 to C63.
+func (eph *EphBDS) IsGEO() bool {
+	return eph.PRN.Num <= 5 || eph.PRN.Num >= 59
+}
+
+func (eph *EphBDS) kepler() *kepler {
+	return &kepler{gm: gmBDS, omegaE: omeBDS, toe: weekTime(bdsEpoch, eph.ToeWeek, eph.Toe), toeSec: eph.Toe, geo: eph.IsGEO(),
+		sqrtA: eph.SqrtA, ecc: eph.Ecc, deltaN: eph.DeltaN, m0: eph.M0, adot: eph.ADOT, deltaNDot: eph.DeltaNDot,
+		omega0: eph.Omega0, omegaDot: eph.OmegaDot, i0: eph.I0, idot: eph.IDOT, omega: eph.Omega,
+		cuc: eph.Cuc, cus: eph.Cus, crc: eph.Crc, crs: eph.Crs, cic: eph.Cic, cis: eph.Cis}
+}
+
+// Position returns the earth-fixed satellite position in m and the velocity in m/s in CGCS2000 at the BDS time t.
+func (eph *EphBDS) Position(t time.Time) (pos, vel Coord, err error) {
+	if eph.SqrtA == 0 {
+		return pos, vel, errNoOrbit
+	}
+	pos, vel, _ = eph.kepler().state(t)
+	return pos, vel, nil
+}
+
+// Clock returns the satellite clock offset in seconds at the BDS time t, including the relativistic correction.
+func (eph *EphBDS) Clock(t time.Time) float64 {
+	return keplerClock(eph.kepler(), eph.TOC, eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate, t)
+}
+
+// GroupDelay returns the group delay in seconds following BDS-SIS-ICD. The clock refers to B3I. The D1 and D2
+// messages give the TGD1 for B1I and the TGD2 for B2I. The CNV1 and CNV2 messages give the TGDB1CP for the B1C
+// pilot and the TGDB2AP for the B2a pilot, the data components add the ISCB1CD of CNV1 and the ISCB2AD of CNV2.
+// The CNV3 message gives the TGDB2BI for B2b. The combined tracking modes are corrected as the pilot.
+// It returns NaN for signals without a broadcast correction.
+func (eph *EphBDS) GroupDelay(sig gnss.Signal) float64 {
+	isIQ := sig.Attribute == 0 || strings.IndexByte("IQX", sig.Attribute) >= 0 // B1I, B2I and B3I
+	if sig.Band == '6' && isIQ {
+		return 0
+	}
+	switch eph.MessageType {
+	case "", "D1", "D2":
+		switch {
+		case sig.Band == '2' && isIQ:
+			return eph.TGD1
+		case sig.Band == '7' && isIQ:
+			return eph.TGD2
+		}
+	case "CNV1", "CNV2":
+		switch {
+		case sig.Band == '1' && (sig.Attribute == 'P' || sig.Attribute == 'X'):
+			return eph.TGDB1CP
+		case sig.Band == '1' && sig.Attribute == 'D' && eph.MessageType == "CNV1":
+			return eph.TGDB1CP + eph.ISCB1CD
+		case sig.Band == '5' && (sig.Attribute == 'P' || sig.Attribute == 'X'):
+			return eph.TGDB2AP
+		case sig.Band == '5' && sig.Attribute == 'D' && eph.MessageType == "CNV2":
+			return eph.TGDB2AP + eph.ISCB2AD
+		}
+	case "CNV3":
+		if sig.Band == '7' && sig.Attribute == 'D' {
+			return eph.TGDB2BI
+		}
+	}
+	return math.NaN()
+}
+
+func (eph *EphNavIC) kepler() *kepler {
+	return &kepler{gm: gmGPS, omegaE: omeGPS, toe: weekTime(gpsEpoch, eph.ToeWeek, eph.Toe), toeSec: eph.Toe,
+		sqrtA: eph.SqrtA, ecc: eph.Ecc, deltaN: eph.DeltaN, m0: eph.M0, adot: eph.ADOT, deltaNDot: eph.DeltaNDot,
+		omega0: eph.Omega0, omegaDot: eph.OmegaDot, i0: eph.I0, idot: eph.IDOT, omega: eph.Omega,
+		cuc: eph.Cuc, cus: eph.Cus, crc: eph.Crc, crs: eph.Crs, cic: eph.Cic, cis: eph.Cis}
+}
+
+// Position returns the earth-fixed satellite position in m and the velocity in m/s at the NavIC time t.
+func (eph *EphNavIC) Position(t time.Time) (pos, vel Coord, err error) {
+	if eph.SqrtA == 0 {
+		return pos, vel, errNoOrbit
+	}
+	pos, vel, _ = eph.kepler().state(t)
+	return pos, vel, nil
+}
+
+// Clock returns the satellite clock offset in seconds at the NavIC time t, including the relativistic correction.
+func (eph *EphNavIC) Clock(t time.Time) float64 {
+	return keplerClock(eph.kepler(), eph.TOC, eph.ClockBias, eph.ClockDrift, eph.ClockDriftRate, t)
+}
+
+// GroupDelay returns the TGD in seconds for L5 and the TGD scaled by the frequency ratio for S.
+func (eph *EphNavIC) GroupDelay(sig gnss.Signal) float64 {
+	switch sig.Band {
+	case '5':
+		return eph.TGD
+	case '9':
+		return gamma(gnss.SysNavIC, '5', '9') * eph.TGD
+	}
+	return math.NaN()
+}
+
+// Position returns the earth-fixed satellite position in m and the velocity in m/s in PZ-90 at the time t in UTC.
+// The state at TOC is integrated with a 4th-order Runge-Kutta method following the GLONASS ICD.
+func (eph *EphGLO) Position(t time.Time) (pos, vel Coord, err error) {
+	if eph.X == 0 && eph.Y == 0 && eph.Z == 0 {
+		return pos, vel, errNoOrbit
+	}
+	x := [6]float64{eph.X * 1e3, eph.Y * 1e3, eph.Z * 1e3, eph.VelX * 1e3, eph.VelY * 1e3, eph.VelZ * 1e3}
+	acc := [3]float64{eph.AccX * 1e3, eph.AccY * 1e3, eph.AccZ * 1e3}
+	for dt := t.Sub(eph.TOC).Seconds(); dt != 0; {
+		h := math.Copysign(math.Min(math.Abs(dt), gloStep), dt)
+		x = rk4(x, acc, h)
+		dt -= h
+	}
+	return Coord{X: x[0], Y: x[1], Z: x[2]}, Coord{X: x[3], Y: x[4], Z: x[5]}, nil
+}
+
+// Clock returns the satellite clock offset in seconds at the time t in UTC, i.e. -TauN + GammaN*(t-TOC).
+// The relativistic correction is already contained.
+func (eph *EphGLO) Clock(t time.Time) float64 {
+	return eph.ClockBias + eph.RelFreqBias*t.Sub(eph.TOC).Seconds()
+}
+
+// GroupDelay returns the L1/L2 group delay difference in seconds with negative sign for G2. The clock refers to G1.
+// It returns NaN for the CDMA signals and if the delay is unknown.
+func (eph *EphGLO) GroupDelay(sig gnss.Signal) float64 {
+	switch {
+	case sig.Band == '1':
+		return 0
+	case sig.Band == '2' && eph.DelayL1L2 < .999999999999e+09:
+		return -eph.DelayL1L2
+	}
+	return math.NaN()
+}
+
+// rk4 integrates the GLONASS state x, i.e. the position and velocity, over the step h with the lunisolar accelerations acc.
+func rk4(x [6]float64, acc [3]float64, h float64) [6]float64 {
+	add := func(x, dx [6]float64, f float64) [6]float64 {
+		for i := range x {
+			x[i] += dx[i] * f
+		}
+		return x
+	}
+	k1 := gloDeriv(x, acc)
+	k2 := gloDeriv(add(x, k1, h/2), acc)
+	k3 := gloDeriv(add(x, k2, h/2), acc)
+	k4 := gloDeriv(add(x, k3, h), acc)
+	for i := range x {
+		x[i] += h / 6 * (k1[i] + 2*k2[i] + 2*k3[i] + k4[i])
+	}
+	return x
+}
+
+// gloDeriv returns the time derivative of the GLONASS state in the earth-fixed PZ-90 frame with the
+// central gravity, the J2 term, the centrifugal and Coriolis forces and the lunisolar accelerations.
+func gloDeriv(x [6]float64, acc [3]float64) [6]float64 {
+	r2 := x[0]*x[0] + x[1]*x[1] + x[2]*x[2]
+	r3 := r2 * math.Sqrt(r2)
+	a := 1.5 * j2GLO * gmGLO * aeGLO * aeGLO / (r2 * r3)
+	b := 5 * x[2] * x[2] / r2
+	c := -gmGLO/r3 - a*(1-b)
+	w2 := omeGLO * omeGLO
+	return [6]float64{
+		x[3], x[4], x[5],
+		(c+w2)*x[0] + 2*omeGLO*x[4] + acc[0],
+		(c+w2)*x[1] - 2*omeGLO*x[3] + acc[1],
+		(c-2*a)*x[2] + acc[2],
+	}
+}
+
+// Position returns the earth-fixed satellite position in m and the velocity in m/s in WGS84 at the GPS time t.
+// The broadcast accelerations are the total accelerations, so the state is extrapolated by a second-order
+// polynomial following RTCA DO-229.
+func (eph *EphSBAS) Position(t time.Time) (pos, vel Coord, err error) {
+	if eph.X == 0 && eph.Y == 0 && eph.Z == 0 {
+		return pos, vel, errNoOrbit
+	}
+	dt := t.Sub(eph.TOC).Seconds()
+	extrapolate := func(p, v, a float64) (float64, float64) {
+		return (p + v*dt + a*dt*dt/2) * 1e3, (v + a*dt) * 1e3
+	}
+	pos.X, vel.X = extrapolate(eph.X, eph.VelX, eph.AccX)
+	pos.Y, vel.Y = extrapolate(eph.Y, eph.VelY, eph.AccY)
+	pos.Z, vel.Z = extrapolate(eph.Z, eph.VelZ, eph.AccZ)
+	return pos, vel, nil
+}
+
+// Clock returns the satellite clock offset in seconds at the GPS time t.
+func (eph *EphSBAS) Clock(t time.Time) float64 {
+	return eph.ClockBias + eph.RelFreqBias*t.Sub(eph.TOC).Seconds()
+}
+
+// GroupDelay returns 0 for L1, the signal the clock refers to, and NaN otherwise. SBAS does not broadcast
+// group delays.
+func (eph *EphSBAS) GroupDelay(sig gnss.Signal) float64 {
+	if sig.Band == '1' {
+		return 0
+	}
+	return math.NaN()
+}
+
+// SatPosition returns the earth-fixed position of the satellite at the transmission time of a signal received
+// at the time t by a receiver at the position rcv, and the signal travel time in seconds. The position is rotated
+// into the earth-fixed frame at the time of reception (Earth-rotation or Sagnac correction).
+// The time t is given in the time system of the ephemeris, the satellite and receiver clock offsets are not considered.
+func SatPosition(eph Eph, t time.Time, rcv Coord) (pos Coord, travelTime float64, err error) {
+	omegaE := omeGPS
+	switch eph.GetPRN().Sys {
+	case gnss.SysGLO:
+		omegaE = omeGLO
+	case gnss.SysBDS:
+		omegaE = omeBDS
+	}
+	for range 5 {
+		satPos, _, err := eph.Position(t.Add(-time.Duration(travelTime * float64(time.Second))))
+		if err != nil {
+			return pos, 0, err
+		}
+		theta := omegaE * travelTime
+		pos = Coord{
+			X: satPos.X*math.Cos(theta) + satPos.Y*math.Sin(theta),
+			Y: -satPos.X*math.Sin(theta) + satPos.Y*math.Cos(theta),
+			Z: satPos.Z,
+		}
+		tau := math.Sqrt(math.Pow(pos.X-rcv.X, 2)+math.Pow(pos.Y-rcv.Y, 2)+math.Pow(pos.Z-rcv.Z, 2)) / gnss.SpeedOfLight
+		if math.Abs(tau-travelTime) < 1e-12 {
+			return pos, tau, nil
+		}
+		travelTime = tau
+	}
+	return pos, travelTime, nil
+}
@@ -0,0 +1,280 @@
+package rinex
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/stretchr/testify/assert"
+)
+
+func readEphemerides(t *testing.T, r *strings.Reader) map[gnss.PRN][]Eph {
+	t.Helper()
+	dec, err := NewNavDecoder(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ephs := map[gnss.PRN][]Eph{}
+	for _, eph := range readAllEphemerides(t, dec) {
+		ephs[eph.GetPRN()] = append(ephs[eph.GetPRN()], eph)
+	}
+	return ephs
+}
+
+func dist(p1, p2 Coord) float64 {
+	return math.Sqrt(math.Pow(p1.X-p2.X, 2) + math.Pow(p1.Y-p2.Y, 2) + math.Pow(p1.Z-p2.Z, 2))
+}
+
+func TestEph_Position(t *testing.T) {
+	data, err := os.ReadFile("testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ephs := readEphemerides(t, strings.NewReader(string(data)))
+
+	tests := []struct {
+		sys     gnss.System
+		radius  float64 // the approximate orbit radius in m
+		maxDiff float64 // the maximum difference of consecutive ephemerides in m
+	}{
+		{sys: gnss.SysGPS, radius: 26560e3, maxDiff: 10},
+		{sys: gnss.SysGAL, radius: 29600e3, maxDiff: 10},
+		{sys: gnss.SysBDS, radius: 27906e3, maxDiff: 10},
+		{sys: gnss.SysGLO, radius: 25510e3, maxDiff: 10},
+		{sys: gnss.SysSBAS, radius: 42164e3, maxDiff: 300}, // SBAS messages are less accurate
+	}
+	for _, tt := range tests {
+		t.Run(tt.sys.String(), func(t *testing.T) {
+			assert := assert.New(t)
+			nCompared := 0
+			for prn, list := range ephs {
+				if prn.Sys != tt.sys || prn == (gnss.PRN{Sys: gnss.SysGAL, Num: 14}) || prn == (gnss.PRN{Sys: gnss.SysGAL, Num: 18}) {
+					continue // E14 and E18 are unhealthy, in eccentric orbits
+				}
+				for i := 1; i < len(list); i++ {
+					t1, t2 := list[i-1].GetTime(), list[i].GetTime()
+					if t2.Sub(t1) <= 0 || t2.Sub(t1) > 2*time.Hour {
+						continue
+					}
+					mid := t1.Add(t2.Sub(t1) / 2)
+					p1, v1, err := list[i-1].Position(mid)
+					assert.NoError(err)
+					p2, _, err := list[i].Position(mid)
+					assert.NoError(err)
+					assert.Less(dist(p1, p2), tt.maxDiff, "%s at %s", prn, mid)
+					nCompared++
+
+					// the orbit radius and the velocity compared to the position difference
+					r := math.Sqrt(p1.X*p1.X + p1.Y*p1.Y + p1.Z*p1.Z)
+					if prn.Sys == gnss.SysBDS && r > 40000e3 {
+						assert.InDelta(42164e3, r, 500e3, "%s IGSO orbit radius", prn)
+					} else {
+						assert.InDelta(tt.radius, r, 700e3, "%s orbit radius", prn)
+					}
+					pa, _, _ := list[i-1].Position(mid.Add(-time.Second / 2))
+					pb, _, _ := list[i-1].Position(mid.Add(time.Second / 2))
+					assert.InDelta(pb.X-pa.X, v1.X, 1e-3, "%s velocity", prn)
+					assert.InDelta(pb.Y-pa.Y, v1.Y, 1e-3, "%s velocity", prn)
+					assert.InDelta(pb.Z-pa.Z, v1.Z, 1e-3, "%s velocity", prn)
+				}
+			}
+			assert.Greater(nCompared, 10)
+		})
+	}
+}
+
+func TestEph_PositionCNAV(t *testing.T) {
+	assert := assert.New(t)
+	ephs := readEphemerides(t, strings.NewReader(navDataV4+navDataV4Modern))
+	cnav := ephs[gnss.PRN{Sys: gnss.SysGPS, Num: 1}][0].(*EphGPS)
+	assert.Equal("CNAV", cnav.MessageType)
+	assert.Equal(1.281738281250e-02, cnav.ADOT)
+	assert.Equal(-1.297e-14, cnav.DeltaNDot)
+	assert.Equal(2.574e+05, cnav.Top)
+	assert.Equal(3.579864278436e-09, cnav.ISCL5Q5)
+	assert.Equal(2.574180e+05, cnav.Tom)
+	assert.Equal(2110.0, cnav.WNop)
+	assert.Equal(2.592e+05, cnav.Toe, "the TOC")
+	assert.Equal(2110.0, cnav.ToeWeek)
+
+	// The orbit of the LNAV ephemeris of G02, without the rates of the semi-major axis and the mean motion.
+	lnav := ephs[gnss.PRN{Sys: gnss.SysGPS, Num: 2}][0].(*EphGPS)
+	noRates := *cnav
+	noRates.ADOT, noRates.DeltaNDot = 0, 0
+	t0 := cnav.TOC.Add(2 * time.Hour)
+	pos, _, err := lnav.Position(t0)
+	assert.NoError(err)
+	pos2, _, err := noRates.Position(t0)
+	assert.NoError(err)
+	assert.InDelta(0, dist(pos, pos2), 1e-6)
+
+	// The semi-major axis grows by ADOT*tk.
+	pos3, _, err := cnav.Position(t0)
+	assert.NoError(err)
+	r := func(p Coord) float64 { return math.Sqrt(p.X*p.X + p.Y*p.Y + p.Z*p.Z) }
+	assert.InDelta(1.281738281250e-02*7200, r(pos3)-r(pos2), 3)
+
+	for _, prn := range []gnss.PRN{{Sys: gnss.SysQZSS, Num: 2}, {Sys: gnss.SysBDS, Num: 45}, {Sys: gnss.SysNavIC, Num: 10}} {
+		for _, eph := range ephs[prn] {
+			pos, _, err := eph.Position(eph.GetTime())
+			if assert.NoError(err, prn) {
+				assert.InDelta(42164e3, r(pos), 15000e3, prn)
+			}
+		}
+	}
+}
+
+func TestEphBDS_PositionGEO(t *testing.T) {
+	assert := assert.New(t)
+	data := `     4.00           NAVIGATION DATA     MIXED               RINEX VERSION / TYPE
+                                                            END OF HEADER
+> EPH C01 D1
+C01 2022 11 29 12 00 00 9.344602003694e-04-3.997691067070e-12 0.000000000000e+00
+     1.000000000000e+00-3.418906250000e+02-3.509431896211e-09-3.073247862435e+00
+    -1.103850081563e-05 6.337405648082e-04-9.746756404638e-06 6.493360338211e+03
+     2.160000000000e+05 2.626329660416e-07 8.175155760052e-02 1.629814505577e-08
+     1.088206710577e-01 2.943281250000e+02 2.415359474789e+00 4.576262048254e-09
+     4.846630453041e-10 0.000000000000e+00 8.820000000000e+02 0.000000000000e+00
+     2.000000000000e+00 0.000000000000e+00-4.700000000000e-09-1.000000000000e-08
+     2.182470000000e+05 0.000000000000e+00 0.000000000000e+00 0.000000000000e+00
+`
+	ephs := readEphemerides(t, strings.NewReader(data))
+	eph := ephs[gnss.PRN{Sys: gnss.SysBDS, Num: 1}][0].(*EphBDS)
+	assert.True(eph.IsGEO())
+
+	for _, dt := range []time.Duration{-time.Hour, 0, time.Hour} {
+		ti := eph.TOC.Add(dt)
+		pos, vel, err := eph.Position(ti)
+		assert.NoError(err)
+		r := math.Sqrt(pos.X*pos.X + pos.Y*pos.Y + pos.Z*pos.Z)
+		assert.InDelta(42164e3, r, 100e3, "GEO orbit radius")
+		assert.InDelta(144.5, math.Atan2(pos.Y, pos.X)*180/math.Pi, 0.5, "longitude of C01")
+		assert.Less(math.Abs(math.Asin(pos.Z/r)*180/math.Pi), 2.0, "latitude of C01")
+		assert.Less(math.Sqrt(vel.X*vel.X+vel.Y*vel.Y+vel.Z*vel.Z), 100.0, "earth-fixed velocity")
+
+		pa, _, _ := eph.Position(ti.Add(-time.Second / 2))
+		pb, _, _ := eph.Position(ti.Add(time.Second / 2))
+		assert.InDelta(pb.X-pa.X, vel.X, 1e-3)
+		assert.InDelta(pb.Y-pa.Y, vel.Y, 1e-3)
+		assert.InDelta(pb.Z-pa.Z, vel.Z, 1e-3)
+	}
+}
+
+func TestEph_Clock(t *testing.T) {
+	assert := assert.New(t)
+	toc := time.Date(2020, 6, 18, 0, 0, 0, 0, time.UTC)
+	gps := &EphGPS{PRN: gnss.PRN{Sys: gnss.SysGPS, Num: 20}, TOC: toc, ClockBias: 5.274894647300e-04, ClockDrift: -1.136868377216e-13,
+		IODE: 83, Crs: 2.078125000000e+01, DeltaN: 5.373438110980e-09, M0: -2.252452975616,
+		Cuc: 1.156702637672e-06, Ecc: 5.203154985793e-03, Cus: 7.405877113342e-06, SqrtA: 5.153647661209e+03,
+		Toe: 3.456000000000e+05, Cic: -1.247972249985e-07, Omega0: -2.679776962713, Cis: 2.048909664154e-08,
+		I0: 9.344138223835e-01, Crc: 2.252500000000e+02, Omega: 2.669542608731, OmegaDot: -8.333918569731e-09,
+		IDOT: 4.632335812523e-10, ToeWeek: 2110, TGD: -8.847564458847e-09}
+
+	// relativistic correction -4.442807633e-10 * e * sqrt(a) * sin(E) at M0 = -2.2525
+	e := -2.252452975616 - 5.203154985793e-03*math.Sin(2.252452975616)
+	rel := -4.442807633e-10 * 5.203154985793e-03 * 5.153647661209e+03 * math.Sin(e)
+	assert.InDelta(5.274894647300e-04+rel, gps.Clock(toc), 1e-12)
+	assert.InDelta(5.274894647300e-04-1.136868377216e-13*3600, gps.Clock(toc.Add(time.Hour))-gps.kepler().relativity(eccAnomaly(gps, toc.Add(time.Hour))), 1e-15)
+
+	glo := &EphGLO{TOC: toc, ClockBias: 4.319325089455e-04, RelFreqBias: 1.818989403546e-12}
+	assert.Equal(4.319325089455e-04+1.818989403546e-12*900, glo.Clock(toc.Add(15*time.Minute)))
+
+	// clock only
+	bds := &EphBDS{PRN: gnss.PRN{Sys: gnss.SysBDS, Num: 45}, MessageType: "CNV1", TOC: toc, ClockBias: -4.519214760512e-04}
+	assert.Equal(-4.519214760512e-04, bds.Clock(toc))
+	_, _, err := bds.Position(toc)
+	assert.ErrorIs(err, errNoOrbit)
+}
+
+func eccAnomaly(eph *EphGPS, t time.Time) float64 {
+	_, _, e := eph.kepler().state(t)
+	return e
+}
+
+func TestEph_GroupDelay(t *testing.T) {
+	assert := assert.New(t)
+	sig := func(sys gnss.System, code ObsCode) gnss.Signal { return code.Signal(sys) }
+	gps := &EphGPS{TGD: -8.847564458847e-09}
+	assert.Equal(-8.847564458847e-09, gps.GroupDelay(sig(gnss.SysGPS, "C1C")))
+	assert.Equal(-8.847564458847e-09, gps.GroupDelay(sig(gnss.SysGPS, "P1")), "RINEX-2")
+	assert.InDelta(-8.847564458847e-09*77*77/60/60, gps.GroupDelay(sig(gnss.SysGPS, "C2W")), 1e-20)
+	assert.True(math.IsNaN(gps.GroupDelay(sig(gnss.SysGPS, "C5Q"))), "L5 LNAV")
+	assert.True(math.IsNaN(gps.GroupDelay(sig(gnss.SysGPS, "C2L"))), "L2C LNAV")
+
+	cnav := &EphGPS{MessageType: "CNAV", TGD: 5e-09, ISCL1CA: 1e-09, ISCL2C: 2e-09, ISCL5I5: 3e-09, ISCL5Q5: 4e-09}
+	assert.InDelta(4e-09, cnav.GroupDelay(sig(gnss.SysGPS, "C1C")), 1e-20)
+	assert.InDelta(3e-09, cnav.GroupDelay(sig(gnss.SysGPS, "C2X")), 1e-20)
+	assert.InDelta(2e-09, cnav.GroupDelay(sig(gnss.SysGPS, "C5I")), 1e-20)
+	assert.InDelta(1e-09, cnav.GroupDelay(sig(gnss.SysGPS, "C5Q")), 1e-20)
+	assert.InDelta(1e-09, cnav.GroupDelay(sig(gnss.SysGPS, "C5X")), 1e-20)
+	assert.True(math.IsNaN(cnav.GroupDelay(sig(gnss.SysGPS, "C1L"))), "L1C CNAV")
+
+	cnv2 := &EphQZSS{MessageType: "CNV2", TGD: 5e-09, ISCL1CD: 1e-09, ISCL1CP: 2e-09}
+	assert.InDelta(4e-09, cnv2.GroupDelay(sig(gnss.SysQZSS, "C1S")), 1e-20)
+	assert.InDelta(3e-09, cnv2.GroupDelay(sig(gnss.SysQZSS, "C1L")), 1e-20)
+	assert.True(math.IsNaN(cnv2.GroupDelay(sig(gnss.SysQZSS, "C5Q"))), "L5 CNV2")
+
+	inav := &EphGAL{MessageType: "INAV", BGDE5aE1: 3.958120942116e-09, BGDE5bE1: 4.423782229424e-09}
+	fnav := &EphGAL{MessageType: "FNAV", BGDE5aE1: 3.958120942116e-09, BGDE5bE1: 4.423782229424e-09}
+	assert.Equal(4.423782229424e-09, inav.GroupDelay(sig(gnss.SysGAL, "C1C")))
+	assert.Equal(3.958120942116e-09, fnav.GroupDelay(sig(gnss.SysGAL, "C1C")))
+	assert.InDelta(3.958120942116e-09*math.Pow(1575.42/1176.45, 2), fnav.GroupDelay(sig(gnss.SysGAL, "C5Q")), 1e-20)
+	assert.InDelta(4.423782229424e-09*math.Pow(1575.42/1207.14, 2), inav.GroupDelay(sig(gnss.SysGAL, "C7Q")), 1e-20)
+	assert.True(math.IsNaN(inav.GroupDelay(sig(gnss.SysGAL, "C6C"))))
+
+	bds := &EphBDS{TGD1: 1.23e-08, TGD2: -2.1e-09}
+	assert.Equal(1.23e-08, bds.GroupDelay(sig(gnss.SysBDS, "C2I")))
+	assert.Equal(-2.1e-09, bds.GroupDelay(sig(gnss.SysBDS, "C7I")))
+	assert.Zero(bds.GroupDelay(sig(gnss.SysBDS, "C6I")))
+	assert.True(math.IsNaN(bds.GroupDelay(sig(gnss.SysBDS, "C1P"))), "B1C D1")
+
+	cnv1 := &EphBDS{MessageType: "CNV1", ISCB1CD: 1e-09, TGDB1CP: 2e-09, TGDB2AP: 3e-09}
+	assert.InDelta(3e-09, cnv1.GroupDelay(sig(gnss.SysBDS, "C1D")), 1e-20)
+	assert.Equal(2e-09, cnv1.GroupDelay(sig(gnss.SysBDS, "C1P")))
+	assert.Equal(2e-09, cnv1.GroupDelay(sig(gnss.SysBDS, "C1X")))
+	assert.Equal(3e-09, cnv1.GroupDelay(sig(gnss.SysBDS, "C5P")))
+	assert.True(math.IsNaN(cnv1.GroupDelay(sig(gnss.SysBDS, "C5D"))), "B2a data CNV1")
+	assert.Zero(cnv1.GroupDelay(sig(gnss.SysBDS, "C6I")))
+	assert.True(math.IsNaN(cnv1.GroupDelay(sig(gnss.SysBDS, "C2I"))), "B1I CNV1")
+
+	bdsCNV2 := &EphBDS{MessageType: "CNV2", ISCB2AD: 1e-09, TGDB1CP: 2e-09, TGDB2AP: 3e-09}
+	assert.InDelta(4e-09, bdsCNV2.GroupDelay(sig(gnss.SysBDS, "C5D")), 1e-20)
+	assert.Equal(3e-09, bdsCNV2.GroupDelay(sig(gnss.SysBDS, "C5X")))
+	assert.True(math.IsNaN(bdsCNV2.GroupDelay(sig(gnss.SysBDS, "C1D"))), "B1C data CNV2")
+
+	glo := &EphGLO{DelayL1L2: -2.793967723846e-09}
+	assert.Equal(2.793967723846e-09, glo.GroupDelay(sig(gnss.SysGLO, "C2P")))
+	assert.Zero(glo.GroupDelay(sig(gnss.SysGLO, "C1C")))
+	assert.True(math.IsNaN((&EphGLO{DelayL1L2: .999999999999e+09}).GroupDelay(sig(gnss.SysGLO, "C2P"))), "unknown")
+}
+
+func TestSatPosition(t *testing.T) {
+	assert := assert.New(t)
+	data, err := os.ReadFile("testdata/white/AREG00PER_R_20201690000_01D_MN.rnx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ephs := readEphemerides(t, strings.NewReader(string(data)))
+	areg := Coord{X: 1942826.2, Y: -5804070.3, Z: -1796894.1}
+
+	for _, prn := range []gnss.PRN{{Sys: gnss.SysGPS, Num: 2}, {Sys: gnss.SysGLO, Num: 2}, {Sys: gnss.SysGAL, Num: 1}} {
+		eph := ephs[prn][0]
+		ti := eph.GetTime().Add(10 * time.Minute)
+		pos, tau, err := SatPosition(eph, ti, areg)
+		assert.NoError(err)
+		assert.InDelta(0.075, tau, 0.02, "%s travel time", prn)
+		assert.InDelta(tau*gnss.SpeedOfLight, dist(pos, areg), 1e-3, "%s range", prn)
+
+		// Without the Earth-rotation correction the position differs by the rotation during the travel time.
+		satPos, _, err := eph.Position(ti.Add(-time.Duration(tau * float64(time.Second))))
+		assert.NoError(err)
+		rotation := math.Hypot(satPos.X, satPos.Y) * 7.2921151467e-5 * tau
+		assert.InDelta(rotation, dist(pos, satPos), 0.1, "%s Sagnac", prn)
+	}
+
+	_, _, err = SatPosition(&EphGPS{}, time.Now(), areg)
+	assert.Error(err)
+}
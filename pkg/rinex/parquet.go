@@ -0,0 +1,351 @@
+package rinex
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// The Apache Parquet file format, see https://parquet.apache.org/docs/file-format/.
+// The pages are written uncompressed with PLAIN encoding, the metadata follows parquet.thrift and is encoded
+// with the Thrift compact protocol.
+
+// parquetMagic starts and ends a Parquet file.
+const parquetMagic = "PAR1"
+
+// The Parquet physical types.
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+// The Parquet converted types, for readers not supporting logical types.
+const (
+	parquetConvertedUTF8 = 0
+	parquetConvertedInt8 = 15
+)
+
+// The Parquet encodings.
+const (
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// parquetColumnChunk is the position of a column chunk in the file, for the footer.
+type parquetColumnChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+// parquetRowGroup is a row group, for the footer.
+type parquetRowGroup struct {
+	chunks  []parquetColumnChunk
+	numRows int64
+}
+
+// parquetWriter writes a Parquet file with a row group per batchRows rows. Each column chunk consists of
+// one data page.
+type parquetWriter struct {
+	w         *countWriter
+	batchRows int
+	batch     *columnBatch
+	rowGroups []parquetRowGroup
+}
+
+func (pw *parquetWriter) writeHeader(cols []column) error {
+	pw.batch = newColumnBatch(cols)
+	_, err := pw.w.Write([]byte(parquetMagic))
+	return err
+}
+
+func (pw *parquetWriter) writeRow(row []any) error {
+	if err := pw.batch.append(row); err != nil {
+		return err
+	}
+	if pw.batch.n == pw.batchRows {
+		return pw.writeRowGroup()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) flush() error {
+	if pw.batch.n > 0 {
+		if err := pw.writeRowGroup(); err != nil {
+			return err
+		}
+	}
+	meta := pw.fileMetaData()
+	pw.w.Write(meta)
+	pw.w.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta))))
+	pw.w.Write([]byte(parquetMagic))
+	return pw.w.w.Flush()
+}
+
+// writeRowGroup writes the buffered rows as row group.
+func (pw *parquetWriter) writeRowGroup() error {
+	n := pw.batch.n
+	rg := parquetRowGroup{numRows: int64(n)}
+	var err error
+	for i, col := range pw.batch.cols {
+		cd := &pw.batch.data[i]
+		var page []byte
+		if col.nullable {
+			// The definition levels, 1 for a value and 0 for null, as a single bit-packed run with a length prefix.
+			levels := binary.AppendUvarint(nil, uint64((n+7)/8)<<1|1)
+			bits := make([]byte, (n+7)/8)
+			for j, valid := range cd.valid {
+				if valid {
+					bits[j/8] |= 1 << (j % 8)
+				}
+			}
+			levels = append(levels, bits...)
+			page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+			page = append(page, levels...)
+		}
+		for j, valid := range cd.valid {
+			if !valid {
+				continue
+			}
+			switch col.typ {
+			case colTime:
+				page = binary.LittleEndian.AppendUint64(page, uint64(cd.ints[j]))
+			case colInt8:
+				page = binary.LittleEndian.AppendUint32(page, uint32(int32(cd.ints[j])))
+			case colFloat64:
+				page = binary.LittleEndian.AppendUint64(page, math.Float64bits(cd.floats[j]))
+			case colString:
+				page = binary.LittleEndian.AppendUint32(page, uint32(len(cd.strs[j])))
+				page = append(page, cd.strs[j]...)
+			}
+		}
+
+		var hdr thriftWriter // PageHeader
+		hdr.structBegin()
+		hdr.i32Field(1, 0) // DATA_PAGE
+		hdr.i32Field(2, int32(len(page)))
+		hdr.i32Field(3, int32(len(page)))
+		hdr.structField(5) // DataPageHeader
+		hdr.i32Field(1, int32(n))
+		hdr.i32Field(2, parquetPlain)
+		hdr.i32Field(3, parquetRLE)
+		hdr.i32Field(4, parquetRLE)
+		hdr.structEnd()
+		hdr.structEnd()
+
+		chunk := parquetColumnChunk{offset: pw.w.n, size: int64(len(hdr.buf) + len(page)), numValues: int64(n)}
+		pw.w.Write(hdr.buf)
+		_, err = pw.w.Write(page) // the errors of the buffered writer are sticky
+		rg.chunks = append(rg.chunks, chunk)
+	}
+	pw.rowGroups = append(pw.rowGroups, rg)
+	pw.batch.reset()
+	return err
+}
+
+// fileMetaData returns the encoded FileMetaData of the footer.
+func (pw *parquetWriter) fileMetaData() []byte {
+	var t thriftWriter
+	t.structBegin()
+	t.i32Field(1, 2) // version
+
+	cols := pw.batch.cols
+	t.listField(2, thriftStruct, len(cols)+1) // schema
+	t.structBegin()
+	t.binaryField(4, "schema")
+	t.i32Field(5, int32(len(cols)))
+	t.structEnd()
+	for _, col := range cols {
+		t.structBegin()
+		switch col.typ {
+		case colTime:
+			t.i32Field(1, parquetInt64)
+		case colString:
+			t.i32Field(1, parquetByteArray)
+		case colFloat64:
+			t.i32Field(1, parquetDouble)
+		case colInt8:
+			t.i32Field(1, parquetInt32)
+		}
+		repetition := int32(0) // REQUIRED
+		if col.nullable {
+			repetition = 1 // OPTIONAL
+		}
+		t.i32Field(3, repetition)
+		t.binaryField(4, col.name)
+		switch col.typ {
+		case colTime:
+			t.structField(10) // LogicalType
+			t.structField(8)  // TIMESTAMP
+			t.boolField(1, true)
+			t.structField(2) // TimeUnit
+			t.structField(3) // NANOS
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+		case colString:
+			t.i32Field(6, parquetConvertedUTF8)
+			t.structField(10) // LogicalType
+			t.structField(1)  // STRING
+			t.structEnd()
+			t.structEnd()
+		case colInt8:
+			t.i32Field(6, parquetConvertedInt8)
+			t.structField(10) // LogicalType
+			t.structField(10) // INTEGER
+			t.byteField(1, 8)
+			t.boolField(2, true)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.structEnd()
+	}
+
+	numRows := int64(0)
+	for _, rg := range pw.rowGroups {
+		numRows += rg.numRows
+	}
+	t.i64Field(3, numRows)
+
+	t.listField(4, thriftStruct, len(pw.rowGroups))
+	for _, rg := range pw.rowGroups {
+		t.structBegin()
+		t.listField(1, thriftStruct, len(rg.chunks))
+		size := int64(0)
+		for i, chunk := range rg.chunks {
+			size += chunk.size
+			t.structBegin() // ColumnChunk
+			t.i64Field(2, chunk.offset)
+			t.structField(3) // ColumnMetaData
+			switch cols[i].typ {
+			case colTime:
+				t.i32Field(1, parquetInt64)
+			case colString:
+				t.i32Field(1, parquetByteArray)
+			case colFloat64:
+				t.i32Field(1, parquetDouble)
+			case colInt8:
+				t.i32Field(1, parquetInt32)
+			}
+			t.listField(2, thriftI32, 2)
+			t.varint(zigzag(parquetPlain))
+			t.varint(zigzag(parquetRLE))
+			t.listField(3, thriftBinary, 1)
+			t.binary(cols[i].name)
+			t.i32Field(4, 0) // UNCOMPRESSED
+			t.i64Field(5, chunk.numValues)
+			t.i64Field(6, chunk.size)
+			t.i64Field(7, chunk.size)
+			t.i64Field(9, chunk.offset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64Field(2, size)
+		t.i64Field(3, rg.numRows)
+		t.structEnd()
+	}
+	t.binaryField(6, "gognss")
+	t.structEnd()
+	return t.buf
+}
+
+// The Thrift compact protocol types.
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol.
+type thriftWriter struct {
+	buf    []byte
+	lastID []int16 // the last field id per nested struct
+}
+
+func (t *thriftWriter) structBegin() {
+	t.lastID = append(t.lastID, 0)
+}
+
+func (t *thriftWriter) structEnd() {
+	t.buf = append(t.buf, 0) // stop field
+	t.lastID = t.lastID[:len(t.lastID)-1]
+}
+
+// field writes the header of the field with the id and type.
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.lastID[len(t.lastID)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32Field(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64Field(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) byteField(id int16, v int8) {
+	t.field(id, thriftByte)
+	t.buf = append(t.buf, byte(v))
+}
+
+func (t *thriftWriter) boolField(id int16, v bool) {
+	if v {
+		t.field(id, thriftTrue)
+	} else {
+		t.field(id, thriftFalse)
+	}
+}
+
+func (t *thriftWriter) binaryField(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.binary(s)
+}
+
+// structField starts a struct field, to be ended by structEnd.
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.structBegin()
+}
+
+// listField writes the header of a list field with size elements of type elemType.
+// The elements follow, structs are written with structBegin and structEnd.
+func (t *thriftWriter) listField(id int16, elemType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|elemType)
+		return
+	}
+	t.buf = append(t.buf, 0xf0|elemType)
+	t.varint(uint64(size))
+}
+
+func (t *thriftWriter) binary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf = binary.AppendUvarint(t.buf, v)
+}
+
+// zigzag maps signed to unsigned integers, for varints of small absolute values.
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
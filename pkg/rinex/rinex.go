@@ -2,8 +2,11 @@
 package rinex
 
 import (
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -89,13 +92,35 @@ var (
 	ErrParser = errors.New("rinex: parse error")
 )
 
+// Severity is the severity of a RinexError.
+type Severity int
+
+// The severities of a RinexError.
+const (
+	SeverityError   Severity = iota // The record could not be decoded, the decoder stops.
+	SeverityWarning                 // The record was skipped or is questionable, the decoder continues.
+)
+
+// String returns the severity as lower case word.
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
 // A RinexError holds a warning or error that may occur during the processing a RINEX file.
-// RinexError implements the error interface.
+// RinexError implements the error interface. Parse errors of the decoders are of type *RinexError and
+// match ErrParser with errors.Is. Errors of the underlying reader, e.g. of the decompression, are no RinexErrors.
 type RinexError struct {
 	Err error
 
-	// The line where the error occured.
-	// Line int
+	File     string   // The name of the file, if known.
+	Line     int      // The line where the error occured, starting with 1.
+	StartCol int      // The first column of the erroneous field, starting with 1. Zero if unknown.
+	EndCol   int      // The last column of the erroneous field.
+	Record   string   // The record type, i.e. the header label or "EPOCH", "OBSERVATION", "EPHEMERIS".
+	Severity Severity // SeverityError, or SeverityWarning for records skipped in lenient mode.
 
 	// Additional information. This can have any type, mainly used struct, map or string.
 	Meta any
@@ -104,12 +129,80 @@ type RinexError struct {
 // Unwrap returns the wrapped error, to allow interoperability with errors.Is(), errors.As() and errors.Unwrap()
 func (e *RinexError) Unwrap() error { return e.Err }
 
-// RinexError implements the error interface.
+// Is reports whether the target is ErrParser.
+func (e *RinexError) Is(target error) bool { return target == ErrParser }
+
+// RinexError implements the error interface. The message has the form
+// "rinex: <file>:<line>:<startcol>-<endcol>: <record>: <error>".
 func (e *RinexError) Error() string {
+	var b strings.Builder
+	b.WriteString("rinex: ")
+	if e.Severity == SeverityWarning {
+		b.WriteString("warning: ")
+	}
+	if e.File != "" || e.Line > 0 {
+		file := e.File
+		if file == "" {
+			file = "line "
+		} else {
+			file += ":"
+		}
+		fmt.Fprintf(&b, "%s%d", file, e.Line)
+		if e.StartCol > 0 {
+			fmt.Fprintf(&b, ":%d-%d", e.StartCol, e.EndCol)
+		}
+		b.WriteString(": ")
+	}
+	if e.Record != "" {
+		b.WriteString(e.Record + ": ")
+	}
+	b.WriteString(strings.TrimPrefix(e.Err.Error(), "rinex: "))
 	if e.Meta != nil {
-		return fmt.Sprintf("%s: %+v", e.Err.Error(), e.Meta)
+		fmt.Fprintf(&b, ": %+v", e.Meta)
 	}
-	return e.Err.Error()
+	return b.String()
+}
+
+// The record types of a RinexError for data records.
+const (
+	recordEpoch       = "EPOCH"
+	recordObservation = "OBSERVATION"
+	recordEphemeris   = "EPHEMERIS"
+	recordClock       = "CLOCK DATA"
+)
+
+// decoderDiag holds the file name, the lenient mode and the warnings of a decoder.
+type decoderDiag struct {
+	filename string
+	lenient  bool
+	warnings []*RinexError
+}
+
+// init sets the file name, if the reader knows it, like *os.File or the reader returned by OpenFile.
+func (d *decoderDiag) init(r io.Reader) {
+	if n, ok := r.(interface{ Name() string }); ok {
+		d.filename = n.Name()
+	}
+}
+
+// newError returns an error for the given line and columns. The columns start with 1, or are 0 if unknown.
+func (d *decoderDiag) newError(lineNum int, record string, startCol, endCol int, err error) *RinexError {
+	return &RinexError{Err: err, File: d.filename, Line: lineNum, StartCol: startCol, EndCol: endCol, Record: record}
+}
+
+// readError returns the error of the underlying reader, e.g. of a truncated compressed stream, with the line
+// reached. It is no parse error and does not match ErrParser.
+func (d *decoderDiag) readError(lineNum int, err error) error {
+	if d.filename != "" {
+		return fmt.Errorf("rinex: %s: read line %d: %w", d.filename, lineNum+1, err)
+	}
+	return fmt.Errorf("rinex: read line %d: %w", lineNum+1, err)
+}
+
+// warn adds a warning.
+func (d *decoderDiag) warn(e *RinexError) {
+	e.Severity = SeverityWarning
+	d.warnings = append(d.warnings, e)
 }
 
 var (
@@ -492,6 +585,72 @@ func ParseDoy(year, doy int) time.Time {
 	return t.Add(time.Duration(doy) * time.Hour * 24)
 }
 
+// ParseDataFreq returns the observation interval for a data frequency of the RINEX-3 filename, e.g. "30S", "05Z" or "01M".
+// The units are C (100 Hz), Z (Hz), S (seconds), M (minutes), H (hours) and D (days).
+// The unspecified frequency "00U" returns 0.
+func ParseDataFreq(freq string) (time.Duration, error) {
+	if len(freq) != 3 {
+		return 0, fmt.Errorf("invalid data frequency: %q", freq)
+	}
+	n, err := strconv.Atoi(freq[:2])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid data frequency: %q", freq)
+	}
+	unit := strings.ToUpper(freq[2:])
+	if n == 0 && unit != "U" {
+		return 0, fmt.Errorf("invalid data frequency: %q", freq)
+	}
+	switch unit {
+	case "C":
+		return time.Second / time.Duration(100*n), nil
+	case "Z":
+		return time.Second / time.Duration(n), nil
+	case "S":
+		return time.Duration(n) * time.Second, nil
+	case "M":
+		return time.Duration(n) * time.Minute, nil
+	case "H":
+		return time.Duration(n) * time.Hour, nil
+	case "D":
+		return time.Duration(n) * 24 * time.Hour, nil
+	case "U":
+		return 0, nil
+	}
+	return 0, fmt.Errorf("invalid data frequency: %q", freq)
+}
+
+// FormatDataFreq returns the data frequency of the RINEX-3 filename for the observation interval, the inverse of
+// ParseDataFreq, e.g. "05M" for 5 minutes or "10Z" for 0.1 seconds. The largest unit that gives an integer
+// number of two digits is used. "00U" is returned if the interval can not be expressed that way.
+func FormatDataFreq(interval time.Duration) string {
+	if interval <= 0 {
+		return "00U"
+	}
+	if interval < time.Second {
+		if time.Second%interval != 0 {
+			return "00U"
+		}
+		hz := int(time.Second / interval)
+		switch {
+		case hz < 100:
+			return fmt.Sprintf("%02dZ", hz)
+		case hz%100 == 0 && hz/100 < 100:
+			return fmt.Sprintf("%02dC", hz/100)
+		}
+		return "00U"
+	}
+	units := []struct {
+		d    time.Duration
+		unit string
+	}{{24 * time.Hour, "D"}, {time.Hour, "H"}, {time.Minute, "M"}, {time.Second, "S"}}
+	for _, u := range units {
+		if interval%u.d == 0 && interval/u.d < 100 {
+			return fmt.Sprintf("%02d%s", interval/u.d, u.unit)
+		}
+	}
+	return "00U"
+}
+
 func parseFloat(s string) (float64, error) {
 	//s. bncutils::readDbl
 	if strings.TrimSpace(s) == "" {
@@ -502,6 +661,14 @@ func parseFloat(s string) (float64, error) {
 	return strconv.ParseFloat(scleaned, 64)
 }
 
+// parseInt parses an integer field. Blank fields are zero.
+func parseInt(s string) (int, error) {
+	if strings.TrimSpace(s) == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(strings.TrimSpace(s))
+}
+
 // Parse the Date/Time in the PGM / RUN BY / DATE header record.
 // It is recommended to use UTC as the time zone. Set zone to LCL if an unknown local time was used.
 func parseHeaderDate(date string) (time.Time, error) {
@@ -647,3 +814,29 @@ func getHourAsDigit(char rune) (int, error) {
 
 	return time.Time{}, fmt.Errorf("Could not parse date from string: '%s': %v", str, err)
 } */
+
+// gzipFile compresses the file src into the gzip file dst.
+func gzipFile(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	_, err = io.Copy(zw, r)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
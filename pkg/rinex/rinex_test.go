@@ -1,6 +1,7 @@
 package rinex
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
@@ -258,6 +259,67 @@ func TestFilePeriod_Duration(t *testing.T) {
 	}
 }
 
+func TestParseDataFreq(t *testing.T) {
+	tests := []struct {
+		freq    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{freq: "30S", want: 30 * time.Second},
+		{freq: "01S", want: time.Second},
+		{freq: "05Z", want: 200 * time.Millisecond},
+		{freq: "01C", want: 10 * time.Millisecond},
+		{freq: "15M", want: 15 * time.Minute},
+		{freq: "01H", want: time.Hour},
+		{freq: "01D", want: 24 * time.Hour},
+		{freq: "00U", want: 0},
+		{freq: "00S", wantErr: true},
+		{freq: "30X", wantErr: true},
+		{freq: "30", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.freq, func(t *testing.T) {
+			got, err := ParseDataFreq(tt.freq)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatDataFreq(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     string
+	}{
+		{interval: 10 * time.Millisecond, want: "01C"},
+		{interval: 100 * time.Millisecond, want: "10Z"},
+		{interval: time.Second, want: "01S"},
+		{interval: 30 * time.Second, want: "30S"},
+		{interval: 90 * time.Second, want: "90S"},
+		{interval: 300 * time.Second, want: "05M"},
+		{interval: 3600 * time.Second, want: "01H"},
+		{interval: 24 * time.Hour, want: "01D"},
+		{interval: 0, want: "00U"},
+		{interval: 1500 * time.Millisecond, want: "00U"},
+		{interval: 300 * time.Millisecond, want: "00U"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.interval.String(), func(t *testing.T) {
+			got := FormatDataFreq(tt.interval)
+			assert.Equal(t, tt.want, got)
+			if got != "00U" {
+				d, err := ParseDataFreq(got)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.interval, d)
+			}
+		})
+	}
+}
+
 func Test_parseSeconds(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -339,3 +401,23 @@ func Test_parseTimeFirstObs(t *testing.T) {
 		})
 	}
 }
+
+func TestRinexError(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		err  *RinexError
+		want string
+	}{
+		{err: &RinexError{Err: errors.New("invalid epoch flag")}, want: "rinex: invalid epoch flag"},
+		{err: &RinexError{Err: errors.New("invalid epoch flag"), Line: 34, StartCol: 32, EndCol: 32, Record: "EPOCH"},
+			want: "rinex: line 34:32-32: EPOCH: invalid epoch flag"},
+		{err: &RinexError{Err: errors.New("rinex: invalid value"), File: "brux.rnx", Line: 3, Record: "MARKER NAME", Severity: SeverityWarning, Meta: "BRUX"},
+			want: "rinex: warning: brux.rnx:3: MARKER NAME: invalid value: BRUX"},
+	}
+	for _, tt := range tests {
+		assert.Equal(tt.want, tt.err.Error())
+		assert.ErrorIs(tt.err, ErrParser)
+		assert.ErrorIs(tt.err, tt.err.Err)
+	}
+	assert.Equal("warning", SeverityWarning.String())
+}
@@ -0,0 +1,165 @@
+package rinex
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// ErrEquipmentChanged is returned by Splice if the receiver or antenna differs between the input files.
+var ErrEquipmentChanged = errors.New("rinex: equipment changed")
+
+// SpliceOptions for splicing RINEX observation files.
+type SpliceOptions struct {
+	// If EquipmentEvents is set, a change of the receiver or antenna between the input files is reported
+	// with a header information event (epoch flag 4) containing the new header records.
+	// Otherwise Splice fails with ErrEquipmentChanged.
+	EquipmentEvents bool
+}
+
+// Splice merges the observation data of consecutive files, e.g. hourly files into a daily file, and writes
+// the spliced data to w. The decoders must be given in chronological order and must share the RINEX version
+// and the marker name.
+//
+// The headers are merged: the observation types are united and MergedFiles is set to the number of input files.
+// The number of satellites and of observations per satellite are omitted. The receiver and antenna of the first file are written into the header, see SpliceOptions for
+// equipment changes. Epochs that are not later than the last epoch already written, e.g. from overlapping
+// files, are dropped.
+//
+// The header is written first and the epochs are streamed to w, so the written header only carries the values
+// derived from the input headers: the time of the first observation is taken from the header of the first file,
+// and the time of the last observation from the header of the last file. The merged header is returned with the
+// times of the first and the last written epoch instead, e.g. to rewrite the header.
+func Splice(w io.Writer, decs []*ObsDecoder, opts SpliceOptions) (ObsHeader, error) {
+	if len(decs) == 0 {
+		return ObsHeader{}, fmt.Errorf("rinex: splice: no input")
+	}
+	hdr, err := mergeObsHeaders(decs, opts)
+	if err != nil {
+		return ObsHeader{}, err
+	}
+
+	enc, err := NewObsEncoder(w, hdr)
+	if err != nil {
+		return ObsHeader{}, err
+	}
+	var firstObs, lastObs time.Time
+	for i, dec := range decs {
+		if i > 0 {
+			if recs := equipmentChanges(&decs[i-1].Header, &dec.Header); len(recs) > 0 {
+				if err := enc.Encode(&Epoch{Flag: EpochFlagHeaderInfo, Records: recs}); err != nil {
+					return ObsHeader{}, err
+				}
+			}
+		}
+
+		for dec.NextEpoch() {
+			epo := dec.Epoch()
+			if epo.Flag > EpochFlagPowerFailure && epo.Flag != EpochFlagCycleSlip { // special events
+				if err := enc.Encode(epo); err != nil {
+					return ObsHeader{}, err
+				}
+				continue
+			}
+			if !lastObs.IsZero() && !epo.Time.After(lastObs) {
+				continue
+			}
+			if firstObs.IsZero() {
+				firstObs = epo.Time
+			}
+			lastObs = epo.Time
+			if err := enc.Encode(epo); err != nil {
+				return ObsHeader{}, err
+			}
+		}
+		if err := dec.Err(); err != nil {
+			return ObsHeader{}, fmt.Errorf("rinex: splice: file %d: %w", i+1, err)
+		}
+	}
+	if !firstObs.IsZero() {
+		hdr.TimeOfFirstObs, hdr.TimeOfLastObs = firstObs, lastObs
+	}
+	return hdr, enc.Flush()
+}
+
+// mergeObsHeaders merges the headers of the decoders into the header of the spliced file.
+func mergeObsHeaders(decs []*ObsDecoder, opts SpliceOptions) (ObsHeader, error) {
+	first := &decs[0].Header
+	hdr := *first
+	hdr.Comments = slices.Clone(first.Comments)
+	hdr.ObsTypes = make(map[gnss.System][]ObsCode, len(first.ObsTypes))
+	hdr.GloSlots = make(map[gnss.PRN]int, len(first.GloSlots))
+	isV2 := first.RINEXVersion < 3
+
+	for i, dec := range decs {
+		h := &dec.Header
+		if int(h.RINEXVersion) != int(first.RINEXVersion) {
+			return ObsHeader{}, fmt.Errorf("rinex: splice: file %d: RINEX version %.2f differs from %.2f", i+1, h.RINEXVersion, first.RINEXVersion)
+		}
+		if !strings.EqualFold(strings.TrimSpace(h.MarkerName), strings.TrimSpace(first.MarkerName)) {
+			return ObsHeader{}, fmt.Errorf("rinex: splice: file %d: marker name %q differs from %q", i+1, h.MarkerName, first.MarkerName)
+		}
+		if i > 0 && !opts.EquipmentEvents {
+			if recs := equipmentChanges(&decs[i-1].Header, h); len(recs) > 0 {
+				labels := make([]string, 0, len(recs))
+				for _, rec := range recs {
+					labels = append(labels, strings.TrimSpace(rec[60:]))
+				}
+				return ObsHeader{}, fmt.Errorf("%w: file %d: %s", ErrEquipmentChanged, i+1, strings.Join(labels, ", "))
+			}
+		}
+
+		if h.SatSystem != hdr.SatSystem {
+			hdr.SatSystem = gnss.SysMIXED
+		}
+		if h.Interval != 0 && (hdr.Interval == 0 || h.Interval < hdr.Interval) {
+			hdr.Interval = h.Interval
+		}
+		for sys, typs := range h.ObsTypes {
+			if isV2 {
+				sys = first.SatSystem // in RINEX-2 the observation types are valid for all systems
+			}
+			for _, typ := range typs {
+				if !slices.Contains(hdr.ObsTypes[sys], typ) {
+					hdr.ObsTypes[sys] = append(hdr.ObsTypes[sys], typ)
+				}
+			}
+		}
+		for prn, slot := range h.GloSlots {
+			hdr.GloSlots[prn] = slot
+		}
+		if h.LeapSeconds > hdr.LeapSeconds {
+			hdr.LeapSeconds = h.LeapSeconds
+		}
+	}
+
+	if isV2 && hdr.SatSystem != first.SatSystem {
+		hdr.ObsTypes[hdr.SatSystem] = hdr.ObsTypes[first.SatSystem]
+		delete(hdr.ObsTypes, first.SatSystem)
+	}
+	hdr.TimeOfLastObs = decs[len(decs)-1].Header.TimeOfLastObs
+	hdr.NSatellites, hdr.ObsPerSat = 0, nil
+	hdr.MergedFiles = len(decs)
+	hdr.Comments = append(hdr.Comments, fmt.Sprintf("SPLICED FROM %d FILES", len(decs)))
+	return hdr, nil
+}
+
+// equipmentChanges returns the receiver and antenna header records of cur that differ from prev.
+func equipmentChanges(prev, cur *ObsHeader) []string {
+	var recs []string
+	if rec := cur.receiverRecord(); rec != prev.receiverRecord() {
+		recs = append(recs, rec)
+	}
+	if rec := cur.antennaRecord(); rec != prev.antennaRecord() {
+		recs = append(recs, rec)
+	}
+	if rec := cur.antennaDeltaRecord(); rec != prev.antennaDeltaRecord() {
+		recs = append(recs, rec)
+	}
+	return recs
+}
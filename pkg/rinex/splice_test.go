@@ -0,0 +1,159 @@
+package rinex
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+	"github.com/stretchr/testify/assert"
+)
+
+// readObsFile returns the header and the epochs of the RINEX obs file.
+func TestSplice(t *testing.T) {
+	files := []string{
+		"testdata/white/brst155h.20o",
+		"testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx",
+	}
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			assert := assert.New(t)
+			hdr, epochs := readObsFile(t, file)
+			if len(epochs) < 100 {
+				t.Fatalf("too few epochs: %d", len(epochs))
+			}
+
+			// three files, with overlapping epochs between the first and the second one
+			chunk := func(epochs []*Epoch) *ObsDecoder {
+				h := hdr
+				h.TimeOfFirstObs, h.TimeOfLastObs = epochs[0].Time, epochs[len(epochs)-1].Time
+				return encodeObs(t, h, epochs)
+			}
+			decs := []*ObsDecoder{chunk(epochs[:50]), chunk(epochs[45:90]), chunk(epochs[90:])}
+			var buf bytes.Buffer
+			mergedHdr, err := Splice(&buf, decs, SpliceOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(3, mergedHdr.MergedFiles)
+			assert.Equal(epochs[0].Time, mergedHdr.TimeOfFirstObs)
+			assert.Equal(epochs[len(epochs)-1].Time, mergedHdr.TimeOfLastObs)
+			assert.Zero(mergedHdr.NSatellites)
+			assert.Nil(mergedHdr.ObsPerSat)
+
+			dec, err := NewObsDecoder(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(hdr.MarkerName, dec.Header.MarkerName)
+			assert.Equal(hdr.ObsTypes, dec.Header.ObsTypes)
+			assert.Equal(epochs[0].Time, dec.Header.TimeOfFirstObs)
+			assert.Equal(epochs[len(epochs)-1].Time, dec.Header.TimeOfLastObs)
+			assert.Contains(dec.Header.Comments, "SPLICED FROM 3 FILES")
+
+			got := readAllEpochs(t, dec)
+			if assert.Len(got, len(epochs)) {
+				for i := range epochs {
+					assert.Equal(withoutBlankObs(epochs[i]), withoutBlankObs(got[i]), "epoch %s", epochs[i].Time)
+				}
+			}
+		})
+	}
+}
+
+func TestSplice_obsTypes(t *testing.T) {
+	assert := assert.New(t)
+	hdr, epochs := readObsFile(t, "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+
+	// The first file lacks the GPS L5 observations.
+	hdr1 := hdr
+	hdr1.ObsTypes = map[gnss.System][]ObsCode{}
+	for sys, typs := range hdr.ObsTypes {
+		hdr1.ObsTypes[sys] = slices.Clone(typs)
+	}
+	hdr1.ObsTypes[gnss.SysGPS] = slices.DeleteFunc(hdr1.ObsTypes[gnss.SysGPS], func(typ ObsCode) bool { return typ[1] == '5' })
+
+	// The header times do not match the data: the first file starts earlier, the last one has no time of last obs.
+	hdr1.TimeOfFirstObs = epochs[0].Time.Add(-time.Hour)
+	hdr2 := hdr
+	hdr2.TimeOfLastObs = time.Time{}
+
+	decs := []*ObsDecoder{encodeObs(t, hdr1, epochs[:60]), encodeObs(t, hdr2, epochs[60:])}
+	var buf bytes.Buffer
+	mergedHdr, err := Splice(&buf, decs, SpliceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sys, typs := range hdr.ObsTypes {
+		assert.ElementsMatch(typs, mergedHdr.ObsTypes[sys], "%s obs types", sys)
+	}
+	assert.Equal(epochs[0].Time, mergedHdr.TimeOfFirstObs)
+	assert.Equal(epochs[len(epochs)-1].Time, mergedHdr.TimeOfLastObs)
+
+	dec, err := NewObsDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(hdr1.TimeOfFirstObs, dec.Header.TimeOfFirstObs, "written header")
+	assert.True(dec.Header.TimeOfLastObs.IsZero(), "written header")
+	got := readAllEpochs(t, dec)
+	if !assert.Len(got, len(epochs)) {
+		return
+	}
+	for _, satObs := range got[0].ObsList {
+		if satObs.Prn.Sys == gnss.SysGPS {
+			assert.Equal(Obs{}, satObs.Obss["C5Q"], "%s C5Q in first file", satObs.Prn)
+		}
+	}
+	assert.Equal(withoutBlankObs(epochs[60]), withoutBlankObs(got[60]))
+}
+
+func TestSplice_equipmentChange(t *testing.T) {
+	assert := assert.New(t)
+	hdr, epochs := readObsFile(t, "testdata/white/brst155h.20o")
+	hdr2 := hdr
+	hdr2.ReceiverType = "SEPT POLARX5"
+	hdr2.ReceiverVersion = "5.3.2"
+
+	// fail
+	decs := []*ObsDecoder{encodeObs(t, hdr, epochs[:60]), encodeObs(t, hdr2, epochs[60:])}
+	_, err := Splice(&bytes.Buffer{}, decs, SpliceOptions{})
+	assert.ErrorIs(err, ErrEquipmentChanged)
+
+	// header event
+	decs = []*ObsDecoder{encodeObs(t, hdr, epochs[:60]), encodeObs(t, hdr2, epochs[60:])}
+	var buf bytes.Buffer
+	mergedHdr, err := Splice(&buf, decs, SpliceOptions{EquipmentEvents: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(hdr.ReceiverType, mergedHdr.ReceiverType)
+
+	dec, err := NewObsDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readAllEpochs(t, dec)
+	if !assert.Len(got, len(epochs)+1) {
+		return
+	}
+	event := got[60]
+	assert.Equal(EpochFlagHeaderInfo, event.Flag)
+	assert.Equal([]string{hdr2.receiverRecord()}, event.Records)
+	assert.Equal(withoutBlankObs(epochs[60]), withoutBlankObs(got[61]))
+}
+
+func TestSplice_errors(t *testing.T) {
+	assert := assert.New(t)
+	hdr, epochs := readObsFile(t, "testdata/white/brst155h.20o")
+
+	_, err := Splice(&bytes.Buffer{}, nil, SpliceOptions{})
+	assert.Error(err, "no input")
+
+	hdr2 := hdr
+	hdr2.MarkerName = "WTZR"
+	decs := []*ObsDecoder{encodeObs(t, hdr, epochs[:60]), encodeObs(t, hdr2, epochs[60:])}
+	_, err = Splice(&bytes.Buffer{}, decs, SpliceOptions{EquipmentEvents: true})
+	assert.ErrorContains(err, "marker name")
+}
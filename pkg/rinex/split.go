@@ -0,0 +1,177 @@
+package rinex
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/de-bkg/gognss/pkg/gnss"
+)
+
+// obsChunk holds the epochs of one file period while splitting.
+type obsChunk struct {
+	start             time.Time // the nominal start of the period
+	firstObs, lastObs time.Time
+	epochs            []*Epoch
+}
+
+// Split splits the observation file at the boundaries of the file period, e.g. a daily file into hourly files.
+// The files are written to the directory dir and named following the RINEX convention of the file's version,
+// with the start of the period as start time, see Rnx3Filename and Rnx2Filename. For RINEX-3 and -4 files the
+// CountryCode must be set. The headers get the times of the first and last observation of each file.
+//
+// Periods without observations are skipped, a warning is added to the file's Warnings for each of them.
+// The epochs must be in chronological order. Special events are kept in the file of the preceding epoch.
+func (f *ObsFile) Split(dir string, period FilePeriod) ([]*ObsFile, error) {
+	d := period.Duration()
+	if d == 0 || d > 24*time.Hour {
+		return nil, fmt.Errorf("rinex: split: unsupported file period %q", period)
+	}
+
+	r, err := OpenFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	dec, err := NewObsDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*ObsFile{}
+	var chunk *obsChunk
+	var pending []*Epoch // special events before the first observation epoch
+	for dec.NextEpoch() {
+		epo := dec.Epoch()
+		if epo.Flag > EpochFlagPowerFailure && epo.Flag != EpochFlagCycleSlip {
+			if chunk == nil {
+				pending = append(pending, epo)
+			} else {
+				chunk.epochs = append(chunk.epochs, epo)
+			}
+			continue
+		}
+
+		start := epo.Time.Truncate(d)
+		if chunk != nil && !start.Equal(chunk.start) {
+			if start.Before(chunk.start) {
+				return files, fmt.Errorf("rinex: split: epoch %s not in chronological order", epo.Time)
+			}
+			fil, err := f.writeChunk(dir, dec.Header, period, chunk)
+			if err != nil {
+				return files, err
+			}
+			files = append(files, fil)
+			chunk = nil
+		}
+		if chunk == nil {
+			chunk = &obsChunk{start: start, firstObs: epo.Time, epochs: pending}
+			pending = nil
+		}
+		chunk.lastObs = epo.Time
+		chunk.epochs = append(chunk.epochs, epo)
+	}
+	if err := dec.Err(); err != nil {
+		return files, err
+	}
+	if chunk != nil {
+		fil, err := f.writeChunk(dir, dec.Header, period, chunk)
+		if err != nil {
+			return files, err
+		}
+		files = append(files, fil)
+	}
+
+	f.warnEmptyPeriods(files, d)
+	return files, nil
+}
+
+// writeChunk writes the epochs of the chunk to a new file in dir.
+func (f *ObsFile) writeChunk(dir string, hdr ObsHeader, period FilePeriod, chunk *obsChunk) (*ObsFile, error) {
+	hdr.TimeOfFirstObs, hdr.TimeOfLastObs = chunk.firstObs, chunk.lastObs
+	if hdr.NSatellites > 0 {
+		sats := make(map[gnss.PRN]struct{}, hdr.NSatellites)
+		for _, epo := range chunk.epochs {
+			for _, satObs := range epo.ObsList {
+				sats[satObs.Prn] = struct{}{}
+			}
+		}
+		hdr.NSatellites = len(sats)
+	}
+
+	rnx := *f.RnxFil
+	rnx.StartTime = chunk.start
+	rnx.FilePeriod = period
+	rnx.Format = "rnx"
+	rnx.Compression = ""
+	rnx.Warnings = nil
+	rnx.DataType = fmt.Sprintf("%s%s", hdr.SatSystem.Abbr(), "O")
+	if rnx.DataFreq == "" {
+		rnx.DataFreq = "00U"
+		if hdr.Interval != 0 {
+			rnx.DataFreq = FormatDataFreq(time.Duration(hdr.Interval * float64(time.Second)).Round(time.Microsecond))
+		}
+	}
+
+	var fn string
+	var err error
+	if hdr.RINEXVersion < 3 {
+		fn, err = rnx.Rnx2Filename()
+	} else {
+		fn, err = (&ObsFile{RnxFil: &rnx}).Rnx3Filename()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rinex: split: build filename: %v", err)
+	}
+	rnx.Path = filepath.Join(dir, fn)
+
+	w, err := os.Create(rnx.Path)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := NewObsEncoder(w, hdr)
+	if err == nil {
+		for _, epo := range chunk.epochs {
+			if err = enc.Encode(epo); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(rnx.Path)
+		return nil, err
+	}
+
+	return &ObsFile{RnxFil: &rnx, Header: &hdr, Opts: &Options{}}, nil
+}
+
+// warnEmptyPeriods adds a warning for each period of length d without observations. The periods are
+// checked within the nominal period of the file, or between the first and the last file written.
+func (f *ObsFile) warnEmptyPeriods(files []*ObsFile, d time.Duration) {
+	if len(files) == 0 {
+		f.Warnings = append(f.Warnings, "split: no observations")
+		return
+	}
+
+	from, to := files[0].StartTime, files[len(files)-1].StartTime.Add(d)
+	if dur := f.FilePeriod.Duration(); !f.StartTime.IsZero() && dur > d {
+		from, to = f.StartTime, f.StartTime.Add(dur)
+	}
+
+	written := make(map[time.Time]bool, len(files))
+	for _, fil := range files {
+		written[fil.StartTime] = true
+	}
+	for t := from.Truncate(d); t.Before(to); t = t.Add(d) {
+		if !written[t] {
+			f.Warnings = append(f.Warnings, fmt.Sprintf("split: no observations between %s and %s", t.Format(time.DateTime), t.Add(d).Format(time.DateTime)))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package rinex
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObsFile_Split(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		country   string
+		period    FilePeriod
+		wantFiles []string
+	}{
+		{name: "v3-15min", file: "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx", period: FilePeriod15Min,
+			wantFiles: []string{
+				"BRUX00BEL_R_20183101900_15M_30S_MO.rnx",
+				"BRUX00BEL_R_20183101915_15M_30S_MO.rnx",
+				"BRUX00BEL_R_20183101930_15M_30S_MO.rnx",
+				"BRUX00BEL_R_20183101945_15M_30S_MO.rnx",
+			}},
+		{name: "v2-15min", file: "testdata/white/brst155h.20o", period: FilePeriod15Min,
+			wantFiles: []string{"brst155h00.20o", "brst155h15.20o", "brst155h30.20o", "brst155h45.20o"}},
+		{name: "crx-hourly", file: "testdata/white/brst155h.20d.Z", period: FilePeriodHourly,
+			wantFiles: []string{"brst155h.20o"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			obsFil, err := NewObsFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, epochs := readObsFile(t, tt.file)
+
+			dir := t.TempDir()
+			files, err := obsFil.Split(dir, tt.period)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Empty(obsFil.Warnings)
+
+			names := []string{}
+			nEpochs := 0
+			for _, fil := range files {
+				names = append(names, filepath.Base(fil.Path))
+				assert.Equal(tt.period, fil.FilePeriod)
+
+				// read back
+				dec := newObsDecoder(t, fil.Path)
+				got := readAllEpochs(t, dec)
+				if assert.NotEmpty(got) {
+					assert.Equal(got[0].Time, dec.Header.TimeOfFirstObs, "TimeOfFirstObs")
+					assert.Equal(got[len(got)-1].Time, dec.Header.TimeOfLastObs, "TimeOfLastObs")
+					assert.Equal(fil.StartTime, got[0].Time.Truncate(tt.period.Duration()))
+					assert.True(got[len(got)-1].Time.Before(fil.StartTime.Add(tt.period.Duration())))
+				}
+				for i, epo := range got {
+					assert.Equal(withoutBlankObs(epochs[nEpochs+i]), withoutBlankObs(epo))
+				}
+				nEpochs += len(got)
+			}
+			assert.Equal(tt.wantFiles, names)
+			assert.Equal(len(epochs), nEpochs, "number of epochs")
+		})
+	}
+}
+
+func TestObsFile_Split_emptyPeriods(t *testing.T) {
+	assert := assert.New(t)
+	hdr, epochs := readObsFile(t, "testdata/white/BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+
+	// Remove the epochs between 19:15 and 19:30.
+	gapStart := time.Date(2018, 11, 6, 19, 15, 0, 0, time.UTC)
+	gapEnd := gapStart.Add(15 * time.Minute)
+	kept := []*Epoch{}
+	for _, epo := range epochs {
+		if epo.Time.Before(gapStart) || !epo.Time.Before(gapEnd) {
+			kept = append(kept, epo)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "BRUX00BEL_R_20183101900_01H_30S_MO.rnx")
+	w, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := NewObsEncoder(w, hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, epo := range kept {
+		if err := enc.Encode(epo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	obsFil, err := NewObsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := obsFil.Split(t.TempDir(), FilePeriod15Min)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(files, 3)
+	assert.Equal([]string{"split: no observations between 2018-11-06 19:15:00 and 2018-11-06 19:30:00"}, obsFil.Warnings)
+
+	_, err = obsFil.Split(t.TempDir(), FilePeriodYearly)
+	assert.Error(err)
+}